@@ -90,10 +90,19 @@ func (f *authFilter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		f.unauthorizedHandler.ServeHTTP(w, r)
 		return
 	}
+	if t, ok := r.Context().Value(trackerContextKey).(*tracker); ok {
+		t.principal = p
+	}
 	ctx := newContext(r.Context(), p)
 	filter.Continue(w, r.WithContext(ctx))
 }
 
+// Priority returns filter.PriorityAuthentication so requests are authenticated
+// before being rate limited.
+func (f *authFilter) Priority() int {
+	return filter.PriorityAuthentication
+}
+
 // Option is a Filter option.
 type Option func(f *authFilter)
 
@@ -113,7 +122,15 @@ func (c *contextKey) String() string {
 	return "melon/auth context value " + c.name
 }
 
-var principalContextKey = &contextKey{"principal"}
+var (
+	principalContextKey = &contextKey{"principal"}
+	trackerContextKey   = &contextKey{"tracker"}
+)
+
+// tracker records principal assigned by authentication filter.
+type tracker struct {
+	principal Principal
+}
 
 func newContext(ctx context.Context, p Principal) context.Context {
 	return context.WithValue(ctx, principalContextKey, p)
@@ -126,6 +143,24 @@ func fromContext(ctx context.Context) Principal {
 	return nil
 }
 
+// FromRequest returns Principal assigned to the request or nil if it is not
+// authenticated.
+func FromRequest(r *http.Request) Principal {
+	return fromContext(r.Context())
+}
+
+// Track returns a request derived from r and a function returning Principal
+// assigned to it by the authentication filter. It allows filters executed
+// before authentication, e.g. auditing, to know the principal after calling
+// filter.Continue with the returned request.
+func Track(r *http.Request) (*http.Request, func() Principal) {
+	t := &tracker{principal: FromRequest(r)}
+	r = r.WithContext(context.WithValue(r.Context(), trackerContextKey, t))
+	return r, func() Principal {
+		return t.principal
+	}
+}
+
 // Must returns Principal assigned to the request.
 // If no principal found in the request context, it will panic.
 // This panic should not happen if Filter is added to the server correctly.
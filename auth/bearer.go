@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+)
+
+const bearerPrefix = "Bearer "
+
+// bearerAuthenticator is an Authenticator which authenticates requests
+// using Bearer tokens in header Authorization.
+type bearerAuthenticator struct {
+	authFunc func(token string) (Principal, error)
+}
+
+// NewBearerAuthenticator returns a new Bearer Authenticator with given authFunc.
+func NewBearerAuthenticator(authFunc func(token string) (Principal, error)) Authenticator {
+	return &bearerAuthenticator{
+		authFunc: authFunc,
+	}
+}
+
+// Authenticate authenticates token in header Authorization.
+func (b *bearerAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	token, ok := bearerToken(r)
+	if !ok {
+		return nil, nil
+	}
+	return b.authFunc(token)
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	h := r.Header.Get("Authorization")
+	if len(h) < len(bearerPrefix) || !strings.EqualFold(h[:len(bearerPrefix)], bearerPrefix) {
+		return "", false
+	}
+	return h[len(bearerPrefix):], true
+}
@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBearerAuthenticator(t *testing.T) {
+	auth := NewBearerAuthenticator(func(token string) (Principal, error) {
+		if token == "sec" {
+			return NewPrincipal("admin"), nil
+		}
+		return nil, nil
+	})
+
+	tests := []struct {
+		header string
+		name   string
+	}{
+		{"", ""},
+		{"Basic YWRtOnNlYw==", ""},
+		{"Bearer abc", ""},
+		{"Bearer sec", "admin"},
+		{"bearer sec", "admin"},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		if test.header != "" {
+			r.Header.Set("Authorization", test.header)
+		}
+		p, err := auth.Authenticate(r)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if (p == nil && test.name != "") || (p != nil && p.Name() != test.name) {
+			t.Fatalf("unexpected principal of %q: %v", test.header, p)
+		}
+	}
+
+	f := NewFilter(auth, WithUnauthorizedHandler(NewUnauthorizedHandler("Bearer", "Admin")))
+	w := httptest.NewRecorder()
+	f.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") != `Bearer realm="Admin"` {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Header())
+	}
+}
@@ -0,0 +1,339 @@
+/*
+Package canary provides synthetic checks which periodically send requests to
+the application and report results as health checks and metrics.
+*/
+package canary
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codahale/metrics"
+	"github.com/goburrow/melon/configuration"
+	"github.com/goburrow/melon/core"
+	"github.com/goburrow/melon/health"
+)
+
+const (
+	defaultInterval = 60 * time.Second
+	defaultTimeout  = 5 * time.Second
+)
+
+// Factory implements core.CanaryFactory interface.
+type Factory struct {
+	// Interval is the interval between runs, e.g. "30s", default is one minute.
+	Interval configuration.Duration `valid:"min=0"`
+	// BaseURL is the URL of the application, e.g. http://localhost:8080.
+	// If it is empty, requests are dispatched to the application router directly.
+	BaseURL string `valid:"url"`
+	Checks  []CheckConfiguration
+}
+
+// CheckConfiguration is a synthetic request and its expected response status.
+type CheckConfiguration struct {
+	Name    string `valid:"notempty"`
+	Method  string
+	Path    string            `valid:"notempty"`
+	Headers map[string]string `secret:"true"`
+	Body    string
+	// ExpectedStatus is 200 by default.
+	ExpectedStatus int
+	// Timeout is e.g. "1s" or a number of milliseconds, default is 5 seconds.
+	Timeout configuration.Duration `valid:"min=0"`
+}
+
+// ConfigureCanary registers health checks for all configured canaries and
+// starts running them along with the application.
+func (factory *Factory) ConfigureCanary(env *core.Environment) error {
+	if len(factory.Checks) == 0 {
+		return nil
+	}
+	r, err := factory.build(env)
+	if err != nil {
+		return err
+	}
+	env.Lifecycle.Manage(r)
+	return nil
+}
+
+// build creates a runner and registers its checks to admin health checks.
+func (factory *Factory) build(env *core.Environment) (*runner, error) {
+	r := &runner{
+		interval: defaultInterval,
+	}
+	if factory.Interval > 0 {
+		r.interval = time.Duration(factory.Interval)
+	}
+	if factory.BaseURL != "" {
+		r.client = &http.Client{}
+		r.baseURL = strings.TrimSuffix(factory.BaseURL, "/")
+	} else {
+		r.env = env.Server
+	}
+	for i := range factory.Checks {
+		c, err := newCheck(&factory.Checks[i])
+		if err != nil {
+			return nil, err
+		}
+		r.checks = append(r.checks, c)
+	}
+	for _, c := range r.checks {
+		env.Admin.HealthChecks.Register("canary."+c.name, c)
+	}
+	return r, nil
+}
+
+// check is a canary check. It implements health.Checker which returns
+// the last result.
+type check struct {
+	name    string
+	method  string
+	path    string
+	headers map[string]string
+	body    string
+	status  int
+	timeout time.Duration
+
+	success metrics.Counter
+	failure metrics.Counter
+	latency metrics.Gauge
+
+	mu     sync.Mutex
+	result health.Result
+}
+
+func newCheck(config *CheckConfiguration) (*check, error) {
+	c := &check{
+		name:    config.Name,
+		method:  config.Method,
+		path:    config.Path,
+		headers: config.Headers,
+		body:    config.Body,
+		status:  config.ExpectedStatus,
+		timeout: defaultTimeout,
+
+		success: metrics.Counter("Canary." + config.Name + ".Success"),
+		failure: metrics.Counter("Canary." + config.Name + ".Failure"),
+		latency: metrics.Gauge("Canary." + config.Name + ".Latency"),
+
+		result: health.ResultHealthy("not run yet"),
+	}
+	if c.method == "" {
+		c.method = "GET"
+	}
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	if config.Timeout > 0 {
+		c.timeout = time.Duration(config.Timeout)
+	}
+	if !strings.HasPrefix(c.path, "/") {
+		return nil, fmt.Errorf("canary: path of %s must start with /: %s", c.name, c.path)
+	}
+	return c, nil
+}
+
+// Check returns the result of the last run.
+func (c *check) Check() health.Result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.result
+}
+
+func (c *check) setResult(status int, latency time.Duration, err error) {
+	var result health.Result
+	if err != nil {
+		result = health.ResultUnhealthy("request failed", err)
+	} else if status != c.status {
+		result = health.ResultUnhealthy(fmt.Sprintf("unexpected status %d, expected %d", status, c.status), nil)
+	} else {
+		result = health.ResultHealthy(fmt.Sprintf("%s %s responded %d in %v", c.method, c.path, status, latency))
+	}
+	if result.Healthy() {
+		c.success.Add()
+	} else {
+		c.failure.Add()
+		logger().Warnf("canary %s is unhealthy: %s %v", c.name, result.Message(), result.Cause())
+	}
+	c.latency.Set(int64(latency / time.Millisecond))
+	c.mu.Lock()
+	c.result = result
+	c.mu.Unlock()
+}
+
+func (c *check) newRequest(ctx context.Context, url string) (*http.Request, error) {
+	var body io.Reader
+	if c.body != "" {
+		body = strings.NewReader(c.body)
+	}
+	r, err := http.NewRequest(c.method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range c.headers {
+		r.Header.Set(k, v)
+	}
+	return r.WithContext(ctx), nil
+}
+
+// runner runs all checks periodically. It implements core.Managed.
+type runner struct {
+	interval time.Duration
+	checks   []*check
+
+	// Either client or env is used.
+	client  *http.Client
+	baseURL string
+	env     *core.ServerEnvironment
+
+	quit chan struct{}
+	done chan struct{}
+}
+
+// Start runs checks in background. The first run is after an interval so that
+// the server is ready.
+func (r *runner) Start() error {
+	r.quit = make(chan struct{})
+	r.done = make(chan struct{})
+	go r.run()
+	return nil
+}
+
+// Stop stops running checks.
+func (r *runner) Stop() error {
+	if r.quit != nil {
+		close(r.quit)
+		<-r.done
+		r.quit = nil
+	}
+	return nil
+}
+
+func (r *runner) run() {
+	defer close(r.done)
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.runChecks()
+		case <-r.quit:
+			return
+		}
+	}
+}
+
+func (r *runner) runChecks() {
+	var wg sync.WaitGroup
+	for _, c := range r.checks {
+		wg.Add(1)
+		go func(c *check) {
+			defer wg.Done()
+			r.runCheck(c)
+		}(c)
+	}
+	wg.Wait()
+}
+
+func (r *runner) runCheck(c *check) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	var status int
+	var err error
+	if r.client != nil {
+		status, err = r.doRemote(ctx, c)
+	} else {
+		status, err = r.doLocal(ctx, c)
+	}
+	c.setResult(status, time.Since(start), err)
+}
+
+// doRemote sends request to the base URL.
+func (r *runner) doRemote(ctx context.Context, c *check) (int, error) {
+	req, err := c.newRequest(ctx, r.baseURL+c.path)
+	if err != nil {
+		return 0, err
+	}
+	rsp, err := r.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	io.Copy(ioutil.Discard, rsp.Body)
+	rsp.Body.Close()
+	return rsp.StatusCode, nil
+}
+
+// doLocal dispatches request to the server router.
+func (r *runner) doLocal(ctx context.Context, c *check) (int, error) {
+	handler, ok := r.env.Router.(http.Handler)
+	if !ok {
+		return 0, fmt.Errorf("router is not a http.Handler: %T", r.env.Router)
+	}
+	req, err := c.newRequest(ctx, "http://localhost"+r.env.Router.PathPrefix()+c.path)
+	if err != nil {
+		return 0, err
+	}
+	req.RemoteAddr = "127.0.0.1:0"
+	w := &responseWriter{header: make(http.Header)}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer func() {
+			if v := recover(); v != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+		}()
+		handler.ServeHTTP(w, req)
+	}()
+	select {
+	case <-done:
+		return w.statusCode(), nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+// responseWriter discards response body and records status.
+type responseWriter struct {
+	mu     sync.Mutex
+	header http.Header
+	status int
+}
+
+func (w *responseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return len(b), nil
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	w.mu.Lock()
+	if w.status == 0 {
+		w.status = status
+	}
+	w.mu.Unlock()
+}
+
+func (w *responseWriter) statusCode() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func logger() core.Logger {
+	return core.GetLogger("melon/canary")
+}
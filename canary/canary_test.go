@@ -0,0 +1,111 @@
+package canary
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/goburrow/melon/configuration"
+	"github.com/goburrow/melon/core"
+	"github.com/goburrow/melon/server/router"
+)
+
+var _ core.CanaryFactory = (*Factory)(nil)
+var _ core.Managed = (*runner)(nil)
+
+func testHandler() *router.Router {
+	r := router.New()
+	r.Handle("GET", "/ok", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	r.Handle("GET", "/slow", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	return r
+}
+
+var testChecks = []CheckConfiguration{
+	{Name: "ok", Path: "/ok"},
+	{Name: "notfound", Path: "/notfound"},
+	{Name: "created", Method: "GET", Path: "/ok", ExpectedStatus: 201},
+	{Name: "slow", Path: "/slow", Timeout: configuration.Duration(10 * time.Millisecond)},
+}
+
+func testChecksResults(t *testing.T, env *core.Environment, factory *Factory) {
+	r, err := factory.build(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := env.Admin.HealthChecks.RunChecker("canary.ok")
+	if !result.Healthy() {
+		t.Fatalf("unexpected result before running: %#v", result)
+	}
+	r.runChecks()
+	expected := map[string]bool{
+		"canary.ok":       true,
+		"canary.notfound": false,
+		"canary.created":  false,
+		"canary.slow":     false,
+	}
+	for name, healthy := range expected {
+		result = env.Admin.HealthChecks.RunChecker(name)
+		if result.Healthy() != healthy {
+			t.Errorf("unexpected result of %s: %v %v", name, result.Message(), result.Cause())
+		}
+	}
+}
+
+func TestLocalChecks(t *testing.T) {
+	env := core.NewEnvironment()
+	env.Server.Router = testHandler()
+	factory := &Factory{
+		Checks: testChecks,
+	}
+	testChecksResults(t, env, factory)
+}
+
+func TestRemoteChecks(t *testing.T) {
+	srv := httptest.NewServer(testHandler())
+	defer srv.Close()
+
+	env := core.NewEnvironment()
+	env.Server.Router = router.New()
+	factory := &Factory{
+		BaseURL: srv.URL + "/",
+		Checks:  testChecks,
+	}
+	testChecksResults(t, env, factory)
+}
+
+func TestRunner(t *testing.T) {
+	env := core.NewEnvironment()
+	env.Server.Router = testHandler()
+	factory := &Factory{
+		Checks: testChecks[:1],
+	}
+	r, err := factory.build(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.interval = time.Millisecond
+	// Stopping a runner which is not started does not block.
+	r.Stop()
+	r.Start()
+	time.Sleep(20 * time.Millisecond)
+	r.Stop()
+	result := env.Admin.HealthChecks.RunChecker("canary.ok")
+	if !result.Healthy() || result.Message() == "not run yet" {
+		t.Fatalf("unexpected result: %#v", result)
+	}
+}
+
+func TestInvalidPath(t *testing.T) {
+	env := core.NewEnvironment()
+	factory := &Factory{
+		Checks: []CheckConfiguration{{Name: "invalid", Path: "invalid"}},
+	}
+	if err := factory.ConfigureCanary(env); err == nil {
+		t.Fatal("error expected")
+	}
+}
@@ -1,20 +1,37 @@
 package melon
 
 import (
+	"encoding/json"
 	"fmt"
 
+	"github.com/goburrow/melon/canary"
+	"github.com/goburrow/melon/configuration"
 	"github.com/goburrow/melon/core"
+	"github.com/goburrow/melon/debug"
+	"github.com/goburrow/melon/diagnostics"
 	"github.com/goburrow/melon/logging"
 	"github.com/goburrow/melon/metrics"
+	"github.com/goburrow/melon/mock"
+	"github.com/goburrow/melon/report"
 	"github.com/goburrow/melon/server"
+	"github.com/goburrow/melon/tracing"
+	"github.com/goburrow/melon/validation"
+	"github.com/goburrow/melon/watchdog"
 )
 
 // Configuration is the default configuration that implements core.Configuration
 // interface.
 type Configuration struct {
-	Server  server.Factory
-	Logging logging.Factory
-	Metrics metrics.Factory
+	Server         server.Factory
+	Logging        logging.Factory
+	Metrics        metrics.Factory
+	ErrorReporting report.Factory
+	Canary         canary.Factory
+	Watchdog       watchdog.Factory
+	Mock           mock.Factory
+	Diagnostics    diagnostics.Factory
+	Tracing        tracing.Factory
+	Debug          debug.Factory
 }
 
 // Configuration implements core.Configuration interface.
@@ -35,6 +52,95 @@ func (c *Configuration) MetricsFactory() core.MetricsFactory {
 	return &c.Metrics
 }
 
+// ErrorReportingFactory returns default factory from report package.
+func (c *Configuration) ErrorReportingFactory() core.ErrorReportingFactory {
+	return &c.ErrorReporting
+}
+
+// CanaryFactory returns default factory from canary package.
+func (c *Configuration) CanaryFactory() core.CanaryFactory {
+	return &c.Canary
+}
+
+// WatchdogFactory returns default factory from watchdog package.
+func (c *Configuration) WatchdogFactory() core.WatchdogFactory {
+	return &c.Watchdog
+}
+
+// MockFactory returns default factory from mock package.
+func (c *Configuration) MockFactory() core.MockFactory {
+	return &c.Mock
+}
+
+// DiagnosticsFactory returns default factory from diagnostics package.
+func (c *Configuration) DiagnosticsFactory() core.DiagnosticsFactory {
+	return &c.Diagnostics
+}
+
+// TracingFactory returns default factory from tracing package.
+func (c *Configuration) TracingFactory() core.TracingFactory {
+	return &c.Tracing
+}
+
+// DebugFactory returns default factory from debug package.
+func (c *Configuration) DebugFactory() core.DebugFactory {
+	return &c.Debug
+}
+
+// errorReportingConfiguration is implemented by configurations which support
+// error reporting. It is optional for core.Configuration.
+type errorReportingConfiguration interface {
+	ErrorReportingFactory() core.ErrorReportingFactory
+}
+
+// canaryConfiguration is implemented by configurations which support
+// synthetic checks. It is optional for core.Configuration.
+type canaryConfiguration interface {
+	CanaryFactory() core.CanaryFactory
+}
+
+// watchdogConfiguration is implemented by configurations which support
+// runtime watchdog. It is optional for core.Configuration.
+type watchdogConfiguration interface {
+	WatchdogFactory() core.WatchdogFactory
+}
+
+// mockConfiguration is implemented by configurations which support
+// mocked routes. It is optional for core.Configuration.
+type mockConfiguration interface {
+	MockFactory() core.MockFactory
+}
+
+// diagnosticsConfiguration is implemented by configurations which support
+// diagnostics dump. It is optional for core.Configuration.
+type diagnosticsConfiguration interface {
+	DiagnosticsFactory() core.DiagnosticsFactory
+}
+
+// tracingConfiguration is implemented by configurations which support
+// request tracing. It is optional for core.Configuration.
+type tracingConfiguration interface {
+	TracingFactory() core.TracingFactory
+}
+
+// debugConfiguration is implemented by configurations which support
+// debugging endpoints. It is optional for core.Configuration.
+type debugConfiguration interface {
+	DebugFactory() core.DebugFactory
+}
+
+// strictConfigurationFactory is implemented by configuration factories which
+// can reject unknown fields. It is optional for core.ConfigurationFactory.
+type strictConfigurationFactory interface {
+	SetStrict(bool)
+}
+
+// schemaConfigurationFactory is implemented by configuration factories which
+// generate JSON Schema of the configuration.
+type schemaConfigurationFactory interface {
+	Schema() map[string]interface{}
+}
+
 // configurationCommand parses configuration.
 type configurationCommand struct {
 	// validator is created by bootstrap.ValidatorFactory.
@@ -44,6 +150,8 @@ type configurationCommand struct {
 }
 
 // Run loads and validates configuration provided by ConfigurationFactory in bootstrap.
+// Configuration hooks of bootstrap are invoked before validation.
+// The configuration is logged at debug level with values of secret fields masked.
 func (command *configurationCommand) Run(bootstrap *core.Bootstrap) error {
 	var err error
 	command.validator, err = bootstrap.ValidatorFactory.BuildValidator(bootstrap)
@@ -54,9 +162,15 @@ func (command *configurationCommand) Run(bootstrap *core.Bootstrap) error {
 	if err != nil {
 		return err
 	}
+	if err = bootstrap.RunConfigurationHooks(command.configuration); err != nil {
+		return err
+	}
+	if b, err := redactedConfiguration(command.configuration); err == nil {
+		logger().Debugf("configuration: %s", b)
+	}
 	err = command.validator.Validate(command.configuration)
 	if err != nil {
-		return fmt.Errorf("configuration is invalid: %v", err)
+		return &invalidConfigurationError{err}
 	}
 	// Configuration provided must implement core.Configuration interface.
 	if _, ok := command.configuration.(core.Configuration); !ok {
@@ -65,6 +179,15 @@ func (command *configurationCommand) Run(bootstrap *core.Bootstrap) error {
 	return nil
 }
 
+// invalidConfigurationError is returned when configuration fails validation.
+type invalidConfigurationError struct {
+	err error
+}
+
+func (e *invalidConfigurationError) Error() string {
+	return "configuration is invalid: " + e.err.Error()
+}
+
 // checkCommand is a command for validating configuration files.
 type checkCommand struct {
 	configurationCommand
@@ -81,11 +204,79 @@ func (c *checkCommand) Description() string {
 }
 
 // Run utilizes underlying configurationCommand to verify configuration file.
+// Unknown fields are rejected unless flag -config-strict=false is given. The
+// resulting configuration is printed with values of secret fields masked.
 func (c *checkCommand) Run(bootstrap *core.Bootstrap) error {
+	if f, ok := bootstrap.ConfigurationFactory.(strictConfigurationFactory); ok {
+		f.SetStrict(true)
+	}
 	if err := c.configurationCommand.Run(bootstrap); err != nil {
-		fmt.Println(err)
+		if errs, ok := c.validationErrors(err); ok {
+			fmt.Println("configuration is invalid:")
+			for _, e := range errs {
+				fmt.Printf("  %s: %s\n", e.Field, e.Message)
+			}
+		} else {
+			fmt.Println(err)
+		}
 		return err
 	}
 	fmt.Println("configuration is OK")
+	if b, err := redactedConfiguration(c.configuration); err == nil {
+		fmt.Printf("%s\n", b)
+	}
+	return nil
+}
+
+// redactedConfiguration returns configuration in JSON with values of fields
+// tagged with configuration.SecretTag masked.
+func redactedConfiguration(config interface{}) ([]byte, error) {
+	v, err := configuration.Redact(config)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(v, "", "  ")
+}
+
+// validationErrors returns field errors of the configuration if err is
+// produced by validation.Validator.
+func (c *checkCommand) validationErrors(err error) (validation.Errors, bool) {
+	e, ok := err.(*invalidConfigurationError)
+	if !ok {
+		return nil, false
+	}
+	errs, ok := e.err.(validation.Errors)
+	return errs, ok
+}
+
+// schemaCommand prints JSON Schema of the configuration so that editors and
+// CI can validate configuration files against the application.
+type schemaCommand struct {
+}
+
+// Name returns name of this schema command.
+func (c *schemaCommand) Name() string {
+	return "schema"
+}
+
+// Description returns description of this schema command.
+func (c *schemaCommand) Description() string {
+	return "prints JSON Schema of the configuration"
+}
+
+// Run prints schema of the configuration of ConfigurationFactory in bootstrap.
+func (c *schemaCommand) Run(bootstrap *core.Bootstrap) error {
+	f, ok := bootstrap.ConfigurationFactory.(schemaConfigurationFactory)
+	if !ok {
+		err := fmt.Errorf("configuration factory does not support schema: %T", bootstrap.ConfigurationFactory)
+		fmt.Println(err)
+		return err
+	}
+	b, err := json.MarshalIndent(f.Schema(), "", "  ")
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+	fmt.Printf("%s\n", b)
 	return nil
 }
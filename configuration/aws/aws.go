@@ -0,0 +1,298 @@
+/*
+Package aws provides configuration secret providers for AWS Secrets Manager
+and Systems Manager Parameter Store.
+
+Configuration values in form of aws-sm://<secret-id>[#<key>] are replaced by
+the secret string of Secrets Manager secret, or field key of the secret if it
+is a JSON object. Values ssm://<parameter-name> are replaced by the decrypted
+value of SSM parameter, e.g. ssm:///app/db/password. NewKMSBundle decrypts
+values enc:<base64 ciphertext> with AWS Key Management Service. Credentials are
+read from environment variables AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+AWS_SESSION_TOKEN, or else obtained from web identity token file of
+AWS_WEB_IDENTITY_TOKEN_FILE and AWS_ROLE_ARN (EKS), ECS task role or EC2
+instance profile. Settings are given in block secrets of configuration:
+
+	secrets:
+	  aws:
+	    region: us-east-1
+	    roleArn: arn:aws:iam::123456789012:role/app
+*/
+package aws
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goburrow/melon/configuration"
+	"github.com/goburrow/melon/core"
+)
+
+const (
+	// SecretsManagerScheme is the URL scheme of Secrets Manager references.
+	SecretsManagerScheme = "aws-sm"
+	// SSMScheme is the URL scheme of SSM Parameter Store references.
+	SSMScheme = "ssm"
+
+	configKey          = "aws"
+	defaultSessionName = "melon"
+)
+
+// Configuration is settings of AWS secret providers.
+type Configuration struct {
+	// Region defaults to environment variable AWS_REGION or AWS_DEFAULT_REGION.
+	Region string
+	// RoleARN is the role assumed to read secrets if not empty.
+	RoleARN         string
+	RoleSessionName string
+	// Endpoint overrides endpoints of AWS services, e.g. for testing.
+	Endpoint string
+}
+
+// provider reads secrets from AWS.
+type provider struct {
+	config Configuration
+	client *http.Client
+	now    func() time.Time
+
+	// metadataClient has short timeout as instance metadata service is not
+	// reachable outside EC2.
+	metadataClient *http.Client
+	ecsEndpoint    string
+	imdsEndpoint   string
+
+	mu    sync.Mutex
+	creds *credentials
+	// secrets caches Secrets Manager secret strings by ID.
+	secrets map[string]string
+}
+
+// NewProvider returns a configuration.SecretProvider for both Secrets Manager
+// and SSM Parameter Store references.
+func NewProvider() configuration.SecretProvider {
+	return newProvider()
+}
+
+// NewDecrypter returns a configuration.Decrypter which decrypts values with
+// AWS Key Management Service. The ciphertext is the blob returned by KMS
+// Encrypt API.
+func NewDecrypter() configuration.Decrypter {
+	return newProvider()
+}
+
+func newProvider() *provider {
+	return &provider{
+		config: Configuration{
+			Region: firstEnv("AWS_REGION", "AWS_DEFAULT_REGION"),
+		},
+		client:         &http.Client{Timeout: 10 * time.Second},
+		now:            time.Now,
+		metadataClient: &http.Client{Timeout: time.Second},
+		ecsEndpoint:    defaultECSEndpoint,
+		imdsEndpoint:   strings.TrimSuffix(firstEnv("AWS_EC2_METADATA_SERVICE_ENDPOINT"), "/"),
+		secrets:        make(map[string]string),
+	}
+}
+
+// ConfigureSecrets reads settings from block secrets.aws of configuration.
+func (p *provider) ConfigureSecrets(decode func(string, interface{}) error) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return decode(configKey, &p.config)
+}
+
+// Secret returns value of Secrets Manager secret or SSM parameter.
+func (p *provider) Secret(ref string) (string, error) {
+	switch {
+	case strings.HasPrefix(ref, SecretsManagerScheme+"://"):
+		return p.secretValue(strings.TrimPrefix(ref, SecretsManagerScheme+"://"))
+	case strings.HasPrefix(ref, SSMScheme+"://"):
+		return p.parameter(strings.TrimPrefix(ref, SSMScheme+"://"))
+	}
+	return "", fmt.Errorf("aws: unsupported reference %s", ref)
+}
+
+func (p *provider) secretValue(id string) (string, error) {
+	var key string
+	if i := strings.LastIndexByte(id, '#'); i >= 0 {
+		id, key = id[:i], id[i+1:]
+	}
+	if id == "" {
+		return "", fmt.Errorf("aws: secret id required")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	value, ok := p.secrets[id]
+	if !ok {
+		var resp struct {
+			SecretString string
+		}
+		err := p.call("secretsmanager", "secretsmanager.GetSecretValue",
+			map[string]interface{}{"SecretId": id}, &resp)
+		if err != nil {
+			return "", err
+		}
+		value = resp.SecretString
+		p.secrets[id] = value
+	}
+	if key == "" {
+		return value, nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(value), &fields); err != nil {
+		return "", fmt.Errorf("aws: secret %s is not a JSON object: %v", id, err)
+	}
+	v, ok := fields[key]
+	if !ok {
+		return "", fmt.Errorf("aws: key %s not found in secret %s", key, id)
+	}
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprint(v), nil
+}
+
+func (p *provider) parameter(name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("aws: parameter name required")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var resp struct {
+		Parameter struct {
+			Value string
+		}
+	}
+	err := p.call("ssm", "AmazonSSM.GetParameter",
+		map[string]interface{}{"Name": name, "WithDecryption": true}, &resp)
+	if err != nil {
+		return "", err
+	}
+	return resp.Parameter.Value, nil
+}
+
+// Decrypt decrypts ciphertext with KMS.
+func (p *provider) Decrypt(ciphertext []byte) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var resp struct {
+		Plaintext []byte
+	}
+	err := p.call("kms", "TrentService.Decrypt",
+		map[string]interface{}{"CiphertextBlob": ciphertext}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Plaintext, nil
+}
+
+// call invokes action of AWS JSON protocol service.
+func (p *provider) call(service, target string, input, output interface{}) error {
+	creds, err := p.credentials()
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	r, err := http.NewRequest("POST", p.endpoint(service), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	r.Header.Set("X-Amz-Target", target)
+	sign(r, body, creds, p.config.Region, service, p.now())
+	resp, err := p.client.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		json.Unmarshal(data, &e)
+		return fmt.Errorf("aws: %s failed: %s %s %s", target, resp.Status, e.Type, e.Message)
+	}
+	return json.Unmarshal(data, output)
+}
+
+func (p *provider) endpoint(service string) string {
+	if p.config.Endpoint != "" {
+		return p.config.Endpoint
+	}
+	return "https://" + service + "." + p.config.Region + ".amazonaws.com/"
+}
+
+// credentials returns credentials from the default chain or of the assumed
+// role.
+func (p *provider) credentials() (*credentials, error) {
+	if p.config.Region == "" {
+		return nil, fmt.Errorf("aws: region required")
+	}
+	if p.creds != nil && (p.creds.Expiration.IsZero() || p.now().Before(p.creds.Expiration)) {
+		return p.creds, nil
+	}
+	creds, err := p.defaultCredentials()
+	if err != nil {
+		return nil, err
+	}
+	if p.config.RoleARN != "" {
+		if creds, err = p.assumeRole(creds); err != nil {
+			return nil, err
+		}
+	}
+	p.creds = creds
+	return creds, nil
+}
+
+type bundle struct{}
+
+func (b *bundle) Initialize(bootstrap *core.Bootstrap) {
+	f, ok := bootstrap.ConfigurationFactory.(*configuration.Factory)
+	if ok {
+		p := NewProvider()
+		f.SetSecretProvider(SecretsManagerScheme, p)
+		f.SetSecretProvider(SSMScheme, p)
+	}
+}
+
+func (b *bundle) Run(config interface{}, env *core.Environment) error {
+	return nil
+}
+
+// NewBundle creates a Bundle that resolves AWS Secrets Manager and SSM
+// Parameter Store references in configuration.
+func NewBundle() core.Bundle {
+	return &bundle{}
+}
+
+type kmsBundle struct{}
+
+func (b *kmsBundle) Initialize(bootstrap *core.Bootstrap) {
+	f, ok := bootstrap.ConfigurationFactory.(*configuration.Factory)
+	if ok {
+		f.SetDecrypter(NewDecrypter())
+	}
+}
+
+func (b *kmsBundle) Run(config interface{}, env *core.Environment) error {
+	return nil
+}
+
+// NewKMSBundle creates a Bundle that decrypts encrypted configuration values
+// with AWS Key Management Service.
+func NewKMSBundle() core.Bundle {
+	return &kmsBundle{}
+}
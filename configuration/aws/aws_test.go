@@ -0,0 +1,191 @@
+package aws
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProvider(t *testing.T) {
+	os.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	os.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	defer os.Unsetenv("AWS_ACCESS_KEY_ID")
+	defer os.Unsetenv("AWS_SECRET_ACCESS_KEY")
+
+	calls := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if r.URL.Query().Get("Action") == "AssumeRole" {
+			calls["AssumeRole"]++
+			if !strings.Contains(auth, "Credential=AKIDEXAMPLE/") || r.URL.Query().Get("RoleSessionName") != "melon" {
+				http.Error(w, "invalid request", http.StatusForbidden)
+				return
+			}
+			w.Write([]byte(`<AssumeRoleResponse><AssumeRoleResult><Credentials>
+<AccessKeyId>ASIAROLE</AccessKeyId><SecretAccessKey>rolesecret</SecretAccessKey>
+<SessionToken>token</SessionToken><Expiration>2100-01-01T00:00:00Z</Expiration>
+</Credentials></AssumeRoleResult></AssumeRoleResponse>`))
+			return
+		}
+		if !strings.Contains(auth, "Credential=ASIAROLE/") || r.Header.Get("X-Amz-Security-Token") != "token" {
+			http.Error(w, "invalid credentials", http.StatusForbidden)
+			return
+		}
+		target := r.Header.Get("X-Amz-Target")
+		calls[target]++
+		var input map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&input)
+		switch target {
+		case "secretsmanager.GetSecretValue":
+			if input["SecretId"] != "db" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"__type":"ResourceNotFoundException","message":"not found"}`))
+				return
+			}
+			w.Write([]byte(`{"SecretString":"{\"password\":\"melon\",\"port\":5432}"}`))
+		case "AmazonSSM.GetParameter":
+			if input["WithDecryption"] != true {
+				http.Error(w, "invalid request", http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"Parameter":{"Value":"` + input["Name"].(string) + `-value"}}`))
+		case "TrentService.Decrypt":
+			if input["CiphertextBlob"] != "Y2lwaGVy" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"__type":"InvalidCiphertextException","message":"invalid"}`))
+				return
+			}
+			w.Write([]byte(`{"Plaintext":"cGxhaW4="}`))
+		}
+	}))
+	defer server.Close()
+
+	p := NewProvider().(*provider)
+	err := p.ConfigureSecrets(func(key string, v interface{}) error {
+		if key != "aws" {
+			t.Fatalf("unexpected key: %v", key)
+		}
+		return json.Unmarshal([]byte(`{"region":"us-east-1","roleArn":"arn:aws:iam::1:role/app","endpoint":"`+server.URL+`"}`), v)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		ref   string
+		value string
+		valid bool
+	}{
+		{"aws-sm://db#password", "melon", true},
+		{"aws-sm://db#port", "5432", true},
+		{"aws-sm://db", `{"password":"melon","port":5432}`, true},
+		{"aws-sm://db#user", "", false},
+		{"aws-sm://none", "", false},
+		{"ssm:///app/db/password", "/app/db/password-value", true},
+		{"ssm://", "", false},
+	}
+	for _, test := range tests {
+		v, err := p.Secret(test.ref)
+		if (err == nil) != test.valid || v != test.value {
+			t.Fatalf("unexpected secret of %+v: %v %v", test, v, err)
+		}
+	}
+	if calls["AssumeRole"] != 1 || calls["secretsmanager.GetSecretValue"] != 2 {
+		t.Fatalf("unexpected calls: %v", calls)
+	}
+
+	plaintext, err := p.Decrypt([]byte("cipher"))
+	if err != nil || string(plaintext) != "plain" {
+		t.Fatalf("unexpected plaintext: %s %v", plaintext, err)
+	}
+	if _, err = p.Decrypt([]byte("other")); err == nil || !strings.Contains(err.Error(), "InvalidCiphertextException") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestDefaultCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			q := r.URL.Query()
+			if q.Get("Action") != "AssumeRoleWithWebIdentity" || q.Get("WebIdentityToken") != "oidc" ||
+				q.Get("RoleArn") != "arn:aws:iam::1:role/eks" || r.Header.Get("Authorization") != "" {
+				http.Error(w, "invalid request", http.StatusForbidden)
+				return
+			}
+			w.Write([]byte(`<AssumeRoleWithWebIdentityResponse><AssumeRoleWithWebIdentityResult><Credentials>
+<AccessKeyId>ASIAEKS</AccessKeyId><SecretAccessKey>ekssecret</SecretAccessKey>
+<SessionToken>token</SessionToken><Expiration>2100-01-01T00:00:00Z</Expiration>
+</Credentials></AssumeRoleWithWebIdentityResult></AssumeRoleWithWebIdentityResponse>`))
+		case "/v2/credentials/task":
+			if r.Header.Get("Authorization") != "" {
+				http.Error(w, "invalid request", http.StatusForbidden)
+				return
+			}
+			w.Write([]byte(`{"AccessKeyId":"ASIAECS","SecretAccessKey":"ecssecret","Token":"token","Expiration":"2100-01-01T00:00:00Z"}`))
+		case "/latest/api/token":
+			if r.Method != "PUT" || r.Header.Get("X-aws-ec2-metadata-token-ttl-seconds") == "" {
+				http.Error(w, "invalid request", http.StatusForbidden)
+				return
+			}
+			w.Write([]byte("imds"))
+		case "/latest/meta-data/iam/security-credentials/":
+			if r.Header.Get("X-aws-ec2-metadata-token") != "imds" {
+				http.Error(w, "invalid token", http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte("app\n"))
+		case "/latest/meta-data/iam/security-credentials/app":
+			w.Write([]byte(`{"AccessKeyId":"ASIAEC2","SecretAccessKey":"ec2secret","Token":"token","Expiration":"2100-01-01T00:00:00Z"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "aws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "token")
+	if err = ioutil.WriteFile(tokenFile, []byte("oidc\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	p := newProvider()
+	p.config = Configuration{Region: "us-east-1", Endpoint: server.URL + "/"}
+	p.ecsEndpoint = server.URL
+	p.imdsEndpoint = server.URL
+
+	tests := []struct {
+		env map[string]string
+		key string
+	}{
+		{map[string]string{"AWS_WEB_IDENTITY_TOKEN_FILE": tokenFile, "AWS_ROLE_ARN": "arn:aws:iam::1:role/eks"}, "ASIAEKS"},
+		{map[string]string{"AWS_CONTAINER_CREDENTIALS_RELATIVE_URI": "/v2/credentials/task"}, "ASIAECS"},
+		{map[string]string{}, "ASIAEC2"},
+	}
+	for _, test := range tests {
+		for k, v := range test.env {
+			os.Setenv(k, v)
+		}
+		creds, err := p.defaultCredentials()
+		for k := range test.env {
+			os.Unsetenv(k)
+		}
+		if err != nil || creds.AccessKeyID != test.key || creds.SessionToken != "token" || creds.Expiration.IsZero() {
+			t.Fatalf("unexpected credentials of %v: %+v %v", test.env, creds, err)
+		}
+	}
+
+	os.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+	defer os.Unsetenv("AWS_EC2_METADATA_DISABLED")
+	if _, err = p.defaultCredentials(); err == nil {
+		t.Fatal("expected error")
+	}
+}
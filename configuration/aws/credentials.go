@@ -0,0 +1,178 @@
+package aws
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	defaultECSEndpoint  = "http://169.254.170.2"
+	defaultIMDSEndpoint = "http://169.254.169.254"
+	imdsTokenTTL        = "21600"
+)
+
+// defaultCredentials returns credentials from the first source of the
+// standard chain which is available:
+//
+// 	- environment variables AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY,
+// 	- web identity token of AWS_WEB_IDENTITY_TOKEN_FILE and role AWS_ROLE_ARN,
+// 	  e.g. IAM roles for service accounts of EKS,
+// 	- container credentials of ECS task role,
+// 	- EC2 instance profile from instance metadata service.
+func (p *provider) defaultCredentials() (*credentials, error) {
+	creds := &credentials{
+		AccessKeyID:     firstEnv("AWS_ACCESS_KEY_ID", "AWS_ACCESS_KEY"),
+		SecretAccessKey: firstEnv("AWS_SECRET_ACCESS_KEY", "AWS_SECRET_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID != "" && creds.SecretAccessKey != "" {
+		return creds, nil
+	}
+	if tokenFile := os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"); tokenFile != "" {
+		token, err := ioutil.ReadFile(tokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("aws: could not read web identity token: %v", err)
+		}
+		return p.assumeRoleWithWebIdentity(os.Getenv("AWS_ROLE_ARN"),
+			os.Getenv("AWS_ROLE_SESSION_NAME"), strings.TrimSpace(string(token)))
+	}
+	if uri := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); uri != "" {
+		return p.containerCredentials(p.ecsEndpoint + uri)
+	}
+	if uri := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI"); uri != "" {
+		return p.containerCredentials(uri)
+	}
+	if !strings.EqualFold(os.Getenv("AWS_EC2_METADATA_DISABLED"), "true") {
+		creds, err := p.instanceCredentials()
+		if err == nil {
+			return creds, nil
+		}
+		return nil, fmt.Errorf("aws: credentials not found in environment variables, web identity, container or instance metadata: %v", err)
+	}
+	return nil, fmt.Errorf("aws: credentials not found in environment variables, web identity or container")
+}
+
+// metadataCredentials are credentials returned by ECS and EC2 metadata
+// endpoints.
+type metadataCredentials struct {
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string
+	Token           string
+	Expiration      time.Time
+}
+
+func (c *metadataCredentials) credentials() *credentials {
+	creds := &credentials{
+		AccessKeyID:     c.AccessKeyID,
+		SecretAccessKey: c.SecretAccessKey,
+		SessionToken:    c.Token,
+	}
+	if !c.Expiration.IsZero() {
+		// Refresh credentials before they actually expire.
+		creds.Expiration = c.Expiration.Add(-time.Minute)
+	}
+	return creds
+}
+
+// containerCredentials returns credentials of ECS task role.
+func (p *provider) containerCredentials(uri string) (*credentials, error) {
+	r, err := http.NewRequest("GET", uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	token := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN")
+	if tokenFile := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE"); tokenFile != "" {
+		b, err := ioutil.ReadFile(tokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("aws: could not read container authorization token: %v", err)
+		}
+		token = strings.TrimSpace(string(b))
+	}
+	if token != "" {
+		r.Header.Set("Authorization", token)
+	}
+	var c metadataCredentials
+	if err = p.getJSON(p.client, r, &c); err != nil {
+		return nil, fmt.Errorf("aws: could not get container credentials: %v", err)
+	}
+	return c.credentials(), nil
+}
+
+// instanceCredentials returns credentials of EC2 instance profile using
+// instance metadata service version 2.
+func (p *provider) instanceCredentials() (*credentials, error) {
+	endpoint := p.imdsEndpoint
+	if endpoint == "" {
+		endpoint = defaultIMDSEndpoint
+	}
+	r, err := http.NewRequest("PUT", endpoint+"/latest/api/token", nil)
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", imdsTokenTTL)
+	token, err := p.get(p.metadataClient, r)
+	if err != nil {
+		return nil, err
+	}
+	path := endpoint + "/latest/meta-data/iam/security-credentials/"
+	if r, err = http.NewRequest("GET", path, nil); err != nil {
+		return nil, err
+	}
+	r.Header.Set("X-aws-ec2-metadata-token", string(token))
+	roles, err := p.get(p.metadataClient, r)
+	if err != nil {
+		return nil, err
+	}
+	role := strings.TrimSpace(strings.SplitN(string(roles), "\n", 2)[0])
+	if role == "" {
+		return nil, fmt.Errorf("no instance profile")
+	}
+	if r, err = http.NewRequest("GET", path+role, nil); err != nil {
+		return nil, err
+	}
+	r.Header.Set("X-aws-ec2-metadata-token", string(token))
+	var c metadataCredentials
+	if err = p.getJSON(p.metadataClient, r, &c); err != nil {
+		return nil, err
+	}
+	return c.credentials(), nil
+}
+
+// get returns response body of the request.
+func (p *provider) get(client *http.Client, r *http.Request) ([]byte, error) {
+	resp, err := client.Do(r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s %s: %s", r.Method, r.URL.Path, resp.Status)
+	}
+	return data, nil
+}
+
+func (p *provider) getJSON(client *http.Client, r *http.Request, v interface{}) error {
+	data, err := p.get(client, r)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
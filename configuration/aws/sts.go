@@ -0,0 +1,101 @@
+package aws
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// stsCredentials are credentials in responses of STS.
+type stsCredentials struct {
+	AccessKeyID     string `xml:"AccessKeyId"`
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      time.Time
+}
+
+// assumeRole returns temporary credentials of the configured role.
+func (p *provider) assumeRole(creds *credentials) (*credentials, error) {
+	query := url.Values{
+		"Action":          {"AssumeRole"},
+		"Version":         {"2011-06-15"},
+		"RoleArn":         {p.config.RoleARN},
+		"RoleSessionName": {p.sessionName()},
+	}
+	return p.sts(query, creds)
+}
+
+// assumeRoleWithWebIdentity returns temporary credentials of role roleARN
+// using OIDC token, e.g. of Kubernetes service accounts, without signing the
+// request.
+func (p *provider) assumeRoleWithWebIdentity(roleARN, sessionName, token string) (*credentials, error) {
+	if sessionName == "" {
+		sessionName = p.sessionName()
+	}
+	query := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {roleARN},
+		"RoleSessionName":  {sessionName},
+		"WebIdentityToken": {token},
+	}
+	return p.sts(query, nil)
+}
+
+func (p *provider) sessionName() string {
+	if p.config.RoleSessionName != "" {
+		return p.config.RoleSessionName
+	}
+	return defaultSessionName
+}
+
+// sts calls STS action given in query. The request is signed with creds if
+// it is not nil.
+func (p *provider) sts(query url.Values, creds *credentials) (*credentials, error) {
+	u, err := url.Parse(p.endpoint("sts"))
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery = query.Encode()
+	r, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if creds != nil {
+		sign(r, nil, creds, p.config.Region, "sts", p.now())
+	}
+	resp, err := p.client.Do(r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	action := query.Get("Action")
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("aws: could not %s %s: %s %s", action, query.Get("RoleArn"), resp.Status, data)
+	}
+	var result struct {
+		AssumeRole  stsCredentials `xml:"AssumeRoleResult>Credentials"`
+		WebIdentity stsCredentials `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+	}
+	if err = xml.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("aws: could not decode %s response: %v", action, err)
+	}
+	c := result.AssumeRole
+	if action == "AssumeRoleWithWebIdentity" {
+		c = result.WebIdentity
+	}
+	return &credentials{
+		AccessKeyID:     c.AccessKeyID,
+		SecretAccessKey: c.SecretAccessKey,
+		SessionToken:    c.SessionToken,
+		// Refresh credentials before they actually expire.
+		Expiration: c.Expiration.Add(-time.Minute),
+	}, nil
+}
@@ -1,14 +1,104 @@
 /*
 Package configuration provides JSON file support for application configuration.
+
+Format of the configuration file is detected by its extension. It can be given
+explicitly with flag -config-format before the file name:
+
+	./app server -config-format=toml app.conf
+
+Configuration can also be fetched from an http or https URL or read from
+standard input when the file name is "-". Sources are opened by
+ConfigurationSourceProvider of core.Bootstrap if it is set, e.g. one created
+by NewBytesSourceProvider for tests. Flag -config-checksum verifies
+content of the configuration in form of [sha256|sha512:]hex digest:
+
+	./app server -config-checksum=sha256:9f86d0... https://config/app.yaml
+	cat app.yaml | ./app server -config-format=yaml -
+
+Multiple configuration files are deep merged in order so that later files only
+override differences, e.g. ./app server base.yaml production.yaml. A file can
+also list files to be merged before it with top-level key "includes":
+
+	includes:
+	  - base.yaml
+	server:
+	  applicationConnectors:
+	    - type: http
+	      addr: :80
+
+Settings of profiles in top-level block "profiles" are merged over the file
+declaring them when the profiles are activated with flag -config-profile or
+environment variable MELON_PROFILE, in the given order:
+
+	server:
+	  applicationConnectors:
+	    - type: http
+	      addr: :8080
+	profiles:
+	  prod:
+	    server:
+	      applicationConnectors:
+	        - type: https
+	          addr: :443
+
+	./app server -config-profile prod app.yaml
+
+Relative paths in fields tagged with `file:"true"`, such as certificate files
+of connectors, are resolved against directory of the configuration file
+declaring them unless it is disabled with SetResolvePaths.
+
+Values of the files can be overridden by environment variables when a prefix
+is set with SetEnvPrefix, and by flags -config-set, which take precedence:
+
+	MYAPP_SERVER_ADMINCONNECTORS_0_ADDR=:9091 ./app server app.yaml
+	./app server -config-set server.adminConnectors[0].addr=:9091 app.yaml
+
+Fields which are not given in any of them keep values of the configuration
+object passed to NewFactory, so the precedence order is flags, environment
+variables, files, then defaults.
+
+String values can refer to other values of the configuration in form of
+${path}, e.g. ${server.applicationConnectors[0].addr}, so that they are
+declared once. Such references are resolved after overrides are applied. A
+value which is only a reference takes the referred value, which can be a
+number or an object, while $${ is kept as ${ without interpolation.
+
+Values referring to secrets, such as vault://secret/db#password, are resolved
+by providers registered with SetSecretProvider after files are merged. Settings
+of the providers are given in top-level block "secrets".
+
+Values in form of enc:<base64 ciphertext> are decrypted with the Decrypter set
+by SetDecrypter, or with AES-GCM key in environment variable MELON_CONFIG_KEY,
+so that semi-sensitive settings can be kept in version-controlled files.
+Function Encrypt produces such values.
+
+Fields holding credentials should be tagged with `secret:"true"` so that their
+values are masked by Redact when configuration is logged or printed, e.g. by
+the check command.
+
+Bundles can declare their own configuration bound to a top-level key, e.g.
+"redis", with SetSection or BindSection instead of requiring the application
+configuration to embed it. The section is decoded from the same files and
+supports overrides and strict mode like other fields.
+
+Schema returns JSON Schema of the configuration, which is printed by the
+schema command for editor autocompletion and validating files in CI:
+
+	./app schema > app.schema.json
+
+Keys which do not map to any field of the configuration, e.g. a misspelled
+"applicaitonConnectors", are ignored unless strict mode is enabled with
+SetStrict or flag -config-strict. The check command enables it by default.
 */
 package configuration
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
+	"reflect"
 
 	"github.com/goburrow/melon/core"
 )
@@ -16,22 +106,38 @@ import (
 // Factory implements melon.ConfigurationFactory interface.
 type Factory struct {
 	// ref is the type/pointer of application configuration.
-	ref      interface{}
-	decoders map[string]func(io.Reader, interface{}) error
+	ref interface{}
+	// defaults is a copy of the configuration given to NewFactory.
+	defaults  reflect.Value
+	decoders  map[string]func(io.Reader, interface{}) error
+	secrets   map[string]SecretProvider
+	decrypter Decrypter
+	strict    bool
+	envPrefix string
+	// resolvePaths is true by default.
+	resolvePaths bool
+	// sections contains configuration of bundles by lower case keys.
+	sections map[string]interface{}
 }
 
 // NewFactory creates a new core.ConfigurationFactory with given pointer to
 // configuration object.
 func NewFactory(ref interface{}) *Factory {
 	f := &Factory{
-		ref:      ref,
-		decoders: make(map[string]func(io.Reader, interface{}) error),
+		ref:          ref,
+		decoders:     make(map[string]func(io.Reader, interface{}) error),
+		resolvePaths: true,
+	}
+	if v := reflect.ValueOf(ref); v.Kind() == reflect.Ptr && !v.IsNil() {
+		f.defaults = reflect.New(v.Elem().Type()).Elem()
+		f.defaults.Set(v.Elem())
 	}
 	f.decoders[".js"] = unmarshalJSON
 	f.decoders[".json"] = unmarshalJSON
 	return f
 }
 
+// SetDecoder registers decode function for files with extension ext, e.g. ".yaml".
 func (f *Factory) SetDecoder(ext string, decode func(io.Reader, interface{}) error) {
 	f.decoders[ext] = decode
 }
@@ -41,25 +147,136 @@ func (f *Factory) BuildConfiguration(bootstrap *core.Bootstrap) (interface{}, er
 	if len(bootstrap.Arguments) < 2 {
 		return nil, fmt.Errorf("configuration: no file specified in command arguments")
 	}
-	if err := f.unmarshal(bootstrap.Arguments[1], f.ref); err != nil {
+	flags := flag.NewFlagSet(bootstrap.Arguments[0], flag.ContinueOnError)
+	opts := loadOptions{
+		provider: bootstrap.ConfigurationSourceProvider,
+		strict:   f.strict,
+	}
+	flags.StringVar(&opts.format, "config-format", "", "format of configuration file, e.g. json, yaml or toml")
+	flags.StringVar(&opts.checksum, "config-checksum", "", "checksum of configuration file, e.g. sha256:<hex>")
+	flags.BoolVar(&opts.strict, "config-strict", opts.strict, "reject fields which are unknown to the configuration")
+	flags.StringVar(&opts.profile, "config-profile", "", "active configuration profiles separated by commas")
+	flags.Var(&opts.overrides, "config-set", "override configuration field, e.g. server.adminConnectors[0].addr=:9091")
+	if err := flags.Parse(bootstrap.Arguments[1:]); err != nil {
+		return nil, fmt.Errorf("configuration: %v", err)
+	}
+	if flags.NArg() < 1 {
+		return nil, fmt.Errorf("configuration: no file specified in command arguments")
+	}
+	if opts.checksum != "" && flags.NArg() > 1 {
+		return nil, fmt.Errorf("configuration: flag -config-checksum requires only one file")
+	}
+	if err := f.unmarshal(flags.Args(), &opts, f.ref); err != nil {
 		return nil, fmt.Errorf("configuration: %v", err)
 	}
 	return f.ref, nil
 }
 
-// unmarshal decodes the given file to output type.
-func (f *Factory) unmarshal(path string, output interface{}) error {
-	ext := filepath.Ext(path)
-	decoder := f.decoders[ext]
-	if decoder == nil {
-		return fmt.Errorf("unsupported file extention %s", ext)
+// ReloadConfiguration parses configuration file again into a new value of the
+// configuration type, initialized with the defaults given to NewFactory, so
+// that the current configuration is unchanged.
+func (f *Factory) ReloadConfiguration(bootstrap *core.Bootstrap) (interface{}, error) {
+	t := reflect.TypeOf(f.ref)
+	if t == nil || t.Kind() != reflect.Ptr {
+		return nil, fmt.Errorf("configuration: could not reload configuration of type %T", f.ref)
+	}
+	factory := *f
+	ref := reflect.New(t.Elem())
+	if f.defaults.IsValid() {
+		ref.Elem().Set(f.defaults)
+	}
+	factory.ref = ref.Interface()
+	factory.sections = f.newSections()
+	return factory.BuildConfiguration(bootstrap)
+}
+
+// loadOptions are options of loading configuration, most of them are given in
+// command line flags.
+type loadOptions struct {
+	// provider is from core.Bootstrap and can be nil.
+	provider core.ConfigurationSourceProvider
+
+	// format is the file extension if not specified.
+	format    string
+	checksum  string
+	strict    bool
+	profile   string
+	overrides overrideFlag
+}
+
+// unmarshal decodes and merges the given files, URLs or standard input to
+// output type, then applies environment variables and flag overrides.
+func (f *Factory) unmarshal(paths []string, opts *loadOptions, output interface{}) error {
+	l := &loader{
+		factory:  f,
+		provider: opts.provider,
+		typ:      reflect.TypeOf(output),
+		loading:  make(map[string]bool),
+		found:    make(map[string]bool),
+	}
+	if l.provider == nil {
+		l.provider = defaultProvider
+	}
+	if opts.profile != "" {
+		l.profiles = parseProfiles(opts.profile)
+	} else {
+		l.profiles = parseProfiles(os.Getenv(ProfileEnv))
+	}
+	data := make(map[string]interface{})
+	for _, p := range paths {
+		if err := l.load(data, p, opts.format, opts.checksum); err != nil {
+			return err
+		}
+	}
+	if opts.profile != "" {
+		for _, name := range l.profiles {
+			if !l.found[name] {
+				return fmt.Errorf("profile %s is not defined", name)
+			}
+		}
+	}
+	if f.envPrefix != "" {
+		if err := applyOverrides(data, l.typ, f.sectionType, envOverrides(f.envPrefix), true); err != nil {
+			return err
+		}
 	}
-	file, err := os.Open(path)
+	overrides := make([]override, 0, len(opts.overrides))
+	for _, s := range opts.overrides {
+		o, err := parseOverride(s)
+		if err != nil {
+			return err
+		}
+		overrides = append(overrides, o)
+	}
+	if err := applyOverrides(data, l.typ, f.sectionType, overrides, false); err != nil {
+		return err
+	}
+	if err := interpolate(data); err != nil {
+		return err
+	}
+	decrypter, err := f.getDecrypter()
 	if err != nil {
 		return err
 	}
-	defer file.Close()
-	return decoder(file, output)
+	if len(f.secrets) > 0 || decrypter != nil {
+		if err = f.configureSecrets(data, l.typ, decrypter); err != nil {
+			return err
+		}
+	}
+	if _, err = f.resolveSecrets(data, "", decrypter); err != nil {
+		return err
+	}
+	sections := f.sectionsOf(data)
+	if err = decodeMap(withoutKeys(data, sections), output); err != nil {
+		return err
+	}
+	if err = decodeSections(data, sections); err != nil {
+		return err
+	}
+	if opts.strict {
+		return checkUnknownKeys(data, output, sections)
+	}
+	return nil
 }
 
 func unmarshalJSON(r io.Reader, output interface{}) error {
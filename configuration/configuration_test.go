@@ -96,3 +96,64 @@ func testFactory(t *testing.T, bootstrap *core.Bootstrap) {
 		t.Fatalf("invalid Metrics: %+v", config.Metrics)
 	}
 }
+
+func TestConfigFormat(t *testing.T) {
+	bootstrap := core.Bootstrap{
+		Arguments: []string{"server", "--config-format=JSON"},
+	}
+	factory := NewFactory(&configuration{})
+	_, err := factory.BuildConfiguration(&bootstrap)
+	if err == nil || err.Error() != "configuration: no file specified in command arguments" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	bootstrap.Arguments = append(bootstrap.Arguments, "configuration_test.json")
+	testFactory(t, &bootstrap)
+	bootstrap.Arguments = []string{"server", "-config-format=xml", "configuration_test.json"}
+	_, err = factory.BuildConfiguration(&bootstrap)
+	if err == nil || err.Error() != "configuration: unsupported file extention .xml" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestReloadConfiguration(t *testing.T) {
+	bootstrap := core.Bootstrap{
+		Arguments: []string{"server", "configuration_test.json"},
+	}
+	factory := NewFactory(&configuration{})
+	c1, err := factory.BuildConfiguration(&bootstrap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2, err := factory.ReloadConfiguration(&bootstrap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c1 == c2 || c2.(*configuration).Metrics.Frequency != "1s" {
+		t.Fatalf("unexpected configuration: %p %+v", c1, c2)
+	}
+}
+
+type defaultConfiguration struct {
+	configuration
+	Name string
+}
+
+func TestReloadConfigurationWithDefaults(t *testing.T) {
+	bootstrap := core.Bootstrap{
+		Arguments: []string{"server", "configuration_test.json"},
+	}
+	factory := NewFactory(&defaultConfiguration{Name: "melon"})
+	c1, err := factory.BuildConfiguration(&bootstrap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c1.(*defaultConfiguration).Name = "changed"
+	c2, err := factory.ReloadConfiguration(&bootstrap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := c2.(*defaultConfiguration)
+	if c.Name != "melon" || c.Metrics.Frequency != "1s" {
+		t.Fatalf("unexpected configuration: %+v", c)
+	}
+}
@@ -0,0 +1,265 @@
+/*
+Package consul provides a configuration source provider for Consul KV store.
+
+Configuration sources in form of consul://<key> are read from the key of
+Consul KV store, e.g.:
+
+	./app server consul://app/config.yaml
+
+Address and token of the Consul agent are read from environment variables
+CONSUL_HTTP_ADDR and CONSUL_HTTP_TOKEN unless given in options. With option
+WithWatch, the keys read on startup are watched with blocking queries and the
+configuration is reloaded whenever they change. Other sources are opened by
+the provider previously set in core.Bootstrap.
+*/
+package consul
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goburrow/melon/configuration"
+	"github.com/goburrow/melon/core"
+)
+
+// Scheme is the URL scheme of configuration sources in Consul.
+const Scheme = "consul"
+
+const (
+	defaultAddress = "http://127.0.0.1:8500"
+	defaultTimeout = 10 * time.Second
+	defaultWait    = 5 * time.Minute
+	retryInterval  = 5 * time.Second
+)
+
+// provider reads configuration from Consul KV HTTP API.
+type provider struct {
+	address  string
+	token    string
+	client   *http.Client
+	watch    bool
+	wait     time.Duration
+	retry    time.Duration
+	fallback core.ConfigurationSourceProvider
+
+	mu sync.Mutex
+	// indexes contains X-Consul-Index of the keys read.
+	indexes map[string]uint64
+	// watcher is the running watch, or nil if keys are not watched.
+	watcher *watcher
+}
+
+// watcher watches keys read by the provider, including those read after it
+// is started, e.g. by new includes when configuration is reloaded.
+type watcher struct {
+	ctx     context.Context
+	changed func()
+	keys    map[string]bool
+}
+
+// Option adds option for the provider.
+type Option func(p *provider)
+
+// WithAddress sets address of the Consul agent.
+func WithAddress(address string) Option {
+	return func(p *provider) {
+		p.address = address
+	}
+}
+
+// WithToken sets ACL token to read keys.
+func WithToken(token string) Option {
+	return func(p *provider) {
+		p.token = token
+	}
+}
+
+// WithClient sets HTTP client to connect to the Consul agent.
+func WithClient(client *http.Client) Option {
+	return func(p *provider) {
+		p.client = client
+	}
+}
+
+// WithWatch sets whether keys are watched for changes to reload
+// configuration.
+func WithWatch(watch bool) Option {
+	return func(p *provider) {
+		p.watch = watch
+	}
+}
+
+// WithFallback sets provider of sources which are not in Consul.
+func WithFallback(fallback core.ConfigurationSourceProvider) Option {
+	return func(p *provider) {
+		p.fallback = fallback
+	}
+}
+
+// NewProvider returns a core.ConfigurationSourceProvider for Consul keys.
+func NewProvider(options ...Option) core.ConfigurationSourceProvider {
+	p := &provider{
+		address: os.Getenv("CONSUL_HTTP_ADDR"),
+		token:   os.Getenv("CONSUL_HTTP_TOKEN"),
+		client:  &http.Client{},
+		wait:    defaultWait,
+		retry:   retryInterval,
+		indexes: make(map[string]uint64),
+	}
+	for _, opt := range options {
+		opt(p)
+	}
+	if p.address == "" {
+		p.address = defaultAddress
+	} else if !strings.Contains(p.address, "://") {
+		p.address = "http://" + p.address
+	}
+	if p.fallback == nil {
+		p.fallback = configuration.NewDefaultSourceProvider()
+	}
+	return p
+}
+
+// Open reads value of the key if path is in form of consul://<key>.
+func (p *provider) Open(path string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(path, Scheme+"://") {
+		return p.fallback.Open(path)
+	}
+	key := strings.TrimPrefix(path, Scheme+"://")
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	value, index, err := p.get(ctx, key, 0)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	p.indexes[key] = index
+	p.watchKeyLocked(key, index)
+	p.mu.Unlock()
+	return ioutil.NopCloser(bytes.NewReader(value)), nil
+}
+
+// WatchConfiguration calls changed when any of the keys read is modified
+// until stop is closed.
+func (p *provider) WatchConfiguration(changed func(), stop <-chan struct{}) {
+	if !p.watch {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p.mu.Lock()
+	p.watcher = &watcher{ctx: ctx, changed: changed, keys: make(map[string]bool)}
+	for key, index := range p.indexes {
+		p.watchKeyLocked(key, index)
+	}
+	p.mu.Unlock()
+	<-stop
+	p.mu.Lock()
+	p.watcher = nil
+	p.mu.Unlock()
+}
+
+// watchKeyLocked starts watching key if it is not watched yet. p.mu must be
+// held.
+func (p *provider) watchKeyLocked(key string, index uint64) {
+	w := p.watcher
+	if w == nil || w.keys[key] {
+		return
+	}
+	w.keys[key] = true
+	go p.watchKey(w.ctx, key, index, w.changed)
+}
+
+func (p *provider) watchKey(ctx context.Context, key string, index uint64, changed func()) {
+	for {
+		_, newIndex, err := p.get(ctx, key, index)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			logger().Warnf("could not watch %s://%s: %v", Scheme, key, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(p.retry):
+			}
+			continue
+		}
+		if newIndex < index {
+			// Index went backwards, e.g. Consul was restored from snapshot.
+			// Block on the new index instead of reloading.
+			index = newIndex
+			continue
+		}
+		if newIndex != index {
+			index = newIndex
+			changed()
+		}
+	}
+}
+
+// get reads value of key. It is a blocking query if index is not zero.
+func (p *provider) get(ctx context.Context, key string, index uint64) ([]byte, uint64, error) {
+	query := url.Values{"raw": {""}}
+	if index > 0 {
+		query.Set("index", strconv.FormatUint(index, 10))
+		query.Set("wait", strconv.Itoa(int(p.wait/time.Second))+"s")
+	}
+	u := strings.TrimRight(p.address, "/") + "/v1/kv/" + strings.TrimLeft(key, "/") + "?" + query.Encode()
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if p.token != "" {
+		req.Header.Set("X-Consul-Token", p.token)
+	}
+	resp, err := p.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, 0, fmt.Errorf("consul: key %s not found", key)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("consul: could not read key %s: %s", key, resp.Status)
+	}
+	value, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	newIndex, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	return value, newIndex, nil
+}
+
+type bundle struct {
+	options []Option
+}
+
+func (b *bundle) Initialize(bootstrap *core.Bootstrap) {
+	options := append([]Option{WithFallback(bootstrap.ConfigurationSourceProvider)}, b.options...)
+	bootstrap.ConfigurationSourceProvider = NewProvider(options...)
+}
+
+func (b *bundle) Run(config interface{}, env *core.Environment) error {
+	return nil
+}
+
+// NewBundle creates a Bundle that reads configuration sources from Consul.
+func NewBundle(options ...Option) core.Bundle {
+	return &bundle{options}
+}
+
+func logger() core.Logger {
+	return core.GetLogger("melon/configuration")
+}
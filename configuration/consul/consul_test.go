@@ -0,0 +1,179 @@
+package consul
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/goburrow/melon/configuration"
+	"github.com/goburrow/melon/core"
+)
+
+// testAgent is a Consul KV store supporting blocking queries.
+type testAgent struct {
+	mu      sync.Mutex
+	index   uint64
+	value   string
+	changed chan struct{}
+}
+
+func (a *testAgent) set(value string) {
+	a.mu.Lock()
+	a.index++
+	a.value = value
+	close(a.changed)
+	a.changed = make(chan struct{})
+	a.mu.Unlock()
+}
+
+// restore sets index of the store backwards like restoring from a snapshot.
+func (a *testAgent) restore(index uint64) {
+	a.mu.Lock()
+	a.index = index
+	close(a.changed)
+	a.changed = make(chan struct{})
+	a.mu.Unlock()
+}
+
+func (a *testAgent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, "/v1/kv/app/config") || r.Header.Get("X-Consul-Token") != "token" {
+		http.NotFound(w, r)
+		return
+	}
+	a.mu.Lock()
+	index, changed := a.index, a.changed
+	a.mu.Unlock()
+	if r.URL.Query().Get("index") == strconv.FormatUint(index, 10) {
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	w.Header().Set("X-Consul-Index", strconv.FormatUint(a.index, 10))
+	w.Write([]byte(a.value))
+}
+
+func TestProvider(t *testing.T) {
+	agent := &testAgent{index: 1, value: `{"logging":{"level":"INFO"}}`, changed: make(chan struct{})}
+	server := httptest.NewServer(agent)
+	defer server.Close()
+
+	bootstrap := core.Bootstrap{
+		ConfigurationSourceProvider: configuration.NewBytesSourceProvider(map[string][]byte{
+			"local.json": []byte(`{}`),
+		}),
+	}
+	NewBundle(WithAddress(server.URL), WithToken("token"), WithWatch(true)).Initialize(&bootstrap)
+	p := bootstrap.ConfigurationSourceProvider.(*provider)
+
+	r, err := p.Open("consul://app/config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadAll(r)
+	if string(b) != agent.value {
+		t.Fatalf("unexpected value: %s", b)
+	}
+	if _, err = p.Open("consul://app/none.json"); err == nil || err.Error() != "consul: key app/none.json not found" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err = p.Open("local.json"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	changed := make(chan struct{}, 1)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		p.WatchConfiguration(func() { changed <- struct{}{} }, stop)
+		close(done)
+	}()
+	agent.set(`{"logging":{"level":"DEBUG"}}`)
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("change is not notified")
+	}
+	close(stop)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("watch is not stopped")
+	}
+}
+
+func TestWatchReload(t *testing.T) {
+	agent := &testAgent{index: 5, value: `{}`, changed: make(chan struct{})}
+	server := httptest.NewServer(agent)
+	defer server.Close()
+
+	p := NewProvider(WithAddress(server.URL), WithToken("token"), WithWatch(true)).(*provider)
+	changed := make(chan struct{}, 10)
+	stop := make(chan struct{})
+	defer close(stop)
+	go p.WatchConfiguration(func() { changed <- struct{}{} }, stop)
+	// Wait until watch is started.
+	for {
+		p.mu.Lock()
+		started := p.watcher != nil
+		p.mu.Unlock()
+		if started {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// Key read when configuration is reloaded.
+	if _, err := p.Open("consul://app/config-include.json"); err != nil {
+		t.Fatal(err)
+	}
+	agent.set(`{"a":1}`)
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("change is not notified")
+	}
+
+	agent.restore(2)
+	select {
+	case <-changed:
+		t.Fatal("unexpected change after index reset")
+	case <-time.After(50 * time.Millisecond):
+	}
+	agent.set(`{"a":2}`)
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("change is not notified")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	agent := &testAgent{index: 1, value: `{"logging":{"level":"WARN"}}`, changed: make(chan struct{})}
+	server := httptest.NewServer(agent)
+	defer server.Close()
+
+	type config struct {
+		Logging struct {
+			Level string
+		}
+	}
+	bootstrap := core.Bootstrap{
+		Arguments: []string{"server", "consul://app/config.json"},
+	}
+	NewBundle(WithAddress(strings.TrimPrefix(server.URL, "http://")), WithToken("token")).Initialize(&bootstrap)
+	c, err := configuration.NewFactory(&config{}).BuildConfiguration(&bootstrap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.(*config).Logging.Level != "WARN" {
+		t.Fatalf("unexpected configuration: %+v", c)
+	}
+}
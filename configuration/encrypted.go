@@ -0,0 +1,120 @@
+package configuration
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	// EncryptedPrefix is the prefix of encrypted configuration values. The
+	// rest of the value is the base64 encoded ciphertext.
+	EncryptedPrefix = "enc:"
+	// KeyEnv is the environment variable of the base64 encoded AES key used
+	// to decrypt values when no Decrypter is set.
+	KeyEnv = "MELON_CONFIG_KEY"
+)
+
+// Decrypter decrypts encrypted configuration values.
+type Decrypter interface {
+	Decrypt(ciphertext []byte) ([]byte, error)
+}
+
+// SetDecrypter sets decrypter for configuration values in form of
+// enc:<base64 ciphertext>. If no decrypter is set, values are decrypted with
+// AES-GCM key given in environment variable MELON_CONFIG_KEY.
+// A Decrypter which also implements SecretConfigurer is configured with
+// block SecretsKey of configuration.
+func (f *Factory) SetDecrypter(decrypter Decrypter) {
+	f.decrypter = decrypter
+}
+
+// getDecrypter returns decrypter of the factory or the one created from
+// environment variable KeyEnv. It returns nil if there is neither.
+func (f *Factory) getDecrypter() (Decrypter, error) {
+	if f.decrypter != nil {
+		return f.decrypter, nil
+	}
+	s := os.Getenv(KeyEnv)
+	if s == "" {
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", KeyEnv, err)
+	}
+	d, err := NewKeyDecrypter(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", KeyEnv, err)
+	}
+	return d, nil
+}
+
+// decrypt returns plain text of value enc:<base64 ciphertext>.
+func decrypt(decrypter Decrypter, value string) (string, error) {
+	if decrypter == nil {
+		return "", fmt.Errorf("no decryption key, environment variable %s is not set", KeyEnv)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, EncryptedPrefix))
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := decrypter.Decrypt(ciphertext)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// keyDecrypter decrypts values with AES-GCM. Ciphertext is prefixed by
+// the nonce.
+type keyDecrypter struct {
+	aead cipher.AEAD
+}
+
+// NewKeyDecrypter returns a Decrypter using AES-GCM with the given 16, 24 or
+// 32-byte key.
+func NewKeyDecrypter(key []byte) (Decrypter, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &keyDecrypter{aead}, nil
+}
+
+func (d *keyDecrypter) Decrypt(ciphertext []byte) ([]byte, error) {
+	n := d.aead.NonceSize()
+	if len(ciphertext) < n {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	return d.aead.Open(nil, ciphertext[:n], ciphertext[n:], nil)
+}
+
+// Encrypt encrypts plaintext with AES-GCM key and returns the configuration
+// value in form of enc:<base64 ciphertext> which can be decrypted by
+// NewKeyDecrypter with the same key.
+func Encrypt(key []byte, plaintext string) (string, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	ciphertext := aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return EncryptedPrefix + base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,99 @@
+package configuration
+
+import (
+	"encoding/base64"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/goburrow/melon/core"
+)
+
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+func TestEncrypt(t *testing.T) {
+	value, err := Encrypt(testKey, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(value, EncryptedPrefix) {
+		t.Fatalf("unexpected value: %v", value)
+	}
+	d, err := NewKeyDecrypter(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, err := decrypt(d, value)
+	if err != nil || plaintext != "secret" {
+		t.Fatalf("unexpected plaintext: %v %v", plaintext, err)
+	}
+	d, _ = NewKeyDecrypter([]byte("fedcba9876543210"))
+	if _, err = decrypt(d, value); err == nil {
+		t.Fatalf("error must be thrown")
+	}
+	if _, err = Encrypt([]byte("short"), "secret"); err == nil {
+		t.Fatalf("error must be thrown")
+	}
+}
+
+func loadEncrypted(factory *Factory, addr string) (*configuration, error) {
+	orig := stdin
+	defer func() { stdin = orig }()
+	stdin = strings.NewReader(`{"server":{"applicationConnectors":[{"addr":"` + addr + `"}]}}`)
+	bootstrap := core.Bootstrap{
+		Arguments: []string{"server", "-config-format=json", "-"},
+	}
+	c, err := factory.BuildConfiguration(&bootstrap)
+	if err != nil {
+		return nil, err
+	}
+	return c.(*configuration), nil
+}
+
+func TestLoadEncrypted(t *testing.T) {
+	value, err := Encrypt(testKey, ":8443")
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig, ok := os.LookupEnv(KeyEnv)
+	defer func() {
+		if ok {
+			os.Setenv(KeyEnv, orig)
+		} else {
+			os.Unsetenv(KeyEnv)
+		}
+	}()
+
+	os.Unsetenv(KeyEnv)
+	_, err = loadEncrypted(NewFactory(&configuration{}), value)
+	if err == nil || !strings.Contains(err.Error(), "could not decrypt server.applicationConnectors[0].addr") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	os.Setenv(KeyEnv, base64.StdEncoding.EncodeToString(testKey))
+	c, err := loadEncrypted(NewFactory(&configuration{}), value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Server.ApplicationConnectors[0].Addr != ":8443" {
+		t.Fatalf("unexpected configuration: %+v", c.Server)
+	}
+
+	os.Setenv(KeyEnv, "invalid")
+	_, err = loadEncrypted(NewFactory(&configuration{}), value)
+	if err == nil || !strings.Contains(err.Error(), "invalid "+KeyEnv) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Decrypter set explicitly takes precedence over environment variable.
+	d, _ := NewKeyDecrypter(testKey)
+	factory := NewFactory(&configuration{})
+	factory.SetDecrypter(d)
+	c, err = loadEncrypted(factory, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Server.ApplicationConnectors[0].Addr != ":8443" {
+		t.Fatalf("unexpected configuration: %+v", c.Server)
+	}
+}
@@ -0,0 +1,356 @@
+/*
+Package etcd provides a configuration source provider for etcd.
+
+Configuration sources in form of etcd://<key> are read from the key using
+etcd v3 JSON gateway, e.g. for key /app/config.yaml:
+
+	./app server etcd:///app/config.yaml
+
+Address of etcd is the first endpoint of environment variable
+ETCDCTL_ENDPOINTS unless given in options. With option WithWatch, the keys
+read on startup are watched and the configuration is reloaded whenever they
+change. Other sources are opened by the provider previously set in
+core.Bootstrap.
+*/
+package etcd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goburrow/melon/configuration"
+	"github.com/goburrow/melon/core"
+)
+
+// Scheme is the URL scheme of configuration sources in etcd.
+const Scheme = "etcd"
+
+const (
+	defaultAddress = "http://127.0.0.1:2379"
+	defaultTimeout = 10 * time.Second
+	retryInterval  = 5 * time.Second
+)
+
+// provider reads configuration from etcd v3 JSON gateway.
+type provider struct {
+	address  string
+	username string
+	password string
+	client   *http.Client
+	watch    bool
+	retry    time.Duration
+	fallback core.ConfigurationSourceProvider
+
+	mu    sync.Mutex
+	token string
+	// revisions contains revisions of etcd when the keys were read.
+	revisions map[string]int64
+	// watcher is the running watch, or nil if keys are not watched.
+	watcher *watcher
+}
+
+// watcher watches keys read by the provider, including those read after it
+// is started, e.g. by new includes when configuration is reloaded.
+type watcher struct {
+	ctx     context.Context
+	changed func()
+	keys    map[string]bool
+}
+
+// Option adds option for the provider.
+type Option func(p *provider)
+
+// WithAddress sets address of etcd.
+func WithAddress(address string) Option {
+	return func(p *provider) {
+		p.address = address
+	}
+}
+
+// WithCredentials sets user name and password to authenticate with etcd.
+func WithCredentials(username, password string) Option {
+	return func(p *provider) {
+		p.username = username
+		p.password = password
+	}
+}
+
+// WithClient sets HTTP client to connect to etcd.
+func WithClient(client *http.Client) Option {
+	return func(p *provider) {
+		p.client = client
+	}
+}
+
+// WithWatch sets whether keys are watched for changes to reload
+// configuration.
+func WithWatch(watch bool) Option {
+	return func(p *provider) {
+		p.watch = watch
+	}
+}
+
+// WithFallback sets provider of sources which are not in etcd.
+func WithFallback(fallback core.ConfigurationSourceProvider) Option {
+	return func(p *provider) {
+		p.fallback = fallback
+	}
+}
+
+// NewProvider returns a core.ConfigurationSourceProvider for etcd keys.
+func NewProvider(options ...Option) core.ConfigurationSourceProvider {
+	p := &provider{
+		client:    &http.Client{},
+		retry:     retryInterval,
+		revisions: make(map[string]int64),
+	}
+	if endpoints := os.Getenv("ETCDCTL_ENDPOINTS"); endpoints != "" {
+		p.address = strings.Split(endpoints, ",")[0]
+	}
+	for _, opt := range options {
+		opt(p)
+	}
+	if p.address == "" {
+		p.address = defaultAddress
+	} else if !strings.Contains(p.address, "://") {
+		p.address = "http://" + p.address
+	}
+	if p.fallback == nil {
+		p.fallback = configuration.NewDefaultSourceProvider()
+	}
+	return p
+}
+
+type responseHeader struct {
+	Revision int64 `json:"revision,string"`
+}
+
+// Open reads value of the key if path is in form of etcd://<key>.
+func (p *provider) Open(path string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(path, Scheme+"://") {
+		return p.fallback.Open(path)
+	}
+	key := strings.TrimPrefix(path, Scheme+"://")
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	var resp struct {
+		Header responseHeader `json:"header"`
+		KVs    []struct {
+			Value []byte `json:"value"`
+		} `json:"kvs"`
+	}
+	err := p.call(ctx, "/v3/kv/range", map[string]interface{}{"key": []byte(key)}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.KVs) == 0 {
+		return nil, fmt.Errorf("etcd: key %s not found", key)
+	}
+	p.mu.Lock()
+	p.revisions[key] = resp.Header.Revision
+	p.watchKeyLocked(key, resp.Header.Revision)
+	p.mu.Unlock()
+	return ioutil.NopCloser(bytes.NewReader(resp.KVs[0].Value)), nil
+}
+
+// WatchConfiguration calls changed when any of the keys read is modified
+// until stop is closed.
+func (p *provider) WatchConfiguration(changed func(), stop <-chan struct{}) {
+	if !p.watch {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p.mu.Lock()
+	p.watcher = &watcher{ctx: ctx, changed: changed, keys: make(map[string]bool)}
+	for key, revision := range p.revisions {
+		p.watchKeyLocked(key, revision)
+	}
+	p.mu.Unlock()
+	<-stop
+	p.mu.Lock()
+	p.watcher = nil
+	p.mu.Unlock()
+}
+
+// watchKeyLocked starts watching changes of key after revision if it is not
+// watched yet. p.mu must be held.
+func (p *provider) watchKeyLocked(key string, revision int64) {
+	w := p.watcher
+	if w == nil || w.keys[key] {
+		return
+	}
+	w.keys[key] = true
+	go p.watchKey(w.ctx, key, revision+1, w.changed)
+}
+
+func (p *provider) watchKey(ctx context.Context, key string, revision int64, changed func()) {
+	for {
+		err := p.watchStream(ctx, key, &revision, changed)
+		if ctx.Err() != nil {
+			return
+		}
+		logger().Warnf("could not watch %s://%s: %v", Scheme, key, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(p.retry):
+		}
+	}
+}
+
+// watchStream reads watch responses of key from revision and updates it
+// with revision of the changes.
+func (p *provider) watchStream(ctx context.Context, key string, revision *int64, changed func()) error {
+	input := map[string]interface{}{
+		"create_request": map[string]interface{}{
+			"key":            []byte(key),
+			"start_revision": strconv.FormatInt(*revision, 10),
+		},
+	}
+	resp, err := p.post(ctx, "/v3/watch", input)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var msg struct {
+			Result struct {
+				Header          responseHeader    `json:"header"`
+				Events          []json.RawMessage `json:"events"`
+				Canceled        bool              `json:"canceled"`
+				CancelReason    string            `json:"cancel_reason"`
+				CompactRevision int64             `json:"compact_revision,string"`
+			} `json:"result"`
+		}
+		if err = decoder.Decode(&msg); err != nil {
+			return err
+		}
+		if msg.Result.Canceled {
+			if msg.Result.CompactRevision > 0 {
+				// Changes may have been compacted.
+				*revision = msg.Result.CompactRevision
+				changed()
+			}
+			return fmt.Errorf("watch canceled: %s", msg.Result.CancelReason)
+		}
+		if len(msg.Result.Events) > 0 {
+			*revision = msg.Result.Header.Revision + 1
+			changed()
+		}
+	}
+}
+
+// call posts input to etcd and decodes the response to output.
+func (p *provider) call(ctx context.Context, path string, input, output interface{}) error {
+	resp, err := p.post(ctx, path, input)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(output)
+}
+
+func (p *provider) post(ctx context.Context, path string, input interface{}) (*http.Response, error) {
+	token, err := p.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.do(ctx, path, input, token)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		data, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusUnauthorized && token != "" {
+			// Token expired.
+			p.mu.Lock()
+			p.token = ""
+			p.mu.Unlock()
+		}
+		return nil, fmt.Errorf("etcd: %s failed: %s %s", path, resp.Status, bytes.TrimSpace(data))
+	}
+	return resp, nil
+}
+
+// authenticate returns token if credentials are given.
+func (p *provider) authenticate(ctx context.Context) (string, error) {
+	if p.username == "" {
+		return "", nil
+	}
+	p.mu.Lock()
+	token := p.token
+	p.mu.Unlock()
+	if token != "" {
+		return token, nil
+	}
+	resp, err := p.do(ctx, "/v3/auth/authenticate",
+		map[string]string{"name": p.username, "password": p.password}, "")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("etcd: could not authenticate %s: %s", p.username, resp.Status)
+	}
+	var result struct {
+		Token string `json:"token"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	p.mu.Lock()
+	p.token = result.Token
+	p.mu.Unlock()
+	return result.Token, nil
+}
+
+func (p *provider) do(ctx context.Context, path string, input interface{}, token string) (*http.Response, error) {
+	body, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", strings.TrimRight(p.address, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	return p.client.Do(req.WithContext(ctx))
+}
+
+type bundle struct {
+	options []Option
+}
+
+func (b *bundle) Initialize(bootstrap *core.Bootstrap) {
+	options := append([]Option{WithFallback(bootstrap.ConfigurationSourceProvider)}, b.options...)
+	bootstrap.ConfigurationSourceProvider = NewProvider(options...)
+}
+
+func (b *bundle) Run(config interface{}, env *core.Environment) error {
+	return nil
+}
+
+// NewBundle creates a Bundle that reads configuration sources from etcd.
+func NewBundle(options ...Option) core.Bundle {
+	return &bundle{options}
+}
+
+func logger() core.Logger {
+	return core.GetLogger("melon/configuration")
+}
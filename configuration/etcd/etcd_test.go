@@ -0,0 +1,99 @@
+package etcd
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/goburrow/melon/core"
+)
+
+func TestProvider(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString([]byte("/app/config.json"))
+	events := make(chan string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var input map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&input)
+		switch r.URL.Path {
+		case "/v3/auth/authenticate":
+			if input["name"] != "melon" || input["password"] != "secret" {
+				http.Error(w, "invalid credentials", http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"token":"token"}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "token" {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v3/kv/range":
+			if input["key"] != key {
+				w.Write([]byte(`{"header":{"revision":"10"}}`))
+				return
+			}
+			value := base64.StdEncoding.EncodeToString([]byte(`{"logging":{"level":"INFO"}}`))
+			w.Write([]byte(`{"header":{"revision":"10"},"kvs":[{"key":"` + key + `","value":"` + value + `"}]}`))
+		case "/v3/watch":
+			req := input["create_request"].(map[string]interface{})
+			if req["key"] != key || req["start_revision"] != "11" {
+				http.Error(w, "invalid request", http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"result":{"header":{"revision":"10"},"created":true}}` + "\n"))
+			w.(http.Flusher).Flush()
+			select {
+			case e := <-events:
+				w.Write([]byte(e + "\n"))
+				w.(http.Flusher).Flush()
+			case <-r.Context().Done():
+				return
+			}
+			<-r.Context().Done()
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	var bootstrap core.Bootstrap
+	NewBundle(WithAddress(server.URL), WithCredentials("melon", "secret"), WithWatch(true)).Initialize(&bootstrap)
+	p := bootstrap.ConfigurationSourceProvider.(*provider)
+
+	r, err := p.Open("etcd:///app/config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadAll(r)
+	if string(b) != `{"logging":{"level":"INFO"}}` {
+		t.Fatalf("unexpected value: %s", b)
+	}
+	if _, err = p.Open("etcd:///app/none.json"); err == nil || err.Error() != "etcd: key /app/none.json not found" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	changed := make(chan struct{}, 1)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		p.WatchConfiguration(func() { changed <- struct{}{} }, stop)
+		close(done)
+	}()
+	events <- `{"result":{"header":{"revision":"11"},"events":[{"kv":{"key":"` + key + `"}}]}}`
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("change is not notified")
+	}
+	close(stop)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("watch is not stopped")
+	}
+}
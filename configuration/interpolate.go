@@ -0,0 +1,157 @@
+package configuration
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// interpolator replaces references to other values in form of ${path}, e.g.
+// ${server.applicationConnectors[0].addr}, in string values of configuration.
+// A value which is only a reference takes type of the referred value. $${ is
+// replaced by ${ without interpolation.
+type interpolator struct {
+	data map[string]interface{}
+	// resolving contains paths of values being resolved to detect cycles.
+	resolving map[string]bool
+	// resolved contains paths of string values which have been replaced.
+	resolved map[string]bool
+}
+
+// interpolate resolves all references in data.
+func interpolate(data map[string]interface{}) error {
+	i := &interpolator{
+		data:      data,
+		resolving: make(map[string]bool),
+		resolved:  make(map[string]bool),
+	}
+	_, err := i.resolve(data, "")
+	return err
+}
+
+// resolve returns v with references replaced. Maps and lists are updated in
+// place.
+func (i *interpolator) resolve(v interface{}, path string) (interface{}, error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			r, err := i.resolve(e, joinPath(path, k))
+			if err != nil {
+				return nil, err
+			}
+			v[k] = r
+		}
+	case []interface{}:
+		for k, e := range v {
+			r, err := i.resolve(e, path+"["+strconv.Itoa(k)+"]")
+			if err != nil {
+				return nil, err
+			}
+			v[k] = r
+		}
+	case string:
+		if strings.Contains(v, "${") && !i.resolved[path] {
+			r, err := i.resolveString(v, path)
+			if err != nil {
+				return nil, err
+			}
+			i.resolved[path] = true
+			return r, nil
+		}
+	}
+	return v, nil
+}
+
+func (i *interpolator) resolveString(s string, path string) (interface{}, error) {
+	if i.resolving[path] {
+		return nil, fmt.Errorf("could not interpolate %s: reference cycle detected", path)
+	}
+	i.resolving[path] = true
+	defer delete(i.resolving, path)
+
+	var buf bytes.Buffer
+	for {
+		start := strings.Index(s, "${")
+		if start < 0 {
+			buf.WriteString(s)
+			break
+		}
+		if start > 0 && s[start-1] == '$' {
+			// Escaped $${
+			buf.WriteString(s[:start-1])
+			buf.WriteString("${")
+			s = s[start+2:]
+			continue
+		}
+		end := strings.IndexByte(s[start:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("could not interpolate %s: missing } in %q", path, s)
+		}
+		end += start
+		ref := s[start+2 : end]
+		v, err := i.lookup(ref)
+		if err != nil {
+			return nil, fmt.Errorf("could not interpolate %s: %v", path, err)
+		}
+		if start == 0 && end == len(s)-1 && buf.Len() == 0 {
+			// Whole value is the reference.
+			return v, nil
+		}
+		buf.WriteString(s[:start])
+		switch v := v.(type) {
+		case string:
+			buf.WriteString(v)
+		case float64:
+			buf.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
+		case bool:
+			buf.WriteString(strconv.FormatBool(v))
+		default:
+			return nil, fmt.Errorf("could not interpolate %s: ${%s} is not a string, number or boolean", path, ref)
+		}
+		s = s[end+1:]
+	}
+	return buf.String(), nil
+}
+
+// lookup returns resolved value at path ref. Keys are case-insensitive.
+func (i *interpolator) lookup(ref string) (interface{}, error) {
+	keys := splitPath(ref)
+	if containsEmpty(keys) {
+		return nil, fmt.Errorf("invalid reference ${%s}", ref)
+	}
+	var node interface{} = i.data
+	path := ""
+	// set replaces the value in its parent.
+	var set func(interface{})
+	for _, k := range keys {
+		switch n := node.(type) {
+		case map[string]interface{}:
+			key := mapKey(n, k)
+			v, ok := n[key]
+			if !ok {
+				return nil, fmt.Errorf("${%s} not found", ref)
+			}
+			node, path = v, joinPath(path, key)
+			set = func(v interface{}) { n[key] = v }
+		case []interface{}:
+			idx, err := strconv.Atoi(k)
+			if err != nil || idx < 0 || idx >= len(n) {
+				return nil, fmt.Errorf("${%s} not found", ref)
+			}
+			node, path = n[idx], path+"["+k+"]"
+			set = func(v interface{}) { n[idx] = v }
+		default:
+			return nil, fmt.Errorf("${%s} not found", ref)
+		}
+	}
+	if node == nil {
+		return nil, fmt.Errorf("${%s} is null", ref)
+	}
+	v, err := i.resolve(node, path)
+	if err != nil {
+		return nil, err
+	}
+	set(v)
+	return v, nil
+}
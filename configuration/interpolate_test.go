@@ -0,0 +1,99 @@
+package configuration
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/goburrow/melon/core"
+)
+
+func TestInterpolate(t *testing.T) {
+	var data map[string]interface{}
+	err := json.Unmarshal([]byte(`{
+		"host": "localhost",
+		"port": 8080,
+		"server": {
+			"applicationConnectors": [{"addr": "${host}:${port}"}],
+			"adminConnectors": [{"addr": "${HOST}:${server.adminPort}"}],
+			"adminPort": 8081
+		},
+		"canary": {"baseURL": "http://${server.applicationConnectors[0].addr}/"},
+		"metrics": {"port": "${port}", "enabled": "${enabled}", "tags": "${tags}"},
+		"enabled": true,
+		"tags": {"env": "${env}"},
+		"env": "prod",
+		"escaped": "$${host} $$ ${host}"
+	}`), &data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = interpolate(data); err != nil {
+		t.Fatal(err)
+	}
+	var expected map[string]interface{}
+	err = json.Unmarshal([]byte(`{
+		"host": "localhost",
+		"port": 8080,
+		"server": {
+			"applicationConnectors": [{"addr": "localhost:8080"}],
+			"adminConnectors": [{"addr": "localhost:8081"}],
+			"adminPort": 8081
+		},
+		"canary": {"baseURL": "http://localhost:8080/"},
+		"metrics": {"port": 8080, "enabled": true, "tags": {"env": "prod"}},
+		"enabled": true,
+		"tags": {"env": "prod"},
+		"env": "prod",
+		"escaped": "${host} $$ localhost"
+	}`), &expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, data) {
+		b, _ := json.Marshal(data)
+		t.Fatalf("unexpected data: %s", b)
+	}
+}
+
+func TestInterpolateErrors(t *testing.T) {
+	tests := []struct {
+		data string
+		err  string
+	}{
+		{`{"a":"${b}"}`, "could not interpolate a: ${b} not found"},
+		{`{"a":["${a[1]}","${a[0]}"]}`, "could not interpolate a[0]: could not interpolate a[1]: could not interpolate a[0]: reference cycle detected"},
+		{`{"a":"x${b}","b":{"c":1}}`, "could not interpolate a: ${b} is not a string, number or boolean"},
+		{`{"a":"${b","b":1}`, `could not interpolate a: missing } in "${b"`},
+		{`{"a":"${}"}`, "could not interpolate a: invalid reference ${}"},
+		{`{"a":"${b.c}","b":null}`, "could not interpolate a: ${b.c} not found"},
+		{`{"a":"${b[2]}","b":[1]}`, "could not interpolate a: ${b[2]} not found"},
+	}
+	for _, test := range tests {
+		var data map[string]interface{}
+		if err := json.Unmarshal([]byte(test.data), &data); err != nil {
+			t.Fatal(err)
+		}
+		err := interpolate(data)
+		if err == nil || err.Error() != test.err {
+			t.Fatalf("unexpected error of %s: %v, expected %s", test.data, err, test.err)
+		}
+	}
+}
+
+func TestLoadInterpolated(t *testing.T) {
+	bootstrap := core.Bootstrap{
+		Arguments: []string{"server", "-config-set", "logging.level=${metrics.frequency}", "app.json"},
+		ConfigurationSourceProvider: NewBytesSourceProvider(map[string][]byte{
+			"app.json": []byte(`{"server":{"adminConnectors":[{"addr":":8081"}],"applicationConnectors":[{"addr":"${server.adminConnectors[0].addr}"}]},"metrics":{"frequency":"1s"}}`),
+		}),
+	}
+	c, err := NewFactory(&configuration{}).BuildConfiguration(&bootstrap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := c.(*configuration)
+	if config.Server.ApplicationConnectors[0].Addr != ":8081" || config.Logging.Level != "1s" {
+		t.Fatalf("unexpected configuration: %+v", config)
+	}
+}
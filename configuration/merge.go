@@ -0,0 +1,165 @@
+package configuration
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/goburrow/melon/core"
+)
+
+// IncludesKey is the top-level key listing configuration files which are
+// loaded before the file declaring it. Relative paths are resolved against
+// directory of the declaring file.
+const IncludesKey = "includes"
+
+// loader reads and merges configuration sources.
+type loader struct {
+	factory  *Factory
+	provider core.ConfigurationSourceProvider
+	// loading contains sources being loaded to detect include cycles.
+	loading map[string]bool
+	// typ is type of the configuration.
+	typ reflect.Type
+	// profiles are active profiles and found contains those defined in
+	// the sources.
+	profiles []string
+	found    map[string]bool
+}
+
+// load decodes source p and its includes and merges them into data. Format
+// overrides file extension of the source if not empty.
+func (l *loader) load(data map[string]interface{}, p string, format string, checksum string) error {
+	if l.loading[p] {
+		return fmt.Errorf("include cycle detected at %s", p)
+	}
+	l.loading[p] = true
+	defer delete(l.loading, p)
+
+	ext := sourceExt(p)
+	if format != "" {
+		ext = "." + strings.TrimPrefix(strings.ToLower(format), ".")
+	}
+	decoder := l.factory.decoders[ext]
+	if decoder == nil {
+		if ext == "" {
+			return fmt.Errorf("unknown format of %s, flag -config-format is required", p)
+		}
+		return fmt.Errorf("unsupported file extention %s", ext)
+	}
+	content, err := readSource(l.provider, p)
+	if err != nil {
+		return err
+	}
+	if checksum != "" {
+		if err = verifyChecksum(content, checksum); err != nil {
+			return err
+		}
+	}
+	var m map[string]interface{}
+	if err = decoder(bytes.NewReader(content), &m); err != nil {
+		return fmt.Errorf("%s: %v", p, err)
+	}
+	includes, err := includesOf(m)
+	if err != nil {
+		return fmt.Errorf("%s: %v", p, err)
+	}
+	if err = applyProfiles(m, l.profiles, l.found); err != nil {
+		return fmt.Errorf("%s: %v", p, err)
+	}
+	for _, inc := range includes {
+		inc = resolveInclude(p, inc)
+		incFormat := ""
+		if sourceExt(inc) == "" {
+			incFormat = ext
+		}
+		if err = l.load(data, inc, incFormat, ""); err != nil {
+			return err
+		}
+	}
+	if l.factory.resolvePaths && !hasScheme(p) && p != stdinPath {
+		dir := filepath.Dir(p)
+		sections := l.factory.sectionsOf(m)
+		rest := withoutKeys(m, sections)
+		resolvePaths(rest, data, l.typ, dir)
+		for k, v := range rest {
+			m[k] = v
+		}
+		for k, ref := range sections {
+			resolvePaths(m[k], mapValue(data, k), reflect.TypeOf(ref), dir)
+		}
+	}
+	merge(data, m)
+	return nil
+}
+
+// includesOf removes and returns IncludesKey of the configuration.
+func includesOf(m map[string]interface{}) ([]string, error) {
+	v, ok := m[IncludesKey]
+	if !ok {
+		return nil, nil
+	}
+	delete(m, IncludesKey)
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must be a list of files", IncludesKey)
+	}
+	includes := make([]string, len(list))
+	for i, inc := range list {
+		s, ok := inc.(string)
+		if !ok || s == "" {
+			return nil, fmt.Errorf("invalid %s %v", IncludesKey, inc)
+		}
+		includes[i] = s
+	}
+	return includes, nil
+}
+
+// resolveInclude returns path of include inc relative to source p.
+func resolveInclude(p, inc string) string {
+	if hasScheme(inc) || filepath.IsAbs(inc) {
+		return inc
+	}
+	if hasScheme(p) {
+		base, err := url.Parse(p)
+		if err != nil {
+			return inc
+		}
+		ref, err := url.Parse(inc)
+		if err != nil {
+			return inc
+		}
+		return base.ResolveReference(ref).String()
+	}
+	if p == stdinPath {
+		return inc
+	}
+	return filepath.Join(filepath.Dir(p), inc)
+}
+
+// merge deep merges src into dst. Values of src replace those of dst except
+// maps which are merged recursively.
+func merge(dst, src map[string]interface{}) {
+	for k, v := range src {
+		if sv, ok := v.(map[string]interface{}); ok {
+			if dv, ok := dst[k].(map[string]interface{}); ok {
+				merge(dv, sv)
+				continue
+			}
+		}
+		dst[k] = v
+	}
+}
+
+// decodeMap decodes merged configuration data to output.
+func decodeMap(data map[string]interface{}, output interface{}) error {
+	content, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(content, output)
+}
@@ -0,0 +1,61 @@
+package configuration
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/goburrow/melon/core"
+)
+
+func TestIncludes(t *testing.T) {
+	bootstrap := core.Bootstrap{
+		Arguments: []string{"server", "testdata/production.json", "testdata/metrics.json"},
+	}
+	testFactory(t, &bootstrap)
+
+	bootstrap.Arguments = []string{"server", "testdata/cycle.json"}
+	_, err := NewFactory(&configuration{}).BuildConfiguration(&bootstrap)
+	if err == nil || !strings.Contains(err.Error(), "include cycle") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	dst := map[string]interface{}{
+		"a": map[string]interface{}{"b": 1.0, "c": []interface{}{1.0}},
+		"d": "x",
+	}
+	src := map[string]interface{}{
+		"a": map[string]interface{}{"c": []interface{}{2.0}, "e": true},
+		"d": map[string]interface{}{"f": "y"},
+	}
+	merge(dst, src)
+	expected := map[string]interface{}{
+		"a": map[string]interface{}{"b": 1.0, "c": []interface{}{2.0}, "e": true},
+		"d": map[string]interface{}{"f": "y"},
+	}
+	if !reflect.DeepEqual(expected, dst) {
+		t.Fatalf("unexpected merged value: %#v", dst)
+	}
+}
+
+func TestResolveInclude(t *testing.T) {
+	tests := []struct {
+		path, include, expected string
+	}{
+		{"conf/app.yaml", "base.yaml", "conf/base.yaml"},
+		{"conf/app.yaml", "/etc/base.yaml", "/etc/base.yaml"},
+		{"-", "base.yaml", "base.yaml"},
+		{"http://config/app/prod.yaml", "base.yaml", "http://config/app/base.yaml"},
+		{"conf/app.yaml", "https://config/base.yaml", "https://config/base.yaml"},
+		{"consul://app/prod.yaml", "base.yaml", "consul://app/base.yaml"},
+		{"conf/app.yaml", "etcd://app/base.yaml", "etcd://app/base.yaml"},
+	}
+	for _, test := range tests {
+		actual := resolveInclude(test.path, test.include)
+		if actual != test.expected {
+			t.Fatalf("unexpected include of %+v: %v", test, actual)
+		}
+	}
+}
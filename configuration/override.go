@@ -0,0 +1,330 @@
+package configuration
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// environ returns environment variables. It is a variable for testing.
+var environ = os.Environ
+
+// SetEnvPrefix enables overriding configuration with environment variables
+// starting with prefix and an underscore. The rest of the variable name is
+// the path of the field with underscores as separators, e.g.
+// MYAPP_SERVER_ADMINCONNECTORS_0_ADDR=:9091 with prefix "MYAPP". Names are
+// case-insensitive. Variables which do not map to any field are ignored.
+func (f *Factory) SetEnvPrefix(prefix string) {
+	f.envPrefix = prefix
+}
+
+// override is a value to set at path of configuration.
+type override struct {
+	name  string
+	path  []string
+	value string
+}
+
+// overrideFlag collects values of flag -config-set.
+type overrideFlag []string
+
+func (o *overrideFlag) String() string {
+	return strings.Join(*o, ",")
+}
+
+func (o *overrideFlag) Set(s string) error {
+	*o = append(*o, s)
+	return nil
+}
+
+// envOverrides returns overrides from environment variables with prefix.
+func envOverrides(prefix string) []override {
+	var overrides []override
+	prefix += "_"
+	for _, e := range environ() {
+		i := strings.IndexByte(e, '=')
+		if i < 0 || !strings.HasPrefix(strings.ToUpper(e[:i]), strings.ToUpper(prefix)) {
+			continue
+		}
+		name := e[:i]
+		path := strings.Split(name[len(prefix):], "_")
+		if containsEmpty(path) {
+			continue
+		}
+		overrides = append(overrides, override{name: name, path: path, value: e[i+1:]})
+	}
+	sort.Slice(overrides, func(i, j int) bool {
+		return overrides[i].name < overrides[j].name
+	})
+	return overrides
+}
+
+// parseOverride parses flag value in form of path=value, e.g.
+// server.adminConnectors[0].addr=:9091.
+func parseOverride(s string) (override, error) {
+	i := strings.IndexByte(s, '=')
+	if i <= 0 {
+		return override{}, fmt.Errorf("invalid flag -config-set %q, must be in form of path=value", s)
+	}
+	name := s[:i]
+	path := splitPath(name)
+	if containsEmpty(path) {
+		return override{}, fmt.Errorf("invalid flag -config-set %q, must be in form of path=value", s)
+	}
+	return override{name: name, path: path, value: s[i+1:]}, nil
+}
+
+// splitPath returns keys of path in form of a.b[0].c.
+func splitPath(p string) []string {
+	p = strings.Replace(strings.Replace(p, "[", ".", -1), "]", "", -1)
+	return strings.Split(p, ".")
+}
+
+func containsEmpty(path []string) bool {
+	for _, p := range path {
+		if p == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// errUnknownField is returned when override path does not map to a field.
+type errUnknownField string
+
+func (e errUnknownField) Error() string {
+	return "unknown field " + string(e)
+}
+
+// applyOverrides sets values of overrides in data. Type t of configuration
+// decides field names and value types, while sectionType, if not nil, returns
+// type of top-level sections. Unknown fields are ignored if ignoreUnknown is
+// true.
+func applyOverrides(data map[string]interface{}, t reflect.Type, sectionType func(string) reflect.Type,
+	overrides []override, ignoreUnknown bool) error {
+	for _, o := range overrides {
+		var err error
+		if st := sectionOf(sectionType, o.path[0]); st != nil {
+			key := mapKey(data, o.path[0])
+			var v interface{}
+			if v, err = setPath(data[key], st, o.path[1:], o.value); err == nil {
+				data[key] = v
+			}
+		} else {
+			_, err = setPath(data, t, o.path, o.value)
+		}
+		if err != nil {
+			if _, ok := err.(errUnknownField); ok && ignoreUnknown {
+				continue
+			}
+			return fmt.Errorf("could not set %s: %v", o.name, err)
+		}
+	}
+	return nil
+}
+
+// setPath returns node with value set at path. Type t is nil when it is not
+// known, e.g. value of a dynamic type.
+func setPath(node interface{}, t reflect.Type, path []string, value string) (interface{}, error) {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t != nil && t.Kind() == reflect.Struct && reflect.PtrTo(t).Implements(valuerType) {
+		// Type of configuration union is given in its key "type".
+		if len(path) == 1 && strings.EqualFold(path[0], TypeKey) {
+			return setUntyped(node, path, value)
+		}
+		t = dynamicType(t, node)
+		for t != nil && t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+	}
+	if t != nil && (t.Kind() == reflect.Interface || (t.Kind() == reflect.Struct && reflect.PtrTo(t).Implements(unmarshalerType))) {
+		t = nil
+	}
+	if len(path) == 0 {
+		return convertValue(value, t, node)
+	}
+	if t == nil {
+		return setUntyped(node, path, value)
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			m = make(map[string]interface{})
+		}
+		fields := fieldsOf(t)
+		// Field names may contain underscores, e.g. json:"max_size".
+		for n := len(path); n > 0; n-- {
+			name := strings.Join(path[:n], "_")
+			f, ok := lookupField(fields, name)
+			if !ok {
+				continue
+			}
+			key := mapKey(m, f.name)
+			v, err := setPath(m[key], t.FieldByIndex(f.index).Type, path[n:], value)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+			return m, nil
+		}
+		return nil, errUnknownField(path[0])
+	case reflect.Slice, reflect.Array:
+		s, _ := node.([]interface{})
+		return setIndex(s, t.Elem(), path, value)
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, errUnknownField(path[0])
+		}
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			m = make(map[string]interface{})
+		}
+		key := mapKey(m, path[0])
+		v, err := setPath(m[key], t.Elem(), path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = v
+		return m, nil
+	}
+	return nil, errUnknownField(path[0])
+}
+
+func sectionOf(sectionType func(string) reflect.Type, key string) reflect.Type {
+	if sectionType == nil {
+		return nil
+	}
+	return sectionType(key)
+}
+
+var valuerType = reflect.TypeOf((*valuer)(nil)).Elem()
+
+// dynamicType returns type of the value which configuration union t decodes
+// from node, or nil if it is unknown.
+func dynamicType(t reflect.Type, node interface{}) reflect.Type {
+	if node == nil {
+		return nil
+	}
+	b, err := json.Marshal(node)
+	if err != nil {
+		return nil
+	}
+	p := reflect.New(t).Interface()
+	u, ok := p.(json.Unmarshaler)
+	if !ok || u.UnmarshalJSON(b) != nil {
+		return nil
+	}
+	if v := p.(valuer).Value(); v != nil {
+		return reflect.TypeOf(v)
+	}
+	return nil
+}
+
+// setUntyped sets value in node without type information.
+func setUntyped(node interface{}, path []string, value string) (interface{}, error) {
+	if s, ok := node.([]interface{}); ok {
+		return setIndex(s, nil, path, value)
+	}
+	m, ok := node.(map[string]interface{})
+	if !ok {
+		if node != nil {
+			return nil, errUnknownField(path[0])
+		}
+		if _, err := strconv.Atoi(path[0]); err == nil {
+			return setIndex(nil, nil, path, value)
+		}
+		m = make(map[string]interface{})
+	}
+	key := mapKey(m, path[0])
+	v, err := setPath(m[key], nil, path[1:], value)
+	if err != nil {
+		return nil, err
+	}
+	m[key] = v
+	return m, nil
+}
+
+// setIndex sets element at index path[0] of s. The index can be the length
+// of s to append an element.
+func setIndex(s []interface{}, elem reflect.Type, path []string, value string) (interface{}, error) {
+	i, err := strconv.Atoi(path[0])
+	if err != nil || i < 0 || i > len(s) {
+		return nil, fmt.Errorf("invalid index %s", path[0])
+	}
+	var e interface{}
+	if i < len(s) {
+		e = s[i]
+	}
+	v, err := setPath(e, elem, path[1:], value)
+	if err != nil {
+		return nil, err
+	}
+	if i == len(s) {
+		return append(s, v), nil
+	}
+	s[i] = v
+	return s, nil
+}
+
+// mapKey returns existing key in m which equals name case-insensitively,
+// or name if there is none.
+func mapKey(m map[string]interface{}, name string) string {
+	if _, ok := m[name]; ok {
+		return name
+	}
+	for k := range m {
+		if strings.EqualFold(k, name) {
+			return k
+		}
+	}
+	return name
+}
+
+// convertValue converts string value to the JSON type of t, or of the current
+// value when t is nil.
+func convertValue(value string, t reflect.Type, current interface{}) (interface{}, error) {
+	if t == nil {
+		switch current.(type) {
+		case string:
+			return value, nil
+		case float64:
+			return strconv.ParseFloat(value, 64)
+		case bool:
+			return strconv.ParseBool(value)
+		}
+		var v interface{}
+		if err := json.Unmarshal([]byte(value), &v); err == nil {
+			return v, nil
+		}
+		return value, nil
+	}
+	if reflect.PtrTo(t).Implements(unmarshalerType) && t.Kind() != reflect.Struct {
+		// e.g. Duration and Size accept both numbers and strings.
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f, nil
+		}
+		return value, nil
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return value, nil
+	case reflect.Bool:
+		return strconv.ParseBool(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return strconv.ParseFloat(value, 64)
+	}
+	var v interface{}
+	if err := json.Unmarshal([]byte(value), &v); err != nil {
+		return nil, fmt.Errorf("invalid JSON value: %v", err)
+	}
+	return v, nil
+}
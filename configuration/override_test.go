@@ -0,0 +1,124 @@
+package configuration
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/goburrow/melon/core"
+)
+
+func setEnviron(env ...string) func() {
+	orig := environ
+	environ = func() []string {
+		return env
+	}
+	return func() { environ = orig }
+}
+
+func TestOverridePrecedence(t *testing.T) {
+	defer setEnviron(
+		"MYAPP_SERVER_ADMINCONNECTORS_0_ADDR=:9091",
+		"MYAPP_SERVER_APPLICATIONCONNECTORS_2_ADDR=:8443",
+		"MYAPP_LOGGING_LEVEL=DEBUG",
+		"MYAPP_LOGGING_LOGGERS_MELON.SERVER=ERROR",
+		"MYAPP_HOME=/home/melon",
+		"OTHER_LOGGING_LEVEL=WARN",
+	)()
+	bootstrap := core.Bootstrap{
+		Arguments: []string{"server",
+			"-config-set", "logging.level=WARN",
+			"-config-set", "server.applicationConnectors[0].addr=:80",
+			"configuration_test.json"},
+	}
+	factory := NewFactory(&configuration{
+		Metrics: metricsConfiguration{Frequency: "1m"},
+	})
+	factory.SetEnvPrefix("MYAPP")
+	c, err := factory.BuildConfiguration(&bootstrap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := c.(*configuration)
+	if config.Server.AdminConnectors[0].Addr != ":9091" || config.Server.AdminConnectors[0].Type != "http" {
+		t.Fatalf("unexpected admin connectors: %+v", config.Server.AdminConnectors)
+	}
+	if len(config.Server.ApplicationConnectors) != 3 ||
+		config.Server.ApplicationConnectors[0].Addr != ":80" ||
+		config.Server.ApplicationConnectors[2].Addr != ":8443" {
+		t.Fatalf("unexpected application connectors: %+v", config.Server.ApplicationConnectors)
+	}
+	if config.Logging.Level != "WARN" || config.Logging.Loggers["melon.server"] != "ERROR" {
+		t.Fatalf("unexpected logging: %+v", config.Logging)
+	}
+	// Value in file overrides default.
+	if config.Metrics.Frequency != "1s" {
+		t.Fatalf("unexpected metrics: %+v", config.Metrics)
+	}
+}
+
+func TestOverrideTypes(t *testing.T) {
+	type config struct {
+		testStrictConfiguration
+		Enabled bool
+		Port    int
+		MaxSize Size `json:"max_size"`
+		Tags    []string
+	}
+	tests := []struct {
+		path  string
+		value string
+		data  string
+	}{
+		{"ENABLED", "true", `{"Enabled":true}`},
+		{"port", "8080", `{"Port":8080}`},
+		{"MAX_SIZE", "10MB", `{"max_size":"10MB"}`},
+		{"timeout", "1000", `{"Timeout":1000}`},
+		{"tags", `["a","b"]`, `{"Tags":["a","b"]}`},
+		{"level", "INFO", `{"Level":"INFO"}`},
+		{"appenders.0.type", "console", `{"Appenders":[{"type":"console"}]}`},
+		{"appenders.0.out", "stderr", `{"appenders":[{"out":"stderr","type":"console"}]}`},
+		{"appenders.0.TYPE", "file", `{"appenders":[{"type":"file"}]}`},
+		{"extra.a.0", "x", `{"Extra":{"a":["x"]}}`},
+	}
+	for _, test := range tests {
+		data := map[string]interface{}{}
+		if strings.HasPrefix(test.path, "appenders.0.") && test.path != "appenders.0.type" {
+			data["appenders"] = []interface{}{map[string]interface{}{"type": "console"}}
+		}
+		o := override{name: test.path, path: strings.Split(test.path, "."), value: test.value}
+		if strings.HasPrefix(test.path, "MAX_") {
+			o.path = strings.Split(test.path, "_")
+		}
+		if err := applyOverrides(data, reflect.TypeOf(&config{}), nil, []override{o}, false); err != nil {
+			t.Fatalf("unexpected error of %+v: %v", test, err)
+		}
+		b, _ := json.Marshal(data)
+		if string(b) != test.data {
+			t.Fatalf("unexpected data of %+v: %s", test, b)
+		}
+	}
+}
+
+func TestOverrideErrors(t *testing.T) {
+	tests := []struct {
+		flag string
+		err  string
+	}{
+		{"logging", `invalid flag -config-set "logging", must be in form of path=value`},
+		{"server..addr=:80", `invalid flag -config-set "server..addr=:80", must be in form of path=value`},
+		{"logging.levels=INFO", "could not set logging.levels: unknown field levels"},
+		{"server.adminConnectors[2].addr=:80", "could not set server.adminConnectors[2].addr: invalid index 2"},
+		{"logging.level.x=INFO", "could not set logging.level.x: unknown field x"},
+	}
+	for _, test := range tests {
+		bootstrap := core.Bootstrap{
+			Arguments: []string{"server", "-config-set", test.flag, "configuration_test.json"},
+		}
+		_, err := NewFactory(&configuration{}).BuildConfiguration(&bootstrap)
+		if err == nil || err.Error() != "configuration: "+test.err {
+			t.Fatalf("unexpected error of %s: %v", test.flag, err)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package configuration
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// FileTag is the struct tag of configuration fields which are file paths,
+// e.g.:
+//
+// 	CertFile string `file:"true"`
+//
+// Relative paths of these fields are resolved against directory of the
+// configuration file declaring them instead of the working directory.
+const FileTag = "file"
+
+// SetResolvePaths sets whether relative paths of fields tagged with FileTag
+// are resolved against directory of the configuration file. It is enabled by
+// default.
+func (f *Factory) SetResolvePaths(resolve bool) {
+	f.resolvePaths = resolve
+}
+
+// resolvePaths resolves relative file paths in node, which is decoded from
+// a file in dir, according to type t. Merged is the configuration merged so
+// far at the same path and is used to find types of configuration unions
+// which are not given in node.
+func resolvePaths(node, merged interface{}, t reflect.Type, dir string) {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil {
+		return
+	}
+	if t.Kind() == reflect.Struct && reflect.PtrTo(t).Implements(valuerType) {
+		dt := dynamicType(t, node)
+		if dt == nil {
+			dt = dynamicType(t, merged)
+		}
+		resolvePaths(node, merged, dt, dir)
+		return
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		m, ok := node.(map[string]interface{})
+		if !ok || reflect.PtrTo(t).Implements(unmarshalerType) {
+			return
+		}
+		fields := fieldsOf(t)
+		for k, v := range m {
+			f, ok := lookupField(fields, k)
+			if !ok {
+				continue
+			}
+			sf := t.FieldByIndex(f.index)
+			if sf.Tag.Get(FileTag) == "true" {
+				m[k] = resolvePath(dir, v)
+				continue
+			}
+			resolvePaths(v, mapValue(merged, k), sf.Type, dir)
+		}
+	case reflect.Slice, reflect.Array:
+		s, ok := node.([]interface{})
+		if !ok {
+			return
+		}
+		ms, _ := merged.([]interface{})
+		for i, v := range s {
+			var mv interface{}
+			if i < len(ms) {
+				mv = ms[i]
+			}
+			resolvePaths(v, mv, t.Elem(), dir)
+		}
+	case reflect.Map:
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return
+		}
+		for k, v := range m {
+			resolvePaths(v, mapValue(merged, k), t.Elem(), dir)
+		}
+	}
+}
+
+// resolvePath returns path v, or list of paths, relative to dir. Absolute
+// paths, URLs and encrypted values are unchanged.
+func resolvePath(dir string, v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		if v == "" || filepath.IsAbs(v) || hasScheme(v) || strings.HasPrefix(v, EncryptedPrefix) {
+			return v
+		}
+		return filepath.Join(dir, v)
+	case []interface{}:
+		for i, e := range v {
+			v[i] = resolvePath(dir, e)
+		}
+	}
+	return v
+}
+
+func mapValue(m interface{}, key string) interface{} {
+	if m, ok := m.(map[string]interface{}); ok {
+		return m[mapKey(m, key)]
+	}
+	return nil
+}
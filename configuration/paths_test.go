@@ -0,0 +1,79 @@
+package configuration
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/goburrow/melon/core"
+)
+
+type testPathsConfiguration struct {
+	CertFile   string   `file:"true"`
+	Files      []string `file:"true"`
+	Connectors []struct {
+		KeyFile string `file:"true"`
+		Addr    string
+	}
+	Appenders []testAppenderConfiguration
+	Server    testAppenderConfiguration
+}
+
+func loadPaths(factory *Factory) (*testPathsConfiguration, error) {
+	bootstrap := core.Bootstrap{
+		Arguments: []string{"server", "conf/prod/app.json"},
+		ConfigurationSourceProvider: NewBytesSourceProvider(map[string][]byte{
+			"conf/base.json": []byte(`{"certFile":"base.pem","server":{"type":"console","file":"base.log"}}`),
+			"conf/prod/app.json": []byte(`{
+				"includes": ["../base.json"],
+				"certFile": "cert.pem",
+				"files": ["a", "/b", "vault://c", ""],
+				"connectors": [{"keyFile": "key.pem", "addr": "key.pem"}],
+				"appenders": [{"type": "console", "file": "app.log", "out": "out.log"}],
+				"server": {"file": "server.log"}
+			}`),
+		}),
+	}
+	c, err := factory.BuildConfiguration(&bootstrap)
+	if err != nil {
+		return nil, err
+	}
+	return c.(*testPathsConfiguration), nil
+}
+
+func TestResolvePaths(t *testing.T) {
+	c, err := loadPaths(NewFactory(&testPathsConfiguration{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join("conf", "prod")
+	if c.CertFile != filepath.Join(dir, "cert.pem") {
+		t.Fatalf("unexpected cert file: %v", c.CertFile)
+	}
+	expected := []string{filepath.Join(dir, "a"), "/b", "vault://c", ""}
+	if !reflect.DeepEqual(expected, c.Files) {
+		t.Fatalf("unexpected files: %v", c.Files)
+	}
+	if c.Connectors[0].KeyFile != filepath.Join(dir, "key.pem") || c.Connectors[0].Addr != "key.pem" {
+		t.Fatalf("unexpected connectors: %+v", c.Connectors)
+	}
+	appender := c.Appenders[0].Value().(*testConsoleAppender)
+	if appender.File != filepath.Join(dir, "app.log") || appender.Target != "out.log" {
+		t.Fatalf("unexpected appender: %+v", appender)
+	}
+	// Type of the union is declared in the included file.
+	appender = c.Server.Value().(*testConsoleAppender)
+	if appender.File != filepath.Join(dir, "server.log") {
+		t.Fatalf("unexpected server: %+v", appender)
+	}
+
+	factory := NewFactory(&testPathsConfiguration{})
+	factory.SetResolvePaths(false)
+	c, err = loadPaths(factory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.CertFile != "cert.pem" || c.Connectors[0].KeyFile != "key.pem" {
+		t.Fatalf("unexpected configuration: %+v", c)
+	}
+}
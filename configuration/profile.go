@@ -0,0 +1,53 @@
+package configuration
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// ProfilesKey is the top-level key of profile-scoped settings.
+	ProfilesKey = "profiles"
+	// ProfileEnv is the environment variable of active profiles when flag
+	// -config-profile is not given.
+	ProfileEnv = "MELON_PROFILE"
+)
+
+// parseProfiles returns profile names separated by commas.
+func parseProfiles(s string) []string {
+	var names []string
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// applyProfiles removes ProfilesKey from configuration m and merges settings
+// of active profiles, in order, over it. Names of the profiles found are
+// added to found.
+func applyProfiles(m map[string]interface{}, active []string, found map[string]bool) error {
+	v, ok := m[ProfilesKey]
+	if !ok {
+		return nil
+	}
+	delete(m, ProfilesKey)
+	profiles, ok := v.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("%s must be an object of profile names", ProfilesKey)
+	}
+	for _, name := range active {
+		p, ok := profiles[name]
+		if !ok {
+			continue
+		}
+		settings, ok := p.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s.%s must be an object", ProfilesKey, name)
+		}
+		merge(m, settings)
+		found[name] = true
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package configuration
+
+import (
+	"os"
+	"testing"
+
+	"github.com/goburrow/melon/core"
+)
+
+func loadProfiles(args ...string) (*configuration, error) {
+	bootstrap := core.Bootstrap{
+		Arguments: append(append([]string{"server"}, args...), "testdata/profiles.json"),
+	}
+	c, err := NewFactory(&configuration{}).BuildConfiguration(&bootstrap)
+	if err != nil {
+		return nil, err
+	}
+	return c.(*configuration), nil
+}
+
+func TestProfiles(t *testing.T) {
+	c, err := loadProfiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Server.ApplicationConnectors[0].Addr != ":8080" || c.Logging.Level != "DEBUG" || c.Logging.Loggers != nil {
+		t.Fatalf("unexpected configuration: %+v", c)
+	}
+
+	c, err = loadProfiles("-config-profile", "prod, dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Server.ApplicationConnectors) != 1 || c.Server.ApplicationConnectors[0].Type != "https" ||
+		c.Logging.Level != "INFO" || c.Logging.Loggers["melon.server"] != "DEBUG" || c.Metrics.Frequency != "10s" {
+		t.Fatalf("unexpected configuration: %+v", c)
+	}
+
+	_, err = loadProfiles("-config-profile", "test")
+	if err == nil || err.Error() != "configuration: profile test is not defined" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestProfileEnv(t *testing.T) {
+	orig, ok := os.LookupEnv(ProfileEnv)
+	defer func() {
+		if ok {
+			os.Setenv(ProfileEnv, orig)
+		} else {
+			os.Unsetenv(ProfileEnv)
+		}
+	}()
+	os.Setenv(ProfileEnv, "prod,test")
+	c, err := loadProfiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Logging.Level != "INFO" {
+		t.Fatalf("unexpected configuration: %+v", c)
+	}
+	// Flag takes precedence over environment variable.
+	c, err = loadProfiles("-config-profile=dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Logging.Level != "DEBUG" || c.Logging.Loggers["melon.server"] != "DEBUG" {
+		t.Fatalf("unexpected configuration: %+v", c)
+	}
+}
@@ -0,0 +1,95 @@
+package configuration
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// SecretTag is the struct tag of configuration fields which hold
+// credentials, e.g.:
+//
+// 	Password string `secret:"true"`
+//
+// Values of these fields are masked when configuration is logged or printed.
+const SecretTag = "secret"
+
+// RedactedValue replaces values of secret fields in output of Redact.
+const RedactedValue = "[REDACTED]"
+
+// Redact returns generic representation of configuration v, as encoded by
+// encoding/json, with values of fields tagged with SecretTag replaced by
+// RedactedValue.
+func Redact(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var data interface{}
+	if err = json.Unmarshal(b, &data); err != nil {
+		return nil, err
+	}
+	redact(data, reflect.ValueOf(v))
+	return data, nil
+}
+
+// redact replaces values in data which map to secret fields of v.
+func redact(data interface{}, v reflect.Value) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		var i interface{}
+		if v.CanAddr() && v.Addr().CanInterface() {
+			i = v.Addr().Interface()
+		} else if v.CanInterface() {
+			i = v.Interface()
+		}
+		if d, ok := i.(valuer); ok {
+			redact(data, reflect.ValueOf(d.Value()))
+			return
+		}
+		m, ok := data.(map[string]interface{})
+		if !ok {
+			return
+		}
+		fields := fieldsOf(v.Type())
+		for k, e := range m {
+			f, ok := lookupField(fields, k)
+			if !ok {
+				continue
+			}
+			if v.Type().FieldByIndex(f.index).Tag.Get(SecretTag) == "true" {
+				if e != nil {
+					m[k] = RedactedValue
+				}
+				continue
+			}
+			if fv, ok := fieldByIndex(v, f.index); ok {
+				redact(e, fv)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		s, ok := data.([]interface{})
+		if !ok {
+			return
+		}
+		for i := 0; i < len(s) && i < v.Len(); i++ {
+			redact(s[i], v.Index(i))
+		}
+	case reflect.Map:
+		m, ok := data.(map[string]interface{})
+		if !ok || v.Type().Key().Kind() != reflect.String {
+			return
+		}
+		for k, e := range m {
+			mv := v.MapIndex(reflect.ValueOf(k).Convert(v.Type().Key()))
+			if mv.IsValid() {
+				redact(e, mv)
+			}
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package configuration
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+type testCredentials struct {
+	User     string
+	Password string `json:"pass" secret:"true"`
+}
+
+type testSecretConfiguration struct {
+	value interface{}
+}
+
+func (c *testSecretConfiguration) Value() interface{} {
+	return c.value
+}
+
+func (c testSecretConfiguration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.value)
+}
+
+func TestRedact(t *testing.T) {
+	config := struct {
+		Name     string
+		Token    string            `secret:"true"`
+		Empty    *string           `secret:"true"`
+		Users    map[string]string `secret:"true"`
+		Database []testCredentials
+		Backends map[string]*testCredentials
+		Auth     testSecretConfiguration
+	}{
+		Name:     "app",
+		Token:    "token",
+		Users:    map[string]string{"admin": "secret"},
+		Database: []testCredentials{{"user", "secret"}},
+		Backends: map[string]*testCredentials{"a": {"user", "secret"}},
+		Auth:     testSecretConfiguration{&testCredentials{"user", "secret"}},
+	}
+	v, err := Redact(&config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"Auth":{"User":"user","pass":"[REDACTED]"},` +
+		`"Backends":{"a":{"User":"user","pass":"[REDACTED]"}},` +
+		`"Database":[{"User":"user","pass":"[REDACTED]"}],` +
+		`"Empty":null,"Name":"app","Token":"[REDACTED]","Users":"[REDACTED]"}`
+	if string(b) != expected {
+		t.Fatalf("unexpected redacted configuration: %s", b)
+	}
+	if config.Token != "token" || config.Database[0].Password != "secret" {
+		t.Fatalf("unexpected configuration: %+v", config)
+	}
+}
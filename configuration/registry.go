@@ -0,0 +1,110 @@
+package configuration
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// TypeKey is the key of configuration selecting a registered type.
+const TypeKey = "type"
+
+// Registry contains named implementations of an interface which are selected
+// by key "type" in configuration, e.g. appenders of logging:
+//
+// 	logging.AppenderTypes.Register("KafkaAppender", func() interface{} {
+// 		return &KafkaAppenderFactory{}
+// 	})
+//
+// Bundles can register their types in Initialize before configuration is
+// parsed.
+type Registry struct {
+	name  string
+	iface reflect.Type
+
+	mu    sync.RWMutex
+	types map[string]func() interface{}
+}
+
+// NewRegistry creates a Registry of implementations of interface iface, which
+// must be given as a nil pointer, e.g. (*core.ServerFactory)(nil). Name is
+// used in error messages.
+func NewRegistry(name string, iface interface{}) *Registry {
+	t := reflect.TypeOf(iface)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Interface {
+		panic("configuration: registry requires a pointer to interface")
+	}
+	return &Registry{
+		name:  name,
+		iface: t.Elem(),
+		types: make(map[string]func() interface{}),
+	}
+}
+
+// Register adds type name which is created by function fn. It panics if the
+// value returned by fn does not implement the interface of the registry.
+// Registering an existing name replaces it.
+func (r *Registry) Register(name string, fn func() interface{}) {
+	if v := fn(); v == nil || !reflect.TypeOf(v).Implements(r.iface) {
+		panic(fmt.Sprintf("configuration: %T does not implement %v", v, r.iface))
+	}
+	r.mu.Lock()
+	r.types[name] = fn
+	r.mu.Unlock()
+}
+
+// Names returns registered type names in order.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.types))
+	for name := range r.types {
+		names = append(names, name)
+	}
+	r.mu.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+// New returns a new value of type name.
+func (r *Registry) New(name string) (interface{}, error) {
+	r.mu.RLock()
+	fn := r.types[name]
+	r.mu.RUnlock()
+	if fn == nil {
+		return nil, fmt.Errorf("unsupported %s type %q, supported types are %s",
+			r.name, name, strings.Join(r.Names(), ", "))
+	}
+	return fn(), nil
+}
+
+// Unmarshal decodes JSON object data to a new value of the type given in its
+// key "type".
+func (r *Registry) Unmarshal(data []byte) (interface{}, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	var name string
+	for k, v := range fields {
+		if strings.EqualFold(k, TypeKey) {
+			if err := json.Unmarshal(v, &name); err != nil {
+				return nil, fmt.Errorf("invalid %s type: %v", r.name, err)
+			}
+			break
+		}
+	}
+	if name == "" {
+		return nil, fmt.Errorf("%s type is required", r.name)
+	}
+	v, err := r.New(name)
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
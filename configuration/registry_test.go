@@ -0,0 +1,52 @@
+package configuration
+
+import (
+	"fmt"
+	"testing"
+)
+
+type testShape interface {
+	Area() float64
+}
+
+type testSquare struct {
+	Size float64
+}
+
+func (s *testSquare) Area() float64 {
+	return s.Size * s.Size
+}
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry("shape", (*testShape)(nil))
+	registry.Register("Square", func() interface{} { return &testSquare{} })
+
+	v, err := registry.Unmarshal([]byte(`{"type":"Square","size":2}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.(testShape).Area() != 4 {
+		t.Fatalf("unexpected value: %#v", v)
+	}
+	tests := []struct {
+		data string
+		err  string
+	}{
+		{`{"size":2}`, "shape type is required"},
+		{`{"type":"Circle"}`, `unsupported shape type "Circle", supported types are Square`},
+		{`{"type":1}`, "invalid shape type: json: cannot unmarshal number into Go value of type string"},
+	}
+	for _, test := range tests {
+		_, err = registry.Unmarshal([]byte(test.data))
+		if err == nil || err.Error() != test.err {
+			t.Fatalf("unexpected error of %v: %v", test.data, err)
+		}
+	}
+
+	defer func() {
+		if r := recover(); r == nil || fmt.Sprint(r) != "configuration: *configuration.Factory does not implement configuration.testShape" {
+			t.Fatalf("unexpected panic: %v", r)
+		}
+	}()
+	registry.Register("Factory", func() interface{} { return &Factory{} })
+}
@@ -0,0 +1,169 @@
+package configuration
+
+import (
+	"reflect"
+	"unicode"
+)
+
+// SchemaVersion is the JSON Schema draft of schemas generated by Schema.
+const SchemaVersion = "http://json-schema.org/draft-07/schema#"
+
+// typedUnion is implemented by configuration unions, e.g.
+// logging.AppenderConfiguration, to list their types.
+type typedUnion interface {
+	Types() *Registry
+}
+
+// Schema returns JSON Schema of the configuration type, including sections
+// and top-level keys IncludesKey, ProfilesKey and SecretsKey. Property names
+// are in lower camel case, e.g. applicationConnectors, unless given in json
+// tags, and types of configuration unions are those registered at the time
+// of calling.
+func (f *Factory) Schema() map[string]interface{} {
+	g := &schemaGenerator{visiting: make(map[reflect.Type]bool)}
+	s := g.schema(reflect.TypeOf(f.ref))
+	if s["type"] != "object" {
+		return s
+	}
+	properties := s["properties"].(map[string]interface{})
+	for k, ref := range f.sections {
+		properties[k] = g.schema(reflect.TypeOf(ref))
+	}
+	properties[IncludesKey] = map[string]interface{}{
+		"type":  "array",
+		"items": map[string]interface{}{"type": "string"},
+	}
+	properties[ProfilesKey] = map[string]interface{}{
+		"type":                 "object",
+		"additionalProperties": map[string]interface{}{"type": "object"},
+	}
+	properties[SecretsKey] = map[string]interface{}{"type": "object"}
+	s["$schema"] = SchemaVersion
+	return s
+}
+
+// schemaGenerator generates JSON Schema of Go types as decoded by
+// encoding/json.
+type schemaGenerator struct {
+	// visiting contains struct types being generated to stop recursion.
+	visiting map[reflect.Type]bool
+}
+
+var (
+	durationType = reflect.TypeOf(Duration(0))
+	sizeType     = reflect.TypeOf(Size(0))
+	typedType    = reflect.TypeOf((*typedUnion)(nil)).Elem()
+)
+
+func (g *schemaGenerator) schema(t reflect.Type) map[string]interface{} {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil {
+		return map[string]interface{}{}
+	}
+	switch {
+	case t == durationType || t == sizeType:
+		return map[string]interface{}{"type": []string{"string", "integer"}}
+	case reflect.PtrTo(t).Implements(typedType):
+		return g.unionSchema(reflect.New(t).Interface().(typedUnion).Types())
+	case reflect.PtrTo(t).Implements(unmarshalerType):
+		// Format is unknown.
+		return map[string]interface{}{}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			// Base64 encoded.
+			return map[string]interface{}{"type": "string"}
+		}
+		return map[string]interface{}{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		return g.structSchema(t, nil)
+	}
+	return map[string]interface{}{}
+}
+
+// structSchema returns schema of struct t with additional properties.
+func (g *schemaGenerator) structSchema(t reflect.Type, extra map[string]interface{}) map[string]interface{} {
+	if g.visiting[t] {
+		return map[string]interface{}{"type": "object"}
+	}
+	g.visiting[t] = true
+	defer delete(g.visiting, t)
+
+	properties := make(map[string]interface{})
+	for _, f := range fieldsOf(t) {
+		sf := t.FieldByIndex(f.index)
+		name := f.name
+		if name == sf.Name {
+			name = lowerCamel(name)
+		}
+		properties[name] = g.schema(sf.Type)
+	}
+	for k, v := range extra {
+		properties[k] = v
+	}
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
+
+// unionSchema returns schema which is one of types registered in r.
+func (g *schemaGenerator) unionSchema(r *Registry) map[string]interface{} {
+	names := r.Names()
+	alternatives := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		v, err := r.New(name)
+		if err != nil {
+			continue
+		}
+		t := reflect.TypeOf(v)
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		typ := map[string]interface{}{"const": name}
+		var s map[string]interface{}
+		if t.Kind() == reflect.Struct {
+			s = g.structSchema(t, map[string]interface{}{TypeKey: typ})
+		} else {
+			s = map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{TypeKey: typ},
+			}
+		}
+		s["required"] = []string{TypeKey}
+		alternatives = append(alternatives, s)
+	}
+	return map[string]interface{}{"oneOf": alternatives}
+}
+
+// lowerCamel returns name with leading upper case letters in lower case,
+// e.g. ApplicationConnectors to applicationConnectors and TLSConfig to
+// tlsConfig.
+func lowerCamel(name string) string {
+	r := []rune(name)
+	for i := range r {
+		if !unicode.IsUpper(r[i]) {
+			break
+		}
+		if i > 0 && i+1 < len(r) && unicode.IsLower(r[i+1]) {
+			break
+		}
+		r[i] = unicode.ToLower(r[i])
+	}
+	return string(r)
+}
@@ -0,0 +1,84 @@
+package configuration
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+type testSchemaAppender struct {
+	testAppenderConfiguration
+}
+
+func (c *testSchemaAppender) Types() *Registry {
+	return testAppenderTypes
+}
+
+type testSchemaNode struct {
+	Name     string `json:"name"`
+	Children []testSchemaNode
+}
+
+type testSchemaConfiguration struct {
+	BaseURL   string
+	TLSConfig struct {
+		CertFile string `file:"true"`
+	}
+	Timeout   Duration
+	Interval  time.Duration
+	Ratio     float64
+	Enabled   *bool
+	Data      []byte
+	Tags      map[string]string
+	Appenders []testSchemaAppender
+	Tree      testSchemaNode
+	Any       interface{}
+	Ignored   string `json:"-"`
+	internal  string
+}
+
+func TestSchema(t *testing.T) {
+	factory := NewFactory(&testSchemaConfiguration{})
+	factory.SetSection("redis", &testRedisConfiguration{})
+	b, err := json.Marshal(factory.Schema())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"$schema":"http://json-schema.org/draft-07/schema#","additionalProperties":false,"properties":{` +
+		`"any":{},` +
+		`"appenders":{"items":{"oneOf":[{"additionalProperties":false,"properties":{"file":{"type":"string"},"out":{"type":"string"},"type":{"const":"console"}},"required":["type"],"type":"object"}]},"type":"array"},` +
+		`"baseURL":{"type":"string"},` +
+		`"data":{"type":"string"},` +
+		`"enabled":{"type":"boolean"},` +
+		`"includes":{"items":{"type":"string"},"type":"array"},` +
+		`"interval":{"type":"integer"},` +
+		`"profiles":{"additionalProperties":{"type":"object"},"type":"object"},` +
+		`"ratio":{"type":"number"},` +
+		`"redis":{"additionalProperties":false,"properties":{"addr":{"type":"string"},"certFile":{"type":"string"},"db":{"type":"integer"}},"type":"object"},` +
+		`"secrets":{"type":"object"},` +
+		`"tags":{"additionalProperties":{"type":"string"},"type":"object"},` +
+		`"timeout":{"type":["string","integer"]},` +
+		`"tlsConfig":{"additionalProperties":false,"properties":{"certFile":{"type":"string"}},"type":"object"},` +
+		`"tree":{"additionalProperties":false,"properties":{"children":{"items":{"type":"object"},"type":"array"},"name":{"type":"string"}},"type":"object"}` +
+		`},"type":"object"}`
+	if string(b) != expected {
+		t.Fatalf("unexpected schema: %s", b)
+	}
+}
+
+func TestLowerCamel(t *testing.T) {
+	tests := map[string]string{
+		"Addr":      "addr",
+		"BaseURL":   "baseURL",
+		"TLSConfig": "tlsConfig",
+		"DSN":       "dsn",
+		"DB":        "db",
+		"addr":      "addr",
+		"":          "",
+	}
+	for name, expected := range tests {
+		if s := lowerCamel(name); s != expected {
+			t.Fatalf("unexpected lower camel case of %s: %s, expected %s", name, s, expected)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package configuration
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// SecretProvider resolves secret references in configuration values.
+type SecretProvider interface {
+	// Secret returns value of the secret reference, e.g.
+	// vault://secret/db#password.
+	Secret(ref string) (string, error)
+}
+
+// SecretsKey is the top-level key of settings of secret providers. It is
+// reserved when secret providers or decrypter are registered, so building
+// configuration fails if the configuration type or a section also uses it.
+const SecretsKey = "secrets"
+
+// SecretConfigurer is implemented by SecretProvider which has settings in
+// configuration block SecretsKey, e.g.:
+//
+// 	secrets:
+// 	  aws:
+// 	    region: us-east-1
+type SecretConfigurer interface {
+	// ConfigureSecrets is called before secrets are resolved. Function decode
+	// decodes settings with the given key in block SecretsKey to v. It leaves
+	// v unchanged if there is no such settings.
+	ConfigureSecrets(decode func(key string, v interface{}) error) error
+}
+
+// SetSecretProvider registers provider for secret references with the given
+// URL scheme, e.g. "vault". String values of configuration in form of
+// scheme://... are replaced by secrets returned from the provider before
+// being decoded.
+func (f *Factory) SetSecretProvider(scheme string, provider SecretProvider) {
+	if f.secrets == nil {
+		f.secrets = make(map[string]SecretProvider)
+	}
+	f.secrets[scheme] = provider
+}
+
+// configureSecrets removes SecretsKey from configuration data and configures
+// registered providers and decrypter with it. typ is the configuration type.
+func (f *Factory) configureSecrets(data map[string]interface{}, typ reflect.Type, decrypter Decrypter) error {
+	if f.sectionType(SecretsKey) != nil {
+		return fmt.Errorf("%s is reserved for secret providers and can not be a section", SecretsKey)
+	}
+	for typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ != nil && typ.Kind() == reflect.Struct {
+		if fd, ok := lookupField(fieldsOf(typ), SecretsKey); ok {
+			return fmt.Errorf("%s is reserved for secret providers and can not be field %s of %v",
+				SecretsKey, typ.FieldByIndex(fd.index).Name, typ)
+		}
+	}
+	settings, _ := data[SecretsKey].(map[string]interface{})
+	delete(data, SecretsKey)
+	decode := func(key string, v interface{}) error {
+		s, ok := settings[key]
+		if !ok {
+			return nil
+		}
+		b, err := json.Marshal(s)
+		if err != nil {
+			return err
+		}
+		if err = json.Unmarshal(b, v); err != nil {
+			return fmt.Errorf("invalid %s.%s: %v", SecretsKey, key, err)
+		}
+		return nil
+	}
+	for _, provider := range f.secrets {
+		if c, ok := provider.(SecretConfigurer); ok {
+			if err := c.ConfigureSecrets(decode); err != nil {
+				return err
+			}
+		}
+	}
+	if c, ok := decrypter.(SecretConfigurer); ok {
+		if err := c.ConfigureSecrets(decode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveSecrets replaces secret references and encrypted values in v
+// recursively.
+func (f *Factory) resolveSecrets(v interface{}, path string, decrypter Decrypter) (interface{}, error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			r, err := f.resolveSecrets(e, joinPath(path, k), decrypter)
+			if err != nil {
+				return nil, err
+			}
+			v[k] = r
+		}
+	case []interface{}:
+		for i, e := range v {
+			r, err := f.resolveSecrets(e, path+"["+strconv.Itoa(i)+"]", decrypter)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = r
+		}
+	case string:
+		if strings.HasPrefix(v, EncryptedPrefix) {
+			s, err := decrypt(decrypter, v)
+			if err != nil {
+				return nil, fmt.Errorf("could not decrypt %s: %v", path, err)
+			}
+			return s, nil
+		}
+		i := strings.Index(v, "://")
+		if i <= 0 {
+			break
+		}
+		provider := f.secrets[v[:i]]
+		if provider == nil {
+			break
+		}
+		s, err := provider.Secret(v)
+		if err != nil {
+			return nil, fmt.Errorf("could not resolve secret of %s: %v", path, err)
+		}
+		return s, nil
+	}
+	return v, nil
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,115 @@
+package configuration
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/goburrow/melon/core"
+)
+
+type testSecretProvider map[string]string
+
+func (p testSecretProvider) Secret(ref string) (string, error) {
+	v, ok := p[ref]
+	if !ok {
+		return "", fmt.Errorf("%s not found", ref)
+	}
+	return v, nil
+}
+
+func TestResolveSecrets(t *testing.T) {
+	factory := NewFactory(nil)
+	factory.SetSecretProvider("test", testSecretProvider{
+		"test://cert": "/tmp/cert",
+		"test://key":  "/tmp/key",
+	})
+	data := map[string]interface{}{
+		"server": map[string]interface{}{
+			"applicationConnectors": []interface{}{
+				map[string]interface{}{"certFile": "test://cert", "keyFile": "test://key"},
+				map[string]interface{}{"addr": "http://localhost"},
+			},
+		},
+	}
+	if _, err := factory.resolveSecrets(data, "", nil); err != nil {
+		t.Fatal(err)
+	}
+	connectors := data["server"].(map[string]interface{})["applicationConnectors"].([]interface{})
+	c := connectors[0].(map[string]interface{})
+	if c["certFile"] != "/tmp/cert" || c["keyFile"] != "/tmp/key" {
+		t.Fatalf("unexpected secrets: %+v", c)
+	}
+	if connectors[1].(map[string]interface{})["addr"] != "http://localhost" {
+		t.Fatalf("unexpected value: %+v", connectors[1])
+	}
+
+	data = map[string]interface{}{"logging": []interface{}{"test://none"}}
+	_, err := factory.resolveSecrets(data, "", nil)
+	if err == nil || !strings.HasPrefix(err.Error(), "could not resolve secret of logging[0]") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestLoadSecrets(t *testing.T) {
+	bootstrap := core.Bootstrap{
+		Arguments: []string{"server", "configuration_test.json"},
+	}
+	factory := NewFactory(&configuration{})
+	factory.SetSecretProvider("test", testSecretProvider{})
+	if _, err := factory.BuildConfiguration(&bootstrap); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type testSecretConfigurer struct {
+	testSecretProvider
+	region string
+}
+
+func (p *testSecretConfigurer) ConfigureSecrets(decode func(string, interface{}) error) error {
+	var c struct {
+		Region string
+	}
+	if err := decode("test", &c); err != nil {
+		return err
+	}
+	p.region = c.Region
+	return nil
+}
+
+func TestConfigureSecrets(t *testing.T) {
+	provider := &testSecretConfigurer{}
+	factory := NewFactory(nil)
+	factory.SetSecretProvider("test", provider)
+	data := map[string]interface{}{
+		"secrets": map[string]interface{}{
+			"test": map[string]interface{}{"region": "local"},
+		},
+	}
+	if err := factory.configureSecrets(data, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if provider.region != "local" || len(data) != 0 {
+		t.Fatalf("unexpected configuration: %+v %+v", provider, data)
+	}
+	data = map[string]interface{}{
+		"secrets": map[string]interface{}{"test": "local"},
+	}
+	if err := factory.configureSecrets(data, nil, nil); err == nil {
+		t.Fatal("error expected")
+	}
+	var c struct {
+		Secrets map[string]string
+	}
+	err := factory.configureSecrets(map[string]interface{}{}, reflect.TypeOf(&c), nil)
+	if err == nil || !strings.Contains(err.Error(), "secrets is reserved") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	factory.SetSection("secrets", &c)
+	err = factory.configureSecrets(map[string]interface{}{}, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "secrets is reserved") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
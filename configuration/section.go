@@ -0,0 +1,102 @@
+package configuration
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/goburrow/melon/core"
+)
+
+// SetSection binds ref, a pointer to configuration of a bundle, to top-level
+// key of the configuration files, e.g. "redis", so that bundles do not
+// require the application configuration to embed their settings. The section
+// is decoded into ref when configuration is built and fields of ref not given
+// in the files keep their values. Key takes precedence over any field of the
+// application configuration with the same name.
+func (f *Factory) SetSection(key string, ref interface{}) {
+	if f.sections == nil {
+		f.sections = make(map[string]interface{})
+	}
+	f.sections[strings.ToLower(key)] = ref
+}
+
+// BindSection calls SetSection of ConfigurationFactory of bootstrap. It is
+// intended to be called by bundles in Initialize and returns false if the
+// factory is not a Factory.
+func BindSection(bootstrap *core.Bootstrap, key string, ref interface{}) bool {
+	f, ok := bootstrap.ConfigurationFactory.(*Factory)
+	if ok {
+		f.SetSection(key, ref)
+	}
+	return ok
+}
+
+// sectionType returns type of the section bound to key, or nil if key is not
+// a section.
+func (f *Factory) sectionType(key string) reflect.Type {
+	if ref, ok := f.sections[strings.ToLower(key)]; ok {
+		return reflect.TypeOf(ref)
+	}
+	return nil
+}
+
+// sectionsOf returns values bound to sections by their keys in data.
+func (f *Factory) sectionsOf(data map[string]interface{}) map[string]interface{} {
+	if len(f.sections) == 0 {
+		return nil
+	}
+	sections := make(map[string]interface{})
+	for k := range data {
+		if ref, ok := f.sections[strings.ToLower(k)]; ok {
+			sections[k] = ref
+		}
+	}
+	return sections
+}
+
+// decodeSections decodes values of sections in data to their bound values.
+func decodeSections(data map[string]interface{}, sections map[string]interface{}) error {
+	for k, ref := range sections {
+		content, err := json.Marshal(data[k])
+		if err != nil {
+			return err
+		}
+		if err = json.Unmarshal(content, ref); err != nil {
+			return fmt.Errorf("%s: %v", k, err)
+		}
+	}
+	return nil
+}
+
+// withoutKeys returns data excluding keys of sections.
+func withoutKeys(data map[string]interface{}, sections map[string]interface{}) map[string]interface{} {
+	if len(sections) == 0 {
+		return data
+	}
+	rest := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		if _, ok := sections[k]; !ok {
+			rest[k] = v
+		}
+	}
+	return rest
+}
+
+// newSections returns sections bound to new zero values of their types so
+// that reloading does not change configuration of the bundles.
+func (f *Factory) newSections() map[string]interface{} {
+	if f.sections == nil {
+		return nil
+	}
+	sections := make(map[string]interface{}, len(f.sections))
+	for k, ref := range f.sections {
+		t := reflect.TypeOf(ref)
+		if t != nil && t.Kind() == reflect.Ptr {
+			ref = reflect.New(t.Elem()).Interface()
+		}
+		sections[k] = ref
+	}
+	return sections
+}
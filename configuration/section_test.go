@@ -0,0 +1,74 @@
+package configuration
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/goburrow/melon/core"
+)
+
+type testRedisConfiguration struct {
+	Addr     string
+	DB       int
+	CertFile string `file:"true"`
+}
+
+func TestSection(t *testing.T) {
+	redis := &testRedisConfiguration{Addr: "localhost:6379", DB: 1}
+	bootstrap := core.Bootstrap{
+		Arguments: []string{"server", "-config-set", "redis.db=2", "conf/app.json"},
+		ConfigurationSourceProvider: NewBytesSourceProvider(map[string][]byte{
+			"conf/app.json": []byte(`{"logging":{"level":"INFO"},"Redis":{"addr":"redis:6379","certFile":"redis.pem"}}`),
+		}),
+		ConfigurationFactory: NewFactory(&configuration{}),
+	}
+	if !BindSection(&bootstrap, "redis", redis) {
+		t.Fatal("section is not bound")
+	}
+	bootstrap.ConfigurationFactory.(*Factory).SetStrict(true)
+	c, err := bootstrap.ConfigurationFactory.BuildConfiguration(&bootstrap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.(*configuration).Logging.Level != "INFO" {
+		t.Fatalf("unexpected configuration: %+v", c)
+	}
+	expected := testRedisConfiguration{Addr: "redis:6379", DB: 2, CertFile: filepath.Join("conf", "redis.pem")}
+	if *redis != expected {
+		t.Fatalf("unexpected section: %+v", redis)
+	}
+	// Reloading does not change the section.
+	_, err = bootstrap.ConfigurationFactory.(*Factory).ReloadConfiguration(&bootstrap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *redis != expected {
+		t.Fatalf("unexpected section: %+v", redis)
+	}
+}
+
+func TestSectionErrors(t *testing.T) {
+	tests := []struct {
+		args []string
+		data string
+		err  string
+	}{
+		{[]string{"server", "-config-set", "redis.port=1", "app.json"}, `{}`, "configuration: could not set redis.port: unknown field port"},
+		{[]string{"server", "app.json"}, `{"redis":1}`, "configuration: redis: json: cannot unmarshal number into Go value of type configuration.testRedisConfiguration"},
+		{[]string{"server", "-config-strict", "app.json"}, `{"redis":{"port":1}}`, "configuration: unknown fields redis.port"},
+	}
+	for _, test := range tests {
+		bootstrap := core.Bootstrap{
+			Arguments: test.args,
+			ConfigurationSourceProvider: NewBytesSourceProvider(map[string][]byte{
+				"app.json": []byte(test.data),
+			}),
+		}
+		factory := NewFactory(&configuration{})
+		factory.SetSection("redis", &testRedisConfiguration{})
+		_, err := factory.BuildConfiguration(&bootstrap)
+		if err == nil || err.Error() != test.err {
+			t.Fatalf("unexpected error of %s: %v, expected %s", test.data, err, test.err)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package configuration
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/goburrow/melon/core"
+)
+
+// stdinPath is the configuration path for reading from standard input.
+const stdinPath = "-"
+
+var (
+	stdin io.Reader = os.Stdin
+
+	httpClient = &http.Client{Timeout: 30 * time.Second}
+)
+
+// isURL returns true if p is an http or https URL.
+func isURL(p string) bool {
+	return strings.HasPrefix(p, "http://") || strings.HasPrefix(p, "https://")
+}
+
+// hasScheme returns true if p is in form of scheme://..., e.g. an http URL or
+// a consul key.
+func hasScheme(p string) bool {
+	i := strings.Index(p, "://")
+	if i <= 0 {
+		return false
+	}
+	for _, c := range p[:i] {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '+' || c == '-' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// sourceExt returns file extension of the configuration path or URL.
+func sourceExt(p string) string {
+	if hasScheme(p) {
+		u, err := url.Parse(p)
+		if err != nil {
+			return ""
+		}
+		return path.Ext(u.Path)
+	}
+	if p == stdinPath {
+		return ""
+	}
+	return filepath.Ext(p)
+}
+
+// fileSourceProvider opens files or standard input.
+type fileSourceProvider struct{}
+
+// NewFileSourceProvider returns a core.ConfigurationSourceProvider which
+// reads files, or standard input if the path is "-".
+func NewFileSourceProvider() core.ConfigurationSourceProvider {
+	return fileSourceProvider{}
+}
+
+func (fileSourceProvider) Open(p string) (io.ReadCloser, error) {
+	if p == stdinPath {
+		return ioutil.NopCloser(stdin), nil
+	}
+	return os.Open(p)
+}
+
+// urlSourceProvider fetches http or https URLs.
+type urlSourceProvider struct {
+	client *http.Client
+}
+
+// NewURLSourceProvider returns a core.ConfigurationSourceProvider which
+// fetches configuration from http or https URLs using client. A client with
+// 30 seconds timeout is used if client is nil.
+func NewURLSourceProvider(client *http.Client) core.ConfigurationSourceProvider {
+	if client == nil {
+		client = httpClient
+	}
+	return &urlSourceProvider{client}
+}
+
+func (s *urlSourceProvider) Open(p string) (io.ReadCloser, error) {
+	resp, err := s.client.Get(p)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("could not fetch %s: %s", p, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// bytesSourceProvider contains configuration content by paths.
+type bytesSourceProvider map[string][]byte
+
+// NewBytesSourceProvider returns a core.ConfigurationSourceProvider which
+// provides content of sources from memory, so that tests and embedders do not
+// need configuration files:
+//
+// 	bootstrap.ConfigurationSourceProvider = configuration.NewBytesSourceProvider(map[string][]byte{
+// 		"app.json": []byte(`{"server":{"type":"SimpleServer"}}`),
+// 	})
+func NewBytesSourceProvider(sources map[string][]byte) core.ConfigurationSourceProvider {
+	return bytesSourceProvider(sources)
+}
+
+func (s bytesSourceProvider) Open(p string) (io.ReadCloser, error) {
+	b, ok := s[p]
+	if !ok {
+		return nil, fmt.Errorf("configuration source %s not found", p)
+	}
+	return ioutil.NopCloser(bytes.NewReader(b)), nil
+}
+
+// defaultSourceProvider opens URLs, files or standard input.
+type defaultSourceProvider struct {
+	file core.ConfigurationSourceProvider
+	url  core.ConfigurationSourceProvider
+}
+
+func (s *defaultSourceProvider) Open(p string) (io.ReadCloser, error) {
+	if isURL(p) {
+		return s.url.Open(p)
+	}
+	return s.file.Open(p)
+}
+
+// NewDefaultSourceProvider returns a core.ConfigurationSourceProvider which
+// fetches http and https URLs and reads other paths as files, or standard
+// input if the path is "-". It is used when core.Bootstrap does not have one.
+func NewDefaultSourceProvider() core.ConfigurationSourceProvider {
+	return defaultProvider
+}
+
+var defaultProvider = &defaultSourceProvider{
+	file: NewFileSourceProvider(),
+	url:  NewURLSourceProvider(nil),
+}
+
+// readSource reads content of source p from provider.
+func readSource(provider core.ConfigurationSourceProvider, p string) ([]byte, error) {
+	r, err := provider.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// verifyChecksum checks content against checksum in form of [algorithm:]hex
+// where algorithm is sha256 (default) or sha512.
+func verifyChecksum(content []byte, checksum string) error {
+	algorithm, sum := "sha256", checksum
+	if i := strings.IndexByte(checksum, ':'); i >= 0 {
+		algorithm, sum = strings.ToLower(checksum[:i]), checksum[i+1:]
+	}
+	var h hash.Hash
+	switch algorithm {
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return fmt.Errorf("unsupported checksum algorithm %s", algorithm)
+	}
+	expected, err := hex.DecodeString(sum)
+	if err != nil {
+		return fmt.Errorf("invalid checksum %s: %v", checksum, err)
+	}
+	h.Write(content)
+	if subtle.ConstantTimeCompare(h.Sum(nil), expected) != 1 {
+		return fmt.Errorf("checksum mismatch: expected %s:%s, actual %s:%x",
+			algorithm, sum, algorithm, h.Sum(nil))
+	}
+	return nil
+}
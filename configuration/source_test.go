@@ -0,0 +1,114 @@
+package configuration
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/goburrow/melon/core"
+)
+
+func TestLoadURL(t *testing.T) {
+	content, err := ioutil.ReadFile("configuration_test.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/app.json" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(content)
+	}))
+	defer server.Close()
+
+	checksum := fmt.Sprintf("sha256:%x", sha256.Sum256(content))
+	bootstrap := core.Bootstrap{
+		Arguments: []string{"server", "-config-checksum=" + checksum, server.URL + "/app.json?v=1"},
+	}
+	testFactory(t, &bootstrap)
+
+	factory := NewFactory(&configuration{})
+	bootstrap.Arguments = []string{"server", "-config-checksum=sha256:00", server.URL + "/app.json"}
+	_, err = factory.BuildConfiguration(&bootstrap)
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	bootstrap.Arguments = []string{"server", server.URL + "/none.json"}
+	_, err = factory.BuildConfiguration(&bootstrap)
+	if err == nil || !strings.Contains(err.Error(), "404 Not Found") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestLoadStdin(t *testing.T) {
+	content, err := ioutil.ReadFile("configuration_test.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := stdin
+	defer func() { stdin = orig }()
+	stdin = strings.NewReader(string(content))
+	bootstrap := core.Bootstrap{
+		Arguments: []string{"server", "-config-format=json", "-"},
+	}
+	testFactory(t, &bootstrap)
+
+	bootstrap.Arguments = []string{"server", "-"}
+	_, err = NewFactory(&configuration{}).BuildConfiguration(&bootstrap)
+	if err == nil || err.Error() != "configuration: unknown format of -, flag -config-format is required" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestBytesSourceProvider(t *testing.T) {
+	base, err := ioutil.ReadFile("testdata/base.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bootstrap := core.Bootstrap{
+		Arguments: []string{"server", "conf/app.json"},
+		ConfigurationSourceProvider: NewBytesSourceProvider(map[string][]byte{
+			"conf/base.json": base,
+			"conf/app.json":  []byte(`{"includes":["base.json"],"logging":{"level":"WARN"}}`),
+		}),
+	}
+	c, err := NewFactory(&configuration{}).BuildConfiguration(&bootstrap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := c.(*configuration)
+	if config.Server.ApplicationConnectors[0].Addr != ":8080" || config.Logging.Level != "WARN" {
+		t.Fatalf("unexpected configuration: %+v", config)
+	}
+
+	bootstrap.Arguments = []string{"server", "configuration_test.json"}
+	_, err = NewFactory(&configuration{}).BuildConfiguration(&bootstrap)
+	if err == nil || err.Error() != "configuration: configuration source configuration_test.json not found" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	content := []byte("melon")
+	tests := []struct {
+		checksum string
+		valid    bool
+	}{
+		{fmt.Sprintf("%x", sha256.Sum256(content)), true},
+		{fmt.Sprintf("SHA256:%x", sha256.Sum256(content)), true},
+		{"sha512:00", false},
+		{"md5:00", false},
+		{"sha256:xyz", false},
+	}
+	for _, test := range tests {
+		err := verifyChecksum(content, test.checksum)
+		if (err == nil) != test.valid {
+			t.Fatalf("unexpected result of %v: %v", test.checksum, err)
+		}
+	}
+}
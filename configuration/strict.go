@@ -0,0 +1,179 @@
+package configuration
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SetStrict sets whether keys in configuration which do not map to any field
+// of the configuration type are rejected. It can also be enabled with flag
+// -config-strict.
+func (f *Factory) SetStrict(strict bool) {
+	f.strict = strict
+}
+
+// valuer is implemented by dynamic types, e.g. logging.AppenderConfiguration.
+type valuer interface {
+	Value() interface{}
+}
+
+// checkUnknownKeys returns error listing keys in data which are not decoded
+// to output, or to values of sections by their keys in data.
+func checkUnknownKeys(data map[string]interface{}, output interface{}, sections map[string]interface{}) error {
+	var keys []string
+	unknownKeys(withoutKeys(data, sections), reflect.ValueOf(output), "", false, &keys)
+	for k, ref := range sections {
+		unknownKeys(data[k], reflect.ValueOf(ref), k, false, &keys)
+	}
+	if len(keys) > 0 {
+		sort.Strings(keys)
+		return fmt.Errorf("unknown fields %s", strings.Join(keys, ", "))
+	}
+	return nil
+}
+
+// unknownKeys appends paths of keys in data which do not match fields of v.
+// Key TypeKey is allowed when typed is true.
+func unknownKeys(data interface{}, v reflect.Value, path string, typed bool, keys *[]string) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		var i interface{}
+		if v.CanAddr() && v.Addr().CanInterface() {
+			i = v.Addr().Interface()
+		} else if v.CanInterface() {
+			i = v.Interface()
+		}
+		if d, ok := i.(valuer); ok {
+			unknownKeys(data, reflect.ValueOf(d.Value()), path, true, keys)
+			return
+		}
+		if reflect.PtrTo(v.Type()).Implements(unmarshalerType) {
+			return
+		}
+		m, ok := data.(map[string]interface{})
+		if !ok {
+			return
+		}
+		fields := fieldsOf(v.Type())
+		for k, e := range m {
+			f, ok := lookupField(fields, k)
+			if !ok {
+				if (typed && strings.EqualFold(k, TypeKey)) || (path == "" && k == SecretsKey) {
+					continue
+				}
+				*keys = append(*keys, joinPath(path, k))
+				continue
+			}
+			if fv, ok := fieldByIndex(v, f.index); ok {
+				unknownKeys(e, fv, joinPath(path, k), false, keys)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		s, ok := data.([]interface{})
+		if !ok {
+			return
+		}
+		for i := 0; i < len(s) && i < v.Len(); i++ {
+			unknownKeys(s[i], v.Index(i), path+"["+strconv.Itoa(i)+"]", false, keys)
+		}
+	case reflect.Map:
+		m, ok := data.(map[string]interface{})
+		if !ok || v.Type().Key().Kind() != reflect.String {
+			return
+		}
+		for k, e := range m {
+			mv := v.MapIndex(reflect.ValueOf(k).Convert(v.Type().Key()))
+			if mv.IsValid() {
+				unknownKeys(e, mv, joinPath(path, k), false, keys)
+			}
+		}
+	}
+}
+
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+type field struct {
+	name  string
+	index []int
+}
+
+// fieldsOf returns fields of struct type t which are decoded by encoding/json,
+// including fields of embedded structs.
+func fieldsOf(t reflect.Type) []field {
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := tag
+		if j := strings.IndexByte(tag, ','); j >= 0 {
+			name = tag[:j]
+		}
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			for _, e := range fieldsOf(ft) {
+				fields = append(fields, field{e.name, append([]int{i}, e.index...)})
+			}
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, field{name, []int{i}})
+	}
+	return fields
+}
+
+// lookupField returns the field matching key as encoding/json does,
+// preferring exact matches and shallower fields.
+func lookupField(fields []field, key string) (field, bool) {
+	var found field
+	exact := false
+	for _, f := range fields {
+		if f.name == key {
+			if !exact || len(f.index) < len(found.index) {
+				found, exact = f, true
+			}
+		} else if !exact && strings.EqualFold(f.name, key) {
+			if found.index == nil || len(f.index) < len(found.index) {
+				found = f
+			}
+		}
+	}
+	return found, found.index != nil
+}
+
+// fieldByIndex returns nested field of v. It returns false if an embedded
+// pointer is nil.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 {
+			if v.Kind() == reflect.Ptr {
+				if v.IsNil() {
+					return v, false
+				}
+				v = v.Elem()
+			}
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
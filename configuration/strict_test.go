@@ -0,0 +1,113 @@
+package configuration
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/goburrow/melon/core"
+)
+
+type testAppender interface {
+	Append() string
+}
+
+type testConsoleAppender struct {
+	Target string `json:"out"`
+	File   string `file:"true"`
+}
+
+func (a *testConsoleAppender) Append() string {
+	return a.Target
+}
+
+var testAppenderTypes = NewRegistry("appender", (*testAppender)(nil))
+
+func init() {
+	testAppenderTypes.Register("console", func() interface{} {
+		return &testConsoleAppender{}
+	})
+}
+
+type testAppenderConfiguration struct {
+	value interface{}
+}
+
+func (c *testAppenderConfiguration) Value() interface{} {
+	return c.value
+}
+
+func (c *testAppenderConfiguration) UnmarshalJSON(data []byte) error {
+	v, err := testAppenderTypes.Unmarshal(data)
+	c.value = v
+	return err
+}
+
+type testCommon struct {
+	Level string
+}
+
+type testStrictConfiguration struct {
+	testCommon
+	Appenders []testAppenderConfiguration
+	Loggers   map[string]testCommon
+	Timeout   Duration
+	Extra     interface{}
+	Ignored   string `json:"-"`
+}
+
+func TestUnknownKeys(t *testing.T) {
+	tests := []struct {
+		data string
+		err  string
+	}{
+		{`{"level":"INFO","appenders":[{"type":"console","out":"stderr"}],"loggers":{"a":{"level":"DEBUG"}},"timeout":"1s","extra":{"any":1}}`, ""},
+		{`{"LEVEL":"INFO","Appenders":[{"Type":"console","OUT":"stderr"}],"secrets":{}}`, ""},
+		{`{"levle":"INFO","ignored":"x"}`, "unknown fields ignored, levle"},
+		{`{"appenders":[{"type":"console"},{"type":"console","target":"stderr"}]}`, "unknown fields appenders[1].target"},
+		{`{"loggers":{"a":{"level":"DEBUG","type":"x"}},"testCommon":{}}`, "unknown fields loggers.a.type, testCommon"},
+	}
+	for _, test := range tests {
+		var data map[string]interface{}
+		if err := json.Unmarshal([]byte(test.data), &data); err != nil {
+			t.Fatal(err)
+		}
+		var c testStrictConfiguration
+		if err := decodeMap(data, &c); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		err := checkUnknownKeys(data, &c, nil)
+		if (err == nil && test.err != "") || (err != nil && err.Error() != test.err) {
+			t.Fatalf("unexpected error of %s: %v, expected %q", test.data, err, test.err)
+		}
+	}
+}
+
+func TestStrictFlag(t *testing.T) {
+	orig := stdin
+	defer func() { stdin = orig }()
+
+	load := func(factory *Factory, args ...string) error {
+		stdin = strings.NewReader(`{"server":{"applicaitonConnectors":[{"addr":":8080"}]}}`)
+		bootstrap := core.Bootstrap{
+			Arguments: append(append([]string{"server"}, args...), "-config-format=json", "-"),
+		}
+		_, err := factory.BuildConfiguration(&bootstrap)
+		return err
+	}
+	if err := load(NewFactory(&configuration{})); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err := load(NewFactory(&configuration{}), "-config-strict")
+	if err == nil || err.Error() != "configuration: unknown fields server.applicaitonConnectors" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	factory := NewFactory(&configuration{})
+	factory.SetStrict(true)
+	if err = load(factory); err == nil {
+		t.Fatalf("error must be thrown")
+	}
+	if err = load(factory, "-config-strict=false"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+/*
+Package toml provides TOML file support for application configuration.
+
+TOML documents are converted to JSON before decoding so configuration structs
+are mapped the same way as JSON and YAML files.
+*/
+package toml
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+
+	"github.com/BurntSushi/toml"
+	"github.com/goburrow/melon/configuration"
+	"github.com/goburrow/melon/core"
+)
+
+type bundle struct{}
+
+func (b *bundle) Initialize(bootstrap *core.Bootstrap) {
+	f, ok := bootstrap.ConfigurationFactory.(*configuration.Factory)
+	if ok {
+		f.SetDecoder(".toml", unmarshalTOML)
+	}
+}
+
+func (b *bundle) Run(config interface{}, env *core.Environment) error {
+	return nil
+}
+
+func unmarshalTOML(r io.Reader, output interface{}) error {
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	var data map[string]interface{}
+	if err = toml.Unmarshal(content, &data); err != nil {
+		return err
+	}
+	content, err = json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(content, output)
+}
+
+// NewBundle creates a Bundle that adds support for TOML configuration file.
+func NewBundle() core.Bundle {
+	return &bundle{}
+}
@@ -0,0 +1,148 @@
+package configuration
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Duration is a time.Duration which is given in configuration as a string
+// parsed by time.ParseDuration, e.g. "500ms" or "2h", or a number of
+// milliseconds.
+type Duration time.Duration
+
+// ParseDuration parses a duration string or a number of milliseconds.
+func ParseDuration(s string) (Duration, error) {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return Duration(time.Duration(n) * time.Millisecond), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return Duration(d), nil
+}
+
+// String returns the duration formatted like time.Duration.
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// MarshalJSON encodes duration as a string.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON decodes duration from a string or a number of milliseconds.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	s, err := unquote(b)
+	if err != nil {
+		return err
+	}
+	*d, err = ParseDuration(s)
+	return err
+}
+
+// Size is a number of bytes which is given in configuration as a number or a
+// string with a decimal (kB, MB, GB, TB) or binary (KiB, MiB, GiB, TiB) unit,
+// e.g. "128MiB". Units are case-insensitive.
+type Size int64
+
+// Units of Size.
+const (
+	Byte Size = 1
+
+	Kilobyte Size = 1000 * Byte
+	Megabyte Size = 1000 * Kilobyte
+	Gigabyte Size = 1000 * Megabyte
+	Terabyte Size = 1000 * Gigabyte
+
+	Kibibyte Size = 1024 * Byte
+	Mebibyte Size = 1024 * Kibibyte
+	Gibibyte Size = 1024 * Mebibyte
+	Tebibyte Size = 1024 * Gibibyte
+)
+
+var sizeUnits = []struct {
+	name string
+	size Size
+}{
+	// Larger units first for formatting.
+	{"TiB", Tebibyte},
+	{"TB", Terabyte},
+	{"GiB", Gibibyte},
+	{"GB", Gigabyte},
+	{"MiB", Mebibyte},
+	{"MB", Megabyte},
+	{"KiB", Kibibyte},
+	{"kB", Kilobyte},
+	{"B", Byte},
+}
+
+// ParseSize parses a number of bytes with an optional unit.
+func ParseSize(s string) (Size, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i < 0 {
+		i = len(s)
+	}
+	number, unit := s[:i], strings.TrimSpace(s[i:])
+	size := Byte
+	if unit != "" {
+		size = 0
+		for _, u := range sizeUnits {
+			if strings.EqualFold(u.name, unit) {
+				size = u.size
+				break
+			}
+		}
+	}
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil || size == 0 || n*float64(size) > float64(1<<63-1) {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return Size(n * float64(size)), nil
+}
+
+// String returns the size with the largest unit which divides it.
+func (s Size) String() string {
+	for _, u := range sizeUnits {
+		if s != 0 && s%u.size == 0 {
+			return strconv.FormatInt(int64(s/u.size), 10) + u.name
+		}
+	}
+	return strconv.FormatInt(int64(s), 10) + "B"
+}
+
+// MarshalJSON encodes size as a string.
+func (s Size) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// UnmarshalJSON decodes size from a string or a number of bytes.
+func (s *Size) UnmarshalJSON(b []byte) error {
+	v, err := unquote(b)
+	if err != nil {
+		return err
+	}
+	*s, err = ParseSize(v)
+	return err
+}
+
+// unquote returns content of a JSON string or a number.
+func unquote(b []byte) (string, error) {
+	if string(b) == "null" {
+		return "0", nil
+	}
+	if len(b) > 0 && b[0] == '"' {
+		var s string
+		err := json.Unmarshal(b, &s)
+		return s, err
+	}
+	return string(b), nil
+}
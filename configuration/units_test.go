@@ -0,0 +1,78 @@
+package configuration
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestDuration(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Duration
+		valid    bool
+	}{
+		{`"500ms"`, Duration(500 * time.Millisecond), true},
+		{`"2h"`, Duration(2 * time.Hour), true},
+		{`"1m30s"`, Duration(90 * time.Second), true},
+		{`1500`, Duration(1500 * time.Millisecond), true},
+		{`"250"`, Duration(250 * time.Millisecond), true},
+		{`null`, 0, true},
+		{`"1.5"`, 0, false},
+		{`"5 parsecs"`, 0, false},
+		{`true`, 0, false},
+	}
+	for _, test := range tests {
+		var d Duration
+		err := json.Unmarshal([]byte(test.input), &d)
+		if (err == nil) != test.valid || d != test.expected {
+			t.Fatalf("unexpected duration of %v: %v %v", test.input, d, err)
+		}
+	}
+	b, err := json.Marshal(Duration(90 * time.Second))
+	if err != nil || string(b) != `"1m30s"` {
+		t.Fatalf("unexpected json: %s %v", b, err)
+	}
+}
+
+func TestSize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Size
+		valid    bool
+	}{
+		{`1024`, 1024, true},
+		{`"128MiB"`, 128 * Mebibyte, true},
+		{`"128 mib"`, 128 * Mebibyte, true},
+		{`"10MB"`, 10 * Megabyte, true},
+		{`"1.5KiB"`, 1536, true},
+		{`"4kB"`, 4000, true},
+		{`"2TB"`, 2 * Terabyte, true},
+		{`"10B"`, 10, true},
+		{`"10XB"`, 0, false},
+		{`"-1"`, 0, false},
+		{`"MiB"`, 0, false},
+		{`"99999999TiB"`, 0, false},
+	}
+	for _, test := range tests {
+		var s Size
+		err := json.Unmarshal([]byte(test.input), &s)
+		if (err == nil) != test.valid || s != test.expected {
+			t.Fatalf("unexpected size of %v: %v %v", test.input, s, err)
+		}
+	}
+	for _, test := range []struct {
+		size     Size
+		expected string
+	}{
+		{0, "0B"},
+		{1536, "1536B"},
+		{2 * Kibibyte, "2KiB"},
+		{3 * Gigabyte, "3GB"},
+		{Tebibyte, "1TiB"},
+	} {
+		if test.size.String() != test.expected {
+			t.Fatalf("unexpected string of %d: %v", test.size, test.size)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+/*
+Package vault provides a configuration secret provider for HashiCorp Vault.
+
+Configuration values in form of vault://<path>#<key> are replaced by field key
+of the secret at path, e.g. vault://secret/db#password. Both KV version 1 and
+version 2 (vault://secret/data/db#password) secrets engines are supported.
+Address and token of the Vault server are read from environment variables
+VAULT_ADDR, VAULT_TOKEN and VAULT_NAMESPACE unless given in options.
+*/
+package vault
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goburrow/melon/configuration"
+	"github.com/goburrow/melon/core"
+)
+
+// Scheme is the URL scheme of Vault secret references.
+const Scheme = "vault"
+
+const defaultAddress = "https://127.0.0.1:8200"
+
+// provider reads secrets from Vault HTTP API.
+type provider struct {
+	address   string
+	token     string
+	namespace string
+	client    *http.Client
+
+	mu sync.Mutex
+	// cache contains secrets by path so that each path is read once.
+	cache map[string]map[string]interface{}
+}
+
+// Option adds option for the provider.
+type Option func(p *provider)
+
+// WithAddress sets address of the Vault server.
+func WithAddress(address string) Option {
+	return func(p *provider) {
+		p.address = address
+	}
+}
+
+// WithToken sets token to authenticate with the Vault server.
+func WithToken(token string) Option {
+	return func(p *provider) {
+		p.token = token
+	}
+}
+
+// WithClient sets HTTP client to connect to the Vault server.
+func WithClient(client *http.Client) Option {
+	return func(p *provider) {
+		p.client = client
+	}
+}
+
+// NewProvider returns a configuration.SecretProvider for Vault secrets.
+func NewProvider(options ...Option) configuration.SecretProvider {
+	p := &provider{
+		address:   os.Getenv("VAULT_ADDR"),
+		token:     os.Getenv("VAULT_TOKEN"),
+		namespace: os.Getenv("VAULT_NAMESPACE"),
+		client:    &http.Client{Timeout: 10 * time.Second},
+		cache:     make(map[string]map[string]interface{}),
+	}
+	for _, opt := range options {
+		opt(p)
+	}
+	if p.address == "" {
+		p.address = defaultAddress
+	}
+	return p
+}
+
+// Secret returns field of the secret referred by ref.
+func (p *provider) Secret(ref string) (string, error) {
+	path := strings.TrimPrefix(ref, Scheme+"://")
+	i := strings.LastIndexByte(path, '#')
+	if i <= 0 || i == len(path)-1 {
+		return "", fmt.Errorf("invalid vault reference %s: expected %s://<path>#<key>", ref, Scheme)
+	}
+	path, key := strings.Trim(path[:i], "/"), path[i+1:]
+	data, err := p.read(path)
+	if err != nil {
+		return "", err
+	}
+	v, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("vault: key %s not found in %s", key, path)
+	}
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprint(v), nil
+}
+
+func (p *provider) read(path string) (map[string]interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if data, ok := p.cache[path]; ok {
+		return data, nil
+	}
+	req, err := http.NewRequest("GET", strings.TrimRight(p.address, "/")+"/v1/"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if p.token != "" {
+		req.Header.Set("X-Vault-Token", p.token)
+	}
+	if p.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.namespace)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault: could not read %s: %s", path, resp.Status)
+	}
+	var secret struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return nil, fmt.Errorf("vault: could not decode %s: %v", path, err)
+	}
+	data := secret.Data
+	// KV version 2 nests secret in data along with its metadata.
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, ok = data["metadata"]; ok {
+			data = nested
+		}
+	}
+	p.cache[path] = data
+	return data, nil
+}
+
+type bundle struct {
+	options []Option
+}
+
+func (b *bundle) Initialize(bootstrap *core.Bootstrap) {
+	f, ok := bootstrap.ConfigurationFactory.(*configuration.Factory)
+	if ok {
+		f.SetSecretProvider(Scheme, NewProvider(b.options...))
+	}
+}
+
+func (b *bundle) Run(config interface{}, env *core.Environment) error {
+	return nil
+}
+
+// NewBundle creates a Bundle that resolves Vault secrets in configuration.
+func NewBundle(options ...Option) core.Bundle {
+	return &bundle{options: options}
+}
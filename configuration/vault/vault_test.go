@@ -0,0 +1,54 @@
+package vault
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProvider(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("X-Vault-Token") != "root" {
+			http.Error(w, "permission denied", http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/db":
+			w.Write([]byte(`{"data":{"password":"melon","port":5432}}`))
+		case "/v1/kv/data/db":
+			w.Write([]byte(`{"data":{"data":{"password":"melon2"},"metadata":{"version":1}}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	p := NewProvider(WithAddress(server.URL), WithToken("root"))
+	tests := []struct {
+		ref   string
+		value string
+		valid bool
+	}{
+		{"vault://secret/db#password", "melon", true},
+		{"vault://secret/db#port", "5432", true},
+		{"vault://kv/data/db#password", "melon2", true},
+		{"vault://secret/db#user", "", false},
+		{"vault://secret/none#password", "", false},
+		{"vault://secret/db", "", false},
+	}
+	for _, test := range tests {
+		v, err := p.Secret(test.ref)
+		if (err == nil) != test.valid || v != test.value {
+			t.Fatalf("unexpected secret of %+v: %v %v", test, v, err)
+		}
+	}
+	if requests != 3 {
+		t.Fatalf("unexpected number of requests: %v", requests)
+	}
+	_, err := NewProvider(WithAddress(server.URL)).Secret("vault://secret/db#password")
+	if err == nil {
+		t.Fatal("error expected")
+	}
+}
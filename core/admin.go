@@ -2,27 +2,53 @@ package core
 
 import (
 	"bytes"
+	"encoding/json"
+	"expvar"
 	"fmt"
+	"html/template"
+	"io"
 	"net/http"
 	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/goburrow/melon/health"
 )
 
 const (
 	pingPath        = "/ping"
+	pingBody        = "pong\n"
 	runtimePath     = "/runtime"
 	healthCheckPath = "/healthcheck"
+	livePath        = "/live"
+	readyPath       = "/ready"
+	infoPath        = "/info"
+	varsPath        = "/vars"
+	componentsPath  = "/components"
 	tasksPath       = "/tasks"
 
 	adminHTML = `<!DOCTYPE html>
 <html>
 <head>
-	<title>Operational Menu</title>
+	<title>{{.Name}} - Operational Menu</title>
 </head>
 <body>
-	<h1>Operational Menu</h1>
-	<ul>%[1]s</ul>
+	<h1>{{.Name}} - Operational Menu</h1>
+	<p>{{with .Version}}Version {{.}}, {{end}}Uptime {{.Uptime}}</p>
+	<ul>{{range .Handlers}}
+		<li><a href="{{$.ContextPath}}{{.Path}}">{{.Name}}</a></li>{{end}}
+	</ul>
+	<h2>Tasks</h2>
+	<ul>{{range .Tasks}}
+		<li><form method="post" action="{{$.ContextPath}}/tasks/{{.}}" onsubmit="var p = this.elements[0].value; if (p) this.action += '?' + p;">
+			<code>{{.}}</code> <input type="text" placeholder="key=value&amp;..."> <input type="submit" value="Run">
+		</form></li>{{end}}
+	</ul>
 </body>
 </html>
 `
@@ -32,7 +58,8 @@ const (
 !!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!
 `
 
-	gcTaskName = "gc"
+	gcTaskName       = "gc"
+	shutdownTaskName = "shutdown"
 )
 
 // AdminHandler is an item listed in the admin homepage.
@@ -43,21 +70,51 @@ type AdminHandler interface {
 }
 
 // AdminEnvironment is an environment context for administrating the application.
+// HealthChecks are run by admin endpoint /healthcheck, it can be replaced,
+// e.g. to run checks serially, before the environment is started.
+// ReadinessChecks are run by admin endpoint /ready, which tells whether the
+// application can serve traffic, while /live only tells it is running.
+// HealthPolicy aggregates results of the checks, default is
+// health.CriticalHealthy.
 type AdminEnvironment struct {
-	Router       Router
-	HealthChecks health.Registry
-
+	Router          Router
+	HealthChecks    health.Registry
+	ReadinessChecks health.Registry
+	HealthPolicy    health.Policy
+	// StartupHealthCheck enables running health checks after the environment
+	// is started and aborting startup if they fail according to HealthPolicy.
+	StartupHealthCheck bool
+	// DrainPeriod is the time waited after Drain before the server is stopped
+	// so that load balancers stop sending new requests.
+	DrainPeriod time.Duration
+	// Vars are application variables shown at /vars along with variables
+	// published in package expvar. Unlike expvar.Publish, they are not global
+	// so names can be reused by other environments.
+	Vars *expvar.Map
+
+	ping     *pingHandler
 	handlers []AdminHandler
+	routes   []adminRoute
 	tasks    []Task
+	draining int32
+
+	shutdownOnce sync.Once
+	shutdown     chan struct{}
 }
 
 // NewAdminEnvironment allocates and returns a new AdminEnvironment.
 func NewAdminEnvironment() *AdminEnvironment {
 	env := &AdminEnvironment{
-		HealthChecks: health.NewRegistry(),
+		HealthChecks:    health.NewRegistry(),
+		ReadinessChecks: health.NewRegistry(),
+		HealthPolicy:    health.CriticalHealthy,
+		Vars:            new(expvar.Map).Init(),
+		shutdown:        make(chan struct{}),
 	}
 	// Default handlers
-	env.AddHandler(&pingHandler{}, &runtimeHandler{}, &healthCheckHandler{env.HealthChecks})
+	env.ping = NewPingHandler("", "").(*pingHandler)
+	env.AddHandler(env.ping, &runtimeHandler{}, &healthCheckHandler{env},
+		&liveHandler{}, &readyHandler{env}, &varsHandler{env})
 	// Default tasks
 	env.AddTask(&gcTask{})
 	return env
@@ -73,16 +130,89 @@ func (env *AdminEnvironment) AddHandler(handler ...AdminHandler) {
 	env.handlers = append(env.handlers, handler...)
 }
 
+// SetPing changes path and response body of the ping endpoint. Default values
+// are used if they are empty.
+func (env *AdminEnvironment) SetPing(path, body string) {
+	env.ping.set(path, body)
+}
+
+// adminRoute is a handler registered by Handle.
+type adminRoute struct {
+	method  string
+	pattern string
+	handler http.Handler
+}
+
+// Handle registers handler for the given method and pattern in the admin
+// router when the environment starts, so bundles can add admin endpoints
+// before the server is built. Unlike AddHandler, it is not listed in the admin
+// homepage. Handle is not concurrent-safe.
+func (env *AdminEnvironment) Handle(method, pattern string, handler http.Handler) {
+	env.routes = append(env.routes, adminRoute{method: method, pattern: pattern, handler: handler})
+}
+
+// Drain marks the application not ready so that load balancers stop sending
+// new requests while it is shutting down.
+func (env *AdminEnvironment) Drain() {
+	if atomic.SwapInt32(&env.draining, 1) == 0 {
+		GetLogger("melon").Infof("draining")
+	}
+}
+
+// Draining returns true after Drain is called.
+func (env *AdminEnvironment) Draining() bool {
+	return atomic.LoadInt32(&env.draining) != 0
+}
+
+// Shutdown requests the server to stop gracefully as if it received an
+// interrupt signal.
+func (env *AdminEnvironment) Shutdown() {
+	env.shutdownOnce.Do(func() {
+		close(env.shutdown)
+	})
+}
+
+// ShutdownRequested returns a channel which is closed when Shutdown is called.
+func (env *AdminEnvironment) ShutdownRequested() <-chan struct{} {
+	return env.shutdown
+}
+
+// CheckHealth runs health checks and returns an error naming unhealthy checks
+// if they fail according to HealthPolicy.
+func (env *AdminEnvironment) CheckHealth() error {
+	results := env.HealthChecks.RunCheckers()
+	policy := env.HealthPolicy
+	if policy == nil {
+		policy = health.CriticalHealthy
+	}
+	if policy(results) {
+		return nil
+	}
+	var failed []string
+	for name, result := range results {
+		if !result.Healthy() {
+			failed = append(failed, fmt.Sprintf("%s (%s)", name, result.Message()))
+		}
+	}
+	sort.Strings(failed)
+	return fmt.Errorf("unhealthy: %s", strings.Join(failed, ", "))
+}
+
 // start registers all required HTTP handlers
 func (env *AdminEnvironment) start() {
 	env.Router.Handle("GET", "/", &adminIndex{
 		handlers:    env.handlers,
+		tasks:       env.tasks,
 		contextPath: env.Router.PathPrefix(),
+		started:     time.Now(),
 	})
 	// Registered handlers
 	for _, h := range env.handlers {
 		env.Router.Handle("*", h.Path(), h)
 	}
+	for _, r := range env.routes {
+		env.Router.Handle(r.method, r.pattern, r.handler)
+	}
 	// Registered tasks
 	for _, task := range env.tasks {
 		path := tasksPath + "/" + task.Name()
@@ -102,7 +232,7 @@ func (env *AdminEnvironment) logTasks() {
 	GetLogger("melon").Infof("tasks =\n\n%s", buf.String())
 }
 
-// logTasks prints all registered tasks to the log
+// logHealthChecks prints all registered health checks to the log
 func (env *AdminEnvironment) logHealthChecks() {
 	names := env.HealthChecks.Names()
 	logger := GetLogger("melon")
@@ -112,36 +242,88 @@ func (env *AdminEnvironment) logHealthChecks() {
 	}
 }
 
-// Task is simply a HTTP Handler.
+// CanaryFactory is a factory for configuring synthetic checks for the environment.
+type CanaryFactory interface {
+	ConfigureCanary(*Environment) error
+}
+
+// WatchdogFactory is a factory for configuring runtime watchdog for the environment.
+type WatchdogFactory interface {
+	ConfigureWatchdog(*Environment) error
+}
+
+// MockFactory is a factory for configuring mocked routes for the environment.
+type MockFactory interface {
+	ConfigureMock(*Environment) error
+}
+
+// DiagnosticsFactory is a factory for configuring diagnostics dump for the
+// environment. configuration is the application configuration to be summarized.
+type DiagnosticsFactory interface {
+	ConfigureDiagnostics(env *Environment, configuration interface{}) error
+}
+
+// DebugFactory is a factory for configuring debugging endpoints for the environment.
+type DebugFactory interface {
+	ConfigureDebug(*Environment) error
+}
+
+// TracingFactory is a factory for configuring request tracing for the environment.
+type TracingFactory interface {
+	ConfigureTracing(*Environment) error
+}
+
+// Task is simply a HTTP Handler which is served at POST /tasks/{name} of
+// the admin handler. See NewTask for tasks taking parameters.
 type Task interface {
 	Name() string
 	http.Handler
 }
 
+// adminIndexTemplate renders adminHTML.
+var adminIndexTemplate = template.Must(template.New("admin").Parse(adminHTML))
+
 // adminIndex is the home page of admin.
 type adminIndex struct {
 	handlers    []AdminHandler
+	tasks       []Task
 	contextPath string
+	started     time.Time
 }
 
 // ServeHTTP handles request to the root of Admin page
 func (handler *adminIndex) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	info := GetBuildInfo()
+	data := struct {
+		Name        string
+		Version     string
+		Uptime      time.Duration
+		ContextPath string
+		Handlers    []AdminHandler
+		Tasks       []string
+	}{
+		Name:        info.Name,
+		Version:     info.Version,
+		Uptime:      time.Since(handler.started).Truncate(time.Second),
+		ContextPath: handler.contextPath,
+		Handlers:    handler.handlers,
+	}
+	for _, t := range handler.tasks {
+		data.Tasks = append(data.Tasks, t.Name())
+	}
 	var buf bytes.Buffer
-
-	for _, h := range handler.handlers {
-		fmt.Fprintf(&buf, "<li><a href=\"%[1]s%[2]s\">%[3]s</a></li>",
-			handler.contextPath, h.Path(), h.Name())
+	if err := adminIndexTemplate.Execute(&buf, &data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
-
 	w.Header().Set("Cache-Control", "must-revalidate,no-cache,no-store")
 	w.Header().Set("Content-Type", "text/html")
-
-	fmt.Fprintf(w, adminHTML, buf.String())
+	w.Write(buf.Bytes())
 }
 
 // healthCheckHandler is the http handler for /healthcheck page
 type healthCheckHandler struct {
-	registry health.Registry
+	env *AdminEnvironment
 }
 
 func (handler *healthCheckHandler) Name() string {
@@ -152,50 +334,129 @@ func (handler *healthCheckHandler) Path() string {
 	return healthCheckPath
 }
 
+// healthCheckInfo is the result of a health check with duration in milliseconds.
+type healthCheckInfo struct {
+	Healthy  bool
+	Critical bool
+	Message  string `json:",omitempty"`
+	Cause    string `json:",omitempty"`
+	Duration float64
+}
+
 func (handler *healthCheckHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Cache-Control", "must-revalidate,no-cache,no-store")
 
-	results := handler.registry.RunCheckers()
+	results := handler.env.HealthChecks.RunCheckers()
 	if len(results) == 0 {
 		http.Error(w, "No health checks registered.", http.StatusNotImplemented)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	if !isAllHealthy(results) {
-		w.WriteHeader(http.StatusInternalServerError)
-	}
-	first := true
-	w.Write([]byte("{"))
+	writeHealthResults(w, results, handler.env.HealthPolicy, http.StatusInternalServerError)
+}
+
+// writeHealthResults writes results as JSON with the given status code if they
+// are unhealthy according to policy.
+func writeHealthResults(w http.ResponseWriter, results map[string]health.Result, policy health.Policy, unhealthyStatus int) {
+	info := make(map[string]healthCheckInfo, len(results))
 	for name, result := range results {
-		if first {
-			first = false
-		} else {
-			w.Write([]byte(","))
-		}
-		fmt.Fprintf(w, "\n%q: {\"Healthy\": %t", name, result.Healthy())
-		if result.Message() != "" {
-			fmt.Fprintf(w, ", \"Message\": %q", result.Message())
+		i := healthCheckInfo{
+			Healthy:  result.Healthy(),
+			Critical: health.IsCritical(result),
+			Message:  result.Message(),
+			Duration: milliseconds(health.Duration(result)),
 		}
 		if result.Cause() != nil {
-			fmt.Fprintf(w, ", \"Cause\": %q", result.Cause())
+			i.Cause = result.Cause().Error()
 		}
-		w.Write([]byte("}"))
+		info[name] = i
+	}
+	b, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if policy == nil {
+		policy = health.CriticalHealthy
+	}
+	if !policy(results) {
+		w.WriteHeader(unhealthyStatus)
 	}
-	w.Write([]byte("\n}\n"))
+	w.Write(b)
 }
 
-// isAllHealthy checks if all are healthy
-func isAllHealthy(results map[string]health.Result) bool {
-	for _, result := range results {
-		if !result.Healthy() {
-			return false
-		}
+// liveHandler tells the application process is running. It does not run any
+// checks so that the process is not restarted because of its dependencies.
+type liveHandler struct {
+}
+
+func (handler *liveHandler) Name() string {
+	return "Liveness"
+}
+
+func (handler *liveHandler) Path() string {
+	return livePath
+}
+
+func (handler *liveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "must-revalidate,no-cache,no-store")
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte("live\n"))
+}
+
+// readyHandler tells the application can serve requests. It responds 503
+// when the application is draining or any readiness check fails.
+type readyHandler struct {
+	env *AdminEnvironment
+}
+
+func (handler *readyHandler) Name() string {
+	return "Readiness"
+}
+
+func (handler *readyHandler) Path() string {
+	return readyPath
+}
+
+func (handler *readyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "must-revalidate,no-cache,no-store")
+
+	if handler.env.Draining() {
+		http.Error(w, "Draining.", http.StatusServiceUnavailable)
+		return
 	}
-	return true
+	results := handler.env.ReadinessChecks.RunCheckers()
+	if len(results) == 0 {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("ready\n"))
+		return
+	}
+	writeHealthResults(w, results, handler.env.HealthPolicy, http.StatusServiceUnavailable)
 }
 
 // pingHandler handles ping request to admin /ping
 type pingHandler struct {
+	path string
+	body string
+}
+
+// NewPingHandler returns a handler at path responding body, which are
+// /ping and "pong\n" by default.
+func NewPingHandler(path, body string) AdminHandler {
+	h := &pingHandler{}
+	h.set(path, body)
+	return h
+}
+
+func (handler *pingHandler) set(path, body string) {
+	if path == "" {
+		path = pingPath
+	}
+	if body == "" {
+		body = pingBody
+	}
+	handler.path = path
+	handler.body = body
 }
 
 func (handler *pingHandler) Name() string {
@@ -203,13 +464,108 @@ func (handler *pingHandler) Name() string {
 }
 
 func (handler *pingHandler) Path() string {
-	return pingPath
+	return handler.path
 }
 
 func (handler *pingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Cache-Control", "must-revalidate,no-cache,no-store")
 	w.Header().Set("Content-Type", "text/plain")
-	w.Write([]byte("pong\n"))
+	w.Write([]byte(handler.body))
+}
+
+// filterRouter is implemented by routers which have filters.
+type filterRouter interface {
+	// VisitFilters calls fn with each filter, unwrapped from its options, and
+	// its priority in execution order.
+	VisitFilters(fn func(f http.Handler, priority int))
+}
+
+// componentsHandler lists filters, tasks, health checks and managed objects
+// registered to the environment.
+type componentsHandler struct {
+	env *Environment
+}
+
+func (handler *componentsHandler) Name() string {
+	return "Components"
+}
+
+func (handler *componentsHandler) Path() string {
+	return componentsPath
+}
+
+func (handler *componentsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "must-revalidate,no-cache,no-store")
+	w.Header().Set("Content-Type", "text/plain")
+
+	env := handler.env
+	fmt.Fprintf(w, "APPLICATION FILTERS\n")
+	writeFilters(w, env.Server.Router)
+	fmt.Fprintf(w, "\nADMIN FILTERS\n")
+	writeFilters(w, env.Admin.Router)
+	fmt.Fprintf(w, "\nTASKS\n")
+	for _, task := range env.Admin.tasks {
+		fmt.Fprintf(w, "    %s (%T)\n", task.Name(), task)
+	}
+	fmt.Fprintf(w, "\nHEALTH CHECKS\n")
+	for _, name := range env.Admin.HealthChecks.Names() {
+		fmt.Fprintf(w, "    %s\n", name)
+	}
+	fmt.Fprintf(w, "\nREADINESS CHECKS\n")
+	for _, name := range env.Admin.ReadinessChecks.Names() {
+		fmt.Fprintf(w, "    %s\n", name)
+	}
+	fmt.Fprintf(w, "\nMANAGED OBJECTS\n")
+	for _, m := range env.Lifecycle.ManagedObjects() {
+		fmt.Fprintf(w, "    %T\n", m)
+	}
+}
+
+// writeFilters prints priority and type of filters in execution order.
+func writeFilters(w io.Writer, router Router) {
+	fr, ok := router.(filterRouter)
+	if !ok {
+		return
+	}
+	fr.VisitFilters(func(f http.Handler, priority int) {
+		fmt.Fprintf(w, "    %-5d %T\n", priority, f)
+	})
+}
+
+// varsHandler displays expvar variables in JSON.
+type varsHandler struct {
+	env *AdminEnvironment
+}
+
+func (handler *varsHandler) Name() string {
+	return "Variables"
+}
+
+func (handler *varsHandler) Path() string {
+	return varsPath
+}
+
+func (handler *varsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "must-revalidate,no-cache,no-store")
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	fmt.Fprintf(w, "{\n")
+	first := true
+	write := func(kv expvar.KeyValue) {
+		if !first {
+			fmt.Fprintf(w, ",\n")
+		}
+		first = false
+		fmt.Fprintf(w, "%q: %s", kv.Key, kv.Value)
+	}
+	expvar.Do(func(kv expvar.KeyValue) {
+		// Environment variables take precedence.
+		if handler.env.Vars.Get(kv.Key) == nil {
+			write(kv)
+		}
+	})
+	handler.env.Vars.Do(write)
+	fmt.Fprintf(w, "\n}\n")
 }
 
 // runtimeHandler displays runtime statistics.
@@ -248,7 +604,62 @@ func (handler *runtimeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		m.NextGC, m.LastGC, m.PauseTotalNs, m.NumGC, m.EnableGC, m.DebugGC)
 }
 
-// gcTask performs a garbage collection
+// infoHandler displays information of the application including timings of
+// the last startup.
+type infoHandler struct {
+	timeline *Timeline
+}
+
+func (handler *infoHandler) Name() string {
+	return "Info"
+}
+
+func (handler *infoHandler) Path() string {
+	return infoPath
+}
+
+// phaseInfo is a phase with duration in milliseconds.
+type phaseInfo struct {
+	Name     string
+	Started  time.Time
+	Duration float64
+}
+
+func (handler *infoHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "must-revalidate,no-cache,no-store")
+
+	phases := handler.timeline.StartupPhases()
+	info := struct {
+		Build   BuildInfo
+		Startup struct {
+			Duration float64
+			Phases   []phaseInfo
+		}
+	}{}
+	info.Build = GetBuildInfo()
+	info.Startup.Duration = milliseconds(handler.timeline.StartupTime())
+	info.Startup.Phases = make([]phaseInfo, len(phases))
+	for i, p := range phases {
+		info.Startup.Phases[i] = phaseInfo{p.Name, p.Started, milliseconds(p.Duration)}
+	}
+	b, err := json.MarshalIndent(&info, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// gcTask performs a garbage collection and reports memory statistics before
+// and after it. With parameter free=true, memory is also returned to the
+// operating system:
+//
+// 	POST /tasks/gc?free=true
 type gcTask struct {
 }
 
@@ -257,7 +668,58 @@ func (*gcTask) Name() string {
 }
 
 func (*gcTask) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	w.Write([]byte("Running GC...\n"))
-	runtime.GC()
-	w.Write([]byte("Done!\n"))
+	w.Header().Set("Cache-Control", "must-revalidate,no-cache,no-store")
+	w.Header().Set("Content-Type", "text/plain")
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	started := time.Now()
+	if free, _ := strconv.ParseBool(r.URL.Query().Get("free")); free {
+		w.Write([]byte("Running GC and freeing OS memory...\n"))
+		debug.FreeOSMemory()
+	} else {
+		w.Write([]byte("Running GC...\n"))
+		runtime.GC()
+	}
+	elapsed := time.Since(started)
+	runtime.ReadMemStats(&after)
+	fmt.Fprintf(w, "Done in %v!\n", elapsed)
+	fmt.Fprintf(w, "%-14s %14s %14s\n", "", "Before", "After")
+	for _, m := range []struct {
+		name          string
+		before, after uint64
+	}{
+		{"HeapAlloc", before.HeapAlloc, after.HeapAlloc},
+		{"HeapInuse", before.HeapInuse, after.HeapInuse},
+		{"HeapIdle", before.HeapIdle, after.HeapIdle},
+		{"HeapReleased", before.HeapReleased, after.HeapReleased},
+		{"HeapObjects", before.HeapObjects, after.HeapObjects},
+		{"Sys", before.Sys, after.Sys},
+		{"NumGC", uint64(before.NumGC), uint64(after.NumGC)},
+	} {
+		fmt.Fprintf(w, "%-14s %14d %14d\n", m.name+":", m.before, m.after)
+	}
+}
+
+// shutdownTask stops the server gracefully.
+type shutdownTask struct {
+	env *AdminEnvironment
+}
+
+// NewShutdownTask returns admin task "shutdown" which drains and stops the
+// server. As it is able to stop the process, it should only be registered
+// when admin endpoints are protected.
+func NewShutdownTask(env *AdminEnvironment) Task {
+	return &shutdownTask{env: env}
+}
+
+func (*shutdownTask) Name() string {
+	return shutdownTaskName
+}
+
+func (t *shutdownTask) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	GetLogger("melon").Infof("shutdown requested by %s", r.RemoteAddr)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "shutting down\n")
+	t.env.Shutdown()
 }
@@ -0,0 +1,343 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goburrow/melon/health"
+)
+
+func TestHealthCheckHandler(t *testing.T) {
+	env := NewAdminEnvironment()
+	handler := &healthCheckHandler{env}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/healthcheck", nil))
+	if w.Code != http.StatusNotImplemented {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Body.String())
+	}
+
+	env.HealthChecks = health.NewRegistry(health.WithParallel(false))
+	env.HealthChecks.Register("db", health.CheckerFunc(func() health.Result {
+		return health.ResultHealthy("connected")
+	}))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/healthcheck", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Body.String())
+	}
+
+	env.HealthChecks.Register("cache", health.CheckerFunc(func() health.Result {
+		return health.ResultUnhealthy("", errors.New("timeout"))
+	}))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/healthcheck", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Body.String())
+	}
+	var info map[string]map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil {
+		t.Fatal(err)
+	}
+	if info["db"]["Healthy"] != true || info["db"]["Message"] != "connected" ||
+		info["cache"]["Healthy"] != false || info["cache"]["Cause"] != "timeout" {
+		t.Fatalf("unexpected response: %v", info)
+	}
+	if _, ok := info["cache"]["Duration"].(float64); !ok {
+		t.Fatalf("unexpected duration: %v", info["cache"])
+	}
+
+	env.HealthChecks.Register("cache", health.NonCritical(health.CheckerFunc(func() health.Result {
+		return health.ResultUnhealthy("", errors.New("timeout"))
+	})))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/healthcheck", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"Critical": false`) {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Body.String())
+	}
+	env.HealthPolicy = health.AllHealthy
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/healthcheck", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Body.String())
+	}
+}
+
+func TestLiveReadyHandlers(t *testing.T) {
+	env := NewAdminEnvironment()
+	env.HealthChecks.Register("db", health.CheckerFunc(func() health.Result {
+		return health.ResultUnhealthy("down", nil)
+	}))
+	live := &liveHandler{}
+	ready := &readyHandler{env}
+
+	w := httptest.NewRecorder()
+	live.ServeHTTP(w, httptest.NewRequest("GET", "/live", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "live\n" {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	ready.ServeHTTP(w, httptest.NewRequest("GET", "/ready", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "ready\n" {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Body.String())
+	}
+
+	healthy := false
+	env.ReadinessChecks.Register("migration", health.CheckerFunc(func() health.Result {
+		if healthy {
+			return health.Healthy
+		}
+		return health.ResultUnhealthy("running", nil)
+	}))
+	w = httptest.NewRecorder()
+	ready.ServeHTTP(w, httptest.NewRequest("GET", "/ready", nil))
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Body.String())
+	}
+	healthy = true
+	w = httptest.NewRecorder()
+	ready.ServeHTTP(w, httptest.NewRequest("GET", "/ready", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Body.String())
+	}
+
+	env.Drain()
+	w = httptest.NewRecorder()
+	ready.ServeHTTP(w, httptest.NewRequest("GET", "/ready", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	live.ServeHTTP(w, httptest.NewRequest("GET", "/live", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Body.String())
+	}
+}
+
+func TestTask(t *testing.T) {
+	task := NewTask("flush", func(params url.Values, body io.Reader, w io.Writer) error {
+		b, err := ioutil.ReadAll(body)
+		if err != nil {
+			return err
+		}
+		if params.Get("fail") != "" {
+			return errors.New(params.Get("fail"))
+		}
+		fmt.Fprintf(w, "flushing %s\n", params.Get("cache"))
+		fmt.Fprintf(w, "%s\n", b)
+		return errors.New("partial")
+	})
+	if task.Name() != "flush" {
+		t.Fatalf("unexpected name: %v", task.Name())
+	}
+	w := httptest.NewRecorder()
+	task.ServeHTTP(w, httptest.NewRequest("POST", "/tasks/flush?cache=users", strings.NewReader("all")))
+	if w.Code != http.StatusOK || !w.Flushed || w.Body.String() != "flushing users\nall\nerror: partial\n" {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	task.ServeHTTP(w, httptest.NewRequest("POST", "/tasks/flush?fail=invalid", nil))
+	if w.Code != http.StatusInternalServerError || w.Body.String() != "error: invalid\n" {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Body.String())
+	}
+}
+
+func TestGCTask(t *testing.T) {
+	task := &gcTask{}
+	w := httptest.NewRecorder()
+	task.ServeHTTP(w, httptest.NewRequest("POST", "/tasks/gc?free=true", nil))
+	body := w.Body.String()
+	if w.Code != http.StatusOK || !strings.HasPrefix(body, "Running GC and freeing OS memory...\nDone in ") ||
+		!strings.Contains(body, "HeapAlloc:") || !strings.Contains(body, "NumGC:") {
+		t.Fatalf("unexpected response: %v %v", w.Code, body)
+	}
+}
+
+func TestVarsHandler(t *testing.T) {
+	env := NewAdminEnvironment()
+	handler := &varsHandler{env}
+	requests := new(expvar.Int)
+	requests.Set(3)
+	env.Vars.Set("requests", requests)
+	env.Vars.Set("cmdline", expvar.Func(func() interface{} {
+		return "overridden"
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/vars", nil))
+	var vars map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &vars); err != nil {
+		t.Fatalf("unexpected response: %v %s", err, w.Body.String())
+	}
+	if vars["requests"] != 3.0 || vars["cmdline"] != "overridden" || vars["memstats"] == nil {
+		t.Fatalf("unexpected vars: %s", w.Body.String())
+	}
+}
+
+func TestInfoHandler(t *testing.T) {
+	Version, Commit = "1.0.0", "abc123"
+	defer func() {
+		Version, Commit = "", ""
+	}()
+	timeline := NewTimeline()
+	handler := &infoHandler{timeline: timeline}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/info", nil))
+	var info struct {
+		Build BuildInfo
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil {
+		t.Fatalf("unexpected response: %v %s", err, w.Body.String())
+	}
+	if info.Build.Name == "" || info.Build.Version != "1.0.0" || info.Build.Commit != "abc123" ||
+		info.Build.GoVersion != runtime.Version() {
+		t.Fatalf("unexpected build info: %+v", info.Build)
+	}
+}
+
+type testFilterRouter struct {
+	Router
+	filters    []http.Handler
+	priorities []int
+}
+
+func (r *testFilterRouter) VisitFilters(fn func(http.Handler, int)) {
+	for i, f := range r.filters {
+		fn(f, r.priorities[i])
+	}
+}
+
+type testManaged struct{}
+
+func (testManaged) Start() error { return nil }
+func (testManaged) Stop() error  { return nil }
+
+func TestComponentsHandler(t *testing.T) {
+	env := NewEnvironment()
+	env.Server.Router = &testFilterRouter{
+		filters:    []http.Handler{http.NotFoundHandler()},
+		priorities: []int{3000},
+	}
+	env.Admin.HealthChecks.Register("database", health.CheckerFunc(func() health.Result {
+		return health.ResultHealthy("")
+	}))
+	env.Lifecycle.Manage(testManaged{})
+	handler := &componentsHandler{env}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/components", nil))
+	body := w.Body.String()
+	for _, s := range []string{"3000  http.HandlerFunc", "gc (*core.gcTask)", "    database\n", "    core.testManaged\n"} {
+		if !strings.Contains(body, s) {
+			t.Fatalf("%q expected: %s", s, body)
+		}
+	}
+}
+
+func TestCheckHealth(t *testing.T) {
+	env := NewAdminEnvironment()
+	if err := env.CheckHealth(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	env.HealthChecks.Register("cache", health.NonCritical(health.CheckerFunc(func() health.Result {
+		return health.ResultUnhealthy("evicted", nil)
+	})))
+	if err := env.CheckHealth(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	env.HealthChecks.Register("db", health.CheckerFunc(func() health.Result {
+		return health.ResultUnhealthy("connection refused", nil)
+	}))
+	err := env.CheckHealth()
+	if err == nil || err.Error() != "unhealthy: cache (evicted), db (connection refused)" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestAdminIndex(t *testing.T) {
+	Name, Version = "melon-test", "1.0.0"
+	defer func() {
+		Name, Version = "", ""
+	}()
+	handler := &adminIndex{
+		handlers:    []AdminHandler{NewPingHandler("", "")},
+		tasks:       []Task{&gcTask{}},
+		contextPath: "/admin",
+		started:     time.Now().Add(-time.Minute),
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/admin/", nil))
+	body := w.Body.String()
+	for _, s := range []string{
+		"<title>melon-test - Operational Menu</title>",
+		"Version 1.0.0, Uptime 1m0s",
+		`<a href="/admin/ping">Ping</a>`,
+		`<form method="post" action="/admin/tasks/gc"`,
+	} {
+		if !strings.Contains(body, s) {
+			t.Fatalf("%q expected: %s", s, body)
+		}
+	}
+}
+
+func TestShutdownTask(t *testing.T) {
+	env := NewAdminEnvironment()
+	task := NewShutdownTask(env)
+	select {
+	case <-env.ShutdownRequested():
+		t.Fatal("shutdown must not be requested")
+	default:
+	}
+	w := httptest.NewRecorder()
+	task.ServeHTTP(w, httptest.NewRequest("POST", "/tasks/shutdown", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "shutting down\n" {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Body.String())
+	}
+	select {
+	case <-env.ShutdownRequested():
+	default:
+		t.Fatal("shutdown must be requested")
+	}
+	// Requesting again must not panic.
+	env.Shutdown()
+}
+
+// testRouter records registered patterns.
+type testRouter struct {
+	nopRouter
+	patterns []string
+}
+
+func (r *testRouter) Handle(method, pattern string, handler http.Handler) {
+	r.patterns = append(r.patterns, method+" "+pattern)
+}
+
+func TestAdminHandle(t *testing.T) {
+	env := NewAdminEnvironment()
+	router := &testRouter{}
+	env.Handle("GET", "/custom/*", http.NotFoundHandler())
+	// Router is set after handlers are added.
+	env.Router = router
+	env.start()
+	found := false
+	for _, p := range router.patterns {
+		if p == "GET /custom/*" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("unexpected patterns: %v", router.patterns)
+	}
+}
@@ -3,6 +3,8 @@ Package core provides APIs and basic behaviors for a server stack like Dropwizar
 */
 package core
 
+import "io"
+
 // Bootstrap contains everything required to bootstrap a command
 type Bootstrap struct {
 	Application Bundle
@@ -10,9 +12,13 @@ type Bootstrap struct {
 
 	ConfigurationFactory ConfigurationFactory
 	ValidatorFactory     ValidatorFactory
+	// ConfigurationSourceProvider opens configuration sources given in
+	// Arguments. ConfigurationFactory uses its default if it is nil.
+	ConfigurationSourceProvider ConfigurationSourceProvider
 
 	bundles  []Bundle
 	commands []Command
+	hooks    []func(interface{}) error
 }
 
 // Bundles returns registered bundles.
@@ -36,6 +42,23 @@ func (bootstrap *Bootstrap) AddCommand(command Command) {
 	bootstrap.commands = append(bootstrap.commands, command)
 }
 
+// AddConfigurationHook adds a function which is invoked with the configuration
+// after it is parsed and before it is validated, e.g. to derive defaults from
+// other fields. AddConfigurationHook is not concurrent-safe.
+func (bootstrap *Bootstrap) AddConfigurationHook(hook func(interface{}) error) {
+	bootstrap.hooks = append(bootstrap.hooks, hook)
+}
+
+// RunConfigurationHooks invokes all registered configuration hooks in order.
+func (bootstrap *Bootstrap) RunConfigurationHooks(configuration interface{}) error {
+	for _, hook := range bootstrap.hooks {
+		if err := hook(configuration); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Run runs all registered bundles
 func (bootstrap *Bootstrap) Run(configuration interface{}, environment *Environment) error {
 	for _, bundle := range bootstrap.bundles {
@@ -73,6 +96,12 @@ type ConfigurationFactory interface {
 	BuildConfiguration(bootstrap *Bootstrap) (interface{}, error)
 }
 
+// ConfigurationSourceProvider provides content of configuration sources,
+// e.g. files or URLs.
+type ConfigurationSourceProvider interface {
+	Open(path string) (io.ReadCloser, error)
+}
+
 // Validator validates objects.
 type Validator interface {
 	Validate(interface{}) error
@@ -0,0 +1,35 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestConfigurationHooks(t *testing.T) {
+	var bootstrap Bootstrap
+	if err := bootstrap.RunConfigurationHooks(nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var calls []string
+	bootstrap.AddConfigurationHook(func(c interface{}) error {
+		calls = append(calls, "a")
+		*c.(*string) += "a"
+		return nil
+	})
+	bootstrap.AddConfigurationHook(func(c interface{}) error {
+		calls = append(calls, "b")
+		return errors.New("b")
+	})
+	bootstrap.AddConfigurationHook(func(c interface{}) error {
+		calls = append(calls, "c")
+		return nil
+	})
+	config := "x"
+	err := bootstrap.RunConfigurationHooks(&config)
+	if err == nil || err.Error() != "b" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(calls) != 2 || config != "xa" {
+		t.Fatalf("unexpected calls: %v, configuration: %s", calls, config)
+	}
+}
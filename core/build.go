@@ -0,0 +1,47 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// Build information of the application, which is usually set via linker flags:
+//
+// 	go build -ldflags "-X github.com/goburrow/melon/core.Version=1.0.0
+// 		-X github.com/goburrow/melon/core.Commit=$(git rev-parse HEAD)
+// 		-X github.com/goburrow/melon/core.BuildTime=$(date -u +%FT%TZ)"
+//
+// Values not set are read from build information embedded in the binary when
+// available (Go 1.18 or later).
+var (
+	Name      string
+	Version   string
+	Commit    string
+	BuildTime string
+)
+
+// BuildInfo contains application version and build details.
+type BuildInfo struct {
+	Name      string
+	Version   string
+	Commit    string
+	BuildTime string
+	GoVersion string
+}
+
+// GetBuildInfo returns build information of the running application.
+func GetBuildInfo() BuildInfo {
+	info := BuildInfo{
+		Name:      Name,
+		Version:   Version,
+		Commit:    Commit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+	}
+	readBuildInfo(&info)
+	if info.Name == "" && len(os.Args) > 0 {
+		info.Name = filepath.Base(os.Args[0])
+	}
+	return info
+}
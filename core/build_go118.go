@@ -0,0 +1,36 @@
+//go:build go1.18
+// +build go1.18
+
+package core
+
+import (
+	"path"
+	"runtime/debug"
+)
+
+// readBuildInfo fills missing fields from build information embedded by
+// the go command.
+func readBuildInfo(info *BuildInfo) {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+	if info.Name == "" && bi.Main.Path != "" {
+		info.Name = path.Base(bi.Main.Path)
+	}
+	if info.Version == "" && bi.Main.Version != "(devel)" {
+		info.Version = bi.Main.Version
+	}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			if info.Commit == "" {
+				info.Commit = s.Value
+			}
+		case "vcs.time":
+			if info.BuildTime == "" {
+				info.BuildTime = s.Value
+			}
+		}
+	}
+}
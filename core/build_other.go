@@ -0,0 +1,8 @@
+//go:build !go1.18
+// +build !go1.18
+
+package core
+
+// readBuildInfo does nothing as build settings are not available.
+func readBuildInfo(info *BuildInfo) {
+}
@@ -1,5 +1,15 @@
 package core
 
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/goburrow/melon/health"
+)
+
+const defaultWorkTimeout = 30 * time.Second
+
 // Managed is an interface for objects which need to be started and stopped as
 // the application is started or stopped.
 type Managed interface {
@@ -11,14 +21,40 @@ type Managed interface {
 	Stop() error
 }
 
+// HealthChecked is implemented by managed objects which report their health,
+// e.g. database pools. Their health checks are registered automatically
+// when the environment starts.
+type HealthChecked interface {
+	// HealthCheckName returns name of the health check.
+	HealthCheckName() string
+	health.Checker
+}
+
 // LifecycleEnvironment is an environment context to manage Managed objects.
 type LifecycleEnvironment struct {
+	// WorkTimeout is the maximum duration to wait for tracked work when
+	// stopping. Default is 30 seconds.
+	WorkTimeout time.Duration
+	// Timeline records durations of startup and shutdown phases.
+	Timeline *Timeline
+
 	managedObjects []Managed
+	works          *WorkTracker
 }
 
 // NewLifecycleEnvironment allocates and returns a new LifecycleEnvironment.
 func NewLifecycleEnvironment() *LifecycleEnvironment {
-	return &LifecycleEnvironment{}
+	return &LifecycleEnvironment{
+		WorkTimeout: defaultWorkTimeout,
+		Timeline:    NewTimeline(),
+		works:       NewWorkTracker(),
+	}
+}
+
+// TrackWork returns the WorkTracker of the application. In-flight work
+// registered to the tracker is waited for before managed objects are stopped.
+func (env *LifecycleEnvironment) TrackWork() *WorkTracker {
+	return env.works
 }
 
 // Manage adds the given object to the list of objects managed by the server's
@@ -27,24 +63,54 @@ func (env *LifecycleEnvironment) Manage(obj Managed) {
 	env.managedObjects = append(env.managedObjects, obj)
 }
 
+// ManagedObjects returns objects managed by the server's lifecycle in
+// starting order.
+func (env *LifecycleEnvironment) ManagedObjects() []Managed {
+	return env.managedObjects
+}
+
 // start indicates the application is going to start.
 func (env *LifecycleEnvironment) start() {
 	// Starting managed objects in order.
 	for _, m := range env.managedObjects {
+		started := time.Now()
 		// Panic from a managed object will stop the application.
 		if err := m.Start(); err != nil {
 			GetLogger("melon").Errorf("error starting managed object %#v: %v", m, err)
+			ReportError(&ErrorEvent{Source: "lifecycle", Err: err})
 		}
+		env.Timeline.Startup(fmt.Sprintf("start %T", m), started)
 	}
 }
 
 // stop indicates the application has stopped.
 func (env *LifecycleEnvironment) stop() {
+	started := time.Now()
+	env.waitWork()
+	env.Timeline.Shutdown("wait work", started)
 	// Stopping managed objects in reversed order.
 	for i := len(env.managedObjects) - 1; i >= 0; i-- {
+		m := env.managedObjects[i]
+		started = time.Now()
 		// Panic from a managed object will NOT stop the application immediately.
-		stopManagedObject(env.managedObjects[i])
+		stopManagedObject(m)
+		env.Timeline.Shutdown(fmt.Sprintf("stop %T", m), started)
+	}
+}
+
+// waitWork waits for tracked work and logs work which is abandoned.
+func (env *LifecycleEnvironment) waitWork() {
+	abandoned := env.works.Shutdown(env.WorkTimeout)
+	if len(abandoned) == 0 {
+		return
+	}
+	var buf bytes.Buffer
+	now := time.Now()
+	for _, w := range abandoned {
+		fmt.Fprintf(&buf, "    %s (%v)\n", w.Name, now.Sub(w.Started))
 	}
+	GetLogger("melon").Warnf("abandoned %d in-flight work after %v =\n\n%s",
+		len(abandoned), env.WorkTimeout, buf.String())
 }
 
 func stopManagedObject(m Managed) {
@@ -52,8 +118,10 @@ func stopManagedObject(m Managed) {
 	defer func() {
 		if err != nil {
 			GetLogger("melon").Errorf("error stopping managed object %#v: %v", m, err)
+			ReportError(&ErrorEvent{Source: "lifecycle", Err: err})
 		} else if r := recover(); r != nil {
 			GetLogger("melon").Errorf("panic stopping managed object %#v: %v", m, r)
+			ReportError(&ErrorEvent{Source: "lifecycle", Err: fmt.Errorf("panic: %v", r)})
 		}
 	}()
 	err = m.Stop()
@@ -75,23 +143,47 @@ type Environment struct {
 
 // NewEnvironment allocates and returns new Environment
 func NewEnvironment() *Environment {
-	return &Environment{
+	env := &Environment{
 		Server:    NewServerEnvironment(),
 		Lifecycle: NewLifecycleEnvironment(),
 		Admin:     NewAdminEnvironment(),
 	}
+	env.Admin.AddHandler(&infoHandler{timeline: env.Lifecycle.Timeline}, &componentsHandler{env})
+	return env
 }
 
 // SetStarting calls onStarting of all registered event listeners.
 func (env *Environment) Start() error {
+	started := time.Now()
+	env.registerHealthChecks()
 	env.Server.start()
 	env.Admin.start()
+	env.Lifecycle.Timeline.Startup("register resources", started)
 	env.Lifecycle.start()
 	return nil
 }
 
+// registerHealthChecks adds health checks of managed objects implementing
+// HealthChecked unless checks having the same names are already registered.
+func (env *Environment) registerHealthChecks() {
+	registered := make(map[string]bool)
+	for _, name := range env.Admin.HealthChecks.Names() {
+		registered[name] = true
+	}
+	for _, m := range env.Lifecycle.managedObjects {
+		if c, ok := m.(HealthChecked); ok {
+			name := c.HealthCheckName()
+			if !registered[name] {
+				env.Admin.HealthChecks.Register(name, c)
+				registered[name] = true
+			}
+		}
+	}
+}
+
 // SetStopped calls onStopped of all registered event listeners in descending order.
 func (env *Environment) Stop() error {
 	env.Lifecycle.stop()
+	env.Lifecycle.Timeline.ShutdownDone()
 	return nil
 }
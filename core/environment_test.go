@@ -2,8 +2,13 @@ package core
 
 import (
 	"bytes"
+	"errors"
 	"io"
+	"net/http"
 	"testing"
+	"time"
+
+	"github.com/goburrow/melon/health"
 )
 
 type writerManaged struct {
@@ -61,3 +66,158 @@ func TestPanicManagedObject(t *testing.T) {
 		t.Fatalf("unexpected stopping order %s", buf.String())
 	}
 }
+
+type errorManaged struct {
+}
+
+func (m *errorManaged) Start() error {
+	return errors.New("start")
+}
+
+func (m *errorManaged) Stop() error {
+	return errors.New("stop")
+}
+
+type eventsReporter []*ErrorEvent
+
+func (r *eventsReporter) ReportError(e *ErrorEvent) {
+	*r = append(*r, e)
+}
+
+func TestReportLifecycleErrors(t *testing.T) {
+	var reporter eventsReporter
+	SetErrorReporter(&reporter)
+	defer SetErrorReporter(nil)
+
+	lifecycle := NewLifecycleEnvironment()
+	lifecycle.Manage(&errorManaged{})
+	lifecycle.start()
+	// Panic when starting is not recovered.
+	lifecycle.Manage(&panicManaged{})
+	lifecycle.stop()
+	if len(reporter) != 3 {
+		t.Fatalf("unexpected events: %#v", reporter)
+	}
+	for _, e := range reporter {
+		if e.Source != "lifecycle" || e.Err == nil {
+			t.Fatalf("unexpected event: %#v", e)
+		}
+	}
+}
+
+func TestWorkTracker(t *testing.T) {
+	lifecycle := NewLifecycleEnvironment()
+	lifecycle.WorkTimeout = 10 * time.Millisecond
+	tracker := lifecycle.TrackWork()
+
+	w1, err := tracker.Begin("job1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w2, err := tracker.Begin("job2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w1.Done()
+	w1.Done()
+	if pending := tracker.Pending(); len(pending) != 1 || pending[0] != w2 {
+		t.Fatalf("unexpected pending work: %v", pending)
+	}
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		w2.Done()
+	}()
+	if abandoned := tracker.Shutdown(time.Second); len(abandoned) != 0 {
+		t.Fatalf("unexpected abandoned work: %v", abandoned)
+	}
+	if _, err = tracker.Begin("job3"); err != ErrShuttingDown {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestWorkTrackerAbandoned(t *testing.T) {
+	tracker := NewWorkTracker()
+	w, err := tracker.Begin("job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	abandoned := tracker.Shutdown(time.Millisecond)
+	if len(abandoned) != 1 || abandoned[0] != w {
+		t.Fatalf("unexpected abandoned work: %v", abandoned)
+	}
+	w.Done()
+	if pending := tracker.Pending(); len(pending) != 0 {
+		t.Fatalf("unexpected pending work: %v", pending)
+	}
+}
+
+type nopRouter struct{}
+
+func (*nopRouter) Handle(method, pattern string, handler http.Handler) {}
+
+func (*nopRouter) PathPrefix() string {
+	return ""
+}
+
+func (*nopRouter) Endpoints() []string {
+	return nil
+}
+
+func TestTimeline(t *testing.T) {
+	env := NewEnvironment()
+	env.Server.Router = &nopRouter{}
+	env.Admin.Router = &nopRouter{}
+	env.Lifecycle.Manage(&writerManaged{"1", &bytes.Buffer{}})
+	env.Start()
+	env.Stop()
+
+	timeline := env.Lifecycle.Timeline
+	startup := timeline.StartupPhases()
+	if len(startup) != 2 || startup[0].Name != "register resources" || startup[1].Name != "start *core.writerManaged" {
+		t.Fatalf("unexpected startup phases: %+v", startup)
+	}
+	shutdown := timeline.ShutdownPhases()
+	if len(shutdown) != 2 || shutdown[0].Name != "wait work" || shutdown[1].Name != "stop *core.writerManaged" {
+		t.Fatalf("unexpected shutdown phases: %+v", shutdown)
+	}
+	if timeline.StartupTime() < startup[1].Duration {
+		t.Fatalf("unexpected startup time: %v", timeline.StartupTime())
+	}
+}
+
+type checkedManaged struct {
+	writerManaged
+	name string
+}
+
+func (m *checkedManaged) HealthCheckName() string {
+	return m.name
+}
+
+func (m *checkedManaged) Check() health.Result {
+	return health.ResultUnhealthy(m.name, nil)
+}
+
+func TestHealthCheckedManagedObject(t *testing.T) {
+	env := NewEnvironment()
+	env.Server.Router = &nopRouter{}
+	env.Admin.Router = &nopRouter{}
+	env.Admin.HealthChecks.Register("db", health.CheckerFunc(func() health.Result {
+		return health.ResultHealthy("registered")
+	}))
+	env.Lifecycle.Manage(&checkedManaged{writerManaged{"1", &bytes.Buffer{}}, "db"})
+	env.Lifecycle.Manage(&checkedManaged{writerManaged{"2", &bytes.Buffer{}}, "queue"})
+	env.Start()
+	defer env.Stop()
+
+	names := env.Admin.HealthChecks.Names()
+	if len(names) != 2 {
+		t.Fatalf("unexpected health checks: %v", names)
+	}
+	if r := env.Admin.HealthChecks.RunChecker("db"); r.Message() != "registered" {
+		t.Fatalf("unexpected result: %v", r.Message())
+	}
+	if r := env.Admin.HealthChecks.RunChecker("queue"); r.Healthy() || r.Message() != "queue" {
+		t.Fatalf("unexpected result: %v", r.Message())
+	}
+}
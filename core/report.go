@@ -0,0 +1,42 @@
+package core
+
+import "net/http"
+
+// ErrorEvent is an error or a panic occurred in the application.
+type ErrorEvent struct {
+	// Source is where the error comes from, e.g. "panic" or "lifecycle".
+	Source string
+	// Err is the error or recovered value.
+	Err error
+	// Stack is the optional stack trace.
+	Stack []byte
+	// Request is the HTTP request being processed if available.
+	Request *http.Request
+}
+
+// ErrorReporter sends errors to an external service.
+type ErrorReporter interface {
+	// ReportError reports the given event. It must not block the caller.
+	ReportError(*ErrorEvent)
+}
+
+var errorReporter ErrorReporter
+
+// ReportError reports the event to the ErrorReporter set by SetErrorReporter.
+// The event is discarded if there is no reporter.
+func ReportError(event *ErrorEvent) {
+	if errorReporter != nil {
+		errorReporter.ReportError(event)
+	}
+}
+
+// SetErrorReporter sets the reporter for ReportError. SetErrorReporter is not
+// concurrent-safe and should be called before the application starts.
+func SetErrorReporter(reporter ErrorReporter) {
+	errorReporter = reporter
+}
+
+// ErrorReportingFactory is a factory for configuring error reporting for the environment.
+type ErrorReportingFactory interface {
+	ConfigureErrorReporting(*Environment) error
+}
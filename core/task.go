@@ -0,0 +1,60 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// TaskFunc performs a task with query parameters and body of the request,
+// writing its output to w.
+type TaskFunc func(params url.Values, body io.Reader, w io.Writer) error
+
+// funcTask is a Task running TaskFunc.
+type funcTask struct {
+	name string
+	fn   TaskFunc
+}
+
+// NewTask returns a Task named name which runs fn when requested, e.g.
+// POST /tasks/name?key=value. The output is sent to the client as it is
+// written so long running tasks can report their progress. If fn returns
+// an error, it is responded with status 500 unless output has been written.
+func NewTask(name string, fn TaskFunc) Task {
+	return &funcTask{name: name, fn: fn}
+}
+
+func (t *funcTask) Name() string {
+	return t.name
+}
+
+func (t *funcTask) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "must-revalidate,no-cache,no-store")
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	output := &taskWriter{w: w}
+	err := t.fn(r.URL.Query(), r.Body, output)
+	if err == nil {
+		return
+	}
+	GetLogger("melon").Errorf("error running task %s: %v", t.name, err)
+	if !output.written {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	fmt.Fprintf(w, "error: %v\n", err)
+}
+
+// taskWriter flushes every write to the client.
+type taskWriter struct {
+	w       http.ResponseWriter
+	written bool
+}
+
+func (w *taskWriter) Write(b []byte) (int, error) {
+	w.written = true
+	n, err := w.w.Write(b)
+	if f, ok := w.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return n, err
+}
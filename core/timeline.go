@@ -0,0 +1,112 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Phase is a step when the application starts or stops.
+type Phase struct {
+	Name     string
+	Started  time.Time
+	Duration time.Duration
+}
+
+// Timeline records durations of phases when the application starts and
+// stops.
+type Timeline struct {
+	mu       sync.Mutex
+	startup  []Phase
+	shutdown []Phase
+}
+
+// NewTimeline allocates and returns a new Timeline.
+func NewTimeline() *Timeline {
+	return &Timeline{}
+}
+
+// Startup records a startup phase which began at started and has just finished.
+func (t *Timeline) Startup(name string, started time.Time) {
+	t.mu.Lock()
+	t.startup = append(t.startup, newPhase(name, started))
+	t.mu.Unlock()
+}
+
+// Shutdown records a shutdown phase which began at started and has just finished.
+func (t *Timeline) Shutdown(name string, started time.Time) {
+	t.mu.Lock()
+	t.shutdown = append(t.shutdown, newPhase(name, started))
+	t.mu.Unlock()
+}
+
+// StartupPhases returns recorded startup phases.
+func (t *Timeline) StartupPhases() []Phase {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Phase(nil), t.startup...)
+}
+
+// ShutdownPhases returns recorded shutdown phases.
+func (t *Timeline) ShutdownPhases() []Phase {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Phase(nil), t.shutdown...)
+}
+
+// StartupTime returns total duration of startup phases.
+func (t *Timeline) StartupTime() time.Duration {
+	return totalDuration(t.StartupPhases())
+}
+
+// ShutdownTime returns total duration of shutdown phases.
+func (t *Timeline) ShutdownTime() time.Duration {
+	return totalDuration(t.ShutdownPhases())
+}
+
+// StartupDone logs the startup timeline when the application has started.
+func (t *Timeline) StartupDone() {
+	logPhases("startup", t.StartupPhases())
+}
+
+// ShutdownDone logs the shutdown timeline when the application has stopped.
+func (t *Timeline) ShutdownDone() {
+	logPhases("shutdown", t.ShutdownPhases())
+}
+
+func newPhase(name string, started time.Time) Phase {
+	return Phase{
+		Name:     name,
+		Started:  started,
+		Duration: time.Since(started),
+	}
+}
+
+// totalDuration returns duration from the beginning of the first phase to
+// the end of the last one.
+func totalDuration(phases []Phase) time.Duration {
+	if len(phases) == 0 {
+		return 0
+	}
+	first := phases[0].Started
+	last := first
+	for _, p := range phases {
+		if p.Started.Before(first) {
+			first = p.Started
+		}
+		if end := p.Started.Add(p.Duration); end.After(last) {
+			last = end
+		}
+	}
+	return last.Sub(first)
+}
+
+func logPhases(stage string, phases []Phase) {
+	total := totalDuration(phases)
+	var buf bytes.Buffer
+	for _, p := range phases {
+		fmt.Fprintf(&buf, "    %-40s %v\n", p.Name, p.Duration)
+	}
+	GetLogger("melon").Infof("%s took %v =\n\n%s", stage, total, buf.String())
+}
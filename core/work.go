@@ -0,0 +1,113 @@
+package core
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrShuttingDown is returned by WorkTracker when the application is stopping
+// and no longer accepts new work.
+var ErrShuttingDown = errors.New("shutting down")
+
+// Work is a unit of in-flight work registered to WorkTracker.
+type Work struct {
+	Name    string
+	Started time.Time
+
+	tracker *WorkTracker
+	once    sync.Once
+}
+
+// Done marks the work completed. It is safe to call Done more than once.
+func (w *Work) Done() {
+	w.once.Do(func() {
+		w.tracker.done(w)
+	})
+}
+
+// WorkTracker tracks in-flight work of handlers and background jobs, so that
+// the application waits for them when stopping.
+type WorkTracker struct {
+	mu     sync.Mutex
+	works  map[*Work]struct{}
+	closed bool
+	// idle is closed when all work is done after shutting down.
+	idle chan struct{}
+}
+
+// NewWorkTracker allocates and returns a new WorkTracker.
+func NewWorkTracker() *WorkTracker {
+	return &WorkTracker{
+		works: make(map[*Work]struct{}),
+	}
+}
+
+// Begin registers a new unit of work. Caller must call Done on the returned
+// Work when it completes. ErrShuttingDown is returned if the tracker has been
+// shut down.
+func (t *WorkTracker) Begin(name string) (*Work, error) {
+	w := &Work{
+		Name:    name,
+		Started: time.Now(),
+		tracker: t,
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return nil, ErrShuttingDown
+	}
+	t.works[w] = struct{}{}
+	return w, nil
+}
+
+func (t *WorkTracker) done(w *Work) {
+	t.mu.Lock()
+	delete(t.works, w)
+	if len(t.works) == 0 && t.idle != nil {
+		close(t.idle)
+		t.idle = nil
+	}
+	t.mu.Unlock()
+}
+
+// Pending returns in-flight work ordered by starting time.
+func (t *WorkTracker) Pending() []*Work {
+	t.mu.Lock()
+	works := make([]*Work, 0, len(t.works))
+	for w := range t.works {
+		works = append(works, w)
+	}
+	t.mu.Unlock()
+	sort.Slice(works, func(i, j int) bool {
+		return works[i].Started.Before(works[j].Started)
+	})
+	return works
+}
+
+// Shutdown stops accepting new work and waits for in-flight work to complete
+// until timeout. It returns work which has been abandoned.
+func (t *WorkTracker) Shutdown(timeout time.Duration) []*Work {
+	t.mu.Lock()
+	t.closed = true
+	if len(t.works) == 0 {
+		t.mu.Unlock()
+		return nil
+	}
+	idle := t.idle
+	if idle == nil {
+		idle = make(chan struct{})
+		t.idle = idle
+	}
+	t.mu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-idle:
+		return nil
+	case <-timer.C:
+		return t.Pending()
+	}
+}
@@ -0,0 +1,55 @@
+package cors
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/goburrow/melon/server/filter"
+)
+
+// originFilter rejects cross-origin requests which are not allowed.
+type originFilter struct {
+	allowedOrigins []string
+	paths          []string
+}
+
+// NewOriginFilter returns a Filter which responds status 403 to cross-origin
+// requests unless their header Origin is one of origins and their path matches
+// any of paths, or all paths if none is given. Requests without header Origin
+// or from the same host are always allowed. It protects endpoints changing
+// states from requests sent by browsers on other sites.
+func NewOriginFilter(origins []string, paths ...string) filter.Filter {
+	return &originFilter{
+		allowedOrigins: origins,
+		paths:          paths,
+	}
+}
+
+func (f *originFilter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	if origin == "" || sameHost(origin, r.Host) || f.allowed(origin, r.URL.Path) {
+		filter.Continue(w, r)
+		return
+	}
+	http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+}
+
+func (f *originFilter) allowed(origin, path string) bool {
+	if !inArray(f.allowedOrigins, origin) {
+		return false
+	}
+	if len(f.paths) == 0 {
+		return true
+	}
+	for _, p := range f.paths {
+		if filter.MatchPath(p, path) {
+			return true
+		}
+	}
+	return false
+}
+
+func sameHost(origin, host string) bool {
+	u, err := url.Parse(origin)
+	return err == nil && u.Host != "" && u.Host == host
+}
@@ -0,0 +1,39 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/goburrow/melon/server/filter"
+)
+
+func TestOriginFilter(t *testing.T) {
+	chain := filter.NewChain()
+	chain.Add(NewOriginFilter([]string{"http://dashboard"}, "/healthcheck", "/metrics*"), http.HandlerFunc(ping))
+
+	tests := []struct {
+		origin string
+		path   string
+		status int
+	}{
+		{"", "/ping", http.StatusOK},
+		{"http://example.com", "/ping", http.StatusOK},
+		{"http://evil", "/ping", http.StatusForbidden},
+		{"http://dashboard", "/ping", http.StatusForbidden},
+		{"http://dashboard", "/healthcheck", http.StatusNotFound},
+		{"http://evil", "/healthcheck", http.StatusForbidden},
+		{"http://dashboard", "/metrics/prometheus", http.StatusNotFound},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("POST", "http://example.com"+test.path, nil)
+		if test.origin != "" {
+			r.Header.Set("Origin", test.origin)
+		}
+		w := httptest.NewRecorder()
+		chain.ServeHTTP(w, r)
+		if w.Code != test.status {
+			t.Fatalf("unexpected status of %+v: %v", test, w.Code)
+		}
+	}
+}
@@ -4,11 +4,16 @@ Package debug adds debug endpoint to admin page.
 package debug
 
 import (
+	"bytes"
 	"expvar"
 	"fmt"
 	"net/http"
 	"net/http/pprof"
+	"runtime"
+	rpprof "runtime/pprof"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/goburrow/melon/core"
 )
@@ -16,6 +21,10 @@ import (
 const (
 	pprofPath  = "/debug/pprof/"
 	expvarPath = "/debug/vars"
+
+	cpuProfileTaskName    = "cpuprofile"
+	defaultProfileSeconds = 30
+	maxProfileSeconds     = 600
 )
 
 // bundle adds pprof into admin environment.
@@ -34,11 +43,89 @@ func (b *bundle) Initialize(bootstrap *core.Bootstrap) {
 // Run registers /debug/vars and /debug/pprof/.
 func (b *bundle) Run(conf interface{}, env *core.Environment) error {
 	env.Admin.AddHandler(&expvarHandler{})
+	addProfiling(env)
+	return nil
+}
 
+// Factory mounts profiling endpoints /debug/pprof/ and task cpuprofile on the
+// admin handler when it is enabled, so they are not exposed on application
+// connectors.
+type Factory struct {
+	Enabled bool
+	// BlockProfileRate is the rate of blocking events reported in the block
+	// profile, see runtime.SetBlockProfileRate. It is disabled by default.
+	BlockProfileRate int `valid:"min=0"`
+	// MutexProfileFraction is the rate of mutex contention events reported in
+	// the mutex profile, see runtime.SetMutexProfileFraction. It is disabled
+	// by default.
+	MutexProfileFraction int `valid:"min=0"`
+}
+
+// ConfigureDebug registers profiling endpoints if enabled.
+func (f *Factory) ConfigureDebug(env *core.Environment) error {
+	if !f.Enabled {
+		return nil
+	}
+	if f.BlockProfileRate > 0 {
+		runtime.SetBlockProfileRate(f.BlockProfileRate)
+	}
+	if f.MutexProfileFraction > 0 {
+		runtime.SetMutexProfileFraction(f.MutexProfileFraction)
+	}
+	addProfiling(env)
+	return nil
+}
+
+// addProfiling registers pprof handlers and CPU profile task to the admin
+// environment.
+func addProfiling(env *core.Environment) {
 	pprofIndexHandler := &pprofHandler{}
 	env.Admin.AddHandler(pprofIndexHandler)
-	env.Admin.Router.Handle("*", pprofPath+"*", pprofIndexHandler)
-	return nil
+	env.Admin.Handle("*", pprofPath+"*", pprofIndexHandler)
+	env.Admin.AddTask(&cpuProfileTask{})
+}
+
+// cpuProfileTask captures CPU profile for the duration given in query parameter
+// seconds, default is 30, and returns it as a file:
+//
+// 	curl -X POST -o cpu.pprof http://localhost:8081/tasks/cpuprofile?seconds=10
+// 	go tool pprof cpu.pprof
+type cpuProfileTask struct {
+}
+
+func (*cpuProfileTask) Name() string {
+	return cpuProfileTaskName
+}
+
+func (*cpuProfileTask) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	seconds := defaultProfileSeconds
+	if s := r.URL.Query().Get("seconds"); s != "" {
+		var err error
+		seconds, err = strconv.Atoi(s)
+		if err != nil || seconds <= 0 || seconds > maxProfileSeconds {
+			http.Error(w, "invalid seconds: "+s, http.StatusBadRequest)
+			return
+		}
+	}
+	var buf bytes.Buffer
+	if err := rpprof.StartCPUProfile(&buf); err != nil {
+		// Profiling is already enabled.
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	timer := time.NewTimer(time.Duration(seconds) * time.Second)
+	select {
+	case <-timer.C:
+	case <-r.Context().Done():
+		timer.Stop()
+	}
+	rpprof.StopCPUProfile()
+	if r.Context().Err() != nil {
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="cpu.pprof"`)
+	w.Write(buf.Bytes())
 }
 
 // pprofHandler is a modification of httppprof.Index with path prefix support.
@@ -63,7 +150,8 @@ func (h *pprofHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			pprof.Profile(w, r)
 		case "symbol":
 			pprof.Symbol(w, r)
-		// TODO: pprof.Trace
+		case "trace":
+			pprof.Trace(w, r)
 		default:
 			pprof.Handler(name).ServeHTTP(w, r)
 		}
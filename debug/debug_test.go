@@ -13,11 +13,15 @@ import (
 
 func TestBundle(t *testing.T) {
 	env := core.NewEnvironment()
+	// Routers are created by the server after the bundle is run.
+	bundle := NewBundle()
+	if err := bundle.Run(nil, env); err != nil {
+		t.Fatal(err)
+	}
 	handler := router.New()
 	env.Admin.Router = handler
-
-	bundle := NewBundle()
-	bundle.Run(nil, env)
+	env.Server.Router = router.New()
+	env.Start()
 
 	server := httptest.NewServer(handler)
 	defer server.Close()
@@ -38,3 +42,51 @@ func TestBundle(t *testing.T) {
 		t.Fatalf("unexpected body %s", body)
 	}
 }
+
+func TestFactory(t *testing.T) {
+	newEnvironment := func(factory *Factory) http.Handler {
+		env := core.NewEnvironment()
+		// Debug is configured before the server creates routers.
+		if err := factory.ConfigureDebug(env); err != nil {
+			t.Fatal(err)
+		}
+		handler := router.New()
+		env.Admin.Router = handler
+		env.Server.Router = router.New()
+		env.Start()
+		return handler
+	}
+	handler := newEnvironment(&Factory{})
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/debug/pprof/", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("unexpected response code: %v", w.Code)
+	}
+
+	handler = newEnvironment(&Factory{Enabled: true})
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/debug/pprof/trace?seconds=0.01", nil))
+	if w.Code != http.StatusOK || w.Body.Len() == 0 {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Header())
+	}
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/debug/pprof/heap?debug=1", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "heap profile") {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Body.String())
+	}
+}
+
+func TestCPUProfileTask(t *testing.T) {
+	task := &cpuProfileTask{}
+	w := httptest.NewRecorder()
+	task.ServeHTTP(w, httptest.NewRequest("POST", "/tasks/cpuprofile?seconds=0", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	task.ServeHTTP(w, httptest.NewRequest("POST", "/tasks/cpuprofile?seconds=1", nil))
+	if w.Code != http.StatusOK || w.Body.Len() == 0 ||
+		w.Header().Get("Content-Disposition") != `attachment; filename="cpu.pprof"` {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Header())
+	}
+}
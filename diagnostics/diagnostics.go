@@ -0,0 +1,222 @@
+/*
+Package diagnostics writes a diagnostic bundle of the running application to
+a file when receiving a signal, SIGQUIT by default, without stopping the
+process.
+
+The bundle contains runtime information, memory statistics, results of health
+checks, a summary of the configuration with secrets redacted and stack traces
+of all goroutines.
+*/
+package diagnostics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/goburrow/melon/configuration"
+	"github.com/goburrow/melon/core"
+	"github.com/goburrow/melon/health"
+)
+
+const (
+	defaultSignal = "SIGQUIT"
+	redactedValue = configuration.RedactedValue
+)
+
+// secretKeys are parts of configuration keys whose values are redacted.
+var secretKeys = []string{"password", "secret", "token", "credential", "dsn", "key", "users"}
+
+// Factory implements core.DiagnosticsFactory interface.
+type Factory struct {
+	Enabled bool
+	// Signal is SIGQUIT by default.
+	Signal string
+	// Directory is where bundles are written, default is the temporary directory.
+	Directory string `file:"true"`
+}
+
+// ConfigureDiagnostics starts listening the signal along with the application.
+func (factory *Factory) ConfigureDiagnostics(env *core.Environment, configuration interface{}) error {
+	if !factory.Enabled {
+		return nil
+	}
+	name := factory.Signal
+	if name == "" {
+		name = defaultSignal
+	}
+	sig, ok := signals[strings.ToUpper(name)]
+	if !ok {
+		return fmt.Errorf("diagnostics: unsupported signal %s", name)
+	}
+	dir := factory.Directory
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	env.Lifecycle.Manage(&dumper{
+		signal:        sig,
+		directory:     dir,
+		healthChecks:  env.Admin.HealthChecks,
+		configuration: configuration,
+	})
+	return nil
+}
+
+// dumper writes diagnostic bundles on signal. It implements core.Managed.
+type dumper struct {
+	signal        os.Signal
+	directory     string
+	healthChecks  health.Registry
+	configuration interface{}
+
+	sigCh chan os.Signal
+	done  chan struct{}
+}
+
+// Start listens to the signal.
+func (d *dumper) Start() error {
+	d.sigCh = make(chan os.Signal, 1)
+	d.done = make(chan struct{})
+	signal.Notify(d.sigCh, d.signal)
+	go d.run()
+	return nil
+}
+
+// Stop stops listening to the signal.
+func (d *dumper) Stop() error {
+	if d.sigCh == nil {
+		return nil
+	}
+	signal.Stop(d.sigCh)
+	close(d.sigCh)
+	<-d.done
+	d.sigCh = nil
+	return nil
+}
+
+func (d *dumper) run() {
+	defer close(d.done)
+	for range d.sigCh {
+		path, err := d.dumpFile(time.Now())
+		if err != nil {
+			logger().Errorf("could not write diagnostics: %v", err)
+		} else {
+			logger().Infof("diagnostics written to %s", path)
+		}
+	}
+}
+
+// dumpFile writes diagnostic bundle to a timestamped file in the directory.
+func (d *dumper) dumpFile(t time.Time) (string, error) {
+	if err := os.MkdirAll(d.directory, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(d.directory, fmt.Sprintf("diagnostics-%s-%d.txt",
+		t.Format("20060102T150405"), os.Getpid()))
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	err = d.dump(f, t)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return path, err
+}
+
+func (d *dumper) dump(w io.Writer, t time.Time) error {
+	fmt.Fprintf(w, "=== Runtime\n\nTime: %s\nPID: %d\nVersion: %s\nGOOS: %s\nGOARCH: %s\nNumCPU: %d\nNumGoroutine: %d\n",
+		t.Format(time.RFC3339), os.Getpid(), runtime.Version(), runtime.GOOS, runtime.GOARCH,
+		runtime.NumCPU(), runtime.NumGoroutine())
+
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	fmt.Fprintf(w, "\n=== Memory\n\nAlloc: %d\nTotalAlloc: %d\nSys: %d\nMallocs: %d\nFrees: %d\n"+
+		"HeapAlloc: %d\nHeapSys: %d\nHeapIdle: %d\nHeapInuse: %d\nHeapObjects: %d\n"+
+		"StackInuse: %d\nNextGC: %d\nNumGC: %d\nPauseTotalNs: %d\n",
+		m.Alloc, m.TotalAlloc, m.Sys, m.Mallocs, m.Frees,
+		m.HeapAlloc, m.HeapSys, m.HeapIdle, m.HeapInuse, m.HeapObjects,
+		m.StackInuse, m.NextGC, m.NumGC, m.PauseTotalNs)
+
+	fmt.Fprintf(w, "\n=== Health\n\n")
+	results := d.healthChecks.RunCheckers()
+	names := make([]string, 0, len(results))
+	for name := range results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		r := results[name]
+		fmt.Fprintf(w, "%s: healthy=%t message=%q", name, r.Healthy(), r.Message())
+		if r.Cause() != nil {
+			fmt.Fprintf(w, " cause=%q", r.Cause())
+		}
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintf(w, "\n=== Configuration\n\n")
+	writeJSON(w, redact(d.configuration))
+
+	fmt.Fprintf(w, "\n=== Goroutines\n\n")
+	return pprof.Lookup("goroutine").WriteTo(w, 2)
+}
+
+func writeJSON(w io.Writer, v interface{}) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		fmt.Fprintf(w, "error: %v\n", err)
+		return
+	}
+	w.Write(b)
+	fmt.Fprintln(w)
+}
+
+// redact returns generic representation of v with values of secret fields
+// and secret keys redacted.
+func redact(v interface{}) interface{} {
+	out, err := configuration.Redact(v)
+	if err != nil {
+		return err.Error()
+	}
+	return redactValue(out)
+}
+
+func redactValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			if isSecret(k) && child != nil {
+				v[k] = redactedValue
+			} else {
+				v[k] = redactValue(child)
+			}
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = redactValue(child)
+		}
+	}
+	return v
+}
+
+func isSecret(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range secretKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func logger() core.Logger {
+	return core.GetLogger("melon/diagnostics")
+}
@@ -0,0 +1,88 @@
+package diagnostics
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/goburrow/melon/core"
+	"github.com/goburrow/melon/health"
+)
+
+var _ core.DiagnosticsFactory = (*Factory)(nil)
+var _ core.Managed = (*dumper)(nil)
+
+type testConfiguration struct {
+	Database struct {
+		URL      string
+		Password string
+	}
+	APIKeys []string
+}
+
+func TestDump(t *testing.T) {
+	registry := health.NewRegistry()
+	registry.Register("db", health.CheckerFunc(func() health.Result {
+		return health.ResultHealthy("connected")
+	}))
+	config := &testConfiguration{}
+	config.Database.URL = "localhost"
+	config.Database.Password = "pa55w0rd"
+	config.APIKeys = []string{"k3y"}
+
+	d := &dumper{healthChecks: registry, configuration: config}
+	var buf bytes.Buffer
+	if err := d.dump(&buf, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, s := range []string{"=== Runtime", "HeapAlloc", `db: healthy=true message="connected"`,
+		`"URL": "localhost"`, `"Password": "[REDACTED]"`, `"APIKeys": "[REDACTED]"`, "=== Goroutines"} {
+		if !strings.Contains(out, s) {
+			t.Fatalf("%s not found in diagnostics:\n%s", s, out)
+		}
+	}
+	if strings.Contains(out, "pa55w0rd") || strings.Contains(out, "k3y") {
+		t.Fatalf("secret found in diagnostics:\n%s", out)
+	}
+}
+
+func TestSignal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diagnostics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	env := core.NewEnvironment()
+	factory := &Factory{Enabled: true, Signal: "sigquit", Directory: dir}
+	if err = factory.ConfigureDiagnostics(env, nil); err != nil {
+		t.Fatal(err)
+	}
+	d := &dumper{signal: syscall.SIGQUIT, directory: dir, healthChecks: env.Admin.HealthChecks}
+	// Stopping a dumper which is not started does nothing.
+	if err = d.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	d.Start()
+	d.sigCh <- syscall.SIGQUIT
+	d.Stop()
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || !strings.HasPrefix(files[0].Name(), "diagnostics-") {
+		t.Fatalf("unexpected files: %v", files)
+	}
+}
+
+func TestUnsupportedSignal(t *testing.T) {
+	factory := &Factory{Enabled: true, Signal: "SIGKILL"}
+	if err := factory.ConfigureDiagnostics(core.NewEnvironment(), nil); err == nil {
+		t.Fatal("error expected")
+	}
+}
@@ -0,0 +1,10 @@
+package diagnostics
+
+import (
+	"os"
+	"syscall"
+)
+
+var signals = map[string]os.Signal{
+	"SIGQUIT": syscall.SIGQUIT,
+}
@@ -0,0 +1,102 @@
+package health
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const defaultAsyncInterval = 30 * time.Second
+
+var (
+	errNotChecked = errors.New("health check has not run")
+	errExpired    = errors.New("health check result expired")
+)
+
+// AsyncChecker runs a health check periodically in background and returns its
+// last result so that expensive checks are not run on every request. It must
+// be managed by the application lifecycle to be started and stopped:
+//
+// 	checker := health.NewAsyncChecker(dbChecker, 30*time.Second, time.Minute)
+// 	env.Lifecycle.Manage(checker)
+// 	env.Admin.HealthChecks.Register("database", checker)
+type AsyncChecker struct {
+	checker  Checker
+	interval time.Duration
+	ttl      time.Duration
+
+	mu      sync.Mutex
+	result  Result
+	checked time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewAsyncChecker returns an AsyncChecker running checker every interval.
+// The last result is considered unhealthy when it is older than ttl, e.g.
+// the check takes too long. If ttl is not positive, results never expire.
+// If interval is not positive, the check is run every 30 seconds.
+func NewAsyncChecker(checker Checker, interval, ttl time.Duration) *AsyncChecker {
+	if interval <= 0 {
+		interval = defaultAsyncInterval
+	}
+	return &AsyncChecker{
+		checker:  checker,
+		interval: interval,
+		ttl:      ttl,
+	}
+}
+
+// Check returns the last result of the health check.
+func (c *AsyncChecker) Check() Result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.result == nil {
+		return ResultUnhealthy("", errNotChecked)
+	}
+	if c.ttl > 0 && time.Since(c.checked) > c.ttl {
+		return ResultUnhealthy(c.result.Message(), errExpired)
+	}
+	return c.result
+}
+
+// Start runs the health check immediately and then periodically.
+func (c *AsyncChecker) Start() error {
+	c.stop = make(chan struct{})
+	c.done = make(chan struct{})
+	go c.run()
+	return nil
+}
+
+// Stop stops running the health check.
+func (c *AsyncChecker) Stop() error {
+	if c.stop != nil {
+		close(c.stop)
+		<-c.done
+		c.stop = nil
+	}
+	return nil
+}
+
+func (c *AsyncChecker) run() {
+	defer close(c.done)
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		c.runOnce()
+		select {
+		case <-ticker.C:
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+func (c *AsyncChecker) runOnce() {
+	result := runChecker(c.checker)
+	c.mu.Lock()
+	c.result = result
+	c.checked = time.Now()
+	c.mu.Unlock()
+}
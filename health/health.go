@@ -3,7 +3,10 @@ Package health helps check health of applications in production.
 */
 package health
 
-import "sync"
+import (
+	"sync"
+	"time"
+)
 
 // Result is the result of a health check being run.
 type Result interface {
@@ -80,16 +83,111 @@ type Registry interface {
 	RunCheckers() map[string]Result
 }
 
+// nonCritical is a health check which failure does not affect overall status.
+type nonCritical struct {
+	Checker
+}
+
+// Critical returns false.
+func (nonCritical) Critical() bool {
+	return false
+}
+
+// NonCritical returns a Checker which failures are reported but do not make
+// the application unhealthy with policy CriticalHealthy, e.g. an optional cache.
+func NonCritical(checker Checker) Checker {
+	return nonCritical{checker}
+}
+
+// isCritical returns false if checker has method Critical() returning false.
+func isCritical(checker Checker) bool {
+	if c, ok := checker.(interface {
+		Critical() bool
+	}); ok {
+		return c.Critical()
+	}
+	return true
+}
+
+// timedResult is a Result with its running time.
+type timedResult struct {
+	Result
+	duration time.Duration
+	critical bool
+}
+
+// Duration returns running time of the health check producing result r,
+// which is returned by Registry, or zero if it is unknown.
+func Duration(r Result) time.Duration {
+	if t, ok := r.(*timedResult); ok {
+		return t.duration
+	}
+	return 0
+}
+
+// IsCritical returns false if result r, which is returned by Registry, is of
+// a NonCritical health check.
+func IsCritical(r Result) bool {
+	if t, ok := r.(*timedResult); ok {
+		return t.critical
+	}
+	return true
+}
+
+// Policy decides whether the application is healthy from results of its
+// health checks.
+type Policy func(results map[string]Result) bool
+
+// AllHealthy is a Policy which requires all health checks to be healthy.
+func AllHealthy(results map[string]Result) bool {
+	for _, r := range results {
+		if !r.Healthy() {
+			return false
+		}
+	}
+	return true
+}
+
+// CriticalHealthy is a Policy which ignores failures of non-critical health
+// checks.
+func CriticalHealthy(results map[string]Result) bool {
+	for _, r := range results {
+		if !r.Healthy() && IsCritical(r) {
+			return false
+		}
+	}
+	return true
+}
+
 // defaultRegistry implements Registry interface.
 type defaultRegistry struct {
 	mu       sync.Mutex
 	checkers map[string]Checker
+	// parallel is whether checks are run concurrently.
+	parallel bool
 }
 
-// NewRegistry creates a new health check registry.
-func NewRegistry() Registry {
-	return &defaultRegistry{
+// Option adds option for Registry.
+type Option func(*defaultRegistry)
+
+// NewRegistry creates a new health check registry. Health checks are run
+// in parallel by default.
+func NewRegistry(options ...Option) Registry {
+	registry := &defaultRegistry{
 		checkers: make(map[string]Checker),
+		parallel: true,
+	}
+	for _, opt := range options {
+		opt(registry)
+	}
+	return registry
+}
+
+// WithParallel sets whether RunCheckers runs health checks concurrently or
+// one after another.
+func WithParallel(parallel bool) Option {
+	return func(registry *defaultRegistry) {
+		registry.parallel = parallel
 	}
 }
 
@@ -130,7 +228,7 @@ func (registry *defaultRegistry) RunChecker(name string) Result {
 	if !ok {
 		return ResultUnhealthy("healthcheck: "+name+" not found", nil)
 	}
-	return health.Check()
+	return runChecker(health)
 }
 
 // checkerResult wraps result and name of health check
@@ -144,36 +242,47 @@ func (registry *defaultRegistry) RunCheckers() map[string]Result {
 	registry.mu.Lock()
 	defer registry.mu.Unlock()
 
+	results := make(map[string]Result, len(registry.checkers))
+	if !registry.parallel {
+		for name, checker := range registry.checkers {
+			results[name] = runChecker(checker)
+		}
+		return results
+	}
 	resultChan := make(chan checkerResult)
 	defer close(resultChan)
 
 	for name, checker := range registry.checkers {
-		go runChecker(resultChan, name, checker)
+		go func(name string, checker Checker) {
+			resultChan <- checkerResult{name: name, result: runChecker(checker)}
+		}(name, checker)
 	}
-
-	results := make(map[string]Result, len(registry.checkers))
 	for i := len(registry.checkers); i > 0; i-- {
-		select {
-		case r := <-resultChan:
-			results[r.name] = r.result
-		}
+		r := <-resultChan
+		results[r.name] = r.result
 	}
 	return results
 }
 
-func runChecker(c chan checkerResult, name string, checker Checker) {
-	r := checkerResult{name: name}
+// runChecker runs the health check and recovers its panic.
+func runChecker(checker Checker) (result Result) {
+	start := time.Now()
 	defer func() {
 		if v := recover(); v != nil {
 			if err, ok := v.(error); ok {
-				r.result = ResultUnhealthy("panic", err)
+				result = ResultUnhealthy("panic", err)
 			} else if err, ok := v.(string); ok {
-				r.result = ResultUnhealthy(err, nil)
+				result = ResultUnhealthy(err, nil)
 			} else {
-				r.result = ResultUnhealthy("panic", nil)
+				result = ResultUnhealthy("panic", nil)
 			}
 		}
-		c <- r
+		// Results of asynchronous checks already have their running time.
+		if t, ok := result.(*timedResult); ok {
+			result = &timedResult{Result: t.Result, duration: t.duration, critical: isCritical(checker)}
+		} else {
+			result = &timedResult{Result: result, duration: time.Since(start), critical: isCritical(checker)}
+		}
 	}()
-	r.result = checker.Check()
+	return checker.Check()
 }
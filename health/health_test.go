@@ -5,7 +5,9 @@ import (
 	"runtime"
 	"sort"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func assertEquals(t *testing.T, expected, actual interface{}) {
@@ -123,3 +125,69 @@ func TestRecover(t *testing.T) {
 	assertEquals(t, "error", results["3"].Cause().Error())
 	assertEquals(t, true, results["4"].Healthy())
 }
+
+func TestSerial(t *testing.T) {
+	registry := NewRegistry(WithParallel(false))
+	registry.Register("1", &stubHealthCheck{healthy: true})
+	registry.Register("2", &panicHealthCheck{message: "panic"})
+
+	results := registry.RunCheckers()
+	assertEquals(t, 2, len(results))
+	assertEquals(t, true, results["1"].Healthy())
+	assertEquals(t, "panic", results["2"].Message())
+	_, ok := results["1"].(*timedResult)
+	assertEquals(t, true, ok)
+}
+
+func TestAsyncChecker(t *testing.T) {
+	var count int32
+	checker := NewAsyncChecker(CheckerFunc(func() Result {
+		atomic.AddInt32(&count, 1)
+		return ResultHealthy("checked")
+	}), time.Hour, 50*time.Millisecond)
+
+	result := checker.Check()
+	assertEquals(t, false, result.Healthy())
+	assertEquals(t, errNotChecked, result.Cause())
+
+	checker.Start()
+	for checker.Check().Cause() == errNotChecked {
+		time.Sleep(time.Millisecond)
+	}
+	registry := NewRegistry()
+	registry.Register("async", checker)
+	result = registry.RunChecker("async")
+	assertEquals(t, true, result.Healthy())
+	assertEquals(t, "checked", result.Message())
+
+	time.Sleep(60 * time.Millisecond)
+	result = checker.Check()
+	assertEquals(t, false, result.Healthy())
+	assertEquals(t, errExpired, result.Cause())
+	checker.Stop()
+	assertEquals(t, int32(1), atomic.LoadInt32(&count))
+
+	checker = NewAsyncChecker(CheckerFunc(func() Result {
+		return ResultHealthy("")
+	}), 0, 0)
+	assertEquals(t, defaultAsyncInterval, checker.interval)
+	checker.Start()
+	checker.Stop()
+}
+
+func TestNonCritical(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("1", &stubHealthCheck{healthy: true})
+	registry.Register("2", NonCritical(&stubHealthCheck{healthy: false}))
+
+	results := registry.RunCheckers()
+	assertEquals(t, true, IsCritical(results["1"]))
+	assertEquals(t, false, IsCritical(results["2"]))
+	assertEquals(t, false, results["2"].Healthy())
+	assertEquals(t, true, CriticalHealthy(results))
+	assertEquals(t, false, AllHealthy(results))
+
+	registry.Register("3", &stubHealthCheck{healthy: false})
+	results = registry.RunCheckers()
+	assertEquals(t, false, CriticalHealthy(results))
+}
@@ -85,6 +85,8 @@ type ConsoleAppenderFactory struct {
 	filteredAppenderFactory
 
 	Target string
+	// Format is only used by server request log. See server/logging.NewFormatter.
+	Format string
 }
 
 // Build returns logging appender from given Target.
@@ -108,11 +110,14 @@ func (factory *ConsoleAppenderFactory) Build(environment *core.Environment) (gol
 type FileAppenderFactory struct {
 	filteredAppenderFactory
 
-	CurrentLogFilename string `valid:"notempty"`
+	CurrentLogFilename string `valid:"notempty" file:"true"`
 
 	Archive                    bool
-	ArchivedLogFilenamePattern string
+	ArchivedLogFilenamePattern string `file:"true"`
 	ArchivedFileCount          int
+
+	// Format is only used by server request log. See server/logging.NewFormatter.
+	Format string
 }
 
 // Build returns file logging appender.
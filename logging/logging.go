@@ -9,16 +9,16 @@ import (
 
 	"github.com/goburrow/dynamic"
 	"github.com/goburrow/gol"
+	"github.com/goburrow/melon/configuration"
 	"github.com/goburrow/melon/core"
+	"github.com/goburrow/melon/server/audit"
 
 	golasync "github.com/goburrow/gol/async"
 	// Package log forwards all std loggers to gol
 	_ "github.com/goburrow/gol/log"
 )
 
-const (
-	asyncBufferSize = 1024
-)
+const asyncBufferSize = 1024
 
 var (
 	logLevels = map[string]gol.Level{
@@ -32,10 +32,14 @@ var (
 	}
 )
 
+// AppenderTypes contains appender factories selected by key "type" of
+// appender configuration.
+var AppenderTypes = configuration.NewRegistry("appender", (*AppenderFactory)(nil))
+
 func init() {
-	dynamic.Register("ConsoleAppender", func() interface{} { return &ConsoleAppenderFactory{} })
-	dynamic.Register("FileAppender", func() interface{} { return &FileAppenderFactory{} })
-	dynamic.Register("SyslogAppender", func() interface{} { return &SyslogAppenderFactory{} })
+	AppenderTypes.Register("ConsoleAppender", func() interface{} { return &ConsoleAppenderFactory{} })
+	AppenderTypes.Register("FileAppender", func() interface{} { return &FileAppenderFactory{} })
+	AppenderTypes.Register("SyslogAppender", func() interface{} { return &SyslogAppenderFactory{} })
 }
 
 func getLogLevel(level string) (gol.Level, bool) {
@@ -50,16 +54,35 @@ func setLogLevel(name string, level gol.Level) {
 	}
 }
 
-// AppenderConfiguration is an union of console, file and syslog configuration.
+// AppenderConfiguration is an union of appender configurations registered in
+// AppenderTypes, which are console, file and syslog by default.
 type AppenderConfiguration struct {
 	dynamic.Type
 }
 
+// Types returns registry of appender types.
+func (c *AppenderConfiguration) Types() *configuration.Registry {
+	return AppenderTypes
+}
+
+// UnmarshalJSON decodes the appender configuration of the given type.
+func (c *AppenderConfiguration) UnmarshalJSON(data []byte) error {
+	v, err := AppenderTypes.Unmarshal(data)
+	if err != nil {
+		return err
+	}
+	c.SetValue(v)
+	return nil
+}
+
 // Factory configures logging environment.
 type Factory struct {
 	Level     string
 	Loggers   map[string]string
 	Appenders []AppenderConfiguration
+	// AuditAppenders are appenders of logger "melon/audit" recording admin
+	// task invocations instead of appenders of the root logger.
+	AuditAppenders []AppenderConfiguration
 }
 
 // Configure configures all logging appenders and their level.
@@ -78,10 +101,30 @@ func (factory *Factory) ConfigureLogging(env *core.Environment) error {
 	core.SetLoggerFactory(func(name string) core.Logger {
 		return gol.GetLogger(name)
 	})
-	env.Admin.AddTask(&logTask{})
+	names := newLoggerNames(gol.RootLoggerName)
+	for name := range factory.Loggers {
+		names.add(name)
+	}
+	env.Admin.AddTask(&logTask{name: logLevelTaskName, loggers: names},
+		&logTask{name: logTaskName, loggers: names})
 	return nil
 }
 
+// ReloadLogging applies levels of the root logger and Loggers at runtime.
+// Appenders are not changed.
+func (factory *Factory) ReloadLogging() error {
+	// Check all levels first so that none is changed on error.
+	if _, ok := getLogLevel(factory.Level); !ok && factory.Level != "" {
+		return fmt.Errorf("unsupported level %s", factory.Level)
+	}
+	for _, v := range factory.Loggers {
+		if _, ok := getLogLevel(v); !ok {
+			return fmt.Errorf("unsupported level %s", v)
+		}
+	}
+	return factory.configureLevels()
+}
+
 func (factory *Factory) configureLevels() error {
 	// Change default log level
 	if factory.Level != "" {
@@ -104,18 +147,9 @@ func (factory *Factory) configureLevels() error {
 
 func (factory *Factory) configureAppenders(environment *core.Environment) error {
 	// appenders is a list of appenders for root logger.
-	var appenders []gol.Appender
-
-	for _, appenderFactory := range factory.Appenders {
-		if a, ok := appenderFactory.Value().(AppenderFactory); ok {
-			appender, err := a.Build(environment)
-			if err != nil {
-				return err
-			}
-			appenders = append(appenders, appender)
-		} else {
-			return fmt.Errorf("unsupported appender %#v", appenderFactory.Value())
-		}
+	appenders, err := buildAppenders(environment, factory.Appenders)
+	if err != nil {
+		return err
 	}
 	// Override default appender of the root logger
 	if len(appenders) > 0 {
@@ -126,5 +160,37 @@ func (factory *Factory) configureAppenders(environment *core.Environment) error
 		a := golasync.NewAppenderWithBufSize(asyncBufferSize, appenders...)
 		a.Start()
 	}
+	return factory.configureAuditAppenders(environment)
+}
+
+// configureAuditAppenders sets dedicated appenders of the audit logger.
+func (factory *Factory) configureAuditAppenders(environment *core.Environment) error {
+	appenders, err := buildAppenders(environment, factory.AuditAppenders)
+	if err != nil || len(appenders) == 0 {
+		return err
+	}
+	logger, ok := gol.GetLogger(audit.LoggerName).(*gol.DefaultLogger)
+	if !ok {
+		return fmt.Errorf("logger is not gol.DefaultLogger %T", logger)
+	}
+	a := golasync.NewAppenderWithBufSize(asyncBufferSize, appenders...)
+	a.Start()
+	logger.SetAppender(a)
 	return nil
 }
+
+func buildAppenders(environment *core.Environment, configs []AppenderConfiguration) ([]gol.Appender, error) {
+	var appenders []gol.Appender
+	for _, appenderFactory := range configs {
+		if a, ok := appenderFactory.Value().(AppenderFactory); ok {
+			appender, err := a.Build(environment)
+			if err != nil {
+				return nil, err
+			}
+			appenders = append(appenders, appender)
+		} else {
+			return nil, fmt.Errorf("unsupported appender %#v", appenderFactory.Value())
+		}
+	}
+	return appenders, nil
+}
@@ -1,6 +1,8 @@
 package logging
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/goburrow/gol"
@@ -40,3 +42,43 @@ func TestGetLogLevel(t *testing.T) {
 		t.Fatal("Should not found")
 	}
 }
+
+func TestLogLevelTask(t *testing.T) {
+	task := &logTask{name: logLevelTaskName, loggers: newLoggerNames("melon/test/a")}
+	setLogLevel("melon/test/a", gol.Info)
+
+	w := httptest.NewRecorder()
+	task.ServeHTTP(w, httptest.NewRequest("POST", "/tasks/log-level?logger=melon/test/b&level=debug", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "melon/test/b: DEBUG\n" {
+		t.Fatalf("unexpected response: %v %q", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	task.ServeHTTP(w, httptest.NewRequest("POST", "/tasks/log-level", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "melon/test/a: INFO\nmelon/test/b: DEBUG\n" {
+		t.Fatalf("unexpected response: %v %q", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	task.ServeHTTP(w, httptest.NewRequest("POST", "/tasks/log-level?logger=melon/test/a&level=verbose", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("unexpected response: %v %q", w.Code, w.Body.String())
+	}
+}
+
+func TestReloadLogging(t *testing.T) {
+	setLogLevel("melon/test/reload", gol.Info)
+	factory := Factory{Loggers: map[string]string{"melon/test/reload": "DEBUG", "melon/test/other": "verbose"}}
+	if err := factory.ReloadLogging(); err == nil {
+		t.Fatal("error expected")
+	}
+	logger := gol.GetLogger("melon/test/reload").(*gol.DefaultLogger)
+	if logger.Level() != gol.Info {
+		t.Fatalf("unexpected level: %v", logger.Level())
+	}
+	delete(factory.Loggers, "melon/test/other")
+	if err := factory.ReloadLogging(); err != nil {
+		t.Fatal(err)
+	}
+	if logger.Level() != gol.Debug {
+		t.Fatalf("unexpected level: %v", logger.Level())
+	}
+}
@@ -3,32 +3,44 @@ package logging
 import (
 	"fmt"
 	"net/http"
+	"sort"
+	"sync"
 
 	"github.com/goburrow/gol"
 )
 
 const (
+	logLevelTaskName = "log-level"
+	// logTaskName is the former name of log level task.
 	logTaskName = "log"
 )
 
-// logTask gets and sets logger level
+// logTask gets and sets logger level:
+//
+// 	POST /tasks/log-level?logger=melon&logger=app&level=DEBUG
+//
+// Without parameter logger, it reports levels of the root logger and loggers
+// which have been configured.
 type logTask struct {
+	name    string
+	loggers *loggerNames
 }
 
-func (*logTask) Name() string {
-	return logTaskName
+func (t *logTask) Name() string {
+	return t.name
 }
 
-func (*logTask) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+func (t *logTask) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query()
 	// Can have multiple loggers
-	loggers, ok := query["logger"]
-	if !ok || len(loggers) == 0 {
-		return
-	}
+	loggers := query["logger"]
 	// But only one level
 	level := query.Get("level")
 	if level != "" {
+		if len(loggers) == 0 {
+			http.Error(w, "Missing logger", http.StatusBadRequest)
+			return
+		}
 		logLevel, ok := getLogLevel(level)
 		if !ok {
 			http.Error(w, "Unsupported level "+level, http.StatusBadRequest)
@@ -38,6 +50,10 @@ func (*logTask) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			setLogLevel(name, logLevel)
 		}
 	}
+	t.loggers.add(loggers...)
+	if len(loggers) == 0 {
+		loggers = t.loggers.list()
+	}
 	// Print level of each logger
 	for _, name := range loggers {
 		logger, ok := gol.GetLogger(name).(*gol.DefaultLogger)
@@ -46,3 +62,35 @@ func (*logTask) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 }
+
+// loggerNames is a set of known logger names.
+type loggerNames struct {
+	mu    sync.Mutex
+	names map[string]struct{}
+}
+
+func newLoggerNames(names ...string) *loggerNames {
+	l := &loggerNames{names: make(map[string]struct{})}
+	l.add(names...)
+	return l
+}
+
+func (l *loggerNames) add(names ...string) {
+	l.mu.Lock()
+	for _, name := range names {
+		l.names[name] = struct{}{}
+	}
+	l.mu.Unlock()
+}
+
+// list returns names in order.
+func (l *loggerNames) list() []string {
+	l.mu.Lock()
+	names := make([]string, 0, len(l.names))
+	for name := range l.names {
+		names = append(names, name)
+	}
+	l.mu.Unlock()
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,147 @@
+/*
+Package longpoll provides helpers for long-poll endpoints which hold requests
+until an event happens, the wait times out or the client goes away.
+
+Waiting stops as soon as the request context is done so timers and goroutines
+are released when clients disconnect. Each Poller records histogram
+LongPoll.<name>.WaitTime in milliseconds, gauge LongPoll.<name>.Waiting and
+counters LongPoll.<name>.Timeouts and LongPoll.<name>.Disconnects.
+
+	var poller = longpoll.New("messages")
+
+	func list(r *http.Request) (interface{}, error) {
+		err := poller.WaitUntil(r, 30*time.Second, func() bool {
+			return store.HasNewerThan(since(r))
+		})
+		if err == longpoll.ErrTimeout {
+			return []Message{}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		...
+	}
+
+	func post(r *http.Request) (interface{}, error) {
+		...
+		poller.Notify()
+	}
+*/
+package longpoll
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/codahale/metrics"
+)
+
+// ErrTimeout is returned when the waiting time is over.
+var ErrTimeout = errors.New("longpoll: timeout")
+
+// Poller wakes up requests waiting for events.
+type Poller struct {
+	mu sync.Mutex
+	// notified is closed and replaced on every notification.
+	notified chan struct{}
+
+	waiting     int64
+	waitTime    *metrics.Histogram
+	timeouts    metrics.Counter
+	disconnects metrics.Counter
+}
+
+// New returns a Poller recording metrics with the given name.
+func New(name string) *Poller {
+	p := &Poller{
+		notified: make(chan struct{}),
+		waitTime: metrics.NewHistogram("LongPoll."+name+".WaitTime",
+			1,          // 1ms
+			1000*60*10, // 10min
+			3),         // precision
+		timeouts:    metrics.Counter("LongPoll." + name + ".Timeouts"),
+		disconnects: metrics.Counter("LongPoll." + name + ".Disconnects"),
+	}
+	metrics.Gauge("LongPoll." + name + ".Waiting").SetFunc(func() int64 {
+		return atomic.LoadInt64(&p.waiting)
+	})
+	return p
+}
+
+// Notify wakes up all waiting requests.
+func (p *Poller) Notify() {
+	p.mu.Lock()
+	close(p.notified)
+	p.notified = make(chan struct{})
+	p.mu.Unlock()
+}
+
+func (p *Poller) channel() <-chan struct{} {
+	p.mu.Lock()
+	ch := p.notified
+	p.mu.Unlock()
+	return ch
+}
+
+// Wait blocks until Notify is called. It returns ErrTimeout when timeout
+// elapses or error of the request context when the client goes away.
+func (p *Poller) Wait(r *http.Request, timeout time.Duration) error {
+	_, err := p.Receive(r, timeout, p.channel())
+	return err
+}
+
+// WaitUntil blocks until cond returns true. The condition is checked at first
+// and after every notification.
+func (p *Poller) WaitUntil(r *http.Request, timeout time.Duration, cond func() bool) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		// Channel is taken before checking condition so that notifications
+		// in between are not missed.
+		ch := p.channel()
+		if cond() {
+			return nil
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			p.timeouts.Add()
+			return ErrTimeout
+		}
+		if _, err := p.Receive(r, remaining, ch); err != nil {
+			return err
+		}
+	}
+}
+
+// Receive blocks until a value is received from channel ch, which can be of
+// any channel type. The second return value is the zero value of the channel
+// element type if the channel is closed.
+func (p *Poller) Receive(r *http.Request, timeout time.Duration, ch interface{}) (interface{}, error) {
+	atomic.AddInt64(&p.waiting, 1)
+	start := time.Now()
+	defer func() {
+		atomic.AddInt64(&p.waiting, -1)
+		_ = p.waitTime.RecordValue(time.Since(start).Nanoseconds() / 1E6)
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch)},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(timer.C)},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(r.Context().Done())},
+	}
+	chosen, v, _ := reflect.Select(cases)
+	switch chosen {
+	case 0:
+		return v.Interface(), nil
+	case 1:
+		p.timeouts.Add()
+		return nil, ErrTimeout
+	default:
+		p.disconnects.Add()
+		return nil, r.Context().Err()
+	}
+}
@@ -0,0 +1,71 @@
+package longpoll
+
+import (
+	"context"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWait(t *testing.T) {
+	p := New("test")
+	r := httptest.NewRequest("GET", "/", nil)
+	done := make(chan error, 1)
+	go func() {
+		done <- p.Wait(r, time.Minute)
+	}()
+	for atomic.LoadInt64(&p.waiting) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	p.Notify()
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := p.Wait(r, time.Millisecond); err != ErrTimeout {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestWaitUntil(t *testing.T) {
+	p := New("test")
+	r := httptest.NewRequest("GET", "/", nil)
+	var value int32
+	done := make(chan error, 1)
+	go func() {
+		done <- p.WaitUntil(r, time.Minute, func() bool {
+			return atomic.LoadInt32(&value) == 2
+		})
+	}()
+	for i := 0; i < 2; i++ {
+		atomic.AddInt32(&value, 1)
+		p.Notify()
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err := p.WaitUntil(r, 10*time.Millisecond, func() bool { return false })
+	if err != ErrTimeout {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestReceive(t *testing.T) {
+	p := New("test")
+	ctx, cancel := context.WithCancel(context.Background())
+	r := httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+	ch := make(chan string, 1)
+	ch <- "melon"
+	v, err := p.Receive(r, time.Minute, ch)
+	if err != nil || v != "melon" {
+		t.Fatalf("unexpected value: %v %v", v, err)
+	}
+	cancel()
+	v, err = p.Receive(r, time.Minute, ch)
+	if err != context.Canceled || v != nil {
+		t.Fatalf("unexpected value: %v %v", v, err)
+	}
+	if atomic.LoadInt64(&p.waiting) != 0 {
+		t.Fatalf("unexpected waiting: %v", p.waiting)
+	}
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/goburrow/melon/configuration"
 	"github.com/goburrow/melon/core"
+	"github.com/goburrow/melon/metrics"
 	"github.com/goburrow/melon/validation"
 )
 
@@ -23,6 +24,8 @@ func Run(app core.Bundle, args []string) error {
 	// Register default server commands
 	bootstrap.AddCommand(&checkCommand{})
 	bootstrap.AddCommand(&serverCommand{})
+	bootstrap.AddCommand(&schemaCommand{})
+	bootstrap.AddCommand(&metrics.DumpCommand{})
 
 	app.Initialize(&bootstrap)
 	if len(args) > 0 {
@@ -0,0 +1,189 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"expvar"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/goburrow/melon/core"
+)
+
+const (
+	dumpTaskName = "metrics-dump"
+	defaultURL   = "http://localhost:8081" + metricsPath
+)
+
+// DumpCommand exports a snapshot of metrics from a running instance, or from
+// the current process in dry-run mode, as JSON or CSV.
+//
+// Usage: metrics-dump [-url URL] [-format json|csv] [-output FILE] [-dry-run]
+type DumpCommand struct {
+}
+
+// Name returns name of the command.
+func (*DumpCommand) Name() string {
+	return dumpTaskName
+}
+
+// Description returns description of the command.
+func (*DumpCommand) Description() string {
+	return "exports metrics snapshot of a running instance as JSON or CSV"
+}
+
+// Run fetches and writes metrics snapshot.
+func (c *DumpCommand) Run(bootstrap *core.Bootstrap) error {
+	flags := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	url := flags.String("url", defaultURL, "metrics endpoint of the admin server")
+	format := flags.String("format", "json", "output format: json or csv")
+	output := flags.String("output", "", "output file, default is stdout")
+	dryRun := flags.Bool("dry-run", false, "export metrics of this process instead")
+	var args []string
+	if len(bootstrap.Arguments) > 1 {
+		args = bootstrap.Arguments[1:]
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	var data []byte
+	var err error
+	if *dryRun {
+		data, err = localMetrics()
+	} else {
+		data, err = remoteMetrics(*url)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return err
+	}
+	w := io.Writer(os.Stdout)
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	err = writeSnapshot(w, *format, time.Now(), data)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	return err
+}
+
+// dumpTask writes metrics snapshot in format given in query parameter format.
+type dumpTask struct {
+}
+
+func (*dumpTask) Name() string {
+	return dumpTaskName
+}
+
+func (*dumpTask) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	data, err := localMetrics()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	var buf bytes.Buffer
+	if err = writeSnapshot(&buf, format, time.Now(), data); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.Write(buf.Bytes())
+}
+
+func localMetrics() ([]byte, error) {
+	val := expvar.Get(metricsVar)
+	if val == nil {
+		return nil, fmt.Errorf("metrics: no metrics")
+	}
+	return []byte(val.String()), nil
+}
+
+func remoteMetrics(url string) ([]byte, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	rsp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("metrics: %v", err)
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("metrics: unexpected response status %s", rsp.Status)
+	}
+	return ioutil.ReadAll(rsp.Body)
+}
+
+// writeSnapshot writes metrics in JSON data in the given format.
+func writeSnapshot(w io.Writer, format string, t time.Time, data []byte) error {
+	timestamp := t.UTC().Format(time.RFC3339)
+	switch format {
+	case "json":
+		snapshot := struct {
+			Timestamp string
+			Metrics   json.RawMessage
+		}{timestamp, data}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(&snapshot)
+	case "csv":
+		var metrics interface{}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(&metrics); err != nil {
+			return fmt.Errorf("metrics: %v", err)
+		}
+		values := make(map[string]string)
+		flatten("", metrics, values)
+		names := make([]string, 0, len(values))
+		for name := range values {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"timestamp", "name", "value"})
+		for _, name := range names {
+			cw.Write([]string{timestamp, name, values[name]})
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		return fmt.Errorf("metrics: unsupported format %s", format)
+	}
+}
+
+// flatten joins names of nested objects with dots.
+func flatten(prefix string, v interface{}, values map[string]string) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if prefix != "" {
+			prefix += "."
+		}
+		for k, child := range v {
+			flatten(prefix+k, child, values)
+		}
+	case nil:
+		values[prefix] = ""
+	default:
+		values[prefix] = fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/codahale/metrics"
+	"github.com/goburrow/melon/core"
+)
+
+// healthScheduler runs health checks periodically and records their results
+// in gauges HealthCheck.<name>.Healthy and counters HealthCheck.<name>.Failures.
+// It implements core.Managed.
+type healthScheduler struct {
+	env      *core.AdminEnvironment
+	interval time.Duration
+
+	// healthy is the last status of each health check.
+	mu      sync.Mutex
+	healthy map[string]bool
+
+	quit chan struct{}
+	done chan struct{}
+}
+
+func newHealthScheduler(env *core.AdminEnvironment, interval time.Duration) *healthScheduler {
+	return &healthScheduler{
+		env:      env,
+		interval: interval,
+		healthy:  make(map[string]bool),
+	}
+}
+
+// Start runs health checks in background.
+func (s *healthScheduler) Start() error {
+	s.quit = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.run()
+	return nil
+}
+
+// Stop stops running health checks.
+func (s *healthScheduler) Stop() error {
+	if s.quit != nil {
+		close(s.quit)
+		<-s.done
+		s.quit = nil
+	}
+	return nil
+}
+
+func (s *healthScheduler) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		s.runChecks()
+		select {
+		case <-ticker.C:
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// runChecks runs all registered health checks and logs changes of their status.
+func (s *healthScheduler) runChecks() {
+	results := s.env.HealthChecks.RunCheckers()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, result := range results {
+		healthy := result.Healthy()
+		if healthy {
+			metrics.Gauge("HealthCheck." + name + ".Healthy").Set(1)
+		} else {
+			metrics.Gauge("HealthCheck." + name + ".Healthy").Set(0)
+			metrics.Counter("HealthCheck." + name + ".Failures").Add()
+		}
+		last, ok := s.healthy[name]
+		s.healthy[name] = healthy
+		switch {
+		case healthy && ok && !last:
+			logger().Infof("health check %s is healthy: %s", name, result.Message())
+		case !healthy && (!ok || last):
+			logger().Warnf("health check %s is unhealthy: %s %v", name, result.Message(), result.Cause())
+		}
+	}
+}
+
+func logger() core.Logger {
+	return core.GetLogger("melon/metrics")
+}
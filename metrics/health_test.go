@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/codahale/metrics"
+	"github.com/goburrow/melon/core"
+	"github.com/goburrow/melon/health"
+)
+
+func TestHealthScheduler(t *testing.T) {
+	env := core.NewAdminEnvironment()
+	var healthy int32 = 1
+	env.HealthChecks.Register("scheduler", health.CheckerFunc(func() health.Result {
+		if atomic.LoadInt32(&healthy) == 1 {
+			return health.ResultHealthy("")
+		}
+		return health.ResultUnhealthy("down", nil)
+	}))
+	s := newHealthScheduler(env, time.Hour)
+	// Stopping a scheduler which is not started does not block.
+	if err := s.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	s.runChecks()
+	counters, gauges := metrics.Snapshot()
+	if gauges["HealthCheck.scheduler.Healthy"] != 1 || counters["HealthCheck.scheduler.Failures"] != 0 {
+		t.Fatalf("unexpected metrics: %v %v", counters, gauges)
+	}
+	atomic.StoreInt32(&healthy, 0)
+	s.runChecks()
+	s.runChecks()
+	counters, gauges = metrics.Snapshot()
+	if gauges["HealthCheck.scheduler.Healthy"] != 0 || counters["HealthCheck.scheduler.Failures"] != 2 {
+		t.Fatalf("unexpected metrics: %v %v", counters, gauges)
+	}
+
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Stop(); err != nil {
+		t.Fatal(err)
+	}
+}
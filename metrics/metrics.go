@@ -5,11 +5,14 @@ package metrics
 
 import (
 	"expvar"
+	"fmt"
 	"net/http"
+	"time"
 
 	// Package metrics registers metrics to expvar
-	_ "github.com/codahale/metrics"
+	"github.com/codahale/metrics"
 	_ "github.com/codahale/metrics/runtime"
+	"github.com/goburrow/melon/configuration"
 	"github.com/goburrow/melon/core"
 )
 
@@ -32,6 +35,10 @@ func (handler *metricsHandler) Path() string {
 
 func (*metricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Cache-Control", "must-revalidate,no-cache,no-store")
+	if acceptsPrometheus(r) {
+		servePrometheus(w)
+		return
+	}
 
 	val := expvar.Get(metricsVar)
 	if val == nil {
@@ -44,12 +51,53 @@ func (*metricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 // Factory implements core.MetricsFactory interface.
 type Factory struct {
-	Frequency string
+	// Frequency is the interval of reporting metrics, e.g. "1m".
+	Frequency configuration.Duration `valid:"min=0"`
+	// Reporters are registered in ReporterTypes.
+	Reporters []ReporterConfiguration
+	// HealthCheckInterval is the interval between runs of health checks
+	// recording their results in metrics, e.g. "30s". It is disabled if not
+	// positive.
+	HealthCheckInterval configuration.Duration `valid:"min=0"`
 }
 
-// Configure registers metrics handler to admin environment.
+// Configure registers metrics handlers to admin environment. Metrics are also
+// available in Prometheus text format at /metrics/prometheus or /metrics when
+// requested by a Prometheus scraper. Reporters send metrics every Frequency,
+// default is one minute.
 func (factory *Factory) ConfigureMetrics(env *core.Environment) error {
-	env.Admin.AddHandler(&metricsHandler{})
-	// TODO: configure frequency in metrics.
+	env.Admin.AddHandler(&metricsHandler{}, &prometheusHandler{})
+	env.Admin.AddTask(&dumpTask{})
+	// Startup and shutdown time in milliseconds.
+	timeline := env.Lifecycle.Timeline
+	metrics.Gauge("Lifecycle.StartupTime").SetFunc(func() int64 {
+		return int64(timeline.StartupTime() / time.Millisecond)
+	})
+	metrics.Gauge("Lifecycle.ShutdownTime").SetFunc(func() int64 {
+		return int64(timeline.ShutdownTime() / time.Millisecond)
+	})
+	if factory.HealthCheckInterval > 0 {
+		interval := time.Duration(factory.HealthCheckInterval)
+		env.Lifecycle.Manage(newHealthScheduler(env.Admin, interval))
+	}
+	if len(factory.Reporters) > 0 {
+		reporters := make([]Reporter, len(factory.Reporters))
+		for i, c := range factory.Reporters {
+			f, ok := c.Value().(ReporterFactory)
+			if !ok {
+				return fmt.Errorf("metrics: unsupported reporter %#v", c.Value())
+			}
+			r, err := f.Build(env)
+			if err != nil {
+				return err
+			}
+			reporters[i] = r
+		}
+		frequency := time.Duration(factory.Frequency)
+		if frequency <= 0 {
+			frequency = defaultFrequency
+		}
+		env.Lifecycle.Manage(newReportScheduler(reporters, frequency))
+	}
 	return nil
 }
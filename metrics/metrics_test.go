@@ -1,5 +1,86 @@
 package metrics
 
-import "github.com/goburrow/melon/core"
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goburrow/melon/core"
+)
 
 var _ core.MetricsFactory = (*Factory)(nil)
+var _ core.Command = (*DumpCommand)(nil)
+var _ core.Task = (*dumpTask)(nil)
+
+func TestWriteSnapshot(t *testing.T) {
+	ts := time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC)
+	data := []byte(`{"Counters":{"HTTP.Requests":3},"Gauges":{"Mem.Heap":1024}}`)
+
+	var buf bytes.Buffer
+	if err := writeSnapshot(&buf, "csv", ts, data); err != nil {
+		t.Fatal(err)
+	}
+	expected := "timestamp,name,value\n" +
+		"2018-01-01T00:00:00Z,Counters.HTTP.Requests,3\n" +
+		"2018-01-01T00:00:00Z,Gauges.Mem.Heap,1024\n"
+	if buf.String() != expected {
+		t.Fatalf("unexpected csv: %v", buf.String())
+	}
+	buf.Reset()
+	if err := writeSnapshot(&buf, "json", ts, data); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"Timestamp": "2018-01-01T00:00:00Z"`) ||
+		!strings.Contains(buf.String(), `"HTTP.Requests": 3`) {
+		t.Fatalf("unexpected json: %v", buf.String())
+	}
+	if err := writeSnapshot(&buf, "xml", ts, data); err == nil {
+		t.Fatal("error expected")
+	}
+}
+
+func TestDumpCommand(t *testing.T) {
+	srv := httptest.NewServer(&metricsHandler{})
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	output := filepath.Join(dir, "metrics.csv")
+
+	bootstrap := &core.Bootstrap{
+		Arguments: []string{"metrics-dump", "-url", srv.URL, "-format", "csv", "-output", output},
+	}
+	if err = (&DumpCommand{}).Run(bootstrap); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(b), "timestamp,name,value\n") {
+		t.Fatalf("unexpected output: %s", b)
+	}
+	notFound := httptest.NewServer(http.NotFoundHandler())
+	defer notFound.Close()
+	bootstrap.Arguments = []string{"metrics-dump", "-url", notFound.URL, "-output", output}
+	if err = (&DumpCommand{}).Run(bootstrap); err == nil {
+		t.Fatal("error expected")
+	}
+}
+
+func TestDumpTask(t *testing.T) {
+	w := httptest.NewRecorder()
+	(&dumpTask{}).ServeHTTP(w, httptest.NewRequest("POST", "/tasks/metrics-dump?format=csv", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/csv" {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Body.String())
+	}
+}
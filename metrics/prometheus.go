@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/codahale/metrics"
+)
+
+const (
+	prometheusPath        = "/metrics/prometheus"
+	prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+// prometheusHandler displays metrics in Prometheus text exposition format.
+type prometheusHandler struct {
+}
+
+func (handler *prometheusHandler) Name() string {
+	return "Prometheus Metrics"
+}
+
+func (handler *prometheusHandler) Path() string {
+	return prometheusPath
+}
+
+func (*prometheusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "must-revalidate,no-cache,no-store")
+	servePrometheus(w)
+}
+
+func servePrometheus(w http.ResponseWriter) {
+	var buf bytes.Buffer
+	counters, gauges := metrics.Snapshot()
+	writePrometheus(&buf, counters, gauges)
+	w.Header().Set("Content-Type", prometheusContentType)
+	w.Write(buf.Bytes())
+}
+
+// acceptsPrometheus returns true if the request is sent by a Prometheus scraper.
+func acceptsPrometheus(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, "version=0.0.4") || strings.Contains(accept, "application/openmetrics-text")
+}
+
+// writePrometheus writes counters and gauges sorted by name.
+func writePrometheus(w io.Writer, counters map[string]uint64, gauges map[string]int64) {
+	names := make([]string, 0, len(counters))
+	for name := range counters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		n := prometheusName(name)
+		fmt.Fprintf(w, "# TYPE %s counter\n%s %d\n", n, n, counters[name])
+	}
+	names = names[:0]
+	for name := range gauges {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		n := prometheusName(name)
+		fmt.Fprintf(w, "# TYPE %s gauge\n%s %d\n", n, n, gauges[name])
+	}
+}
+
+// prometheusName replaces characters not allowed in Prometheus metric names,
+// e.g. HTTP.Requests becomes HTTP_Requests.
+func prometheusName(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == ':' || (c >= '0' && c <= '9' && i > 0)) {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
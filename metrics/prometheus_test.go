@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/codahale/metrics"
+)
+
+func TestWritePrometheus(t *testing.T) {
+	var buf bytes.Buffer
+	writePrometheus(&buf,
+		map[string]uint64{"HTTP.Requests": 3, "Canary.1-ok.Success": 1},
+		map[string]int64{"Mem.Heap": 1024})
+	expected := "# TYPE Canary_1_ok_Success counter\nCanary_1_ok_Success 1\n" +
+		"# TYPE HTTP_Requests counter\nHTTP_Requests 3\n" +
+		"# TYPE Mem_Heap gauge\nMem_Heap 1024\n"
+	if buf.String() != expected {
+		t.Fatalf("unexpected output: %v", buf.String())
+	}
+	if prometheusName("5xx") != "_xx" {
+		t.Fatalf("unexpected name: %v", prometheusName("5xx"))
+	}
+}
+
+func TestPrometheusHandler(t *testing.T) {
+	metrics.Counter("Prometheus.Test").Add()
+	for _, h := range []http.Handler{&prometheusHandler{}, &metricsHandler{}} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/metrics", nil)
+		r.Header.Set("Accept", "text/plain;version=0.0.4;q=0.5,*/*;q=0.1")
+		h.ServeHTTP(w, r)
+		if w.Header().Get("Content-Type") != prometheusContentType ||
+			!strings.Contains(w.Body.String(), "Prometheus_Test 1\n") {
+			t.Fatalf("unexpected response: %v %v", w.Header(), w.Body.String())
+		}
+	}
+}
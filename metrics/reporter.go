@@ -0,0 +1,162 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/codahale/metrics"
+	"github.com/goburrow/dynamic"
+	"github.com/goburrow/melon/configuration"
+	"github.com/goburrow/melon/core"
+)
+
+const defaultFrequency = time.Minute
+
+// ReporterTypes contains reporter factories selected by key "type" of
+// reporter configuration.
+var ReporterTypes = configuration.NewRegistry("metrics reporter", (*ReporterFactory)(nil))
+
+func init() {
+	ReporterTypes.Register("LogReporter", func() interface{} { return &LogReporterFactory{} })
+}
+
+// Reporter sends snapshots of counters and gauges.
+type Reporter interface {
+	Report(counters map[string]uint64, gauges map[string]int64) error
+}
+
+// ReporterFactory creates a Reporter.
+type ReporterFactory interface {
+	Build(*core.Environment) (Reporter, error)
+}
+
+// ReporterConfiguration is an union of reporter configurations registered in
+// ReporterTypes, which is log reporter by default.
+type ReporterConfiguration struct {
+	dynamic.Type
+}
+
+// Types returns registry of reporter types.
+func (c *ReporterConfiguration) Types() *configuration.Registry {
+	return ReporterTypes
+}
+
+// UnmarshalJSON decodes the reporter configuration of the given type.
+func (c *ReporterConfiguration) UnmarshalJSON(data []byte) error {
+	v, err := ReporterTypes.Unmarshal(data)
+	if err != nil {
+		return err
+	}
+	c.SetValue(v)
+	return nil
+}
+
+// LogReporterFactory logs all metrics at INFO level to Logger, default is
+// "melon/metrics".
+type LogReporterFactory struct {
+	Logger string
+}
+
+// Build creates a log reporter.
+func (f *LogReporterFactory) Build(*core.Environment) (Reporter, error) {
+	name := f.Logger
+	if name == "" {
+		name = "melon/metrics"
+	}
+	return &logReporter{logger: core.GetLogger(name)}, nil
+}
+
+type logReporter struct {
+	logger core.Logger
+}
+
+func (r *logReporter) Report(counters map[string]uint64, gauges map[string]int64) error {
+	var buf bytes.Buffer
+	for _, k := range sortedKeys(counters, gauges) {
+		if buf.Len() > 0 {
+			buf.WriteByte(' ')
+		}
+		if v, ok := counters[k]; ok {
+			fmt.Fprintf(&buf, "%s=%d", k, v)
+		} else {
+			fmt.Fprintf(&buf, "%s=%d", k, gauges[k])
+		}
+	}
+	r.logger.Infof("metrics: %s", buf.String())
+	return nil
+}
+
+func sortedKeys(counters map[string]uint64, gauges map[string]int64) []string {
+	keys := make([]string, 0, len(counters)+len(gauges))
+	for k := range counters {
+		keys = append(keys, k)
+	}
+	for k := range gauges {
+		if _, ok := counters[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// reportScheduler sends snapshots of metrics to reporters periodically and
+// on stop. It implements core.Managed.
+type reportScheduler struct {
+	reporters []Reporter
+	frequency time.Duration
+
+	quit chan struct{}
+	done chan struct{}
+}
+
+func newReportScheduler(reporters []Reporter, frequency time.Duration) *reportScheduler {
+	return &reportScheduler{
+		reporters: reporters,
+		frequency: frequency,
+	}
+}
+
+// Start reports metrics in background.
+func (s *reportScheduler) Start() error {
+	s.quit = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.run()
+	return nil
+}
+
+// Stop reports metrics the last time.
+func (s *reportScheduler) Stop() error {
+	if s.quit != nil {
+		close(s.quit)
+		<-s.done
+		s.quit = nil
+	}
+	return nil
+}
+
+func (s *reportScheduler) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.frequency)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.report()
+		case <-s.quit:
+			s.report()
+			return
+		}
+	}
+}
+
+func (s *reportScheduler) report() {
+	counters, gauges := metrics.Snapshot()
+	for _, r := range s.reporters {
+		if err := r.Report(counters, gauges); err != nil {
+			logger().Warnf("could not report metrics: %v", err)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/codahale/metrics"
+	"github.com/goburrow/melon/core"
+)
+
+type testReporter struct {
+	counters chan map[string]uint64
+}
+
+func (r *testReporter) Report(counters map[string]uint64, gauges map[string]int64) error {
+	r.counters <- counters
+	return nil
+}
+
+func TestReportScheduler(t *testing.T) {
+	metrics.Reset()
+	metrics.Counter("Test.Reports").Add()
+	r := &testReporter{counters: make(chan map[string]uint64, 10)}
+	s := newReportScheduler([]Reporter{r}, time.Millisecond)
+	// Stopping a scheduler which is not started does not block.
+	if err := s.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	counters := <-r.counters
+	if counters["Test.Reports"] != 1 {
+		t.Fatalf("unexpected counters: %v", counters)
+	}
+	if err := s.Stop(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReporterConfiguration(t *testing.T) {
+	var factory Factory
+	err := json.Unmarshal([]byte(`{"frequency":"10s","reporters":[{"type":"LogReporter","logger":"test"}]}`), &factory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Duration(factory.Frequency) != 10*time.Second || len(factory.Reporters) != 1 {
+		t.Fatalf("unexpected factory: %+v", factory)
+	}
+	f, ok := factory.Reporters[0].Value().(*LogReporterFactory)
+	if !ok || f.Logger != "test" {
+		t.Fatalf("unexpected reporter: %#v", factory.Reporters[0].Value())
+	}
+	r, err := f.Build(&core.Environment{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = r.Report(map[string]uint64{"a": 1}, map[string]int64{"b": 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err = json.Unmarshal([]byte(`{"reporters":[{"type":"Graphite"}]}`), &factory); err == nil {
+		t.Fatal("error expected")
+	}
+}
@@ -0,0 +1,151 @@
+/*
+Package mock provides canned responses for configured routes, so that clients
+can be developed against the application before real handlers exist.
+
+Body of a response is a text/template executed with the request, which has
+fields Method, Path, Params (path parameters), Query and Header. For example:
+
+	mock:
+	  enabled: true
+	  routes:
+	    - method: GET
+	      path: /users/{id}
+	      headers:
+	        Content-Type: application/json
+	      body: '{"id": "{{.Params.id}}", "name": "User {{.Params.id}}"}'
+	      delay: 100ms
+
+Mocked routes take precedence over handlers registered by the application for
+the same paths. Mock mode is intended for development only.
+*/
+package mock
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"text/template"
+	"time"
+
+	"github.com/goburrow/melon/configuration"
+	"github.com/goburrow/melon/core"
+	"github.com/goburrow/melon/server/router"
+)
+
+// Factory implements core.MockFactory interface.
+type Factory struct {
+	Enabled bool
+	Routes  []RouteConfiguration
+}
+
+// RouteConfiguration is a canned response for requests matching Method and Path.
+type RouteConfiguration struct {
+	// Method is "*" by default which matches all methods.
+	Method string
+	Path   string `valid:"notempty"`
+	// Status is 200 by default.
+	Status  int `valid:"min=0"`
+	Headers map[string]string
+	// Body is a text/template.
+	Body string
+	// Delay is the time to wait before responding, e.g. "100ms" or a number
+	// of milliseconds.
+	Delay configuration.Duration `valid:"min=0"`
+}
+
+// ConfigureMock registers mocked routes to the application router when mock
+// mode is enabled.
+func (factory *Factory) ConfigureMock(env *core.Environment) error {
+	if !factory.Enabled {
+		return nil
+	}
+	handlers := make([]*handler, len(factory.Routes))
+	for i := range factory.Routes {
+		h, err := newHandler(&factory.Routes[i])
+		if err != nil {
+			return err
+		}
+		handlers[i] = h
+	}
+	logger().Warnf("mock mode is enabled with %d routes", len(handlers))
+	for i, h := range handlers {
+		env.Server.Router.Handle(h.method, factory.Routes[i].Path, h)
+	}
+	return nil
+}
+
+// handler writes a canned response.
+type handler struct {
+	method  string
+	status  int
+	headers map[string]string
+	body    *template.Template
+	delay   time.Duration
+}
+
+func newHandler(config *RouteConfiguration) (*handler, error) {
+	h := &handler{
+		method:  config.Method,
+		status:  config.Status,
+		headers: config.Headers,
+		delay:   time.Duration(config.Delay),
+	}
+	if h.method == "" {
+		h.method = "*"
+	}
+	if h.status == 0 {
+		h.status = http.StatusOK
+	}
+	if config.Body != "" {
+		var err error
+		h.body, err = template.New(config.Path).Option("missingkey=zero").Parse(config.Body)
+		if err != nil {
+			return nil, fmt.Errorf("mock: invalid body template of %s: %v", config.Path, err)
+		}
+	}
+	return h, nil
+}
+
+// templateData is the data of body template.
+type templateData struct {
+	Method string
+	Path   string
+	Params map[string]string
+	Query  url.Values
+	Header http.Header
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.delay > 0 {
+		select {
+		case <-time.After(h.delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	var buf bytes.Buffer
+	if h.body != nil {
+		data := &templateData{
+			Method: r.Method,
+			Path:   r.URL.Path,
+			Params: router.PathParams(r),
+			Query:  r.URL.Query(),
+			Header: r.Header,
+		}
+		if err := h.body.Execute(&buf, data); err != nil {
+			logger().Errorf("could not execute body template: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	for k, v := range h.headers {
+		w.Header().Set(k, v)
+	}
+	w.WriteHeader(h.status)
+	w.Write(buf.Bytes())
+}
+
+func logger() core.Logger {
+	return core.GetLogger("melon/mock")
+}
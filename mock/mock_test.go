@@ -0,0 +1,64 @@
+package mock
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/goburrow/melon/configuration"
+	"github.com/goburrow/melon/core"
+	"github.com/goburrow/melon/server/router"
+)
+
+var _ core.MockFactory = (*Factory)(nil)
+
+func TestFactory(t *testing.T) {
+	env := core.NewEnvironment()
+	handler := router.New()
+	env.Server.Router = handler
+
+	factory := &Factory{
+		Enabled: true,
+		Routes: []RouteConfiguration{
+			{
+				Method:  "GET",
+				Path:    "/users/{id}",
+				Headers: map[string]string{"Content-Type": "application/json"},
+				Body:    `{"id":"{{.Params.id}}","q":"{{.Query.Get "q"}}"}`,
+			},
+			{
+				Path:   "/slow",
+				Status: http.StatusAccepted,
+				Delay:  configuration.Duration(20 * time.Millisecond),
+			},
+		},
+	}
+	if err := factory.ConfigureMock(env); err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/users/1?q=melon", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json" ||
+		w.Body.String() != `{"id":"1","q":"melon"}` {
+		t.Fatalf("unexpected response: %v %v %v", w.Code, w.Header(), w.Body.String())
+	}
+
+	start := time.Now()
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/slow", nil))
+	if w.Code != http.StatusAccepted || time.Since(start) < 20*time.Millisecond {
+		t.Fatalf("unexpected response: %v %v", w.Code, time.Since(start))
+	}
+}
+
+func TestInvalidTemplate(t *testing.T) {
+	env := core.NewEnvironment()
+	factory := &Factory{
+		Enabled: true,
+		Routes:  []RouteConfiguration{{Path: "/", Body: "{{.Method"}},
+	}
+	if err := factory.ConfigureMock(env); err == nil {
+		t.Fatal("error expected")
+	}
+}
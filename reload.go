@@ -0,0 +1,234 @@
+package melon
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/goburrow/melon/core"
+)
+
+const reloadTaskName = "reload"
+
+// reloadablePaths are configuration paths which can be changed without
+// restarting the server.
+var reloadablePaths = []string{
+	"Logging.Level",
+	"Logging.Loggers",
+	"Server.RequestLog",
+	"Server.RateLimit",
+}
+
+// configurationReloader is implemented by configuration factories which can
+// parse configuration again into a new object.
+type configurationReloader interface {
+	ReloadConfiguration(*core.Bootstrap) (interface{}, error)
+}
+
+// loggingReloader is implemented by logging factories which can apply
+// changes at runtime.
+type loggingReloader interface {
+	ReloadLogging() error
+}
+
+// serverReloader is implemented by server factories which can apply changes
+// of another factory to the running server.
+type serverReloader interface {
+	ReloadServer(core.ServerFactory) error
+}
+
+// configurationWatcher is implemented by configuration source providers
+// which notify changes of the opened sources. It is optional for
+// core.ConfigurationSourceProvider.
+type configurationWatcher interface {
+	// WatchConfiguration calls changed whenever a source changes until stop
+	// is closed.
+	WatchConfiguration(changed func(), stop <-chan struct{})
+}
+
+// restartRequiredError reports changes which can not be applied at runtime.
+type restartRequiredError []string
+
+func (e restartRequiredError) Error() string {
+	return "restart required for changes of " + strings.Join(e, ", ")
+}
+
+// reloader parses configuration again and applies changes of reloadable
+// sections to the running server.
+type reloader struct {
+	mu        sync.Mutex
+	bootstrap *core.Bootstrap
+	validator core.Validator
+	// server is the factory which built the running server.
+	server core.ServerFactory
+	// configuration is the configuration currently applied.
+	configuration interface{}
+}
+
+// Reload returns paths of changed configuration. Nothing is applied if any
+// of the changes requires restarting.
+func (r *reloader) Reload() ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	factory, ok := r.bootstrap.ConfigurationFactory.(configurationReloader)
+	if !ok {
+		return nil, fmt.Errorf("configuration factory does not support reloading: %T", r.bootstrap.ConfigurationFactory)
+	}
+	config, err := factory.ReloadConfiguration(r.bootstrap)
+	if err != nil {
+		return nil, err
+	}
+	if err = r.bootstrap.RunConfigurationHooks(config); err != nil {
+		return nil, err
+	}
+	if err = r.validator.Validate(config); err != nil {
+		return nil, fmt.Errorf("configuration is invalid: %v", err)
+	}
+	newConfig, ok := config.(core.Configuration)
+	if !ok {
+		return nil, fmt.Errorf("configuration does not implement core.Configuration interface %T", config)
+	}
+	changes, err := diffConfiguration(r.configuration, config)
+	if err != nil {
+		return nil, err
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	var rejected restartRequiredError
+	for _, c := range changes {
+		if !isReloadable(c) {
+			rejected = append(rejected, c)
+		}
+	}
+	if len(rejected) > 0 {
+		return changes, rejected
+	}
+	if s, ok := r.server.(serverReloader); ok {
+		if err = s.ReloadServer(newConfig.ServerFactory()); err != nil {
+			return changes, err
+		}
+	}
+	if l, ok := newConfig.LoggingFactory().(loggingReloader); ok {
+		if err = l.ReloadLogging(); err != nil {
+			return changes, err
+		}
+	}
+	r.configuration = config
+	return changes, nil
+}
+
+func isReloadable(path string) bool {
+	for _, p := range reloadablePaths {
+		if path == p || strings.HasPrefix(path, p+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// diffConfiguration returns sorted paths of values which differ in JSON
+// representation of configuration a and b.
+func diffConfiguration(a, b interface{}) ([]string, error) {
+	va, err := genericValue(a)
+	if err != nil {
+		return nil, err
+	}
+	vb, err := genericValue(b)
+	if err != nil {
+		return nil, err
+	}
+	var changes []string
+	diffValue("", va, vb, &changes)
+	sort.Strings(changes)
+	return changes, nil
+}
+
+func genericValue(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	if err = json.Unmarshal(b, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func diffValue(path string, a, b interface{}, changes *[]string) {
+	ma, okA := a.(map[string]interface{})
+	mb, okB := b.(map[string]interface{})
+	if !okA || !okB {
+		if !reflect.DeepEqual(a, b) {
+			*changes = append(*changes, path)
+		}
+		return
+	}
+	for k, v := range ma {
+		diffValue(joinPath(path, k), v, mb[k], changes)
+	}
+	for k, v := range mb {
+		if _, ok := ma[k]; !ok {
+			diffValue(joinPath(path, k), nil, v, changes)
+		}
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// reloadTask reloads configuration:
+//
+// 	POST /tasks/reload
+type reloadTask struct {
+	reloader *reloader
+}
+
+func (*reloadTask) Name() string {
+	return reloadTaskName
+}
+
+func (t *reloadTask) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	changes, err := t.reloader.Reload()
+	if err != nil {
+		if _, ok := err.(restartRequiredError); ok {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if len(changes) == 0 {
+		fmt.Fprintln(w, "configuration is unchanged")
+		return
+	}
+	fmt.Fprintf(w, "reloaded %s\n", strings.Join(changes, ", "))
+}
+
+// reload reloads configuration and logs the result.
+func (r *reloader) reload() {
+	changes, err := r.Reload()
+	if err != nil {
+		logger().Errorf("could not reload configuration: %v", err)
+		return
+	}
+	if len(changes) == 0 {
+		logger().Infof("configuration is unchanged")
+		return
+	}
+	logger().Infof("reloaded %s", strings.Join(changes, ", "))
+}
@@ -0,0 +1,319 @@
+/*
+Package report provides error reporting to external services such as Sentry.
+*/
+package report
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	mathrand "math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/goburrow/dynamic"
+	"github.com/goburrow/melon/configuration"
+	"github.com/goburrow/melon/core"
+)
+
+const (
+	eventBufferSize = 100
+	stopTimeout     = 10 * time.Second
+
+	filteredValue = "[Filtered]"
+)
+
+var defaultScrubs = []string{"authorization", "cookie", "password", "secret", "token"}
+
+// ReporterTypes contains reporter factories selected by key "type" of
+// reporter configuration.
+var ReporterTypes = configuration.NewRegistry("reporter", (*ReporterFactory)(nil))
+
+func init() {
+	ReporterTypes.Register("SentryReporter", func() interface{} { return &SentryReporterFactory{} })
+	ReporterTypes.Register("WebhookReporter", func() interface{} { return &WebhookReporterFactory{} })
+}
+
+// Event is an error report sent to reporters.
+type Event struct {
+	ID          string            `json:"id"`
+	Timestamp   time.Time         `json:"timestamp"`
+	Source      string            `json:"source"`
+	Type        string            `json:"type"`
+	Message     string            `json:"message"`
+	Stack       string            `json:"stack,omitempty"`
+	Request     *Request          `json:"request,omitempty"`
+	Release     string            `json:"release,omitempty"`
+	Environment string            `json:"environment,omitempty"`
+	ServerName  string            `json:"serverName,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+}
+
+// Request is the scrubbed HTTP request of an Event.
+type Request struct {
+	Method      string            `json:"method"`
+	URL         string            `json:"url"`
+	QueryString string            `json:"queryString,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	RemoteAddr  string            `json:"remoteAddr,omitempty"`
+}
+
+// Reporter sends events to an external service.
+type Reporter interface {
+	Report(*Event) error
+}
+
+// ReporterFactory creates a Reporter.
+type ReporterFactory interface {
+	Build(*core.Environment) (Reporter, error)
+}
+
+// ReporterConfiguration is an union of reporter configurations registered in
+// ReporterTypes, which are Sentry and webhook by default.
+type ReporterConfiguration struct {
+	dynamic.Type
+}
+
+// Types returns registry of reporter types.
+func (c *ReporterConfiguration) Types() *configuration.Registry {
+	return ReporterTypes
+}
+
+// UnmarshalJSON decodes the reporter configuration of the given type.
+func (c *ReporterConfiguration) UnmarshalJSON(data []byte) error {
+	v, err := ReporterTypes.Unmarshal(data)
+	if err != nil {
+		return err
+	}
+	c.SetValue(v)
+	return nil
+}
+
+// Factory implements core.ErrorReportingFactory interface.
+type Factory struct {
+	Reporters []ReporterConfiguration
+	// SampleRate is the fraction of events to be reported, from 0 to 1.
+	// All events are reported if it is not set.
+	SampleRate  float64
+	Release     string
+	Environment string
+	Tags        map[string]string
+	// Scrub contains names of headers and query parameters which values
+	// must not be reported. A name matches when it contains any of these.
+	Scrub []string
+}
+
+// ConfigureErrorReporting builds all reporters and sets the error reporter
+// of the application.
+func (factory *Factory) ConfigureErrorReporting(env *core.Environment) error {
+	if len(factory.Reporters) == 0 {
+		return nil
+	}
+	if factory.SampleRate < 0 || factory.SampleRate > 1 {
+		return fmt.Errorf("report: sample rate must be between 0 and 1: %v", factory.SampleRate)
+	}
+	d := newDispatcher()
+	for _, reporterFactory := range factory.Reporters {
+		f, ok := reporterFactory.Value().(ReporterFactory)
+		if !ok {
+			return fmt.Errorf("report: unsupported reporter %#v", reporterFactory.Value())
+		}
+		reporter, err := f.Build(env)
+		if err != nil {
+			return err
+		}
+		d.reporters = append(d.reporters, reporter)
+	}
+	if factory.SampleRate > 0 {
+		d.sampleRate = factory.SampleRate
+	}
+	d.release = factory.Release
+	d.environment = factory.Environment
+	d.tags = factory.Tags
+	d.scrubs = append(d.scrubs, factory.Scrub...)
+	for i := range d.scrubs {
+		d.scrubs[i] = strings.ToLower(d.scrubs[i])
+	}
+	env.Lifecycle.Manage(d)
+	core.SetErrorReporter(d)
+	return nil
+}
+
+// dispatcher converts and delivers events to reporters in background.
+// It implements core.ErrorReporter and core.Managed.
+type dispatcher struct {
+	reporters  []Reporter
+	sampleRate float64
+
+	release     string
+	environment string
+	serverName  string
+	tags        map[string]string
+	scrubs      []string
+
+	events chan *Event
+	quit   chan struct{}
+	done   chan struct{}
+}
+
+func newDispatcher() *dispatcher {
+	serverName, _ := os.Hostname()
+	return &dispatcher{
+		sampleRate: 1,
+		serverName: serverName,
+		scrubs:     append([]string(nil), defaultScrubs...),
+
+		events: make(chan *Event, eventBufferSize),
+	}
+}
+
+// ReportError samples and queues the event. Events are dropped when the queue is full.
+func (d *dispatcher) ReportError(e *core.ErrorEvent) {
+	if d.sampleRate < 1 && mathrand.Float64() >= d.sampleRate {
+		return
+	}
+	event := d.newEvent(e)
+	select {
+	case d.events <- event:
+	default:
+		logger().Warnf("dropped error event %s: queue is full", event.ID)
+	}
+}
+
+// Start starts delivering events.
+func (d *dispatcher) Start() error {
+	d.quit = make(chan struct{})
+	d.done = make(chan struct{})
+	go d.run()
+	return nil
+}
+
+// Stop delivers remaining events and stops. It does nothing if the
+// dispatcher is not started.
+func (d *dispatcher) Stop() error {
+	if d.quit == nil {
+		return nil
+	}
+	close(d.quit)
+	d.quit = nil
+	select {
+	case <-d.done:
+	case <-time.After(stopTimeout):
+		logger().Warnf("timed out delivering error events")
+	}
+	return nil
+}
+
+func (d *dispatcher) run() {
+	defer close(d.done)
+	for {
+		select {
+		case event := <-d.events:
+			d.send(event)
+		case <-d.quit:
+			for {
+				select {
+				case event := <-d.events:
+					d.send(event)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (d *dispatcher) send(event *Event) {
+	for _, reporter := range d.reporters {
+		if err := reporter.Report(event); err != nil {
+			logger().Warnf("could not report error event %s: %v", event.ID, err)
+		}
+	}
+}
+
+func (d *dispatcher) newEvent(e *core.ErrorEvent) *Event {
+	event := &Event{
+		ID:          newEventID(),
+		Timestamp:   time.Now().UTC(),
+		Source:      e.Source,
+		Release:     d.release,
+		Environment: d.environment,
+		ServerName:  d.serverName,
+		Tags:        d.tags,
+	}
+	if e.Err != nil {
+		event.Type = fmt.Sprintf("%T", e.Err)
+		event.Message = e.Err.Error()
+	}
+	if len(e.Stack) > 0 {
+		event.Stack = string(e.Stack)
+	}
+	if e.Request != nil {
+		event.Request = d.newRequest(e.Request)
+	}
+	return event
+}
+
+func (d *dispatcher) newRequest(r *http.Request) *Request {
+	u := url.URL{
+		Scheme: "http",
+		Host:   r.Host,
+		Path:   r.URL.Path,
+	}
+	if r.TLS != nil {
+		u.Scheme = "https"
+	}
+	req := &Request{
+		Method:     r.Method,
+		URL:        u.String(),
+		RemoteAddr: r.RemoteAddr,
+	}
+	if len(r.Header) > 0 {
+		req.Headers = make(map[string]string, len(r.Header))
+		for k, v := range r.Header {
+			if d.isScrubbed(k) {
+				req.Headers[k] = filteredValue
+			} else {
+				req.Headers[k] = strings.Join(v, ", ")
+			}
+		}
+	}
+	if r.URL.RawQuery != "" {
+		query := r.URL.Query()
+		for k := range query {
+			if d.isScrubbed(k) {
+				query[k] = []string{filteredValue}
+			}
+		}
+		req.QueryString = query.Encode()
+	}
+	return req
+}
+
+// isScrubbed returns true if name contains any of scrubbing names.
+func (d *dispatcher) isScrubbed(name string) bool {
+	name = strings.ToLower(name)
+	for _, s := range d.scrubs {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// newEventID returns a random 32-character hex string.
+func newEventID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// Fallback to pseudo-random number.
+		return fmt.Sprintf("%016x%016x", mathrand.Int63(), mathrand.Int63())
+	}
+	return hex.EncodeToString(b[:])
+}
+
+func logger() core.Logger {
+	return core.GetLogger("melon/report")
+}
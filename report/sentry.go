@@ -0,0 +1,140 @@
+package report
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/goburrow/melon/core"
+)
+
+const (
+	sentryVersion = "7"
+	sentryClient  = "melon/1.0"
+)
+
+// SentryReporterFactory provides a reporter that sends events to Sentry.
+// DSN is in the form of {scheme}://{key}[:{secret}]@{host}[/{path}]/{project}.
+type SentryReporterFactory struct {
+	DSN string `valid:"notempty" secret:"true"`
+}
+
+// Build returns a Sentry reporter.
+func (factory *SentryReporterFactory) Build(*core.Environment) (Reporter, error) {
+	storeURL, auth, err := parseSentryDSN(factory.DSN)
+	if err != nil {
+		return nil, err
+	}
+	return &sentryReporter{
+		url: storeURL,
+		headers: map[string]string{
+			"X-Sentry-Auth": auth,
+		},
+		client: &http.Client{Timeout: defaultTimeout},
+	}, nil
+}
+
+// parseSentryDSN returns store endpoint and authentication header from dsn.
+func parseSentryDSN(dsn string) (string, string, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "", "", fmt.Errorf("report: invalid sentry dsn: %v", err)
+	}
+	if u.User == nil || u.User.Username() == "" {
+		return "", "", fmt.Errorf("report: sentry dsn has no public key: %s", dsn)
+	}
+	idx := strings.LastIndex(u.Path, "/")
+	if idx < 0 || idx == len(u.Path)-1 {
+		return "", "", fmt.Errorf("report: sentry dsn has no project id: %s", dsn)
+	}
+	project := u.Path[idx+1:]
+	auth := fmt.Sprintf("Sentry sentry_version=%s, sentry_client=%s, sentry_key=%s",
+		sentryVersion, sentryClient, u.User.Username())
+	if secret, ok := u.User.Password(); ok {
+		auth += ", sentry_secret=" + secret
+	}
+	storeURL := fmt.Sprintf("%s://%s%s/api/%s/store/", u.Scheme, u.Host, u.Path[:idx], project)
+	return storeURL, auth, nil
+}
+
+// sentryReporter sends events to Sentry store endpoint.
+type sentryReporter struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func (r *sentryReporter) Report(event *Event) error {
+	return postJSON(r.client, r.url, r.headers, newSentryEvent(event))
+}
+
+type sentryEvent struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   string            `json:"timestamp"`
+	Level       string            `json:"level"`
+	Logger      string            `json:"logger,omitempty"`
+	Platform    string            `json:"platform"`
+	Message     string            `json:"message"`
+	Release     string            `json:"release,omitempty"`
+	Environment string            `json:"environment,omitempty"`
+	ServerName  string            `json:"server_name,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Exception   *sentryException  `json:"exception,omitempty"`
+	Request     *sentryRequest    `json:"request,omitempty"`
+	Extra       map[string]string `json:"extra,omitempty"`
+}
+
+type sentryException struct {
+	Values []sentryExceptionValue `json:"values"`
+}
+
+type sentryExceptionValue struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type sentryRequest struct {
+	URL         string            `json:"url"`
+	Method      string            `json:"method"`
+	QueryString string            `json:"query_string,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+}
+
+func newSentryEvent(event *Event) *sentryEvent {
+	e := &sentryEvent{
+		EventID:     event.ID,
+		Timestamp:   event.Timestamp.Format(time.RFC3339),
+		Level:       "error",
+		Logger:      event.Source,
+		Platform:    "go",
+		Message:     event.Message,
+		Release:     event.Release,
+		Environment: event.Environment,
+		ServerName:  event.ServerName,
+		Tags:        event.Tags,
+	}
+	if event.Source == "panic" {
+		e.Level = "fatal"
+	}
+	if event.Type != "" {
+		e.Exception = &sentryException{
+			Values: []sentryExceptionValue{
+				{Type: event.Type, Value: event.Message},
+			},
+		}
+	}
+	if event.Request != nil {
+		e.Request = &sentryRequest{
+			URL:         event.Request.URL,
+			Method:      event.Request.Method,
+			QueryString: event.Request.QueryString,
+			Headers:     event.Request.Headers,
+		}
+	}
+	if event.Stack != "" {
+		e.Extra = map[string]string{"stack": event.Stack}
+	}
+	return e
+}
@@ -0,0 +1,69 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/goburrow/melon/core"
+)
+
+const (
+	defaultTimeout = 5 * time.Second
+)
+
+// WebhookReporterFactory provides a reporter that posts events in JSON to an URL.
+type WebhookReporterFactory struct {
+	URL     string            `valid:"notempty,url"`
+	Headers map[string]string `secret:"true"`
+}
+
+// Build returns a webhook reporter.
+func (factory *WebhookReporterFactory) Build(*core.Environment) (Reporter, error) {
+	if factory.URL == "" {
+		return nil, fmt.Errorf("report: webhook url is required")
+	}
+	return &webhookReporter{
+		url:     factory.URL,
+		headers: factory.Headers,
+		client:  &http.Client{Timeout: defaultTimeout},
+	}, nil
+}
+
+// webhookReporter sends events to a HTTP endpoint.
+type webhookReporter struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func (r *webhookReporter) Report(event *Event) error {
+	return postJSON(r.client, r.url, r.headers, event)
+}
+
+// postJSON sends v in JSON to the given url.
+func postJSON(client *http.Client, url string, headers map[string]string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rsp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	rsp.Body.Close()
+	if rsp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response status %s", rsp.Status)
+	}
+	return nil
+}
@@ -3,6 +3,8 @@ package melon
 import (
 	"os"
 	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/goburrow/melon/core"
 )
@@ -29,6 +31,7 @@ func (command *serverCommand) Description() string {
 // Run runs the command with the given bootstrap.
 func (command *serverCommand) Run(bootstrap *core.Bootstrap) error {
 	// Parse configuration
+	started := time.Now()
 	err := command.configurationCommand.Run(bootstrap)
 	if err != nil {
 		logger().Errorf("could not run server: %v", err)
@@ -38,6 +41,9 @@ func (command *serverCommand) Run(bootstrap *core.Bootstrap) error {
 	environment := core.NewEnvironment()
 	environment.Validator = command.configurationCommand.validator
 	defer environment.Stop()
+	timeline := environment.Lifecycle.Timeline
+	timeline.Startup("parse configuration", started)
+	started = time.Now()
 	// Config other factories that affect this environment.
 	configuration := command.configurationCommand.configuration.(core.Configuration)
 	err = configuration.LoggingFactory().ConfigureLogging(environment)
@@ -50,6 +56,48 @@ func (command *serverCommand) Run(bootstrap *core.Bootstrap) error {
 		logger().Errorf("could not run server: %v", err)
 		return err
 	}
+	if c, ok := configuration.(errorReportingConfiguration); ok {
+		err = c.ErrorReportingFactory().ConfigureErrorReporting(environment)
+		if err != nil {
+			logger().Errorf("could not run server: %v", err)
+			return err
+		}
+	}
+	if c, ok := configuration.(canaryConfiguration); ok {
+		err = c.CanaryFactory().ConfigureCanary(environment)
+		if err != nil {
+			logger().Errorf("could not run server: %v", err)
+			return err
+		}
+	}
+	if c, ok := configuration.(watchdogConfiguration); ok {
+		err = c.WatchdogFactory().ConfigureWatchdog(environment)
+		if err != nil {
+			logger().Errorf("could not run server: %v", err)
+			return err
+		}
+	}
+	if c, ok := configuration.(diagnosticsConfiguration); ok {
+		err = c.DiagnosticsFactory().ConfigureDiagnostics(environment, configuration)
+		if err != nil {
+			logger().Errorf("could not run server: %v", err)
+			return err
+		}
+	}
+	if c, ok := configuration.(tracingConfiguration); ok {
+		err = c.TracingFactory().ConfigureTracing(environment)
+		if err != nil {
+			logger().Errorf("could not run server: %v", err)
+			return err
+		}
+	}
+	if c, ok := configuration.(debugConfiguration); ok {
+		err = c.DebugFactory().ConfigureDebug(environment)
+		if err != nil {
+			logger().Errorf("could not run server: %v", err)
+			return err
+		}
+	}
 	// Always run Stop() method on managed objects.
 	// Build server
 	server, err := configuration.ServerFactory().BuildServer(environment)
@@ -57,38 +105,100 @@ func (command *serverCommand) Run(bootstrap *core.Bootstrap) error {
 		logger().Errorf("could not run server: %v", err)
 		return err
 	}
+	// Request log, rate limit and logging levels can be reloaded.
+	reloader := &reloader{
+		bootstrap:     bootstrap,
+		validator:     command.configurationCommand.validator,
+		server:        configuration.ServerFactory(),
+		configuration: configuration,
+	}
+	environment.Admin.AddTask(&reloadTask{reloader: reloader})
+	// Mocked routes require the server router.
+	if c, ok := configuration.(mockConfiguration); ok {
+		err = c.MockFactory().ConfigureMock(environment)
+		if err != nil {
+			logger().Errorf("could not run server: %v", err)
+			return err
+		}
+	}
+	timeline.Startup("build server", started)
 	// Now can start everything
 	printBanner()
 	// Run all bundles in bootstrap
+	started = time.Now()
 	err = bootstrap.Run(command.configurationCommand.configuration, environment)
 	if err != nil {
 		logger().Errorf("could not run bootstrap: %v", err)
 		return err
 	}
+	timeline.Startup("run bundles", started)
 	// Run application
+	started = time.Now()
 	err = bootstrap.Application.Run(command.configurationCommand.configuration, environment)
 	if err != nil {
 		logger().Errorf("could not run application: %v", err)
 		return err
 	}
+	timeline.Startup("run application", started)
 	err = environment.Start()
 	if err != nil {
 		logger().Errorf("could not start environment: %v", err)
 		return err
 	}
+	// Managed objects are started so health checks can be run before
+	// accepting requests.
+	if environment.Admin.StartupHealthCheck {
+		started = time.Now()
+		err = environment.Admin.CheckHealth()
+		if err != nil {
+			logger().Errorf("could not start server: %v", err)
+			return err
+		}
+		timeline.Startup("check health", started)
+	}
+	// Reload configuration on SIGHUP
+	hupCh := make(chan os.Signal, 1)
+	signal.Notify(hupCh, syscall.SIGHUP)
+	defer func() {
+		signal.Stop(hupCh)
+		close(hupCh)
+	}()
+	go func() {
+		for range hupCh {
+			reloader.reload()
+		}
+	}()
+	// Reload configuration when the sources change
+	if w, ok := bootstrap.ConfigurationSourceProvider.(configurationWatcher); ok {
+		stopWatch := make(chan struct{})
+		defer close(stopWatch)
+		go w.WatchConfiguration(reloader.reload, stopWatch)
+	}
 	// Handle signal
 	sigCh := make(chan os.Signal, 1)
 	defer close(sigCh)
 	signal.Notify(sigCh, os.Interrupt)
 	go func() {
-		for sig := range sigCh {
-			logger().Debugf("received signal %v", sig)
-			err := server.Stop()
-			if err != nil {
-				logger().Errorf("could not stop server: %v", err)
+		select {
+		case sig, ok := <-sigCh:
+			if !ok {
+				return
 			}
-			return
+			logger().Debugf("received signal %v", sig)
+		case <-environment.Admin.ShutdownRequested():
+			logger().Debugf("received shutdown request")
+		}
+		started := time.Now()
+		environment.Admin.Drain()
+		if environment.Admin.DrainPeriod > 0 {
+			logger().Infof("waiting %v before stopping server", environment.Admin.DrainPeriod)
+			time.Sleep(environment.Admin.DrainPeriod)
+		}
+		err := server.Stop()
+		if err != nil {
+			logger().Errorf("could not stop server: %v", err)
 		}
+		timeline.Shutdown("stop server", started)
 	}()
 	// Start is blocking
 	err = server.Start()
@@ -0,0 +1,102 @@
+/*
+Package audit provides a filter which records admin task invocations at INFO
+level to logger "melon/audit", which can have dedicated appenders configured
+in logging.auditAppenders.
+*/
+package audit
+
+import (
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/goburrow/melon/auth"
+	"github.com/goburrow/melon/core"
+	"github.com/goburrow/melon/server/filter"
+)
+
+// LoggerName is the name of the audit logger.
+const LoggerName = "melon/audit"
+
+// For testing
+var now = time.Now
+
+// auditFilter logs every request with its caller and outcome.
+type auditFilter struct {
+	logger core.Logger
+}
+
+// Option adds option for Filter.
+type Option func(f *auditFilter)
+
+// NewFilter returns a Filter which logs task name, query parameters, client
+// address, authenticated principal, duration and response status of requests.
+// It has filter.PriorityAudit so requests rejected by authentication filter
+// are also recorded. It is usually added with filter.ForPaths for /tasks/*.
+func NewFilter(options ...Option) filter.Filter {
+	f := &auditFilter{}
+	for _, opt := range options {
+		opt(f)
+	}
+	return f
+}
+
+// WithLogger sets audit logger instead of LoggerName.
+func WithLogger(logger core.Logger) Option {
+	return func(f *auditFilter) {
+		f.logger = logger
+	}
+}
+
+func (f *auditFilter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sw := &statusWriter{ResponseWriter: w}
+	r, principalOf := auth.Track(r)
+	start := now()
+	filter.Continue(sw, r)
+	duration := now().Sub(start)
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	principal := "-"
+	if p := principalOf(); p != nil {
+		principal = p.Name()
+	}
+	logger := f.logger
+	if logger == nil {
+		logger = core.GetLogger(LoggerName)
+	}
+	logger.Infof("task=%s params=%q remote=%s principal=%s status=%d duration=%v",
+		path.Base(r.URL.Path), r.URL.RawQuery, r.RemoteAddr, principal, sw.status, duration)
+}
+
+// Priority returns filter.PriorityAudit.
+func (f *auditFilter) Priority() int {
+	return filter.PriorityAudit
+}
+
+// statusWriter records status code of the response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher so task output is streamed.
+func (w *statusWriter) Flush() {
+	if fl, ok := w.ResponseWriter.(http.Flusher); ok {
+		fl.Flush()
+	}
+}
@@ -0,0 +1,68 @@
+package audit
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/goburrow/melon/auth"
+	"github.com/goburrow/melon/server/filter"
+)
+
+type testLogger struct {
+	infos []string
+}
+
+func (l *testLogger) Debugf(format string, args ...interface{}) {}
+func (l *testLogger) Warnf(format string, args ...interface{})  {}
+func (l *testLogger) Errorf(format string, args ...interface{}) {}
+
+func (l *testLogger) Infof(format string, args ...interface{}) {
+	l.infos = append(l.infos, fmt.Sprintf(format, args...))
+}
+
+func TestFilter(t *testing.T) {
+	start := time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC)
+	calls := 0
+	now = func() time.Time {
+		calls++
+		return start.Add(time.Duration(calls) * time.Second)
+	}
+	defer func() { now = time.Now }()
+
+	logger := &testLogger{}
+	chain := filter.NewChain()
+	chain.Add(NewFilter(WithLogger(logger)))
+	chain.Add(auth.NewFilter(auth.NewBasicAuthenticator(func(username, password string) (auth.Principal, error) {
+		if password != "sec" {
+			return nil, nil
+		}
+		return auth.NewPrincipal(username), nil
+	})))
+	chain.Add(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+
+	r := httptest.NewRequest("POST", "/tasks/gc?free=true", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.SetBasicAuth("adm", "sec")
+	chain.ServeHTTP(httptest.NewRecorder(), r)
+	expected := `task=gc params="free=true" remote=10.0.0.1:1234 principal=adm status=202 duration=1s`
+	if len(logger.infos) != 1 || logger.infos[0] != expected {
+		t.Fatalf("unexpected logs: %v", logger.infos)
+	}
+
+	r = httptest.NewRequest("POST", "/tasks/gc", nil)
+	r.RemoteAddr = "10.0.0.2:1234"
+	r.SetBasicAuth("adm", "invalid")
+	chain.ServeHTTP(httptest.NewRecorder(), r)
+	expected = `task=gc params="" remote=10.0.0.2:1234 principal=- status=401 duration=1s`
+	if len(logger.infos) != 2 || logger.infos[1] != expected {
+		t.Fatalf("unexpected logs: %v", logger.infos)
+	}
+	if p := filter.PriorityOf(NewFilter()); p >= filter.PriorityAuthentication {
+		t.Fatalf("unexpected priority: %v", p)
+	}
+}
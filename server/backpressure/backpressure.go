@@ -0,0 +1,87 @@
+/*
+Package backpressure provides helpers for handlers and filters to signal
+clients to back off consistently.
+
+Rejected requests are responded with status 503 (Service Unavailable) when the
+server is overloaded, or 429 (Too Many Requests) when the client exceeds its
+rate limit. Both include a Retry-After header estimated from the queue depth
+or the rate limiter state.
+*/
+package backpressure
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/codahale/metrics"
+	"github.com/goburrow/melon/server/problem"
+)
+
+const (
+	// MinRetryAfter is the minimum delay suggested to clients.
+	MinRetryAfter = 1 * time.Second
+	// MaxRetryAfter is the maximum delay suggested to clients.
+	MaxRetryAfter = 1 * time.Hour
+)
+
+var rejected = metrics.Counter("HTTP.Rejected")
+
+// Overloaded responds status 503 with header Retry-After.
+func Overloaded(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	Reject(w, r, http.StatusServiceUnavailable, retryAfter)
+}
+
+// TooManyRequests responds status 429 with header Retry-After.
+func TooManyRequests(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	Reject(w, r, http.StatusTooManyRequests, retryAfter)
+}
+
+// Reject responds the given status with header Retry-After and problem details
+// in the body. It also increases counter HTTP.Rejected.
+func Reject(w http.ResponseWriter, r *http.Request, status int, retryAfter time.Duration) {
+	rejected.Add()
+	SetRetryAfter(w.Header(), retryAfter)
+	problem.Error(w, r, status, "")
+}
+
+// SetRetryAfter sets header Retry-After in seconds. The delay is rounded up
+// and limited in range [MinRetryAfter, MaxRetryAfter].
+func SetRetryAfter(header http.Header, retryAfter time.Duration) {
+	header.Set("Retry-After", strconv.FormatInt(seconds(retryAfter), 10))
+}
+
+func seconds(d time.Duration) int64 {
+	if d < MinRetryAfter {
+		d = MinRetryAfter
+	} else if d > MaxRetryAfter {
+		d = MaxRetryAfter
+	}
+	return int64(math.Ceil(d.Seconds()))
+}
+
+// QueueDelay estimates waiting time of a new request given number of requests
+// in the queue, number of workers processing them and the average latency.
+func QueueDelay(depth, workers int, latency time.Duration) time.Duration {
+	if depth <= 0 {
+		return 0
+	}
+	if workers <= 0 {
+		workers = 1
+	}
+	batches := (depth + workers - 1) / workers
+	return time.Duration(batches) * latency
+}
+
+// RateDelay estimates waiting time until a token is available in a token bucket
+// rate limiter which has the given tokens and refills at rate tokens per second.
+func RateDelay(tokens, rate float64) time.Duration {
+	if tokens >= 1 {
+		return 0
+	}
+	if rate <= 0 {
+		return MaxRetryAfter
+	}
+	return time.Duration((1 - tokens) / rate * float64(time.Second))
+}
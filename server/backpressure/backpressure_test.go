@@ -0,0 +1,76 @@
+package backpressure
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/goburrow/melon/server/problem"
+)
+
+func TestReject(t *testing.T) {
+	tests := []struct {
+		f          func(http.ResponseWriter, *http.Request, time.Duration)
+		retryAfter time.Duration
+		status     int
+		header     string
+	}{
+		{Overloaded, 0, http.StatusServiceUnavailable, "1"},
+		{Overloaded, 1500 * time.Millisecond, http.StatusServiceUnavailable, "2"},
+		{TooManyRequests, 30 * time.Second, http.StatusTooManyRequests, "30"},
+		{TooManyRequests, 48 * time.Hour, http.StatusTooManyRequests, "3600"},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		test.f(w, httptest.NewRequest("GET", "/", nil), test.retryAfter)
+		if w.Code != test.status {
+			t.Fatalf("unexpected status: %v, expect: %v", w.Code, test.status)
+		}
+		if w.Header().Get("Content-Type") != problem.ContentType {
+			t.Fatalf("unexpected Content-Type: %v", w.Header().Get("Content-Type"))
+		}
+		if w.Header().Get("Retry-After") != test.header {
+			t.Fatalf("unexpected Retry-After: %v, expect: %v", w.Header().Get("Retry-After"), test.header)
+		}
+	}
+}
+
+func TestQueueDelay(t *testing.T) {
+	tests := []struct {
+		depth   int
+		workers int
+		latency time.Duration
+		delay   time.Duration
+	}{
+		{0, 1, time.Second, 0},
+		{1, 0, time.Second, time.Second},
+		{10, 4, 100 * time.Millisecond, 300 * time.Millisecond},
+		{8, 4, 100 * time.Millisecond, 200 * time.Millisecond},
+	}
+	for _, test := range tests {
+		delay := QueueDelay(test.depth, test.workers, test.latency)
+		if delay != test.delay {
+			t.Fatalf("unexpected delay: %v, expect: %v", delay, test.delay)
+		}
+	}
+}
+
+func TestRateDelay(t *testing.T) {
+	tests := []struct {
+		tokens float64
+		rate   float64
+		delay  time.Duration
+	}{
+		{1, 10, 0},
+		{0, 10, 100 * time.Millisecond},
+		{0.5, 0.1, 5 * time.Second},
+		{0, 0, MaxRetryAfter},
+	}
+	for _, test := range tests {
+		delay := RateDelay(test.tokens, test.rate)
+		if delay != test.delay {
+			t.Fatalf("unexpected delay: %v, expect: %v", delay, test.delay)
+		}
+	}
+}
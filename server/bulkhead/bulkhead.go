@@ -0,0 +1,153 @@
+/*
+Package bulkhead provides a filter which limits the number of concurrent
+requests of each route group so that one overloaded group does not exhaust
+resources of the others.
+
+Requests exceeding the limit wait in a small queue. When the queue is full or
+the waiting time is over, requests are rejected with status 503 and header
+Retry-After. For each group, the filter records gauges Bulkhead.<name>.InFlight,
+Bulkhead.<name>.Queued and Bulkhead.<name>.Saturation, which is the percentage
+of in-flight requests to the limit, and counter Bulkhead.<name>.Rejected.
+*/
+package bulkhead
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/codahale/metrics"
+	"github.com/goburrow/melon/server/backpressure"
+	"github.com/goburrow/melon/server/filter"
+)
+
+// group limits concurrent requests matching pattern.
+type group struct {
+	pattern string
+	slots   chan struct{}
+	// maxQueue is the maximum number of waiting requests.
+	maxQueue int32
+	maxWait  time.Duration
+
+	queued int32
+	// latency is the moving average of latency in nanoseconds.
+	latency int64
+
+	rejected metrics.Counter
+}
+
+// acquire returns false if the request is rejected.
+func (g *group) acquire(r *http.Request) bool {
+	select {
+	case g.slots <- struct{}{}:
+		return true
+	default:
+	}
+	if atomic.AddInt32(&g.queued, 1) > g.maxQueue {
+		atomic.AddInt32(&g.queued, -1)
+		return false
+	}
+	defer atomic.AddInt32(&g.queued, -1)
+	if g.maxWait <= 0 {
+		return false
+	}
+	timer := time.NewTimer(g.maxWait)
+	defer timer.Stop()
+	select {
+	case g.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+func (g *group) release(latency time.Duration) {
+	<-g.slots
+	// Exponentially weighted moving average with alpha 1/8.
+	for {
+		old := atomic.LoadInt64(&g.latency)
+		v := int64(latency)
+		if old > 0 {
+			v = old + (int64(latency)-old)/8
+		}
+		if atomic.CompareAndSwapInt64(&g.latency, old, v) {
+			return
+		}
+	}
+}
+
+// retryAfter estimates when a slot is available for new requests.
+func (g *group) retryAfter() time.Duration {
+	depth := int(atomic.LoadInt32(&g.queued)) + 1
+	return backpressure.QueueDelay(depth, cap(g.slots), time.Duration(atomic.LoadInt64(&g.latency)))
+}
+
+// bulkheadFilter limits concurrent requests of the first matching group.
+type bulkheadFilter struct {
+	groups []*group
+}
+
+// Option adds option for Filter.
+type Option func(f *bulkheadFilter)
+
+// NewFilter returns a Filter which limits concurrent requests per group.
+func NewFilter(options ...Option) filter.Filter {
+	f := &bulkheadFilter{}
+	for _, opt := range options {
+		opt(f)
+	}
+	return f
+}
+
+// WithGroup adds group name for requests matching pattern. At most
+// maxConcurrent requests are served at once and at most maxQueue requests
+// wait up to maxWait for being served. See filter.MatchPath for pattern syntax.
+func WithGroup(name, pattern string, maxConcurrent, maxQueue int, maxWait time.Duration) Option {
+	if maxConcurrent < 1 {
+		maxConcurrent = 1
+	}
+	g := &group{
+		pattern:  pattern,
+		slots:    make(chan struct{}, maxConcurrent),
+		maxQueue: int32(maxQueue),
+		maxWait:  maxWait,
+		rejected: metrics.Counter("Bulkhead." + name + ".Rejected"),
+	}
+	metrics.Gauge("Bulkhead." + name + ".InFlight").SetFunc(func() int64 {
+		return int64(len(g.slots))
+	})
+	metrics.Gauge("Bulkhead." + name + ".Queued").SetFunc(func() int64 {
+		return int64(atomic.LoadInt32(&g.queued))
+	})
+	metrics.Gauge("Bulkhead." + name + ".Saturation").SetFunc(func() int64 {
+		return int64(len(g.slots) * 100 / cap(g.slots))
+	})
+	return func(f *bulkheadFilter) {
+		f.groups = append(f.groups, g)
+	}
+}
+
+func (f *bulkheadFilter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	for _, g := range f.groups {
+		if filter.MatchPath(g.pattern, r.URL.Path) {
+			f.serve(g, w, r)
+			return
+		}
+	}
+	filter.Continue(w, r)
+}
+
+func (f *bulkheadFilter) serve(g *group, w http.ResponseWriter, r *http.Request) {
+	if !g.acquire(r) {
+		g.rejected.Add()
+		backpressure.Overloaded(w, r, g.retryAfter())
+		return
+	}
+	start := time.Now()
+	defer func() {
+		g.release(time.Since(start))
+	}()
+	filter.Continue(w, r)
+}
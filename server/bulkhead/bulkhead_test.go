@@ -0,0 +1,82 @@
+package bulkhead
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/goburrow/melon/server/filter"
+)
+
+func TestFilter(t *testing.T) {
+	block := make(chan struct{})
+	started := make(chan struct{}, 10)
+	chain := filter.NewChain()
+	chain.Add(NewFilter(WithGroup("test", "/slow", 1, 1, time.Second)))
+	chain.Add(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			started <- struct{}{}
+			<-block
+		}
+	}))
+
+	codes := make([]int, 2)
+	var wg sync.WaitGroup
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			chain.ServeHTTP(w, httptest.NewRequest("GET", "/slow", nil))
+			codes[i] = w.Code
+		}(i)
+		if i == 0 {
+			<-started
+		}
+	}
+	// Wait for the second request queued.
+	g := chain.Get(0).(*bulkheadFilter).groups[0]
+	for i := 0; i < 100 && len(g.slots)+int(atomic.LoadInt32(&g.queued)) < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	// Queue is full.
+	w := httptest.NewRecorder()
+	chain.ServeHTTP(w, httptest.NewRequest("GET", "/slow", nil))
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Header())
+	}
+	// Other groups are not affected.
+	w = httptest.NewRecorder()
+	chain.ServeHTTP(w, httptest.NewRequest("GET", "/fast", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected response: %v", w.Code)
+	}
+	close(block)
+	wg.Wait()
+	if codes[0] != http.StatusOK || codes[1] != http.StatusOK {
+		t.Fatalf("unexpected responses: %v", codes)
+	}
+}
+
+func TestMaxWait(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	chain := filter.NewChain()
+	chain.Add(NewFilter(WithGroup("wait", "/*", 1, 1, 10*time.Millisecond)))
+	chain.Add(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-block
+	}))
+	go chain.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	g := chain.Get(0).(*bulkheadFilter).groups[0]
+	for i := 0; i < 100 && len(g.slots) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	w := httptest.NewRecorder()
+	chain.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("unexpected response: %v", w.Code)
+	}
+}
@@ -0,0 +1,340 @@
+/*
+Package cache provides a filter which caches successful responses of GET
+requests in a pluggable Store for a configured time to live.
+
+Responses are keyed by request path, query and header Accept, which determines
+the negotiated media type. HEAD requests are answered from cached GET responses.
+Requests with header Authorization or Cookie and responses with Cache-Control
+no-store or private, with header Set-Cookie or varying by request headers
+other than Accept are never cached. The filter records counters
+Cache.Hits and Cache.Misses.
+
+Cached responses are invalidated by admin task "cache":
+
+	POST /tasks/cache
+	POST /tasks/cache?path=/users/*
+*/
+package cache
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codahale/metrics"
+	"github.com/goburrow/melon/core"
+	"github.com/goburrow/melon/server/filter"
+)
+
+const taskName = "cache"
+
+// Entry is a cached response.
+type Entry struct {
+	Status  int
+	Header  http.Header
+	Body    []byte
+	Created time.Time
+	Expires time.Time
+}
+
+// Store keeps cached responses. It must be safe for concurrent use.
+type Store interface {
+	// Get returns the unexpired entry of key or nil.
+	Get(key string) *Entry
+	// Set stores entry of key.
+	Set(key string, entry *Entry)
+	// Remove deletes all entries which keys match and returns number
+	// of deleted entries.
+	Remove(match func(key string) bool) int
+}
+
+// memoryStore is a Store in memory.
+type memoryStore struct {
+	mu      sync.Mutex
+	entries map[string]*Entry
+	// maxEntries is the maximum number of entries or unlimited if not positive.
+	maxEntries int
+}
+
+// NewMemoryStore returns a Store keeping at most maxEntries responses in
+// memory. Number of entries is unlimited if maxEntries is not positive.
+func NewMemoryStore(maxEntries int) Store {
+	return &memoryStore{
+		entries:    make(map[string]*Entry),
+		maxEntries: maxEntries,
+	}
+}
+
+func (s *memoryStore) Get(key string) *Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.entries[key]
+	if e == nil {
+		return nil
+	}
+	if !time.Now().Before(e.Expires) {
+		delete(s.entries, key)
+		return nil
+	}
+	return e
+}
+
+func (s *memoryStore) Set(key string, entry *Entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[key]; !ok && s.maxEntries > 0 && len(s.entries) >= s.maxEntries {
+		s.evict()
+	}
+	s.entries[key] = entry
+}
+
+// evict removes expired entries or the one which expires soonest if there
+// is none.
+func (s *memoryStore) evict() {
+	now := time.Now()
+	var oldest string
+	var oldestExpires time.Time
+	for k, e := range s.entries {
+		if !now.Before(e.Expires) {
+			delete(s.entries, k)
+			continue
+		}
+		if oldest == "" || e.Expires.Before(oldestExpires) {
+			oldest, oldestExpires = k, e.Expires
+		}
+	}
+	if len(s.entries) >= s.maxEntries && oldest != "" {
+		delete(s.entries, oldest)
+	}
+}
+
+func (s *memoryStore) Remove(match func(key string) bool) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for k := range s.entries {
+		if match(k) {
+			delete(s.entries, k)
+			n++
+		}
+	}
+	return n
+}
+
+// Key returns cache key of the request.
+func Key(r *http.Request) string {
+	// Query is encoded with sorted keys.
+	return r.URL.Path + "?" + r.URL.Query().Encode() + "\n" + r.Header.Get("Accept")
+}
+
+// pathOf returns request path of the key.
+func pathOf(key string) string {
+	if i := strings.LastIndexByte(key, '\n'); i >= 0 {
+		key = key[:i]
+	}
+	if i := strings.LastIndexByte(key, '?'); i >= 0 {
+		return key[:i]
+	}
+	return key
+}
+
+// rule is time to live of responses of requests matching pattern.
+type rule struct {
+	pattern string
+	ttl     time.Duration
+}
+
+// cacheFilter serves cached responses.
+type cacheFilter struct {
+	store Store
+	rules []rule
+
+	hits   metrics.Counter
+	misses metrics.Counter
+}
+
+// Option adds option for Filter.
+type Option func(f *cacheFilter)
+
+// NewFilter returns a Filter which caches responses in store. Only requests
+// matching paths given by WithTTL are cached. It has priority
+// filter.PriorityCache.
+func NewFilter(store Store, options ...Option) filter.Filter {
+	f := &cacheFilter{
+		store:  store,
+		hits:   metrics.Counter("Cache.Hits"),
+		misses: metrics.Counter("Cache.Misses"),
+	}
+	for _, opt := range options {
+		opt(f)
+	}
+	return f
+}
+
+// WithTTL caches responses of requests matching pattern for duration ttl.
+// The first matching pattern is used. See filter.MatchPath for pattern syntax.
+func WithTTL(pattern string, ttl time.Duration) Option {
+	return func(f *cacheFilter) {
+		f.rules = append(f.rules, rule{pattern: pattern, ttl: ttl})
+	}
+}
+
+// Priority returns filter.PriorityCache.
+func (f *cacheFilter) Priority() int {
+	return filter.PriorityCache
+}
+
+func (f *cacheFilter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if (r.Method != "GET" && r.Method != "HEAD") || r.Header.Get("Authorization") != "" || r.Header.Get("Cookie") != "" {
+		filter.Continue(w, r)
+		return
+	}
+	ttl := f.ttl(r.URL.Path)
+	if ttl <= 0 {
+		filter.Continue(w, r)
+		return
+	}
+	key := Key(r)
+	if e := f.store.Get(key); e != nil {
+		f.hits.Add()
+		serveEntry(w, r, e)
+		return
+	}
+	f.misses.Add()
+	if r.Method != "GET" {
+		filter.Continue(w, r)
+		return
+	}
+	cw := &cacheWriter{ResponseWriter: w}
+	filter.Continue(cw, r)
+	if cw.cacheable() {
+		now := time.Now()
+		f.store.Set(key, &Entry{
+			Status:  cw.status,
+			Header:  cw.header,
+			Body:    cw.buf.Bytes(),
+			Created: now,
+			Expires: now.Add(ttl),
+		})
+	}
+}
+
+func (f *cacheFilter) ttl(path string) time.Duration {
+	for _, r := range f.rules {
+		if filter.MatchPath(r.pattern, path) {
+			return r.ttl
+		}
+	}
+	return 0
+}
+
+func serveEntry(w http.ResponseWriter, r *http.Request, e *Entry) {
+	header := w.Header()
+	for k, v := range e.Header {
+		header[k] = v
+	}
+	header.Set("Age", strconv.Itoa(int(time.Since(e.Created)/time.Second)))
+	w.WriteHeader(e.Status)
+	if r.Method != "HEAD" {
+		w.Write(e.Body)
+	}
+}
+
+// cacheWriter writes response through and keeps a copy of it.
+type cacheWriter struct {
+	http.ResponseWriter
+	status int
+	header http.Header
+	buf    bytes.Buffer
+	// streaming is set when handler flushes the response, streaming
+	// responses are not cached.
+	streaming bool
+}
+
+func (w *cacheWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+		w.header = cloneHeader(w.ResponseWriter.Header())
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *cacheWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.streaming {
+		w.buf.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *cacheWriter) Flush() {
+	w.streaming = true
+	if fl, ok := w.ResponseWriter.(http.Flusher); ok {
+		fl.Flush()
+	}
+}
+
+func (w *cacheWriter) cacheable() bool {
+	if w.streaming || w.status != http.StatusOK {
+		return false
+	}
+	if w.header.Get("Set-Cookie") != "" {
+		return false
+	}
+	cc := strings.ToLower(w.header.Get("Cache-Control"))
+	if strings.Contains(cc, "no-store") || strings.Contains(cc, "private") {
+		return false
+	}
+	// Accept is the only request header in the key.
+	for _, v := range w.header["Vary"] {
+		for _, name := range strings.Split(v, ",") {
+			name = strings.TrimSpace(name)
+			if name != "" && !strings.EqualFold(name, "Accept") {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func cloneHeader(h http.Header) http.Header {
+	c := make(http.Header, len(h))
+	for k, v := range h {
+		c[k] = append([]string(nil), v...)
+	}
+	return c
+}
+
+// cacheTask invalidates cached responses.
+type cacheTask struct {
+	store Store
+}
+
+// NewTask returns admin task "cache" which removes cached responses of
+// requests matching query parameter path or all responses.
+func NewTask(store Store) core.Task {
+	return &cacheTask{store: store}
+}
+
+func (*cacheTask) Name() string {
+	return taskName
+}
+
+func (t *cacheTask) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	pattern := r.URL.Query().Get("path")
+	n := t.store.Remove(func(key string) bool {
+		return pattern == "" || filter.MatchPath(pattern, pathOf(key))
+	})
+	fmt.Fprintf(w, "cache: %d removed\n", n)
+}
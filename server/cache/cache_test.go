@@ -0,0 +1,132 @@
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/goburrow/melon/server/filter"
+)
+
+func TestFilter(t *testing.T) {
+	store := NewMemoryStore(0)
+	count := 0
+	chain := filter.NewChain()
+	chain.Add(NewFilter(store, WithTTL("/nocache", 0), WithTTL("/*", time.Minute)))
+	chain.Add(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		switch r.URL.Path {
+		case "/private":
+			w.Header().Set("Cache-Control", "private")
+		case "/vary":
+			w.Header().Set("Vary", "Accept, Accept-Language")
+		case "/accept":
+			w.Header().Set("Vary", "Accept")
+		case "/error":
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		w.Header().Set("Content-Type", r.Header.Get("Accept"))
+		w.Write([]byte(strconv.Itoa(count)))
+	}))
+
+	tests := []struct {
+		method string
+		url    string
+		accept string
+		body   string
+		hit    bool
+	}{
+		{"GET", "/a?x=1&y=2", "application/json", "1", false},
+		{"GET", "/a?y=2&x=1", "application/json", "1", true},
+		{"HEAD", "/a?x=1&y=2", "application/json", "", true},
+		{"GET", "/a?x=1&y=2", "application/xml", "2", false},
+		{"GET", "/a?x=2", "application/json", "3", false},
+		{"POST", "/a?x=1&y=2", "application/json", "4", false},
+		{"GET", "/nocache", "", "5", false},
+		{"GET", "/nocache", "", "6", false},
+		{"GET", "/private", "", "7", false},
+		{"GET", "/private", "", "8", false},
+		{"GET", "/error", "", "9", false},
+		{"GET", "/error", "", "10", false},
+		{"GET", "/vary", "", "11", false},
+		{"GET", "/vary", "", "12", false},
+		{"GET", "/accept", "text/plain", "13", false},
+		{"GET", "/accept", "text/plain", "13", true},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(test.method, test.url, nil)
+		r.Header.Set("Accept", test.accept)
+		chain.ServeHTTP(w, r)
+		if w.Body.String() != test.body || (w.Header().Get("Age") != "") != test.hit {
+			t.Fatalf("unexpected response of %+v: %v %v", test, w.Header(), w.Body.String())
+		}
+		if test.hit && w.Header().Get("Content-Type") != test.accept {
+			t.Fatalf("unexpected content type of %+v: %v", test, w.Header())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/a?x=1&y=2", nil)
+	r.Header.Set("Accept", "application/json")
+	r.Header.Set("Authorization", "Basic dXNlcjpwYXNz")
+	chain.ServeHTTP(w, r)
+	if w.Body.String() != "14" {
+		t.Fatalf("unexpected response: %v", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/a?x=1&y=2", nil)
+	r.Header.Set("Accept", "application/json")
+	r.Header.Set("Cookie", "session=1")
+	chain.ServeHTTP(w, r)
+	if w.Body.String() != "15" {
+		t.Fatalf("unexpected response: %v", w.Body.String())
+	}
+}
+
+func TestTask(t *testing.T) {
+	store := NewMemoryStore(0)
+	expires := time.Now().Add(time.Minute)
+	for _, r := range []*http.Request{
+		httptest.NewRequest("GET", "/users/1", nil),
+		httptest.NewRequest("GET", "/users/2?q=1", nil),
+		httptest.NewRequest("GET", "/groups", nil),
+	} {
+		store.Set(Key(r), &Entry{Status: http.StatusOK, Expires: expires})
+	}
+	task := NewTask(store)
+
+	w := httptest.NewRecorder()
+	task.ServeHTTP(w, httptest.NewRequest("GET", "/tasks/cache", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	task.ServeHTTP(w, httptest.NewRequest("POST", "/tasks/cache?path=/users/*", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "cache: 2 removed\n" {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	task.ServeHTTP(w, httptest.NewRequest("POST", "/tasks/cache", nil))
+	if w.Body.String() != "cache: 1 removed\n" {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Body.String())
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore(2)
+	now := time.Now()
+	store.Set("a", &Entry{Expires: now.Add(time.Minute)})
+	store.Set("b", &Entry{Expires: now.Add(-time.Second)})
+	if store.Get("b") != nil {
+		t.Fatal("expired entry must not be returned")
+	}
+	store.Set("b", &Entry{Expires: now.Add(2 * time.Minute)})
+	store.Set("c", &Entry{Expires: now.Add(3 * time.Minute)})
+	if store.Get("a") != nil || store.Get("b") == nil || store.Get("c") == nil {
+		t.Fatal("entry expiring soonest must be evicted")
+	}
+}
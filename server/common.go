@@ -1,52 +1,471 @@
 package server
 
 import (
+	"crypto/subtle"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/goburrow/gol/file/rotation"
+	"github.com/goburrow/melon/auth"
+	"github.com/goburrow/melon/configuration"
 	"github.com/goburrow/melon/core"
+	"github.com/goburrow/melon/cors"
 	"github.com/goburrow/melon/logging"
+	"github.com/goburrow/melon/server/audit"
+	"github.com/goburrow/melon/server/bulkhead"
+	"github.com/goburrow/melon/server/cache"
+	"github.com/goburrow/melon/server/debuglog"
+	"github.com/goburrow/melon/server/etag"
 	"github.com/goburrow/melon/server/filter"
 	"github.com/goburrow/melon/server/gzip"
+	"github.com/goburrow/melon/server/header"
+	"github.com/goburrow/melon/server/maintenance"
 	slogging "github.com/goburrow/melon/server/logging"
+	"github.com/goburrow/melon/server/problem"
+	"github.com/goburrow/melon/server/ratelimit"
+	"github.com/goburrow/melon/server/realip"
 	"github.com/goburrow/melon/server/recovery"
 	"github.com/goburrow/melon/server/router"
+	"github.com/goburrow/melon/server/slo"
+	"github.com/goburrow/melon/server/slowlog"
+	"github.com/goburrow/melon/server/timeout"
 )
 
 // commonFactory is the shared configuration of DefaultFactory and
 // SimpleFactory.
 type commonFactory struct {
-	RequestLog RequestLogConfiguration
-	Gzip       GzipConfiguration
+	// TrustedProxies are addresses or networks of proxies which forwarding
+	// headers are used to resolve client addresses, e.g. 10.0.0.0/8.
+	TrustedProxies  []string
+	RequestLog      RequestLogConfiguration
+	SlowRequests    SlowRequestConfiguration
+	Gzip            GzipConfiguration
+	ResponseHeaders []ResponseHeaderConfiguration
+	CacheControl    []CacheControlConfiguration
+	CORS            CORSConfiguration
+	SLOs            []SLOConfiguration
+	RateLimit       RateLimitConfiguration
+	Timeouts        []TimeoutConfiguration
+	ETag            ETagConfiguration
+	PayloadLog      PayloadLogConfiguration
+	Maintenance     MaintenanceConfiguration
+	Bulkheads       []BulkheadConfiguration
+	ResponseCache   ResponseCacheConfiguration
+	AdminAuth       AdminAuthConfiguration
+	// AdminCORS allows cross-origin requests from AllowedOrigins to admin
+	// endpoints matching Paths, e.g. /healthcheck and /metrics, and rejects
+	// cross-origin requests to other admin endpoints with status 403.
+	AdminCORS CORSConfiguration
+	// StartupHealthCheck runs health checks before the server starts and
+	// aborts startup if critical checks fail.
+	StartupHealthCheck bool
+	// DrainPeriod is the time between marking the application not ready and
+	// stopping the server when it is shutting down, e.g. "10s". It should be
+	// longer than the interval of readiness probes of load balancers.
+	DrainPeriod configuration.Duration `valid:"min=0"`
+	// ShutdownTask enables admin task "shutdown" which stops the server
+	// gracefully. It requires AdminAuth to be configured.
+	ShutdownTask bool
+	Ping         PingConfiguration
+
+	// requestLogFilter and rateLimitFilter are replaced when configuration
+	// is reloaded.
+	requestLogFilter *reloadableFilter
+	rateLimitFilter  *reloadableFilter
 }
 
 // AddFilters adds request log and panic recovery to the filter chain
 // of the given handlers.
 func (f *commonFactory) AddFilters(env *core.Environment, handlers ...*router.Router) error {
+	// Client address is resolved before all other filters.
+	if len(f.TrustedProxies) > 0 {
+		networks, err := realip.ParseNetworks(f.TrustedProxies...)
+		if err != nil {
+			return err
+		}
+		realIPFilter := realip.NewFilter(realip.WithTrusted(networks...))
+		for _, h := range handlers {
+			h.AddFilter(realIPFilter)
+		}
+	}
 	// Request log must be first as handler panic should be recorded.
-	requestLogFilter, err := f.RequestLog.Build(env)
+	// It is always added so that it can be enabled when reloading.
+	requestLogFilter, closers, err := f.RequestLog.build()
 	if err != nil {
 		return err
 	}
-	if requestLogFilter != nil {
+	f.requestLogFilter = newReloadableFilter(requestLogFilter, closers)
+	for _, h := range handlers {
+		h.AddFilter(filter.WithPriority(f.requestLogFilter, filter.PriorityRequestLog))
+	}
+	// Slow requests
+	if f.SlowRequests.Threshold > 0 {
+		var options []slowlog.Option
+		if f.SlowRequests.Headers {
+			options = append(options, slowlog.WithHeaders())
+		}
+		slowFilter := filter.WithPriority(slowlog.NewFilter(time.Duration(f.SlowRequests.Threshold), options...),
+			filter.PriorityMonitoring)
+		for _, h := range handlers {
+			h.AddFilter(slowFilter)
+		}
+	}
+	// Service level objectives must also count panics.
+	if len(f.SLOs) > 0 {
+		options := make([]slo.Option, len(f.SLOs))
+		for i := range f.SLOs {
+			o := &f.SLOs[i]
+			if o.Target <= 0 || o.Target >= 1 {
+				return fmt.Errorf("server: slo target must be between 0 and 1: %v", o.Target)
+			}
+			options[i] = slo.WithObjective(o.Name, o.Path, time.Duration(o.Latency), o.Target)
+		}
+		sloFilter := filter.WithPriority(slo.NewFilter(options...), filter.PriorityMonitoring)
 		for _, h := range handlers {
-			h.AddFilter(requestLogFilter)
+			h.AddFilter(sloFilter)
 		}
 	}
-	// Recover
-	recoveryFilter := recovery.NewFilter()
+	// Recover panics and render error by the error mapper registered to
+	// the server environment.
+	errorMapper := &errorMapperHandler{}
+	env.Server.AddResourceHandler(errorMapper)
+	recoveryFilter := filter.WithPriority(recovery.NewFilter(recovery.WithErrorMapper(errorMapper)),
+		filter.PriorityRecovery)
 	for _, h := range handlers {
 		h.AddFilter(recoveryFilter)
 	}
+	// Timeouts must be inside recovery as panics are propagated.
+	if len(f.Timeouts) > 0 {
+		options := make([]timeout.Option, len(f.Timeouts))
+		for i := range f.Timeouts {
+			options[i] = timeout.WithTimeout(f.Timeouts[i].Path, time.Duration(f.Timeouts[i].Timeout))
+		}
+		timeoutFilter := filter.WithPriority(timeout.NewFilter(options...), filter.PriorityTimeout)
+		for _, h := range handlers {
+			h.AddFilter(timeoutFilter)
+		}
+	}
+	// Response headers and caching policies
+	if len(f.ResponseHeaders) > 0 || len(f.CacheControl) > 0 {
+		options := make([]header.Option, 0, len(f.ResponseHeaders)+len(f.CacheControl))
+		for i := range f.ResponseHeaders {
+			options = append(options, header.WithHeaders(f.ResponseHeaders[i].Path, f.ResponseHeaders[i].Headers))
+		}
+		for i := range f.CacheControl {
+			c := &f.CacheControl[i]
+			options = append(options, header.WithCacheControl(c.Path, c.CacheControl, time.Duration(c.Expires)*time.Second))
+		}
+		headerFilter := filter.WithPriority(header.NewFilter(options...), filter.PriorityHeader)
+		for _, h := range handlers {
+			h.AddFilter(headerFilter)
+		}
+	}
 	// Gzip
 	if f.Gzip.Enabled {
-		gzipFilter := gzip.NewFilter()
+		gzipFilter := filter.WithPriority(gzip.NewFilter(), filter.PriorityCompression)
+		if len(f.Gzip.Paths) > 0 {
+			gzipFilter = filter.ForPaths(gzipFilter, f.Gzip.Paths...)
+		}
 		for _, h := range handlers {
 			h.AddFilter(gzipFilter)
 		}
 	}
+	// ETag is computed from uncompressed responses so it is added after gzip
+	// having the same priority.
+	if f.ETag.Enabled {
+		var options []etag.Option
+		if f.ETag.Weak {
+			options = append(options, etag.WithWeak())
+		}
+		for _, p := range f.ETag.Paths {
+			options = append(options, etag.WithPath(p))
+		}
+		etagFilter := filter.WithPriority(etag.NewFilter(options...), filter.PriorityCompression)
+		for _, h := range handlers {
+			h.AddFilter(etagFilter)
+		}
+	}
+	// Payloads are logged uncompressed.
+	if f.PayloadLog.Enabled {
+		options := []debuglog.Option{debuglog.WithRedactHeaders(f.PayloadLog.RedactHeaders...)}
+		for _, p := range f.PayloadLog.Paths {
+			options = append(options, debuglog.WithPath(p))
+		}
+		if f.PayloadLog.MaxBodySize > 0 {
+			options = append(options, debuglog.WithMaxBodySize(int(f.PayloadLog.MaxBodySize)))
+		}
+		payloadFilter := filter.WithPriority(debuglog.NewFilter(options...), filter.PriorityCompression)
+		for _, h := range handlers {
+			h.AddFilter(payloadFilter)
+		}
+	}
+	return nil
+}
+
+// AddMaintenanceFilter adds maintenance filter to the application handler and
+// registers admin task "maintenance" to switch it.
+func (f *commonFactory) AddMaintenanceFilter(env *core.Environment, appHandler *router.Router) error {
+	if f.Maintenance.RetryAfter < 0 {
+		return fmt.Errorf("server: invalid maintenance retry after %v", f.Maintenance.RetryAfter)
+	}
+	var options []maintenance.Option
+	if f.Maintenance.Message != "" {
+		options = append(options, maintenance.WithMessage(f.Maintenance.Message, f.Maintenance.ContentType))
+	}
+	if f.Maintenance.RetryAfter > 0 {
+		options = append(options, maintenance.WithRetryAfter(time.Duration(f.Maintenance.RetryAfter)))
+	}
+	mode := &maintenance.Mode{}
+	mode.SetEnabled(f.Maintenance.Enabled)
+	env.Admin.AddTask(maintenance.NewTask(mode))
+	// Rejected requests are still logged and monitored.
+	appHandler.AddFilter(filter.WithPriority(maintenance.NewFilter(mode, options...), filter.PriorityRecovery))
+	return nil
+}
+
+// AddRateLimitFilter adds rate limit filter to the application handler.
+func (f *commonFactory) AddRateLimitFilter(appHandler *router.Router) error {
+	rateLimitFilter, err := f.RateLimit.Build()
+	if err != nil {
+		return err
+	}
+	// It is always added so that it can be enabled when reloading.
+	f.rateLimitFilter = newReloadableFilter(rateLimitFilter, nil)
+	appHandler.AddFilter(filter.WithPriority(f.rateLimitFilter, filter.PriorityRateLimit))
+	return nil
+}
+
+// AddBulkheadFilter adds concurrent request limiter to the application handler.
+func (f *commonFactory) AddBulkheadFilter(appHandler *router.Router) error {
+	if len(f.Bulkheads) == 0 {
+		return nil
+	}
+	options := make([]bulkhead.Option, len(f.Bulkheads))
+	for i := range f.Bulkheads {
+		b := &f.Bulkheads[i]
+		if b.MaxConcurrent <= 0 {
+			return fmt.Errorf("server: bulkhead max concurrent must be positive: %v", b.MaxConcurrent)
+		}
+		options[i] = bulkhead.WithGroup(b.Name, b.Path, b.MaxConcurrent, b.MaxQueue, time.Duration(b.MaxWait))
+	}
+	appHandler.AddFilter(filter.WithPriority(bulkhead.NewFilter(options...), filter.PriorityRateLimit))
+	return nil
+}
+
+// AddCacheFilter adds response cache filter to the application handler and
+// registers admin task "cache" to invalidate cached responses.
+func (f *commonFactory) AddCacheFilter(env *core.Environment, appHandler *router.Router) error {
+	if len(f.ResponseCache.Paths) == 0 {
+		return nil
+	}
+	if f.ResponseCache.MaxEntries < 0 {
+		return fmt.Errorf("server: invalid response cache max entries %v", f.ResponseCache.MaxEntries)
+	}
+	options := make([]cache.Option, len(f.ResponseCache.Paths))
+	for i := range f.ResponseCache.Paths {
+		p := &f.ResponseCache.Paths[i]
+		if p.TTL <= 0 {
+			return fmt.Errorf("server: response cache ttl must be positive: %v", p.TTL)
+		}
+		options[i] = cache.WithTTL(p.Path, time.Duration(p.TTL))
+	}
+	store := cache.NewMemoryStore(f.ResponseCache.MaxEntries)
+	env.Admin.AddTask(cache.NewTask(store))
+	appHandler.AddFilter(cache.NewFilter(store, options...))
+	return nil
+}
+
+// AddAdminAuthFilter adds authentication filter to the admin handler if
+// admin users or token are configured.
+func (f *commonFactory) AddAdminAuthFilter(adminHandler *router.Router) error {
+	authFilter, err := f.AdminAuth.Build()
+	if err != nil {
+		return err
+	}
+	if authFilter != nil {
+		adminHandler.AddFilter(authFilter)
+	}
+	return nil
+}
+
+// AddAuditFilter records invocations of admin tasks, including those rejected
+// by admin authentication.
+func (f *commonFactory) AddAuditFilter(adminHandler *router.Router) {
+	adminHandler.AddFilter(filter.ForPaths(audit.NewFilter(), "/tasks/*"))
+}
+
+// AddShutdownTask registers admin task "shutdown" if it is enabled.
+func (f *commonFactory) AddShutdownTask(env *core.Environment) error {
+	if !f.ShutdownTask {
+		return nil
+	}
+	if len(f.AdminAuth.Users) == 0 && f.AdminAuth.Token == "" && f.AdminAuth.TokenFile == "" {
+		return fmt.Errorf("server: shutdown task requires admin auth")
+	}
+	env.Admin.AddTask(core.NewShutdownTask(env.Admin))
+	return nil
+}
+
+// AddPingHandlers configures admin ping endpoint and adds it to the
+// application handler if enabled.
+func (f *commonFactory) AddPingHandlers(env *core.Environment, appHandler *router.Router) error {
+	if f.Ping.Path != "" && !strings.HasPrefix(f.Ping.Path, "/") {
+		return fmt.Errorf("server: ping path must start with /: %v", f.Ping.Path)
+	}
+	env.Admin.SetPing(f.Ping.Path, f.Ping.Response)
+	if f.Ping.Application {
+		h := core.NewPingHandler(f.Ping.Path, f.Ping.Response)
+		appHandler.Handle("*", h.Path(), h)
+	}
+	return nil
+}
+
+// AddCORSFilters adds CORS filter to the application and/or admin handlers
+// as configured.
+func (f *commonFactory) AddCORSFilters(appHandler, adminHandler *router.Router) error {
+	if err := f.addAdminCORSFilters(adminHandler); err != nil {
+		return err
+	}
+	if !f.CORS.Enabled {
+		return nil
+	}
+	corsFilter, err := f.CORS.Build()
+	if err != nil {
+		return err
+	}
+	corsFilter = filter.WithPriority(corsFilter, filter.PriorityCORS)
+	if len(f.CORS.Paths) > 0 {
+		corsFilter = filter.ForPaths(corsFilter, f.CORS.Paths...)
+	}
+	handlers := f.CORS.Handlers
+	if len(handlers) == 0 {
+		handlers = []string{"application"}
+	}
+	for _, name := range handlers {
+		switch name {
+		case "application":
+			appHandler.AddFilter(corsFilter)
+		case "admin":
+			adminHandler.AddFilter(corsFilter)
+		default:
+			return fmt.Errorf("server: unsupported cors handler %v", name)
+		}
+	}
+	return nil
+}
+
+// AdminAuthConfiguration protects all admin endpoints. Requests must provide
+// credentials of one of Users, which maps user names to passwords, using Basic
+// authentication or Token using Bearer authentication. Token is read from
+// TokenFile, e.g. a mounted secret, if it is not set. Admin endpoints are not
+// protected when neither users nor token is configured.
+type AdminAuthConfiguration struct {
+	Users     map[string]string `secret:"true"`
+	Token     string            `secret:"true"`
+	TokenFile string            `file:"true"`
+}
+
+// Build returns nil Filter if no users or token are set.
+func (f *AdminAuthConfiguration) Build() (filter.Filter, error) {
+	token := f.Token
+	if token == "" && f.TokenFile != "" {
+		b, err := ioutil.ReadFile(f.TokenFile)
+		if err != nil {
+			return nil, err
+		}
+		token = strings.TrimSpace(string(b))
+		if token == "" {
+			return nil, fmt.Errorf("server: empty admin token file %v", f.TokenFile)
+		}
+	}
+	var authenticators adminAuthenticator
+	var unauthorized http.Handler
+	if token != "" {
+		authenticators = append(authenticators, auth.NewBearerAuthenticator(func(t string) (auth.Principal, error) {
+			if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+				return auth.NewPrincipal("admin"), nil
+			}
+			return nil, nil
+		}))
+		unauthorized = auth.NewUnauthorizedHandler("Bearer", "Admin")
+	}
+	if len(f.Users) > 0 {
+		users := f.Users
+		authenticators = append(authenticators, auth.NewBasicAuthenticator(func(username, password string) (auth.Principal, error) {
+			expected, ok := users[username]
+			if ok && subtle.ConstantTimeCompare([]byte(password), []byte(expected)) == 1 {
+				return auth.NewPrincipal(username), nil
+			}
+			return nil, nil
+		}))
+		// Browsers prompt for Basic credentials.
+		unauthorized = auth.NewUnauthorizedHandler("Basic", "Admin")
+	}
+	if len(authenticators) == 0 {
+		return nil, nil
+	}
+	return auth.NewFilter(authenticators, auth.WithUnauthorizedHandler(unauthorized)), nil
+}
+
+// adminAuthenticator returns principal of the first authenticator accepting
+// the request.
+type adminAuthenticator []auth.Authenticator
+
+func (a adminAuthenticator) Authenticate(r *http.Request) (auth.Principal, error) {
+	for _, authenticator := range a {
+		p, err := authenticator.Authenticate(r)
+		if p != nil || err != nil {
+			return p, err
+		}
+	}
+	return nil, nil
+}
+
+// PingConfiguration changes Path and Response of the ping endpoint, which are
+// /ping and "pong\n" by default. If Application is true, it is also served by
+// the application handler for load balancers which can not reach admin
+// connectors.
+type PingConfiguration struct {
+	Path        string
+	Response    string
+	Application bool
+}
+
+// addAdminCORSFilters allows cross-origin requests to admin paths in AdminCORS
+// and rejects all others.
+func (f *commonFactory) addAdminCORSFilters(adminHandler *router.Router) error {
+	if !f.AdminCORS.Enabled {
+		return nil
+	}
+	if len(f.AdminCORS.Handlers) > 0 {
+		return fmt.Errorf("server: admin cors does not support handlers")
+	}
+	for _, name := range f.CORS.Handlers {
+		if name == "admin" && f.CORS.Enabled {
+			return fmt.Errorf("server: cors and admin cors must not be both enabled for admin handler")
+		}
+	}
+	corsFilter, err := f.AdminCORS.Build()
+	if err != nil {
+		return err
+	}
+	origins := f.AdminCORS.AllowedOrigins
+	if len(origins) == 0 {
+		return fmt.Errorf("server: admin cors requires allowed origins")
+	}
+	// Origins are checked before CORS headers are added.
+	adminHandler.AddFilter(filter.WithPriority(cors.NewOriginFilter(origins, f.AdminCORS.Paths...), filter.PriorityCORS))
+	corsFilter = filter.WithPriority(corsFilter, filter.PriorityCORS)
+	if len(f.AdminCORS.Paths) > 0 {
+		corsFilter = filter.ForPaths(corsFilter, f.AdminCORS.Paths...)
+	}
+	adminHandler.AddFilter(corsFilter)
 	return nil
 }
 
@@ -54,41 +473,132 @@ func (f *commonFactory) AddFilters(env *core.Environment, handlers ...*router.Ro
 // It utilized the configuration of logging appenders.
 type RequestLogConfiguration struct {
 	Appenders []logging.AppenderConfiguration
+	// ExcludePaths are path patterns of requests not to be logged,
+	// e.g. /healthcheck or /static/*.
+	ExcludePaths []string
+	// ExcludeStatus are status codes or classes of responses not to be
+	// logged, e.g. 304 or 2xx.
+	ExcludeStatus []string
 }
 
 // Build returns nil Filter if no appenders are set.
 func (f *RequestLogConfiguration) Build(_ *core.Environment) (filter.Filter, error) {
-	var writers []io.Writer
+	h, _, err := f.build()
+	return h, err
+}
+
+// build also returns files opened by the filter.
+func (f *RequestLogConfiguration) build() (filter.Filter, []io.Closer, error) {
+	var options []slogging.Option
+	var writer io.Writer
+	var closers []io.Closer
 
 	for _, appender := range f.Appenders {
+		var w io.Writer
+		var format string
+		var err error
 		switch appenderFactory := appender.Value().(type) {
 		case *logging.ConsoleAppenderFactory:
-			w, err := buildConsoleWriter(appenderFactory)
-			if err != nil {
-				return nil, err
-			}
-			writers = append(writers, w)
+			w, err = buildConsoleWriter(appenderFactory)
+			format = appenderFactory.Format
 		case *logging.FileAppenderFactory:
-			w, err := buildFileWriter(appenderFactory)
-			if err != nil {
-				return nil, err
-			}
-			writers = append(writers, w)
+			w, err = buildFileWriter(appenderFactory)
+			format = appenderFactory.Format
 		default:
-			return nil, fmt.Errorf("server: unsupported request log appender %#v", appender.Value())
+			closeAll(closers)
+			return nil, nil, fmt.Errorf("server: unsupported request log appender %#v", appender.Value())
+		}
+		if err != nil {
+			closeAll(closers)
+			return nil, nil, err
+		}
+		if c, ok := w.(io.Closer); ok && w != os.Stdout && w != os.Stderr {
+			closers = append(closers, c)
+		}
+		formatter, err := slogging.NewFormatter(format)
+		if err != nil {
+			closeAll(closers)
+			return nil, nil, err
+		}
+		if writer == nil {
+			writer = w
+			options = append(options, slogging.WithFormatter(formatter))
+		} else {
+			options = append(options, slogging.WithOutput(w, formatter))
 		}
 	}
-	if len(writers) == 0 {
+	if writer == nil {
 		// No request log
-		return nil, nil
+		return nil, nil, nil
 	}
-	var w io.Writer
-	if len(writers) > 1 {
-		w = io.MultiWriter(writers...)
-	} else {
-		w = writers[0]
+	for _, p := range f.ExcludePaths {
+		options = append(options, slogging.WithExcludePath(p))
 	}
-	return slogging.NewFilter(w), nil
+	for _, s := range f.ExcludeStatus {
+		min, max, err := parseStatusRange(s)
+		if err != nil {
+			closeAll(closers)
+			return nil, nil, err
+		}
+		options = append(options, slogging.WithExcludeStatus(min, max))
+	}
+	return slogging.NewFilter(writer, options...), closers, nil
+}
+
+// parseStatusRange parses a status code, e.g. 404, or a status class, e.g. 2xx.
+func parseStatusRange(s string) (int, int, error) {
+	if len(s) == 3 && strings.ToLower(s[1:]) == "xx" && s[0] >= '1' && s[0] <= '5' {
+		min := int(s[0]-'0') * 100
+		return min, min + 99, nil
+	}
+	status, err := strconv.Atoi(s)
+	if err != nil || status < 100 || status > 599 {
+		return 0, 0, fmt.Errorf("server: invalid request log status %v", s)
+	}
+	return status, status, nil
+}
+
+// SlowRequestConfiguration logs requests taking longer than Threshold, e.g.
+// "1s" or a number of milliseconds, at WARN level. Request and response headers
+// are included when Headers is true.
+type SlowRequestConfiguration struct {
+	Threshold configuration.Duration `valid:"min=0"`
+	Headers   bool
+}
+
+// BulkheadConfiguration limits concurrent requests matching Path to
+// MaxConcurrent. Excess requests wait up to MaxWait duration in a queue
+// of MaxQueue requests before being rejected. Requests are limited by the
+// first matching bulkhead.
+type BulkheadConfiguration struct {
+	Name          string `valid:"notempty"`
+	Path          string `valid:"notempty"`
+	MaxConcurrent int
+	MaxQueue      int                    `valid:"min=0"`
+	MaxWait       configuration.Duration `valid:"min=0"`
+}
+
+// MaintenanceConfiguration is the response of application requests in
+// maintenance mode, which is switched by admin task "maintenance".
+// The application starts in maintenance mode when Enabled is true.
+// RetryAfter is the expected downtime, e.g. "5m" or a number of milliseconds.
+type MaintenanceConfiguration struct {
+	Enabled     bool
+	Message     string
+	ContentType string
+	RetryAfter  configuration.Duration `valid:"min=0"`
+}
+
+// PayloadLogConfiguration logs request and response bodies of requests
+// matching Paths, or all requests if not set, at DEBUG level to logger
+// "melon/server/payload". It should only be enabled for troubleshooting.
+// MaxBodySize is the maximum logged size of each body, e.g. 4KiB, default is
+// 4096 bytes. Values of RedactHeaders and credential headers are not logged.
+type PayloadLogConfiguration struct {
+	Enabled       bool
+	Paths         []string
+	MaxBodySize   configuration.Size `valid:"min=0"`
+	RedactHeaders []string
 }
 
 func buildConsoleWriter(config *logging.ConsoleAppenderFactory) (io.Writer, error) {
@@ -125,11 +635,145 @@ func buildFileWriter(config *logging.FileAppenderFactory) (io.Writer, error) {
 }
 
 // GzipConfiguration indicates whether server should compress http response.
+// If Paths are set, only responses of requests matching them are compressed.
 type GzipConfiguration struct {
 	Enabled bool
+	Paths   []string
+}
+
+// ResponseHeaderConfiguration contains static headers which are set to
+// responses of requests matching Path. A Path ending with "*" matches all
+// requests having that prefix.
+type ResponseHeaderConfiguration struct {
+	Path    string `valid:"notempty"`
+	Headers map[string]string
+}
+
+// CacheControlConfiguration is the caching policy of responses of requests
+// matching Path. If Expires is positive, header Expires is set to the time
+// after Expires seconds. Later rules override earlier ones.
+type CacheControlConfiguration struct {
+	Path         string `valid:"notempty"`
+	CacheControl string
+	Expires      int `valid:"min=0"`
+}
+
+// CORSConfiguration is the configuration for Cross-Origin Resource Sharing.
+// Handlers are either "application" or "admin", default is application only.
+// If Paths are set, only requests matching them are handled.
+type CORSConfiguration struct {
+	Enabled          bool
+	Handlers         []string
+	Paths            []string
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	// MaxAge is in seconds.
+	MaxAge int `valid:"min=0"`
+}
+
+// Build returns a CORS filter.
+func (f *CORSConfiguration) Build() (filter.Filter, error) {
+	var options []cors.Option
+	if len(f.AllowedOrigins) > 0 {
+		options = append(options, cors.WithAllowedOrigins(f.AllowedOrigins...))
+	}
+	if len(f.AllowedMethods) > 0 {
+		options = append(options, cors.WithAllowedMethods(f.AllowedMethods...))
+	}
+	if len(f.AllowedHeaders) > 0 {
+		options = append(options, cors.WithAllowedHeaders(f.AllowedHeaders...))
+	}
+	if len(f.ExposedHeaders) > 0 {
+		options = append(options, cors.WithExposedHeaders(f.ExposedHeaders...))
+	}
+	if f.AllowCredentials {
+		options = append(options, cors.WithAllowCredentials())
+	}
+	if f.MaxAge < 0 {
+		return nil, fmt.Errorf("server: invalid cors max age %v", f.MaxAge)
+	}
+	if f.MaxAge > 0 {
+		options = append(options, cors.WithMaxAge(strconv.Itoa(f.MaxAge)))
+	}
+	return cors.NewFilter(options...), nil
+}
+
+// SLOConfiguration is a service level objective for requests matching Path.
+// A request is good when its response status is not 5xx and, if Latency is set,
+// it is served within Latency duration. Target is the expected ratio of
+// good requests, e.g. 0.999.
+type SLOConfiguration struct {
+	Name    string                 `valid:"notempty"`
+	Path    string                 `valid:"notempty"`
+	Latency configuration.Duration `valid:"min=0"`
+	Target  float64
+}
+
+// RateLimitConfiguration limits request rate of each client, which is
+// identified by remote IP address or value of KeyHeader.
+type RateLimitConfiguration struct {
+	Enabled bool
+	// Path is the pattern of limited requests, default is all requests.
+	Path string
+	// Rate is the number of requests per second.
+	Rate float64
+	// Burst is the maximum number of requests at once, default is 1.
+	Burst     int `valid:"min=0"`
+	KeyHeader string
+}
+
+// Build creates a rate limit filter or returns nil if it is not enabled.
+func (c *RateLimitConfiguration) Build() (filter.Filter, error) {
+	if !c.Enabled {
+		return nil, nil
+	}
+	if c.Rate <= 0 {
+		return nil, fmt.Errorf("server: rate limit must be positive: %v", c.Rate)
+	}
+	var options []ratelimit.Option
+	if c.Path != "" {
+		options = append(options, ratelimit.WithPath(c.Path))
+	}
+	if c.KeyHeader != "" {
+		options = append(options, ratelimit.WithKeyFunc(ratelimit.Header(c.KeyHeader)))
+	}
+	return ratelimit.NewFilter(c.Rate, c.Burst, options...), nil
+}
+
+// ETagConfiguration adds ETag to responses of GET requests matching Paths,
+// or all requests if Paths is empty, and handles conditional requests.
+// Weak ETags are recommended when Gzip is enabled.
+type ETagConfiguration struct {
+	Enabled bool
+	Weak    bool
+	Paths   []string
+}
+
+// ResponseCacheConfiguration caches responses of GET requests in memory.
+// MaxEntries limits number of cached responses, default is unlimited.
+type ResponseCacheConfiguration struct {
+	MaxEntries int `valid:"min=0"`
+	Paths      []ResponseCachePathConfiguration
+}
+
+// ResponseCachePathConfiguration caches responses of requests matching Path
+// for TTL duration, e.g. "30s" or a number of milliseconds.
+type ResponseCachePathConfiguration struct {
+	Path string                 `valid:"notempty"`
+	TTL  configuration.Duration `valid:"min=0"`
 }
 
-// resourceHandler allows user to register server filter.
+// TimeoutConfiguration limits handling time of requests matching Path
+// to Timeout duration, e.g. "30s" or a number of milliseconds.
+type TimeoutConfiguration struct {
+	Path    string                 `valid:"notempty"`
+	Timeout configuration.Duration `valid:"min=0"`
+}
+
+// resourceHandler allows user to register server filter and redirect.
 type resourceHandler struct {
 	router *router.Router
 }
@@ -146,4 +790,29 @@ func (h *resourceHandler) HandleResource(v interface{}) {
 	if r, ok := v.(filter.Filter); ok {
 		h.router.AddFilter(r)
 	}
+	if r, ok := v.(*router.Redirect); ok {
+		h.router.HandleRedirect(r.From, r.To, r.Status)
+	}
+}
+
+// errorMapperHandler allows user to register error mapper for recovered panics.
+// views.ErrorMapper is also applicable.
+type errorMapperHandler struct {
+	errorMapper recovery.ErrorMapper
+}
+
+var _ (core.ResourceHandler) = (*errorMapperHandler)(nil)
+
+func (h *errorMapperHandler) HandleResource(v interface{}) {
+	if r, ok := v.(recovery.ErrorMapper); ok {
+		h.errorMapper = r
+	}
+}
+
+func (h *errorMapperHandler) MapError(w http.ResponseWriter, r *http.Request, err error) {
+	if h.errorMapper != nil {
+		h.errorMapper.MapError(w, r, err)
+		return
+	}
+	problem.Error(w, r, http.StatusInternalServerError, "")
 }
@@ -1,8 +1,14 @@
 package server
 
 import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"testing"
+	"time"
 
+	"github.com/goburrow/melon/configuration"
 	"github.com/goburrow/melon/core"
 	"github.com/goburrow/melon/logging"
 	"github.com/goburrow/melon/server/router"
@@ -19,6 +25,51 @@ func TestCommonFactory(t *testing.T) {
 	}
 }
 
+func TestResponseHeaders(t *testing.T) {
+	env := core.NewEnvironment()
+	factory := commonFactory{
+		ResponseHeaders: []ResponseHeaderConfiguration{
+			{Path: "/*", Headers: map[string]string{"X-Service": "melon"}},
+		},
+	}
+	handler := router.New()
+	handler.Handle("GET", "/", http.NotFoundHandler())
+	err := factory.AddFilters(env, handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(w, r)
+	if w.Header().Get("X-Service") != "melon" {
+		t.Fatalf("unexpected headers: %v", w.Header())
+	}
+}
+
+func TestGzipPaths(t *testing.T) {
+	env := core.NewEnvironment()
+	factory := commonFactory{
+		Gzip: GzipConfiguration{Enabled: true, Paths: []string{"/reports/*"}},
+	}
+	handler := router.New()
+	handler.Handle("GET", "/{name:.*}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("melon"))
+	}))
+	err := factory.AddFilters(env, handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, encoding := range map[string]string{"/reports/1": "gzip", "/users/1": ""} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", path, nil)
+		r.Header.Set("Accept-Encoding", "gzip")
+		handler.ServeHTTP(w, r)
+		if w.Header().Get("Content-Encoding") != encoding {
+			t.Fatalf("unexpected headers of %v: %v", path, w.Header())
+		}
+	}
+}
+
 func TestRequestLogConfiguration(t *testing.T) {
 	appender := logging.AppenderConfiguration{}
 	appender.SetValue(&logging.ConsoleAppenderFactory{})
@@ -39,6 +90,41 @@ func TestRequestLogConfiguration(t *testing.T) {
 	}
 }
 
+func TestRequestLogFormat(t *testing.T) {
+	appender := logging.AppenderConfiguration{}
+	appender.SetValue(&logging.ConsoleAppenderFactory{Format: "unknown"})
+
+	config := RequestLogConfiguration{
+		Appenders: []logging.AppenderConfiguration{
+			appender,
+		},
+	}
+	_, err := config.Build(core.NewEnvironment())
+	if err == nil || err.Error() != "logging: unsupported format unknown" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestParseStatusRange(t *testing.T) {
+	tests := []struct {
+		s        string
+		min, max int
+	}{
+		{"2xx", 200, 299},
+		{"5XX", 500, 599},
+		{"304", 304, 304},
+		{"6xx", 0, 0},
+		{"99", 0, 0},
+		{"abc", 0, 0},
+	}
+	for _, test := range tests {
+		min, max, err := parseStatusRange(test.s)
+		if min != test.min || max != test.max || (err == nil) != (test.min > 0) {
+			t.Fatalf("unexpected range of %v: %v %v %v", test.s, min, max, err)
+		}
+	}
+}
+
 func TestNoRequestLogFactory(t *testing.T) {
 	env := core.NewEnvironment()
 	config := RequestLogConfiguration{}
@@ -50,3 +136,342 @@ func TestNoRequestLogFactory(t *testing.T) {
 		t.Fatalf("unexpected filter %#v", filter)
 	}
 }
+
+func TestResourceHandlerRedirect(t *testing.T) {
+	handler := router.New()
+	newResourceHandler(handler).HandleResource(router.NewPermanentRedirect("/users", "/user"))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/users", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/user" {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Header())
+	}
+}
+
+type testErrorMapper struct{}
+
+func (testErrorMapper) MapError(w http.ResponseWriter, r *http.Request, err error) {
+	http.Error(w, "mapped: "+err.Error(), http.StatusServiceUnavailable)
+}
+
+func TestRecoveryErrorMapper(t *testing.T) {
+	env := core.NewEnvironment()
+	handler := router.New()
+	env.Server.Router = handler
+	handler.Handle("GET", "/panic", http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("failure")
+	}))
+	env.Admin.Router = router.New()
+	factory := &commonFactory{}
+	if err := factory.AddFilters(env, handler); err != nil {
+		t.Fatal(err)
+	}
+	env.Server.Register(testErrorMapper{})
+	if err := env.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer env.Stop()
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/panic", nil))
+	if w.Code != http.StatusServiceUnavailable || w.Body.String() != "mapped: failure\n" {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Body.String())
+	}
+}
+
+func TestCORSConfiguration(t *testing.T) {
+	factory := commonFactory{
+		CORS: CORSConfiguration{
+			Enabled:        true,
+			Handlers:       []string{"admin"},
+			AllowedOrigins: []string{"http://localhost"},
+			MaxAge:         60,
+		},
+	}
+	appHandler := router.New()
+	adminHandler := router.New()
+	appHandler.Handle("GET", "/", http.NotFoundHandler())
+	adminHandler.Handle("GET", "/", http.NotFoundHandler())
+	err := factory.AddCORSFilters(appHandler, adminHandler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newRequest := func() *http.Request {
+		r := httptest.NewRequest("OPTIONS", "/", nil)
+		r.Header.Set("Origin", "http://localhost")
+		r.Header.Set("Access-Control-Request-Method", "GET")
+		return r
+	}
+	w := httptest.NewRecorder()
+	adminHandler.ServeHTTP(w, newRequest())
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "http://localhost" ||
+		w.Header().Get("Access-Control-Max-Age") != "60" {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Header())
+	}
+	w = httptest.NewRecorder()
+	appHandler.ServeHTTP(w, newRequest())
+	if w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Header())
+	}
+
+	factory.CORS.Handlers = []string{"unknown"}
+	if err = factory.AddCORSFilters(appHandler, adminHandler); err == nil {
+		t.Fatal("error expected")
+	}
+}
+
+func TestSLOConfiguration(t *testing.T) {
+	env := core.NewEnvironment()
+	factory := commonFactory{
+		SLOs: []SLOConfiguration{
+			{Name: "API", Path: "/api/*", Latency: configuration.Duration(100 * time.Millisecond), Target: 1},
+		},
+	}
+	err := factory.AddFilters(env, router.New())
+	if err == nil {
+		t.Fatal("error expected")
+	}
+	factory.SLOs[0].Target = 0.99
+	err = factory.AddFilters(env, router.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRateLimitConfiguration(t *testing.T) {
+	config := RateLimitConfiguration{}
+	f, err := config.Build()
+	if err != nil || f != nil {
+		t.Fatalf("unexpected filter: %#v %v", f, err)
+	}
+	config.Enabled = true
+	if _, err = config.Build(); err == nil {
+		t.Fatal("error expected")
+	}
+	config.Rate = 1
+	config.KeyHeader = "X-Api-Key"
+
+	factory := commonFactory{RateLimit: config}
+	handler := router.New()
+	handler.Handle("GET", "/", http.NotFoundHandler())
+	if err = factory.AddRateLimitFilter(handler); err != nil {
+		t.Fatal(err)
+	}
+	for i, status := range []int{http.StatusNotFound, http.StatusTooManyRequests} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("X-Api-Key", "key")
+		handler.ServeHTTP(w, r)
+		if w.Code != status {
+			t.Fatalf("unexpected status of request %d: %v", i, w.Code)
+		}
+	}
+}
+
+func TestTimeoutConfiguration(t *testing.T) {
+	env := core.NewEnvironment()
+	factory := commonFactory{
+		Timeouts: []TimeoutConfiguration{
+			{Path: "/slow", Timeout: configuration.Duration(10 * time.Millisecond)},
+		},
+	}
+	handler := router.New()
+	handler.Handle("GET", "/slow", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	if err := factory.AddFilters(env, handler); err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/slow", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("unexpected status: %v", w.Code)
+	}
+}
+
+func TestETagConfiguration(t *testing.T) {
+	env := core.NewEnvironment()
+	factory := commonFactory{
+		ETag: ETagConfiguration{Enabled: true, Paths: []string{"/static/*"}},
+	}
+	handler := router.New()
+	handler.Handle("GET", "/*", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("melon"))
+	}))
+	if err := factory.AddFilters(env, handler); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"/static/main.css", "/api"} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		hasETag := w.Header().Get("ETag") != ""
+		if hasETag != (path == "/static/main.css") {
+			t.Fatalf("unexpected headers of %s: %v", path, w.Header())
+		}
+	}
+}
+
+func TestCacheControlConfiguration(t *testing.T) {
+	env := core.NewEnvironment()
+	factory := commonFactory{
+		CacheControl: []CacheControlConfiguration{
+			{Path: "/*", CacheControl: "no-cache"},
+			{Path: "/static/*", CacheControl: "max-age=60", Expires: 60},
+		},
+	}
+	handler := router.New()
+	handler.Handle("GET", "/*", http.NotFoundHandler())
+	if err := factory.AddFilters(env, handler); err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/static/main.css", nil))
+	if w.Header().Get("Cache-Control") != "max-age=60" || w.Header().Get("Expires") == "" {
+		t.Fatalf("unexpected headers: %v", w.Header())
+	}
+}
+
+func TestAdminAuthConfiguration(t *testing.T) {
+	tokenFile, err := ioutil.TempFile("", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tokenFile.Name())
+	tokenFile.WriteString("secret-token\n")
+	tokenFile.Close()
+
+	factory := commonFactory{
+		AdminAuth: AdminAuthConfiguration{
+			Users:     map[string]string{"adm": "sec"},
+			TokenFile: tokenFile.Name(),
+		},
+	}
+	adminHandler := router.New()
+	adminHandler.Handle("GET", "/ping", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("pong"))
+	}))
+	if err = factory.AddAdminAuthFilter(adminHandler); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		user   string
+		pass   string
+		token  string
+		status int
+	}{
+		{"", "", "", http.StatusUnauthorized},
+		{"adm", "wrong", "", http.StatusUnauthorized},
+		{"adm", "sec", "", http.StatusOK},
+		{"", "", "wrong", http.StatusUnauthorized},
+		{"", "", "secret-token", http.StatusOK},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/ping", nil)
+		if test.user != "" {
+			r.SetBasicAuth(test.user, test.pass)
+		}
+		if test.token != "" {
+			r.Header.Set("Authorization", "Bearer "+test.token)
+		}
+		w := httptest.NewRecorder()
+		adminHandler.ServeHTTP(w, r)
+		if w.Code != test.status {
+			t.Fatalf("unexpected response of %+v: %v %v", test, w.Code, w.Header())
+		}
+	}
+
+	factory.AdminAuth = AdminAuthConfiguration{TokenFile: tokenFile.Name() + ".notfound"}
+	if err = factory.AddAdminAuthFilter(router.New()); err == nil {
+		t.Fatal("error expected")
+	}
+}
+
+func TestShutdownTask(t *testing.T) {
+	env := core.NewEnvironment()
+	factory := commonFactory{ShutdownTask: true}
+	if err := factory.AddShutdownTask(env); err == nil {
+		t.Fatal("error expected")
+	}
+	factory.AdminAuth.Token = "secret"
+	if err := factory.AddShutdownTask(env); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPingConfiguration(t *testing.T) {
+	env := core.NewEnvironment()
+	factory := commonFactory{
+		Ping: PingConfiguration{Path: "/status", Response: "OK", Application: true},
+	}
+	appHandler := router.New()
+	if err := factory.AddPingHandlers(env, appHandler); err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	appHandler.ServeHTTP(w, httptest.NewRequest("GET", "/status", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "OK" {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Body.String())
+	}
+	adminHandler := router.New()
+	env.Admin.Router = adminHandler
+	env.Server.Router = appHandler
+	env.Start()
+	w = httptest.NewRecorder()
+	adminHandler.ServeHTTP(w, httptest.NewRequest("GET", "/status", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "OK" {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Body.String())
+	}
+
+	factory.Ping.Path = "status"
+	if err := factory.AddPingHandlers(env, router.New()); err == nil {
+		t.Fatal("error expected")
+	}
+}
+
+func TestAdminCORSConfiguration(t *testing.T) {
+	factory := commonFactory{
+		AdminCORS: CORSConfiguration{
+			Enabled:        true,
+			Paths:          []string{"/healthcheck"},
+			AllowedOrigins: []string{"http://dashboard"},
+		},
+	}
+	appHandler := router.New()
+	adminHandler := router.New()
+	adminHandler.Handle("*", "/healthcheck", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	adminHandler.Handle("*", "/tasks/gc", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	if err := factory.AddCORSFilters(appHandler, adminHandler); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		method string
+		path   string
+		origin string
+		status int
+		allow  string
+	}{
+		{"GET", "/healthcheck", "http://dashboard", http.StatusOK, "http://dashboard"},
+		{"GET", "/healthcheck", "http://evil", http.StatusForbidden, ""},
+		{"POST", "/tasks/gc", "http://dashboard", http.StatusForbidden, ""},
+		{"POST", "/tasks/gc", "", http.StatusOK, ""},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(test.method, test.path, nil)
+		if test.origin != "" {
+			r.Header.Set("Origin", test.origin)
+		}
+		w := httptest.NewRecorder()
+		adminHandler.ServeHTTP(w, r)
+		if w.Code != test.status || w.Header().Get("Access-Control-Allow-Origin") != test.allow {
+			t.Fatalf("unexpected response of %+v: %v %v", test, w.Code, w.Header())
+		}
+	}
+
+	factory.CORS = CORSConfiguration{Enabled: true, Handlers: []string{"admin"}}
+	if err := factory.AddCORSFilters(router.New(), router.New()); err == nil {
+		t.Fatal("error expected")
+	}
+}
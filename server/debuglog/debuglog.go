@@ -0,0 +1,190 @@
+/*
+Package debuglog provides a filter which logs request and response payloads
+at DEBUG level to logger "melon/server/payload" for troubleshooting.
+
+Bodies are logged up to a maximum size and only the parts read by handlers
+are included for requests. Values of credential headers are redacted.
+*/
+package debuglog
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/goburrow/melon/core"
+	"github.com/goburrow/melon/server/filter"
+)
+
+// DefaultMaxBodySize is the default maximum number of bytes logged of each body.
+const DefaultMaxBodySize = 4096
+
+// debugFilter logs payloads of requests matching patterns.
+type debugFilter struct {
+	patterns      []string
+	maxBodySize   int
+	redactHeaders []string
+	logger        core.Logger
+}
+
+// Option adds option for Filter.
+type Option func(f *debugFilter)
+
+// NewFilter returns a Filter which logs request and response payloads.
+// All requests are logged unless WithPath is used.
+func NewFilter(options ...Option) filter.Filter {
+	f := &debugFilter{
+		maxBodySize:   DefaultMaxBodySize,
+		redactHeaders: filter.CredentialHeaders(),
+	}
+	for _, opt := range options {
+		opt(f)
+	}
+	if len(f.patterns) == 0 {
+		f.patterns = []string{"/*"}
+	}
+	return f
+}
+
+// WithPath only logs requests matching path pattern.
+func WithPath(pattern string) Option {
+	return func(f *debugFilter) {
+		f.patterns = append(f.patterns, pattern)
+	}
+}
+
+// WithMaxBodySize sets the maximum number of bytes logged of each body.
+func WithMaxBodySize(n int) Option {
+	return func(f *debugFilter) {
+		f.maxBodySize = n
+	}
+}
+
+// WithRedactHeaders redacts values of the given headers in addition to
+// Authorization, Cookie, Proxy-Authorization and Set-Cookie.
+func WithRedactHeaders(names ...string) Option {
+	return func(f *debugFilter) {
+		f.redactHeaders = append(f.redactHeaders, names...)
+	}
+}
+
+// WithLogger sets logger of payloads instead of "melon/server/payload".
+func WithLogger(logger core.Logger) Option {
+	return func(f *debugFilter) {
+		f.logger = logger
+	}
+}
+
+func (f *debugFilter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !f.matchPath(r.URL.Path) {
+		filter.Continue(w, r)
+		return
+	}
+	reqBody := &capture{max: f.maxBodySize}
+	if r.Body != nil {
+		r.Body = &teeReadCloser{ReadCloser: r.Body, capture: reqBody}
+	}
+	cw := &captureWriter{ResponseWriter: w, body: capture{max: f.maxBodySize}}
+	filter.Continue(cw, r)
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s %s %s\n", r.Method, r.RequestURI, r.Proto)
+	filter.WriteHeader(&buf, r.Header, f.redactHeaders)
+	reqBody.writeTo(&buf)
+	fmt.Fprintf(&buf, "response %d\n", cw.status)
+	filter.WriteHeader(&buf, w.Header(), f.redactHeaders)
+	cw.body.writeTo(&buf)
+
+	logger := f.logger
+	if logger == nil {
+		logger = core.GetLogger("melon/server/payload")
+	}
+	logger.Debugf("%s", buf.String())
+}
+
+func (f *debugFilter) matchPath(path string) bool {
+	for _, p := range f.patterns {
+		if filter.MatchPath(p, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// capture keeps the first max bytes and counts all bytes written.
+type capture struct {
+	buf   bytes.Buffer
+	max   int
+	total int
+}
+
+func (c *capture) write(b []byte) {
+	c.total += len(b)
+	if n := c.max - c.buf.Len(); n > 0 {
+		if len(b) > n {
+			b = b[:n]
+		}
+		c.buf.Write(b)
+	}
+}
+
+func (c *capture) writeTo(buf *bytes.Buffer) {
+	if c.total == 0 {
+		return
+	}
+	fmt.Fprintf(buf, "body (%d bytes", c.total)
+	if c.total > c.buf.Len() {
+		buf.WriteString(", truncated")
+	}
+	buf.WriteString("):\n")
+	buf.Write(c.buf.Bytes())
+	buf.WriteString("\n")
+}
+
+// teeReadCloser captures request body read by handlers.
+type teeReadCloser struct {
+	io.ReadCloser
+	capture *capture
+}
+
+func (r *teeReadCloser) Read(b []byte) (int, error) {
+	n, err := r.ReadCloser.Read(b)
+	if n > 0 {
+		r.capture.write(b[:n])
+	}
+	return n, err
+}
+
+// captureWriter captures status and body of the response.
+type captureWriter struct {
+	http.ResponseWriter
+	status int
+	body   capture
+}
+
+func (w *captureWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *captureWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.body.write(b[:n])
+	return n, err
+}
+
+// Flush implements http.Flusher.
+func (w *captureWriter) Flush() {
+	if fl, ok := w.ResponseWriter.(http.Flusher); ok {
+		fl.Flush()
+	}
+}
@@ -0,0 +1,63 @@
+package debuglog
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/goburrow/melon/server/filter"
+)
+
+type testLogger struct {
+	debugs []string
+}
+
+func (l *testLogger) Infof(format string, args ...interface{})  {}
+func (l *testLogger) Warnf(format string, args ...interface{})  {}
+func (l *testLogger) Errorf(format string, args ...interface{}) {}
+
+func (l *testLogger) Debugf(format string, args ...interface{}) {
+	l.debugs = append(l.debugs, fmt.Sprintf(format, args...))
+}
+
+func TestFilter(t *testing.T) {
+	logger := &testLogger{}
+	chain := filter.NewChain()
+	chain.Add(NewFilter(WithPath("/api/*"), WithMaxBodySize(5), WithRedactHeaders("X-Api-Key"), WithLogger(logger)))
+	chain.Add(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Set-Cookie", "session=1")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("created:"))
+		w.Write(b)
+	}))
+
+	chain.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/other", strings.NewReader("melon")))
+	if len(logger.debugs) != 0 {
+		t.Fatalf("unexpected logs: %v", logger.debugs)
+	}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/api/users", strings.NewReader("melon"))
+	r.Header.Set("X-Api-Key", "s3cr3t")
+	r.Header.Set("Content-Type", "text/plain")
+	chain.ServeHTTP(w, r)
+	if w.Body.String() != "created:melon" {
+		t.Fatalf("unexpected body: %v", w.Body.String())
+	}
+	if len(logger.debugs) != 1 {
+		t.Fatalf("unexpected logs: %v", logger.debugs)
+	}
+	expected := "POST /api/users HTTP/1.1\n" +
+		"    Content-Type: text/plain\n" +
+		"    X-Api-Key: [REDACTED]\n" +
+		"body (5 bytes):\nmelon\n" +
+		"response 201\n" +
+		"    Set-Cookie: [REDACTED]\n" +
+		"body (13 bytes, truncated):\ncreat\n"
+	if logger.debugs[0] != expected {
+		t.Fatalf("unexpected log: %v", logger.debugs[0])
+	}
+}
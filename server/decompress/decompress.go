@@ -0,0 +1,86 @@
+/*
+Package decompress provides a filter which decodes compressed request bodies.
+
+Requests with header Content-Encoding gzip or deflate are decompressed before
+being passed to the next filters, so handlers and providers always read plain
+request bodies. Requests with other encodings are rejected with status 415.
+*/
+package decompress
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/goburrow/melon/server/filter"
+	"github.com/goburrow/melon/server/problem"
+)
+
+// DefaultMaxSize is the default limit of decompressed request bodies.
+const DefaultMaxSize = 10 * 1024 * 1024 // 10MB
+
+// decompressFilter decodes request body.
+type decompressFilter struct {
+	maxSize int64
+}
+
+// Option adds option for Filter.
+type Option func(f *decompressFilter)
+
+// NewFilter returns a Filter which decompresses request body encoded in
+// gzip or deflate.
+func NewFilter(options ...Option) filter.Filter {
+	f := &decompressFilter{
+		maxSize: DefaultMaxSize,
+	}
+	for _, opt := range options {
+		opt(f)
+	}
+	return f
+}
+
+// WithMaxSize limits size of decompressed request bodies. Reading more than n
+// bytes from the body results in an error.
+func WithMaxSize(n int64) Option {
+	return func(f *decompressFilter) {
+		f.maxSize = n
+	}
+}
+
+func (f *decompressFilter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+	if encoding == "" || encoding == "identity" || r.Body == nil || r.Body == http.NoBody {
+		filter.Continue(w, r)
+		return
+	}
+	var body io.ReadCloser
+	var err error
+	switch encoding {
+	case "gzip", "x-gzip":
+		body, err = gzip.NewReader(r.Body)
+	case "deflate":
+		body, err = zlib.NewReader(r.Body)
+	default:
+		problem.Error(w, r, http.StatusUnsupportedMediaType, "unsupported content encoding "+encoding)
+		return
+	}
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, "invalid "+encoding+" request body")
+		return
+	}
+	defer body.Close()
+
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.Header = make(http.Header, len(r.Header))
+	for k, v := range r.Header {
+		r2.Header[k] = v
+	}
+	r2.Header.Del("Content-Encoding")
+	r2.Header.Del("Content-Length")
+	r2.ContentLength = -1
+	r2.Body = http.MaxBytesReader(w, body, f.maxSize)
+	filter.Continue(w, r2)
+}
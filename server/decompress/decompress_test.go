@@ -0,0 +1,82 @@
+package decompress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/goburrow/melon/server/filter"
+	"github.com/goburrow/melon/server/problem"
+)
+
+func echoHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Content-Encoding") != "" {
+		http.Error(w, "unexpected content encoding", http.StatusInternalServerError)
+		return
+	}
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	w.Write(b)
+}
+
+func compress(t *testing.T, encoding, s string) io.Reader {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	if encoding == "deflate" {
+		w = zlib.NewWriter(&buf)
+	} else {
+		w = gzip.NewWriter(&buf)
+	}
+	if _, err := w.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestFilter(t *testing.T) {
+	chain := filter.NewChain()
+	chain.Add(NewFilter(WithMaxSize(10)), http.HandlerFunc(echoHandler))
+
+	tests := []struct {
+		encoding string
+		body     io.Reader
+		status   int
+		response string
+	}{
+		{"", strings.NewReader("plain"), 200, "plain"},
+		{"gzip", compress(t, "gzip", "gzipped"), 200, "gzipped"},
+		{"deflate", compress(t, "deflate", "deflated"), 200, "deflated"},
+		{"gzip", compress(t, "gzip", "larger than limit"), 413, ""},
+		{"gzip", strings.NewReader("invalid"), 400, ""},
+		{"br", strings.NewReader("br"), 415, ""},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/", test.body)
+		if test.encoding != "" {
+			r.Header.Set("Content-Encoding", test.encoding)
+		}
+		chain.ServeHTTP(w, r)
+		if w.Code != test.status {
+			t.Fatalf("unexpected status %v for %v: %v", w.Code, test.encoding, w.Body.String())
+		}
+		if (test.status == 400 || test.status == 415) && w.Header().Get("Content-Type") != problem.ContentType {
+			t.Fatalf("unexpected Content-Type for %v: %v", test.encoding, w.Header().Get("Content-Type"))
+		}
+		if test.response != "" && w.Body.String() != test.response {
+			t.Fatalf("unexpected body: %v", w.Body.String())
+		}
+	}
+}
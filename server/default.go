@@ -1,6 +1,8 @@
 package server
 
 import (
+	"time"
+
 	"github.com/goburrow/melon/core"
 	"github.com/goburrow/melon/server/router"
 )
@@ -41,13 +43,49 @@ func (factory *DefaultFactory) BuildServer(env *core.Environment) (core.Managed,
 	// Admin
 	adminHandler := router.New()
 	env.Admin.Router = adminHandler
+	env.Admin.StartupHealthCheck = factory.StartupHealthCheck
+	env.Admin.DrainPeriod = time.Duration(factory.DrainPeriod)
 
 	err := factory.commonFactory.AddFilters(env, appHandler, adminHandler)
 	if err != nil {
 		return nil, err
 	}
+	err = factory.commonFactory.AddMaintenanceFilter(env, appHandler)
+	if err != nil {
+		return nil, err
+	}
+	err = factory.commonFactory.AddRateLimitFilter(appHandler)
+	if err != nil {
+		return nil, err
+	}
+	err = factory.commonFactory.AddBulkheadFilter(appHandler)
+	if err != nil {
+		return nil, err
+	}
+	err = factory.commonFactory.AddCacheFilter(env, appHandler)
+	if err != nil {
+		return nil, err
+	}
+	err = factory.commonFactory.AddAdminAuthFilter(adminHandler)
+	if err != nil {
+		return nil, err
+	}
+	factory.commonFactory.AddAuditFilter(adminHandler)
+	err = factory.commonFactory.AddShutdownTask(env)
+	if err != nil {
+		return nil, err
+	}
+	err = factory.commonFactory.AddPingHandlers(env, appHandler)
+	if err != nil {
+		return nil, err
+	}
+	err = factory.commonFactory.AddCORSFilters(appHandler, adminHandler)
+	if err != nil {
+		return nil, err
+	}
 
 	server := newServer()
+	server.timeline = env.Lifecycle.Timeline
 	err = server.addConnectors(appHandler, factory.ApplicationConnectors)
 	if err != nil {
 		return nil, err
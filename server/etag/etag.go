@@ -0,0 +1,187 @@
+/*
+Package etag provides a filter which handles conditional GET requests.
+
+Successful responses of GET and HEAD requests are buffered to compute ETag
+from their bodies unless handlers have already set it or flush the response. When the request
+header If-None-Match matches the ETag, or If-Modified-Since is not before
+header Last-Modified set by handlers, the filter responds 304 Not Modified
+without body.
+*/
+package etag
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/goburrow/melon/server/filter"
+)
+
+// etagFilter computes ETag of responses.
+type etagFilter struct {
+	weak     bool
+	patterns []string
+}
+
+// Option adds option for Filter.
+type Option func(f *etagFilter)
+
+// NewFilter returns a Filter which adds ETag to responses and handles
+// conditional requests.
+func NewFilter(options ...Option) filter.Filter {
+	f := &etagFilter{}
+	for _, opt := range options {
+		opt(f)
+	}
+	if len(f.patterns) == 0 {
+		f.patterns = []string{"/*"}
+	}
+	return f
+}
+
+// WithWeak generates weak ETags which are suitable when responses are
+// semantically equivalent but may not be byte-identical, e.g. compressed.
+func WithWeak() Option {
+	return func(f *etagFilter) {
+		f.weak = true
+	}
+}
+
+// WithPath only handles requests matching path pattern. All requests are handled
+// by default.
+func WithPath(pattern string) Option {
+	return func(f *etagFilter) {
+		f.patterns = append(f.patterns, pattern)
+	}
+}
+
+func (f *etagFilter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if (r.Method != "GET" && r.Method != "HEAD") || !f.matchPath(r.URL.Path) {
+		filter.Continue(w, r)
+		return
+	}
+	bw := &bufferedWriter{ResponseWriter: w}
+	filter.Continue(bw, r)
+	if bw.streaming {
+		return
+	}
+	if bw.status == 0 {
+		bw.status = http.StatusOK
+	}
+	if bw.status != http.StatusOK {
+		w.WriteHeader(bw.status)
+		w.Write(bw.buf.Bytes())
+		return
+	}
+	header := w.Header()
+	etag := header.Get("ETag")
+	if etag == "" {
+		etag = Compute(bw.buf.Bytes(), f.weak)
+		header.Set("ETag", etag)
+	}
+	if notModified(r, etag, header.Get("Last-Modified")) {
+		header.Del("Content-Type")
+		header.Del("Content-Length")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.WriteHeader(bw.status)
+	w.Write(bw.buf.Bytes())
+}
+
+func (f *etagFilter) matchPath(path string) bool {
+	for _, p := range f.patterns {
+		if filter.MatchPath(p, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// Compute returns quoted ETag of the content.
+func Compute(content []byte, weak bool) string {
+	sum := sha1.Sum(content)
+	etag := `"` + hex.EncodeToString(sum[:]) + `"`
+	if weak {
+		return "W/" + etag
+	}
+	return etag
+}
+
+// notModified checks request conditions. If-Modified-Since is ignored when
+// If-None-Match is present as in RFC 7232.
+func notModified(r *http.Request, etag, lastModified string) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return matchETag(inm, etag)
+	}
+	ims := r.Header.Get("If-Modified-Since")
+	if ims == "" || lastModified == "" {
+		return false
+	}
+	t, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(lastModified)
+	if err != nil {
+		return false
+	}
+	return !modified.Truncate(time.Second).After(t)
+}
+
+// matchETag uses weak comparison which is required for If-None-Match.
+func matchETag(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimSpace(v)
+		if v == "*" || strings.TrimPrefix(v, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// bufferedWriter buffers response body and status until it is flushed.
+type bufferedWriter struct {
+	http.ResponseWriter
+	buf    bytes.Buffer
+	status int
+	// streaming is set when handler flushes the response, ETag is not
+	// computed for streaming responses.
+	streaming bool
+}
+
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+	if w.streaming {
+		return w.ResponseWriter.Write(b)
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.buf.Write(b)
+}
+
+func (w *bufferedWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+// Flush writes buffered response and stops buffering.
+func (w *bufferedWriter) Flush() {
+	if !w.streaming {
+		w.streaming = true
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		w.ResponseWriter.WriteHeader(w.status)
+		w.ResponseWriter.Write(w.buf.Bytes())
+		w.buf.Reset()
+	}
+	if fl, ok := w.ResponseWriter.(http.Flusher); ok {
+		fl.Flush()
+	}
+}
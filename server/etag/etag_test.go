@@ -0,0 +1,71 @@
+package etag
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/goburrow/melon/server/filter"
+)
+
+const lastModified = "Mon, 01 Jan 2018 00:00:00 GMT"
+
+func handler(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/modified":
+		w.Header().Set("Last-Modified", lastModified)
+	case "/error":
+		http.Error(w, "error", http.StatusBadRequest)
+		return
+	case "/stream":
+		w.Write([]byte("mel"))
+		w.(http.Flusher).Flush()
+		w.Write([]byte("on"))
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte("melon"))
+}
+
+func TestFilter(t *testing.T) {
+	chain := filter.NewChain()
+	chain.Add(NewFilter(), http.HandlerFunc(handler))
+
+	etag := Compute([]byte("melon"), false)
+	tests := []struct {
+		method  string
+		path    string
+		headers map[string]string
+		status  int
+		etag    string
+		body    string
+	}{
+		{"GET", "/", nil, 200, etag, "melon"},
+		{"GET", "/", map[string]string{"If-None-Match": etag}, 304, etag, ""},
+		{"GET", "/", map[string]string{"If-None-Match": `"other", W/` + etag}, 304, etag, ""},
+		{"GET", "/", map[string]string{"If-None-Match": `"other"`}, 200, etag, "melon"},
+		{"GET", "/modified", map[string]string{"If-Modified-Since": lastModified}, 304, etag, ""},
+		{"GET", "/modified", map[string]string{"If-Modified-Since": "Sun, 31 Dec 2017 00:00:00 GMT"}, 200, etag, "melon"},
+		{"GET", "/error", map[string]string{"If-None-Match": "*"}, 400, "", "error\n"},
+		{"POST", "/", map[string]string{"If-None-Match": etag}, 200, "", "melon"},
+		{"GET", "/stream", map[string]string{"If-None-Match": etag}, 200, "", "melon"},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(test.method, test.path, nil)
+		for k, v := range test.headers {
+			r.Header.Set(k, v)
+		}
+		chain.ServeHTTP(w, r)
+		if w.Code != test.status || w.Header().Get("ETag") != test.etag || w.Body.String() != test.body {
+			t.Fatalf("unexpected response of %+v: %v %v %q", test, w.Code, w.Header(), w.Body.String())
+		}
+	}
+}
+
+func TestCompute(t *testing.T) {
+	etag := Compute([]byte("melon"), true)
+	if etag[:3] != `W/"` || len(etag) != 44 {
+		t.Fatalf("unexpected etag: %v", etag)
+	}
+}
@@ -6,6 +6,7 @@ package filter
 import (
 	"context"
 	"net/http"
+	"strings"
 )
 
 // Filter performs filtering tasks on the request and response to a HTTP resource.
@@ -15,6 +16,75 @@ type Filter interface {
 	http.Handler
 }
 
+// Priorities of filters. Filters with lower priority are executed first, i.e.
+// they wrap filters with higher priority. Filters not implementing Prioritized
+// have PriorityDefault and are executed after built-in filters.
+const (
+	PriorityClientIP       = 500
+	PriorityRequestLog     = 1000
+	PriorityMonitoring     = 2000
+	PriorityRecovery       = 3000
+	PriorityTimeout        = 4000
+	PriorityHeader         = 5000
+	PriorityCompression    = 6000
+	PriorityCORS           = 7000
+	PriorityAudit          = 7500
+	PriorityAuthentication = 8000
+	PriorityRateLimit      = 9000
+	PriorityCache          = 9500
+	PriorityDefault        = 10000
+)
+
+// Prioritized is implemented by filters which have a specific position in
+// filter chains of the server.
+type Prioritized interface {
+	Priority() int
+}
+
+// PriorityOf returns priority of the filter f or PriorityDefault.
+func PriorityOf(f Filter) int {
+	if p, ok := f.(Prioritized); ok {
+		return p.Priority()
+	}
+	return PriorityDefault
+}
+
+// prioritizedFilter is a filter with the given priority.
+type prioritizedFilter struct {
+	Filter
+	priority int
+}
+
+func (f *prioritizedFilter) Priority() int {
+	return f.priority
+}
+
+// WithPriority returns a Filter executing f with the given priority.
+func WithPriority(f Filter, priority int) Filter {
+	return &prioritizedFilter{Filter: f, priority: priority}
+}
+
+// wrapper is implemented by filters wrapping another filter.
+type wrapper interface {
+	unwrap() Filter
+}
+
+func (f *prioritizedFilter) unwrap() Filter {
+	return f.Filter
+}
+
+// Unwrap returns the underlying filter of f if it is wrapped by WithPriority or
+// ForPaths, otherwise f itself.
+func Unwrap(f Filter) Filter {
+	for {
+		w, ok := f.(wrapper)
+		if !ok {
+			return f
+		}
+		f = w.unwrap()
+	}
+}
+
 // Chain is a http.Handler that executes all filters.
 type Chain struct {
 	filters []Filter
@@ -53,6 +123,14 @@ func (chain *Chain) Insert(f Filter, idx int) bool {
 	return true
 }
 
+// Get returns the filter at the idx position or nil if idx is out of range.
+func (chain *Chain) Get(idx int) Filter {
+	if idx < 0 || idx >= len(chain.filters) {
+		return nil
+	}
+	return chain.filters[idx]
+}
+
 // Length returns length of the chain.
 func (chain *Chain) Length() int {
 	return len(chain.filters)
@@ -85,6 +163,73 @@ func (f *If) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// Middleware is the standard net/http middleware which wraps the next handler.
+type Middleware func(http.Handler) http.Handler
+
+// FromMiddleware returns a Filter executing middleware m. The next handler given
+// to m continues the filter chain.
+func FromMiddleware(m Middleware) Filter {
+	return m(http.HandlerFunc(Continue))
+}
+
+// ToMiddleware returns a middleware executing filters fs before the next handler.
+// The next handler is called with the context of the original request so that
+// an enclosing filter chain, if any, is continued.
+func ToMiddleware(fs ...Filter) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			chain := NewChain()
+			chain.Add(fs...)
+			chain.Add(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				next.ServeHTTP(w, r.WithContext(ctx))
+			}))
+			chain.ServeHTTP(w, r)
+		})
+	}
+}
+
+// pathFilter executes the underlying filter only for matching paths.
+type pathFilter struct {
+	Filter
+	patterns []string
+}
+
+// ForPaths returns a Filter which executes f only for requests which path
+// matches any of the patterns, e.g. "/api/*". See MatchPath for pattern syntax.
+// Priority of f is preserved.
+func ForPaths(f Filter, patterns ...string) Filter {
+	return &pathFilter{Filter: f, patterns: patterns}
+}
+
+func (f *pathFilter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	for _, p := range f.patterns {
+		if MatchPath(p, r.URL.Path) {
+			f.Filter.ServeHTTP(w, r)
+			return
+		}
+	}
+	Continue(w, r)
+}
+
+func (f *pathFilter) Priority() int {
+	return PriorityOf(f.Filter)
+}
+
+func (f *pathFilter) unwrap() Filter {
+	return f.Filter
+}
+
+// MatchPath reports whether the path matches the pattern. Like routes registered
+// in the router, a pattern ending with "*" matches all paths having the same
+// prefix, otherwise path must be identical to the pattern.
+func MatchPath(pattern, path string) bool {
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(path, pattern[:len(pattern)-1])
+	}
+	return pattern == path
+}
+
 // contextKey is a value for use with context.WithValue
 type contextKey struct {
 	name string
@@ -1,6 +1,7 @@
 package filter
 
 import (
+	"bytes"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -85,3 +86,112 @@ func TesIf(t *testing.T) {
 		t.Fatalf("unexpected body: %v", w.Body.String())
 	}
 }
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		match   bool
+	}{
+		{"/", "/", true},
+		{"/", "/a", false},
+		{"/*", "/a", true},
+		{"/api/*", "/api/users", true},
+		{"/api/*", "/api", false},
+		{"/api*", "/api", true},
+		{"/users", "/users/1", false},
+	}
+	for _, test := range tests {
+		if MatchPath(test.pattern, test.path) != test.match {
+			t.Errorf("unexpected match %v: pattern=%v, path=%v", !test.match, test.pattern, test.path)
+		}
+	}
+}
+
+func TestPriority(t *testing.T) {
+	if PriorityOf(testFilter("1")) != PriorityDefault {
+		t.Fatalf("unexpected priority: %v", PriorityOf(testFilter("1")))
+	}
+	f := WithPriority(testFilter("1"), PriorityRecovery)
+	if PriorityOf(f) != PriorityRecovery {
+		t.Fatalf("unexpected priority: %v", PriorityOf(f))
+	}
+}
+
+func TestForPaths(t *testing.T) {
+	chain := NewChain()
+	chain.Add(ForPaths(WithPriority(testFilter("1"), PriorityCompression), "/reports", "/api/*"))
+	chain.Add(endHandler)
+	if PriorityOf(chain.Get(0)) != PriorityCompression {
+		t.Fatalf("unexpected priority: %v", PriorityOf(chain.Get(0)))
+	}
+	if Unwrap(chain.Get(0)) != testFilter("1") {
+		t.Fatalf("unexpected filter: %#v", Unwrap(chain.Get(0)))
+	}
+	tests := []struct {
+		path string
+		body string
+	}{
+		{"/reports", "1END"},
+		{"/api/users", "1END"},
+		{"/reports/1", "END"},
+		{"/", "END"},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		chain.ServeHTTP(w, httptest.NewRequest("GET", test.path, nil))
+		if w.Body.String() != test.body {
+			t.Fatalf("unexpected body of %v: %v", test.path, w.Body.String())
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	middleware := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("m"))
+			next.ServeHTTP(w, r)
+		})
+	}
+	chain := NewChain()
+	chain.Add(testFilter("1"), FromMiddleware(middleware), testFilter("2"))
+	chain.Add(endHandler)
+
+	w := httptest.NewRecorder()
+	chain.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Body.String() != "1m2END" {
+		t.Fatalf("unexpected body: %v", w.Body.String())
+	}
+
+	handler := ToMiddleware(testFilter("1"), testFilter("2"))(endHandler)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Body.String() != "12END" {
+		t.Fatalf("unexpected body: %v", w.Body.String())
+	}
+
+	chain = NewChain()
+	chain.Add(testFilter("1"), FromMiddleware(ToMiddleware(testFilter("2"), testFilter("3"))), testFilter("4"))
+	chain.Add(endHandler)
+	w = httptest.NewRecorder()
+	chain.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Body.String() != "1234END" {
+		t.Fatalf("unexpected body: %v", w.Body.String())
+	}
+}
+
+func TestWriteHeader(t *testing.T) {
+	header := http.Header{}
+	header.Set("X-Request-Id", "1")
+	header.Set("Cookie", "secret")
+	header.Add("Accept", "text/html")
+	header.Add("Accept", "text/plain")
+	var buf bytes.Buffer
+	WriteHeader(&buf, header, CredentialHeaders())
+	expected := "    Accept: text/html, text/plain\n" +
+		"    Cookie: [REDACTED]\n" +
+		"    X-Request-Id: 1\n"
+	if buf.String() != expected {
+		t.Fatalf("unexpected header: %q, expect: %q", buf.String(), expected)
+	}
+}
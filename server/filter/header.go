@@ -0,0 +1,38 @@
+package filter
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// RedactedValue replaces values of redacted headers in WriteHeader.
+const RedactedValue = "[REDACTED]"
+
+// CredentialHeaders returns names of headers carrying credentials, which
+// should be redacted when logged.
+func CredentialHeaders() []string {
+	return []string{"Authorization", "Cookie", "Proxy-Authorization", "Set-Cookie"}
+}
+
+// WriteHeader writes header to buf sorted by names, one indented line per
+// header. Values of headers in redacted are replaced by RedactedValue.
+func WriteHeader(buf *bytes.Buffer, header http.Header, redacted []string) {
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := strings.Join(header[k], ", ")
+		for _, s := range redacted {
+			if strings.EqualFold(k, s) {
+				v = RedactedValue
+				break
+			}
+		}
+		fmt.Fprintf(buf, "    %s: %s\n", k, v)
+	}
+}
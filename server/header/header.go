@@ -0,0 +1,101 @@
+/*
+Package header provides a filter which adds static headers, including caching
+policies, to HTTP responses.
+*/
+package header
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/goburrow/melon/server/filter"
+)
+
+// For testing
+var now = time.Now
+
+// rule associates a path pattern with headers to be set.
+type rule struct {
+	pattern string
+	header  http.Header
+	// expires is added to the current time for header Expires if positive.
+	expires time.Duration
+}
+
+// headerFilter sets headers to responses of requests matching its rules.
+type headerFilter struct {
+	rules []rule
+}
+
+// Option adds option for Filter.
+type Option func(f *headerFilter)
+
+// NewFilter allocates and returns a new Filter which sets static headers to
+// HTTP responses. Headers are set before calling the next filter so handlers
+// can still override them.
+func NewFilter(options ...Option) filter.Filter {
+	f := &headerFilter{}
+	for _, opt := range options {
+		opt(f)
+	}
+	return f
+}
+
+func (f *headerFilter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	for i := range f.rules {
+		if filter.MatchPath(f.rules[i].pattern, r.URL.Path) {
+			h := w.Header()
+			for k, v := range f.rules[i].header {
+				if len(v) == 0 {
+					h.Del(k)
+				} else {
+					h[k] = v
+				}
+			}
+			if f.rules[i].expires > 0 {
+				h.Set("Expires", now().Add(f.rules[i].expires).UTC().Format(http.TimeFormat))
+			} else if _, ok := f.rules[i].header["Cache-Control"]; ok {
+				// Expires of previous rules conflicts with the new Cache-Control.
+				h.Del("Expires")
+			}
+		}
+	}
+	filter.Continue(w, r)
+}
+
+// WithHeaders sets the given headers to responses of requests which path
+// matches pattern. See filter.MatchPath for pattern syntax.
+// An empty value removes the header set by previous rules.
+func WithHeaders(pattern string, headers map[string]string) Option {
+	header := make(http.Header, len(headers))
+	for k, v := range headers {
+		if v == "" {
+			header[http.CanonicalHeaderKey(k)] = nil
+		} else {
+			header.Set(k, v)
+		}
+	}
+	return func(f *headerFilter) {
+		f.rules = append(f.rules, rule{
+			pattern: pattern,
+			header:  header,
+		})
+	}
+}
+
+// WithCacheControl sets header Cache-Control to responses of requests which path
+// matches pattern. If expires is positive, header Expires is also set to the
+// time after the given duration from now for HTTP/1.0 caches.
+func WithCacheControl(pattern string, cacheControl string, expires time.Duration) Option {
+	header := make(http.Header, 1)
+	if cacheControl != "" {
+		header.Set("Cache-Control", cacheControl)
+	}
+	return func(f *headerFilter) {
+		f.rules = append(f.rules, rule{
+			pattern: pattern,
+			header:  header,
+			expires: expires,
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package header
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/goburrow/melon/server/filter"
+)
+
+func TestFilter(t *testing.T) {
+	f := NewFilter(
+		WithHeaders("/*", map[string]string{"x-service": "melon", "cache-control": "no-cache"}),
+		WithHeaders("/static/*", map[string]string{"Cache-Control": "max-age=3600"}),
+		WithHeaders("/private", map[string]string{"X-Service": ""}),
+	)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}
+	chain := filter.NewChain()
+	chain.Add(f, http.HandlerFunc(handler))
+
+	tests := []struct {
+		path         string
+		service      string
+		cacheControl string
+	}{
+		{"/", "melon", "no-cache"},
+		{"/static/main.css", "melon", "max-age=3600"},
+		{"/private", "", "no-cache"},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", test.path, nil)
+		chain.ServeHTTP(w, r)
+		assertHeader(t, w.Header(), "X-Service", test.service)
+		assertHeader(t, w.Header(), "Cache-Control", test.cacheControl)
+	}
+}
+
+func TestCacheControl(t *testing.T) {
+	now = func() time.Time {
+		return time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+	defer func() {
+		now = time.Now
+	}()
+	f := NewFilter(
+		WithCacheControl("/*", "no-store", 0),
+		WithCacheControl("/static/*", "public, max-age=3600", time.Hour),
+		WithCacheControl("/static/private/*", "no-store", 0),
+	)
+	chain := filter.NewChain()
+	chain.Add(f, http.NotFoundHandler())
+
+	tests := []struct {
+		path         string
+		cacheControl string
+		expires      string
+	}{
+		{"/api", "no-store", ""},
+		{"/static/main.css", "public, max-age=3600", "Mon, 01 Jan 2018 01:00:00 GMT"},
+		{"/static/private/key", "no-store", ""},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		chain.ServeHTTP(w, httptest.NewRequest("GET", test.path, nil))
+		assertHeader(t, w.Header(), "Cache-Control", test.cacheControl)
+		assertHeader(t, w.Header(), "Expires", test.expires)
+	}
+}
+
+func assertHeader(t *testing.T, headers http.Header, name string, expected string) {
+	header := headers.Get(name)
+	if expected != header {
+		t.Fatalf("unexpected %s: %v, expect: %v", name, header, expected)
+	}
+}
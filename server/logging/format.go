@@ -0,0 +1,203 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	// FormatDefault is Common Log Format followed by referer, user agent,
+	// response time in milliseconds and request ID.
+	FormatDefault = "default"
+	// FormatCommon is Apache Common Log Format.
+	FormatCommon = "common"
+	// FormatCombined is Apache Combined Log Format.
+	FormatCombined = "combined"
+	// FormatJSON writes each entry as a JSON object in a single line.
+	FormatJSON = "json"
+)
+
+// Entry is an access log entry of a HTTP request.
+type Entry struct {
+	// Time is when the request was received.
+	Time       time.Time
+	RemoteAddr string
+	Method     string
+	URI        string
+	// Path is the URL path without query.
+	Path   string
+	Proto  string
+	Status int
+	// Bytes is the size of response body.
+	Bytes     uint64
+	Referer   string
+	UserAgent string
+	RequestID string
+	// Latency is the duration of handling the request.
+	Latency time.Duration
+}
+
+// Formatter writes access log entries.
+type Formatter interface {
+	Format(w io.Writer, e *Entry) error
+}
+
+// FormatterFunc is an adapter to allow the use of ordinary functions as Formatter.
+type FormatterFunc func(w io.Writer, e *Entry) error
+
+// Format calls f(w, e).
+func (f FormatterFunc) Format(w io.Writer, e *Entry) error {
+	return f(w, e)
+}
+
+// NewFormatter returns Formatter of the given format, which is either one of
+// predefined formats "default", "common", "combined" and "json", or a text/template
+// executed with Entry, e.g.
+//
+//	{{.RemoteAddr}} "{{.Method}} {{.URI}}" {{.Status}} {{.Bytes}} {{.Latency}} {{.UserAgent}} {{.RequestID}}
+//
+// Template functions are ms which converts Latency to milliseconds and
+// dash which replaces empty or zero values by "-".
+func NewFormatter(format string) (Formatter, error) {
+	switch format {
+	case "", FormatDefault:
+		return FormatterFunc(formatDefault), nil
+	case FormatCommon:
+		return FormatterFunc(formatCommon), nil
+	case FormatCombined:
+		return FormatterFunc(formatCombined), nil
+	case FormatJSON:
+		return FormatterFunc(formatJSON), nil
+	}
+	if !strings.Contains(format, "{{") {
+		return nil, fmt.Errorf("logging: unsupported format %v", format)
+	}
+	if !strings.HasSuffix(format, "\n") {
+		format += "\n"
+	}
+	t, err := template.New("requestlog").Funcs(templateFuncs).Parse(format)
+	if err != nil {
+		return nil, err
+	}
+	return FormatterFunc(func(w io.Writer, e *Entry) error {
+		return t.Execute(w, e)
+	}), nil
+}
+
+var templateFuncs = template.FuncMap{
+	"ms": func(d time.Duration) int64 {
+		return d.Nanoseconds() / int64(time.Millisecond)
+	},
+	"dash": dash,
+}
+
+func formatDefault(w io.Writer, e *Entry) error {
+	_, err := fmt.Fprintf(w, "%s - - [%s] \"%s %s %s\" %d %d %q %q %d %q\n",
+		e.RemoteAddr,
+		e.Time.Format(timeFormat),
+		e.Method,
+		e.URI,
+		e.Proto,
+		e.Status,
+		e.Bytes,
+		dash(e.Referer),
+		dash(e.UserAgent),
+		e.Latency.Nanoseconds()/int64(time.Millisecond),
+		e.RequestID,
+	)
+	return err
+}
+
+func formatCommon(w io.Writer, e *Entry) error {
+	_, err := fmt.Fprintf(w, "%s - - [%s] \"%s %s %s\" %d %s\n",
+		e.RemoteAddr,
+		e.Time.Format(timeFormat),
+		e.Method,
+		e.URI,
+		e.Proto,
+		e.Status,
+		dash(e.Bytes),
+	)
+	return err
+}
+
+func formatCombined(w io.Writer, e *Entry) error {
+	_, err := fmt.Fprintf(w, "%s - - [%s] \"%s %s %s\" %d %s %q %q\n",
+		e.RemoteAddr,
+		e.Time.Format(timeFormat),
+		e.Method,
+		e.URI,
+		e.Proto,
+		e.Status,
+		dash(e.Bytes),
+		dash(e.Referer),
+		dash(e.UserAgent),
+	)
+	return err
+}
+
+// jsonEntry is the structured access log entry.
+type jsonEntry struct {
+	Timestamp string  `json:"timestamp"`
+	Method    string  `json:"method"`
+	Path      string  `json:"path"`
+	Query     string  `json:"query,omitempty"`
+	Proto     string  `json:"proto"`
+	Status    int     `json:"status"`
+	Bytes     uint64  `json:"bytes"`
+	Duration  float64 `json:"duration"`
+	RemoteIP  string  `json:"remoteIP"`
+	RequestID string  `json:"requestID,omitempty"`
+	UserAgent string  `json:"userAgent,omitempty"`
+	Referer   string  `json:"referer,omitempty"`
+}
+
+// formatJSON writes duration in milliseconds and timestamp in RFC 3339.
+func formatJSON(w io.Writer, e *Entry) error {
+	v := &jsonEntry{
+		Timestamp: e.Time.Format(time.RFC3339Nano),
+		Method:    e.Method,
+		Path:      e.Path,
+		Proto:     e.Proto,
+		Status:    e.Status,
+		Bytes:     e.Bytes,
+		Duration:  float64(e.Latency) / float64(time.Millisecond),
+		RemoteIP:  e.RemoteAddr,
+		RequestID: e.RequestID,
+		UserAgent: e.UserAgent,
+		Referer:   e.Referer,
+	}
+	if i := strings.IndexByte(e.URI, '?'); i >= 0 {
+		v.Query = e.URI[i+1:]
+	}
+	// Encoder appends a newline.
+	return json.NewEncoder(w).Encode(v)
+}
+
+// dash returns "-" for empty string or zero number.
+func dash(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		if v != "" {
+			return v
+		}
+	case int:
+		if v != 0 {
+			return strconv.Itoa(v)
+		}
+	case uint64:
+		if v != 0 {
+			return strconv.FormatUint(v, 10)
+		}
+	default:
+		if v != nil {
+			return fmt.Sprint(v)
+		}
+	}
+	return "-"
+}
@@ -5,15 +5,17 @@ package logging
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
-	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"strings"
 	"time"
 
+	"github.com/goburrow/melon/core"
 	"github.com/goburrow/melon/server/filter"
+	"github.com/goburrow/melon/server/realip"
 )
 
 const (
@@ -26,56 +28,121 @@ const (
 // For testing
 var now = time.Now
 
-// logFilter is a middleware which logs all requests in Common Log Format.
+// output is a writer with its formatter.
+type output struct {
+	writer    io.Writer
+	formatter Formatter
+}
+
+// statusRange is an inclusive range of response status codes.
+type statusRange struct {
+	min, max int
+}
+
+// logFilter is a middleware which logs all requests.
 type logFilter struct {
-	writer io.Writer
+	outputs []output
+
+	excludePaths  []string
+	excludeStatus []statusRange
 }
 
-// NewFilter returns a new Filter logging all HTTP requests in Common Log Format to given writer.
-func NewFilter(writer io.Writer) filter.Filter {
-	return &logFilter{writer: writer}
+// Option adds option for Filter.
+type Option func(f *logFilter)
+
+// NewFilter returns a new Filter logging all HTTP requests to given writer.
+// Requests are logged in Common Log Format with referer, user agent, response
+// time and request ID unless WithFormatter is used.
+func NewFilter(writer io.Writer, options ...Option) filter.Filter {
+	f := &logFilter{
+		outputs: []output{{writer: writer, formatter: FormatterFunc(formatDefault)}},
+	}
+	for _, opt := range options {
+		opt(f)
+	}
+	return f
+}
+
+// WithFormatter sets formatter of the writer given in NewFilter.
+func WithFormatter(formatter Formatter) Option {
+	return func(f *logFilter) {
+		f.outputs[0].formatter = formatter
+	}
+}
+
+// WithOutput also logs requests to writer using formatter.
+func WithOutput(writer io.Writer, formatter Formatter) Option {
+	return func(f *logFilter) {
+		f.outputs = append(f.outputs, output{writer: writer, formatter: formatter})
+	}
+}
+
+// WithExcludePath does not log requests which path matches pattern.
+// See filter.MatchPath for pattern syntax.
+func WithExcludePath(pattern string) Option {
+	return func(f *logFilter) {
+		f.excludePaths = append(f.excludePaths, pattern)
+	}
+}
+
+// WithExcludeStatus does not log requests which response status is between
+// min and max inclusively.
+func WithExcludeStatus(min, max int) Option {
+	return func(f *logFilter) {
+		f.excludeStatus = append(f.excludeStatus, statusRange{min: min, max: max})
+	}
 }
 
 func (f *logFilter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	for _, p := range f.excludePaths {
+		if filter.MatchPath(p, r.URL.Path) {
+			filter.Continue(w, r)
+			return
+		}
+	}
 	responseWriter := &responseWriter{ResponseWriter: w, status: http.StatusOK}
 
 	start := now()
 	filter.Continue(responseWriter, r)
 	end := now()
 
-	remoteAddr := getRemoteAddr(r)
-	referer := r.Referer()
-	if referer == "" {
-		referer = "-"
-	}
-	userAgent := r.UserAgent()
-	if userAgent == "" {
-		userAgent = "-"
-	}
-	startTime := start.Format(timeFormat)
-	responseTime := end.Sub(start).Nanoseconds() / int64(time.Millisecond)
-	requestID := r.Header.Get(xRequestID)
-
-	// Common log format
-	fmt.Fprintf(f.writer, "%s %s %s [%s] \"%s %s %s\" %d %d %q %q %d %q\n",
-		remoteAddr,
-		"-", // Identity is not supported.
-		"-", // UserID is not supported.
-		startTime,
-		r.Method,
-		r.RequestURI,
-		r.Proto,
-		responseWriter.status,
-		responseWriter.size,
-		referer,
-		userAgent,
-		responseTime,
-		requestID,
-	)
+	for _, s := range f.excludeStatus {
+		if responseWriter.status >= s.min && responseWriter.status <= s.max {
+			return
+		}
+	}
+
+	entry := &Entry{
+		Time:       start,
+		RemoteAddr: getRemoteAddr(r),
+		Method:     r.Method,
+		URI:        r.RequestURI,
+		Path:       r.URL.Path,
+		Proto:      r.Proto,
+		Status:     responseWriter.status,
+		Bytes:      responseWriter.size,
+		Referer:    r.Referer(),
+		UserAgent:  r.UserAgent(),
+		RequestID:  r.Header.Get(xRequestID),
+		Latency:    end.Sub(start),
+	}
+	var buf bytes.Buffer
+	for _, o := range f.outputs {
+		buf.Reset()
+		// Write each entry at once as the writer may be shared.
+		if err := o.formatter.Format(&buf, entry); err != nil {
+			core.GetLogger("melon/server").Warnf("could not format request log: %v", err)
+			continue
+		}
+		o.writer.Write(buf.Bytes())
+	}
 }
 
 func getRemoteAddr(r *http.Request) string {
-	if s := r.Header.Get(xForwardedFor); s != "" {
+	// Forwarding headers are only verified by realip filter, which has
+	// rewritten RemoteAddr if the peer is a trusted proxy. They are used as
+	// is when the filter is not configured.
+	if s := r.Header.Get(xForwardedFor); s != "" && !realip.Checked(r) {
 		return s
 	}
 	if idx := strings.LastIndex(r.RemoteAddr, ":"); idx != -1 {
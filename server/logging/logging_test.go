@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/goburrow/melon/server/filter"
+	"github.com/goburrow/melon/server/realip"
 )
 
 var today = time.Date(2015, time.January, 14, 1, 2, 3, 789000000, time.FixedZone("Asia/Ho_Chi_Minh", 7*60*60))
@@ -97,3 +98,132 @@ func TestResponseError(t *testing.T) {
 		t.Fatalf("unexpected access log %v", buf.String())
 	}
 }
+
+func TestFormatter(t *testing.T) {
+	entry := &Entry{
+		Time:       today,
+		RemoteAddr: "4.3.2.1",
+		Method:     "GET",
+		URI:        "/test?q=1",
+		Proto:      "HTTP/1.1",
+		Status:     200,
+		UserAgent:  "melon/1.0",
+		RequestID:  "go123",
+		Latency:    12 * time.Millisecond,
+	}
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{"common", `4.3.2.1 - - [14/Jan/2015:01:02:03 +0700] "GET /test?q=1 HTTP/1.1" 200 -` + "\n"},
+		{"combined", `4.3.2.1 - - [14/Jan/2015:01:02:03 +0700] "GET /test?q=1 HTTP/1.1" 200 - "-" "melon/1.0"` + "\n"},
+		{`{{.Method}} {{.URI}} {{.Status}} {{dash .Bytes}} {{ms .Latency}} {{.UserAgent}} {{.RequestID}}`, "GET /test?q=1 200 - 12 melon/1.0 go123\n"},
+	}
+	for _, test := range tests {
+		formatter, err := NewFormatter(test.format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err = formatter.Format(&buf, entry); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != test.expected {
+			t.Fatalf("unexpected access log of %v: %v", test.format, buf.String())
+		}
+	}
+	for _, format := range []string{"apache", "{{.Unknown"} {
+		if _, err := NewFormatter(format); err == nil {
+			t.Fatalf("expected error for format %v", format)
+		}
+	}
+}
+
+func TestOutputs(t *testing.T) {
+	var buf1, buf2 bytes.Buffer
+	common, _ := NewFormatter(FormatCommon)
+	custom, _ := NewFormatter("{{.Status}}")
+
+	chain := filter.NewChain()
+	chain.Add(NewFilter(&buf1, WithFormatter(common), WithOutput(&buf2, custom)))
+	chain.Add(http.NotFoundHandler())
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "1.2.3.4:5678"
+	chain.ServeHTTP(httptest.NewRecorder(), r)
+	expected := `1.2.3.4 - - [14/Jan/2015:01:02:03 +0700] "GET / HTTP/1.1" 404 19` + "\n"
+	if buf1.String() != expected {
+		t.Fatalf("unexpected access log %v", buf1.String())
+	}
+	if buf2.String() != "404\n" {
+		t.Fatalf("unexpected access log %v", buf2.String())
+	}
+}
+
+func TestFormatJSON(t *testing.T) {
+	var buf bytes.Buffer
+	formatter, err := NewFormatter(FormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chain := filter.NewChain()
+	chain.Add(NewFilter(&buf, WithFormatter(formatter)))
+	chain.Add(http.NotFoundHandler())
+
+	r := httptest.NewRequest("GET", "/users?id=\"1\"", nil)
+	r.RemoteAddr = "1.2.3.4:5678"
+	r.Header.Set("User-Agent", "melon/1.0")
+	r.Header.Set("X-Request-Id", "go123")
+	chain.ServeHTTP(httptest.NewRecorder(), r)
+	expected := `{"timestamp":"2015-01-14T01:02:03.789+07:00","method":"GET","path":"/users","query":"id=\"1\"",` +
+		`"proto":"HTTP/1.1","status":404,"bytes":19,"duration":0,"remoteIP":"1.2.3.4","requestID":"go123","userAgent":"melon/1.0"}` + "\n"
+	if buf.String() != expected {
+		t.Fatalf("unexpected access log %v", buf.String())
+	}
+}
+
+func TestExclusions(t *testing.T) {
+	var buf bytes.Buffer
+	custom, _ := NewFormatter("{{.Path}} {{.Status}}")
+
+	chain := filter.NewChain()
+	chain.Add(NewFilter(&buf, WithFormatter(custom),
+		WithExcludePath("/healthcheck"), WithExcludePath("/static/*"), WithExcludeStatus(300, 399)))
+	chain.Add(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			w.WriteHeader(http.StatusNotModified)
+		}
+	}))
+	for _, path := range []string{"/healthcheck", "/static/a.js", "/redirect", "/users", "/healthcheck/1"} {
+		chain.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	if buf.String() != "/users 200\n/healthcheck/1 200\n" {
+		t.Fatalf("unexpected access log %v", buf.String())
+	}
+}
+
+func TestUntrustedForwardedFor(t *testing.T) {
+	var buf bytes.Buffer
+	networks, _ := realip.ParseNetworks("10.0.0.0/8")
+	custom, _ := NewFormatter("{{.RemoteAddr}}")
+	chain := filter.NewChain()
+	chain.Add(realip.NewFilter(realip.WithTrusted(networks...)))
+	chain.Add(NewFilter(&buf, WithFormatter(custom)))
+	chain.Add(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	for _, peer := range []string{"1.2.3.4:1000", "10.0.0.1:1000"} {
+		buf.Reset()
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = peer
+		r.Header.Set("X-Forwarded-For", "5.6.7.8")
+		chain.ServeHTTP(httptest.NewRecorder(), r)
+		expected := "1.2.3.4\n"
+		if peer == "10.0.0.1:1000" {
+			expected = "5.6.7.8\n"
+		}
+		if buf.String() != expected {
+			t.Fatalf("unexpected access log of %s: %q", peer, buf.String())
+		}
+	}
+}
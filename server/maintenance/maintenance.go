@@ -0,0 +1,138 @@
+/*
+Package maintenance provides a filter which answers all requests with status
+503 (Service Unavailable) during planned downtime.
+
+Maintenance mode is switched by admin task "maintenance":
+
+	POST /tasks/maintenance?enabled=true
+	POST /tasks/maintenance?enabled=false
+*/
+package maintenance
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/goburrow/melon/core"
+	"github.com/goburrow/melon/server/backpressure"
+	"github.com/goburrow/melon/server/filter"
+)
+
+const (
+	taskName = "maintenance"
+
+	defaultMessage     = "Service is under maintenance."
+	defaultContentType = "text/plain; charset=utf-8"
+)
+
+// Mode is the switch of maintenance mode shared by the filter and the task.
+type Mode struct {
+	enabled int32
+}
+
+// Enabled returns true when the application is under maintenance.
+func (m *Mode) Enabled() bool {
+	return atomic.LoadInt32(&m.enabled) != 0
+}
+
+// SetEnabled turns maintenance mode on or off.
+func (m *Mode) SetEnabled(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+	if atomic.SwapInt32(&m.enabled, v) != v {
+		core.GetLogger("melon/server").Warnf("maintenance mode: %t", enabled)
+	}
+}
+
+// maintenanceFilter responds maintenance message when mode is enabled.
+type maintenanceFilter struct {
+	mode        *Mode
+	message     string
+	contentType string
+	retryAfter  time.Duration
+}
+
+// Option adds option for Filter.
+type Option func(f *maintenanceFilter)
+
+// NewFilter returns a Filter which rejects all requests when mode is enabled.
+func NewFilter(mode *Mode, options ...Option) filter.Filter {
+	f := &maintenanceFilter{
+		mode:        mode,
+		message:     defaultMessage,
+		contentType: defaultContentType,
+	}
+	for _, opt := range options {
+		opt(f)
+	}
+	return f
+}
+
+// WithMessage sets response body and its content type.
+func WithMessage(message, contentType string) Option {
+	return func(f *maintenanceFilter) {
+		f.message = message
+		if contentType != "" {
+			f.contentType = contentType
+		}
+	}
+}
+
+// WithRetryAfter sets header Retry-After of responses to the expected
+// remaining downtime.
+func WithRetryAfter(d time.Duration) Option {
+	return func(f *maintenanceFilter) {
+		f.retryAfter = d
+	}
+}
+
+func (f *maintenanceFilter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !f.mode.Enabled() {
+		filter.Continue(w, r)
+		return
+	}
+	h := w.Header()
+	if f.retryAfter > 0 {
+		backpressure.SetRetryAfter(h, f.retryAfter)
+	}
+	h.Set("Content-Type", f.contentType)
+	h.Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	fmt.Fprint(w, f.message)
+}
+
+// maintenanceTask gets and sets maintenance mode.
+type maintenanceTask struct {
+	mode *Mode
+}
+
+// NewTask returns admin task "maintenance" switching mode.
+func NewTask(mode *Mode) core.Task {
+	return &maintenanceTask{mode: mode}
+}
+
+func (*maintenanceTask) Name() string {
+	return taskName
+}
+
+func (t *maintenanceTask) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if v := r.URL.Query().Get("enabled"); v != "" {
+		if r.Method != "POST" {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, "Invalid enabled "+v, http.StatusBadRequest)
+			return
+		}
+		t.mode.SetEnabled(enabled)
+	}
+	fmt.Fprintf(w, "maintenance: %t\n", t.mode.Enabled())
+}
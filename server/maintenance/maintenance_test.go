@@ -0,0 +1,55 @@
+package maintenance
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/goburrow/melon/server/filter"
+)
+
+func TestFilter(t *testing.T) {
+	mode := &Mode{}
+	task := NewTask(mode)
+	chain := filter.NewChain()
+	chain.Add(NewFilter(mode, WithMessage(`{"message":"maintenance"}`, "application/json"), WithRetryAfter(time.Minute)))
+	chain.Add(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("melon"))
+	}))
+
+	w := httptest.NewRecorder()
+	chain.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "melon" {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	task.ServeHTTP(w, httptest.NewRequest("GET", "/tasks/maintenance?enabled=true", nil))
+	if w.Code != http.StatusMethodNotAllowed || mode.Enabled() {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	task.ServeHTTP(w, httptest.NewRequest("POST", "/tasks/maintenance?enabled=true", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "maintenance: true\n" {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	chain.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusServiceUnavailable || w.Body.String() != `{"message":"maintenance"}` ||
+		w.Header().Get("Content-Type") != "application/json" || w.Header().Get("Retry-After") != "60" {
+		t.Fatalf("unexpected response: %v %v %v", w.Code, w.Header(), w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	task.ServeHTTP(w, httptest.NewRequest("POST", "/tasks/maintenance?enabled=0", nil))
+	if w.Body.String() != "maintenance: false\n" {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	task.ServeHTTP(w, httptest.NewRequest("POST", "/tasks/maintenance?enabled=maybe", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Body.String())
+	}
+}
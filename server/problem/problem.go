@@ -0,0 +1,72 @@
+/*
+Package problem provides Problem Details for HTTP APIs (RFC 7807), which is the
+default representation of error responses.
+*/
+package problem
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+)
+
+const (
+	// ContentType is the media type of problem details in JSON.
+	ContentType = "application/problem+json"
+	// XMLContentType is the media type of problem details in XML.
+	XMLContentType = "application/problem+xml"
+
+	// DefaultType is the problem type when it is not specified.
+	DefaultType = "about:blank"
+)
+
+// Details is a problem details object. To add extension members, embed
+// Details in another struct.
+type Details struct {
+	XMLName  xml.Name `json:"-" xml:"urn:ietf:rfc:7807 problem"`
+	Type     string   `json:"type,omitempty" xml:"type,omitempty"`
+	Title    string   `json:"title,omitempty" xml:"title,omitempty"`
+	Status   int      `json:"status,omitempty" xml:"status,omitempty"`
+	Detail   string   `json:"detail,omitempty" xml:"detail,omitempty"`
+	Instance string   `json:"instance,omitempty" xml:"instance,omitempty"`
+}
+
+// New returns problem Details of status code with the status text as title.
+func New(status int, detail string) *Details {
+	return &Details{
+		Type:   DefaultType,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// ContentTypeOf returns problem media type for the given response media type,
+// which is ContentType for JSON and XMLContentType for XML. Other media types
+// are returned as is.
+func ContentTypeOf(mediaType string) string {
+	switch mediaType {
+	case "application/json", "text/json":
+		return ContentType
+	case "application/xml", "text/xml":
+		return XMLContentType
+	}
+	return mediaType
+}
+
+// Write writes v, which is usually Details, as JSON to w with status code.
+func Write(w http.ResponseWriter, status int, v interface{}) error {
+	h := w.Header()
+	h.Set("Content-Type", ContentType)
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(v)
+}
+
+// Error replies to the request with problem Details of status code, detail
+// and the request path as instance.
+func Error(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	p := New(status, detail)
+	p.Instance = r.URL.Path
+	Write(w, status, p)
+}
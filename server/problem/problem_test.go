@@ -0,0 +1,47 @@
+package problem
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestError(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/items/1?q=1", nil)
+	Error(w, r, http.StatusNotFound, "item 1 not found")
+	if w.Code != http.StatusNotFound || w.Header().Get("Content-Type") != ContentType {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Header())
+	}
+	expected := `{"type":"about:blank","title":"Not Found","status":404,"detail":"item 1 not found","instance":"/items/1"}`
+	if strings.TrimSpace(w.Body.String()) != expected {
+		t.Fatalf("unexpected body: %v", w.Body.String())
+	}
+}
+
+func TestXML(t *testing.T) {
+	b, err := xml.Marshal(New(http.StatusBadRequest, "invalid"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `<problem xmlns="urn:ietf:rfc:7807"><type>about:blank</type><title>Bad Request</title>` +
+		`<status>400</status><detail>invalid</detail></problem>`
+	if string(b) != expected {
+		t.Fatalf("unexpected xml: %s", b)
+	}
+}
+
+func TestContentTypeOf(t *testing.T) {
+	tests := map[string]string{
+		"application/json": ContentType,
+		"text/xml":         XMLContentType,
+		"text/plain":       "text/plain",
+	}
+	for mediaType, expected := range tests {
+		if actual := ContentTypeOf(mediaType); actual != expected {
+			t.Fatalf("unexpected content type of %v: %v", mediaType, actual)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+/*
+Package ratelimit provides a filter which limits request rate of each client
+using token buckets.
+
+Clients are identified by remote IP address or by value of a request header,
+such as an API key. Rejected requests are responded with status 429 and header
+Retry-After. Counters HTTP.RateLimit.Allowed and HTTP.RateLimit.Rejected are
+exposed in metrics.
+*/
+package ratelimit
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/codahale/metrics"
+	"github.com/goburrow/melon/server/backpressure"
+	"github.com/goburrow/melon/server/filter"
+)
+
+// sweepInterval is the interval to remove idle buckets.
+const sweepInterval = time.Minute
+
+// For testing
+var now = time.Now
+
+// KeyFunc returns identity of the client sending the request.
+type KeyFunc func(r *http.Request) string
+
+// RemoteIP returns the IP address of request remote address.
+func RemoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Header returns KeyFunc which uses the given request header as the key.
+// Requests without the header are identified by remote IP address.
+func Header(name string) KeyFunc {
+	return func(r *http.Request) string {
+		if v := r.Header.Get(name); v != "" {
+			return name + ":" + v
+		}
+		return RemoteIP(r)
+	}
+}
+
+// bucket is a token bucket.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimitFilter limits request rate of each client.
+type rateLimitFilter struct {
+	rate    float64
+	burst   int
+	keyFunc KeyFunc
+	pattern string
+
+	allowed  metrics.Counter
+	rejected metrics.Counter
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// Option adds option for Filter.
+type Option func(f *rateLimitFilter)
+
+// NewFilter returns a Filter which allows rate requests per second on average
+// and burst requests at once for each client.
+func NewFilter(rate float64, burst int, options ...Option) filter.Filter {
+	if burst < 1 {
+		burst = 1
+	}
+	f := &rateLimitFilter{
+		rate:    rate,
+		burst:   burst,
+		keyFunc: RemoteIP,
+		pattern: "/*",
+
+		allowed:  metrics.Counter("HTTP.RateLimit.Allowed"),
+		rejected: metrics.Counter("HTTP.RateLimit.Rejected"),
+
+		buckets:   make(map[string]*bucket),
+		lastSweep: now(),
+	}
+	for _, opt := range options {
+		opt(f)
+	}
+	return f
+}
+
+// WithKeyFunc sets the function identifying clients. Default is RemoteIP.
+func WithKeyFunc(k KeyFunc) Option {
+	return func(f *rateLimitFilter) {
+		f.keyFunc = k
+	}
+}
+
+// WithPath only limits requests matching the path pattern.
+func WithPath(pattern string) Option {
+	return func(f *rateLimitFilter) {
+		f.pattern = pattern
+	}
+}
+
+func (f *rateLimitFilter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !filter.MatchPath(f.pattern, r.URL.Path) {
+		filter.Continue(w, r)
+		return
+	}
+	ok, tokens := f.take(f.keyFunc(r))
+	if !ok {
+		f.rejected.Add()
+		backpressure.TooManyRequests(w, r, backpressure.RateDelay(tokens, f.rate))
+		return
+	}
+	f.allowed.Add()
+	filter.Continue(w, r)
+}
+
+// take takes a token from the bucket of the key. It returns false and
+// remaining tokens when the bucket is empty.
+func (f *rateLimitFilter) take(key string) (bool, float64) {
+	t := now()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if t.Sub(f.lastSweep) >= sweepInterval {
+		f.sweep(t)
+	}
+	b, ok := f.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(f.burst), last: t}
+		f.buckets[key] = b
+	} else {
+		f.refill(b, t)
+	}
+	if b.tokens < 1 {
+		return false, b.tokens
+	}
+	b.tokens--
+	return true, b.tokens
+}
+
+func (f *rateLimitFilter) refill(b *bucket, t time.Time) {
+	b.tokens += t.Sub(b.last).Seconds() * f.rate
+	if b.tokens > float64(f.burst) {
+		b.tokens = float64(f.burst)
+	}
+	b.last = t
+}
+
+// sweep removes buckets which are full as they are identical to new ones.
+func (f *rateLimitFilter) sweep(t time.Time) {
+	for key, b := range f.buckets {
+		f.refill(b, t)
+		if b.tokens >= float64(f.burst) {
+			delete(f.buckets, key)
+		}
+	}
+	f.lastSweep = t
+}
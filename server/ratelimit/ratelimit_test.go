@@ -0,0 +1,75 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/goburrow/melon/server/filter"
+)
+
+func TestFilter(t *testing.T) {
+	current := time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC)
+	now = func() time.Time {
+		return current
+	}
+	defer func() {
+		now = time.Now
+	}()
+	f := NewFilter(2, 3, WithKeyFunc(Header("X-Api-Key")), WithPath("/api/*"))
+	chain := filter.NewChain()
+	chain.Add(f, http.NotFoundHandler())
+
+	serve := func(path, key string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", path, nil)
+		if key != "" {
+			r.Header.Set("X-Api-Key", key)
+		}
+		chain.ServeHTTP(w, r)
+		return w
+	}
+	for i := 0; i < 3; i++ {
+		if w := serve("/api/users", "a"); w.Code != http.StatusNotFound {
+			t.Fatalf("unexpected status: %v", w.Code)
+		}
+	}
+	w := serve("/api/users", "a")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Header())
+	}
+	// Other clients and paths are not affected.
+	if w = serve("/api/users", "b"); w.Code != http.StatusNotFound {
+		t.Fatalf("unexpected status: %v", w.Code)
+	}
+	if w = serve("/other", "a"); w.Code != http.StatusNotFound {
+		t.Fatalf("unexpected status: %v", w.Code)
+	}
+	current = current.Add(500 * time.Millisecond)
+	if w = serve("/api/users", "a"); w.Code != http.StatusNotFound {
+		t.Fatalf("unexpected status: %v", w.Code)
+	}
+	if w = serve("/api/users", "a"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("unexpected status: %v", w.Code)
+	}
+	// Idle buckets are removed.
+	current = current.Add(sweepInterval)
+	serve("/api/users", "")
+	rf := f.(*rateLimitFilter)
+	if len(rf.buckets) != 1 {
+		t.Fatalf("unexpected buckets: %v", rf.buckets)
+	}
+}
+
+func TestRemoteIP(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "[::1]:1234"
+	if ip := RemoteIP(r); ip != "::1" {
+		t.Fatalf("unexpected ip: %v", ip)
+	}
+	r.RemoteAddr = "127.0.0.1"
+	if ip := RemoteIP(r); ip != "127.0.0.1" {
+		t.Fatalf("unexpected ip: %v", ip)
+	}
+}
@@ -0,0 +1,207 @@
+/*
+Package realip provides a filter which resolves the real client IP address of
+requests forwarded by trusted proxies.
+
+When the peer address of a request is in a trusted network, the client address
+is taken from header Forwarded, X-Forwarded-For or X-Real-IP in that order.
+Addresses in forwarding headers are walked from the nearest to the farthest
+proxy and the first one which is not trusted is the client. The filter then
+rewrites RemoteAddr of the request so that following filters and handlers see
+the client address. Headers of requests from untrusted peers are ignored as
+they can be forged.
+*/
+package realip
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/goburrow/melon/server/filter"
+)
+
+// realIPFilter resolves client IP address.
+type realIPFilter struct {
+	trusted []*net.IPNet
+}
+
+// Option adds option for Filter.
+type Option func(f *realIPFilter)
+
+// NewFilter returns a Filter which rewrites RemoteAddr of requests from
+// trusted proxies. It has priority filter.PriorityClientIP.
+func NewFilter(options ...Option) filter.Filter {
+	f := &realIPFilter{}
+	for _, opt := range options {
+		opt(f)
+	}
+	return f
+}
+
+// WithTrusted adds trusted proxy networks.
+func WithTrusted(networks ...*net.IPNet) Option {
+	return func(f *realIPFilter) {
+		f.trusted = append(f.trusted, networks...)
+	}
+}
+
+// ParseNetworks parses IP addresses and networks in CIDR notation,
+// e.g. 10.0.0.0/8 or ::1.
+func ParseNetworks(values ...string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(values))
+	for _, v := range values {
+		if !strings.Contains(v, "/") {
+			ip := net.ParseIP(v)
+			if ip == nil {
+				return nil, fmt.Errorf("realip: invalid address %v", v)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip = ip4
+				bits = 8 * net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(v)
+		if err != nil {
+			return nil, fmt.Errorf("realip: invalid network %v", v)
+		}
+		networks = append(networks, n)
+	}
+	return networks, nil
+}
+
+// Priority returns filter.PriorityClientIP.
+func (f *realIPFilter) Priority() int {
+	return filter.PriorityClientIP
+}
+
+func (f *realIPFilter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	host, port, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !f.isTrusted(net.ParseIP(host)) {
+		filter.Continue(w, withResolved(r, false))
+		return
+	}
+	ip := f.clientIP(r.Header)
+	if ip == nil {
+		filter.Continue(w, withResolved(r, false))
+		return
+	}
+	r = withResolved(r, true)
+	if port == "" {
+		r.RemoteAddr = ip.String()
+	} else {
+		r.RemoteAddr = net.JoinHostPort(ip.String(), port)
+	}
+	filter.Continue(w, r)
+}
+
+// clientIP returns the first untrusted address from the nearest proxy or
+// the farthest address if all are trusted.
+func (f *realIPFilter) clientIP(header http.Header) net.IP {
+	addrs := forwardedFor(header)
+	if len(addrs) == 0 {
+		addrs = xForwardedFor(header)
+	}
+	if len(addrs) == 0 {
+		if ip := net.ParseIP(strings.TrimSpace(header.Get("X-Real-Ip"))); ip != nil {
+			return ip
+		}
+		return nil
+	}
+	for i := len(addrs) - 1; i >= 0; i-- {
+		ip := net.ParseIP(addrs[i])
+		if ip == nil {
+			// Invalid or obfuscated address cannot be trusted.
+			return nil
+		}
+		if i == 0 || !f.isTrusted(ip) {
+			return ip
+		}
+	}
+	return nil
+}
+
+func (f *realIPFilter) isTrusted(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range f.trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedFor returns addresses in parameter "for" of header Forwarded (RFC 7239).
+func forwardedFor(header http.Header) []string {
+	var addrs []string
+	for _, h := range header["Forwarded"] {
+		for _, element := range strings.Split(h, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				pair = strings.TrimSpace(pair)
+				if len(pair) < 4 || !strings.EqualFold(pair[:4], "for=") {
+					continue
+				}
+				addrs = append(addrs, stripPort(strings.Trim(pair[4:], `"`)))
+			}
+		}
+	}
+	return addrs
+}
+
+// xForwardedFor returns addresses in header X-Forwarded-For.
+func xForwardedFor(header http.Header) []string {
+	var addrs []string
+	for _, h := range header["X-Forwarded-For"] {
+		for _, addr := range strings.Split(h, ",") {
+			if addr = strings.TrimSpace(addr); addr != "" {
+				addrs = append(addrs, stripPort(addr))
+			}
+		}
+	}
+	return addrs
+}
+
+// stripPort removes port and brackets of IPv6 address.
+func stripPort(addr string) string {
+	if strings.HasPrefix(addr, "[") {
+		if i := strings.IndexByte(addr, ']'); i > 0 {
+			return addr[1:i]
+		}
+		return addr
+	}
+	if strings.Count(addr, ":") == 1 {
+		return addr[:strings.IndexByte(addr, ':')]
+	}
+	return addr
+}
+
+type contextKey struct{}
+
+// withResolved records in request context that the request has been checked
+// by the filter and whether its RemoteAddr is rewritten.
+func withResolved(r *http.Request, resolved bool) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), contextKey{}, resolved))
+}
+
+// Resolved returns true if RemoteAddr of the request has been rewritten by the filter.
+func Resolved(r *http.Request) bool {
+	v, _ := r.Context().Value(contextKey{}).(bool)
+	return v
+}
+
+// Checked returns true if the request has passed through the filter, even if
+// it is not sent by a trusted proxy. Forwarding headers of such requests must
+// not be used as the client address.
+func Checked(r *http.Request) bool {
+	_, ok := r.Context().Value(contextKey{}).(bool)
+	return ok
+}
@@ -0,0 +1,64 @@
+package realip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/goburrow/melon/server/filter"
+)
+
+func TestParseNetworks(t *testing.T) {
+	networks, err := ParseNetworks("10.0.0.0/8", "127.0.0.1", "::1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(networks) != 3 || networks[1].String() != "127.0.0.1/32" || networks[2].String() != "::1/128" {
+		t.Fatalf("unexpected networks: %v", networks)
+	}
+	for _, v := range []string{"10.0.0.0/33", "localhost"} {
+		if _, err = ParseNetworks(v); err == nil {
+			t.Fatalf("expected error for %v", v)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	networks, _ := ParseNetworks("10.0.0.0/8", "192.168.1.1")
+	var remoteAddr string
+	var resolved, checked bool
+	chain := filter.NewChain()
+	chain.Add(NewFilter(WithTrusted(networks...)))
+	chain.Add(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remoteAddr = r.RemoteAddr
+		resolved = Resolved(r)
+		checked = Checked(r)
+	}))
+
+	tests := []struct {
+		peer     string
+		headers  map[string]string
+		expected string
+	}{
+		{"1.2.3.4:1000", map[string]string{"X-Forwarded-For": "5.6.7.8"}, "1.2.3.4:1000"},
+		{"10.0.0.1:1000", nil, "10.0.0.1:1000"},
+		{"10.0.0.1:1000", map[string]string{"X-Forwarded-For": "5.6.7.8"}, "5.6.7.8:1000"},
+		{"10.0.0.1:1000", map[string]string{"X-Forwarded-For": "9.9.9.9, 5.6.7.8, 192.168.1.1"}, "5.6.7.8:1000"},
+		{"10.0.0.1:1000", map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"}, "10.0.0.3:1000"},
+		{"10.0.0.1:1000", map[string]string{"X-Forwarded-For": "unknown"}, "10.0.0.1:1000"},
+		{"10.0.0.1:1000", map[string]string{"X-Real-IP": "5.6.7.8"}, "5.6.7.8:1000"},
+		{"10.0.0.1:1000", map[string]string{"Forwarded": `for=9.9.9.9, for="[2001:db8::17]:4711";proto=https`, "X-Forwarded-For": "1.1.1.1"}, "[2001:db8::17]:1000"},
+		{"10.0.0.1:1000", map[string]string{"Forwarded": "for=5.6.7.8:80;by=10.0.0.1"}, "5.6.7.8:1000"},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = test.peer
+		for k, v := range test.headers {
+			r.Header.Set(k, v)
+		}
+		chain.ServeHTTP(httptest.NewRecorder(), r)
+		if remoteAddr != test.expected || resolved != (test.peer != test.expected) || !checked {
+			t.Fatalf("unexpected remote address of %+v: %v %v", test, remoteAddr, resolved)
+		}
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/codahale/metrics"
 	"github.com/goburrow/melon/core"
 	"github.com/goburrow/melon/server/filter"
+	"github.com/goburrow/melon/server/problem"
 )
 
 const (
@@ -19,24 +20,62 @@ const (
 	stackMax  = 50
 )
 
+// ErrorMapper writes response for the error recovered from a panic.
+// It has the same method as views.ErrorMapper.
+type ErrorMapper interface {
+	MapError(http.ResponseWriter, *http.Request, error)
+}
+
 // recoveryFilter handles panics.
 type recoveryFilter struct {
-	panics metrics.Counter
+	panics      metrics.Counter
+	errorMapper ErrorMapper
 }
 
+// Option adds option for Filter.
+type Option func(f *recoveryFilter)
+
 // NewFilter returns a Filter whichs recovers and logs panics from HTTP handler.
-func NewFilter() filter.Filter {
-	return &recoveryFilter{
+func NewFilter(options ...Option) filter.Filter {
+	f := &recoveryFilter{
 		panics: metrics.Counter("HTTP.Panics"),
 	}
+	for _, opt := range options {
+		opt(f)
+	}
+	return f
+}
+
+// WithErrorMapper sets the mapper for rendering response of recovered panics.
+// By default, response is problem details with status 500.
+func WithErrorMapper(m ErrorMapper) Option {
+	return func(f *recoveryFilter) {
+		f.errorMapper = m
+	}
 }
 
 func (f *recoveryFilter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	defer func() {
 		if err := recover(); err != nil {
 			f.panics.Add()
-			core.GetLogger("melon/server").Errorf("%v\n%s", err, stack())
-			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			st := stack()
+			core.GetLogger("melon/server").Errorf("%v\n%s", err, st)
+			// Keep the panic error so that error mappers can match it.
+			e, ok := err.(error)
+			if !ok {
+				e = fmt.Errorf("%v", err)
+			}
+			core.ReportError(&core.ErrorEvent{
+				Source:  "panic",
+				Err:     e,
+				Stack:   st,
+				Request: r,
+			})
+			if f.errorMapper != nil {
+				f.errorMapper.MapError(w, r, e)
+			} else {
+				problem.Error(w, r, http.StatusInternalServerError, "")
+			}
 		}
 	}()
 	filter.Continue(w, r)
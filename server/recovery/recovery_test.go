@@ -1,6 +1,8 @@
 package recovery
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -46,6 +48,56 @@ func TestNilPointer(t *testing.T) {
 	testFilter(t, http.HandlerFunc(f))
 }
 
+type testErrorMapper struct{}
+
+func (testErrorMapper) MapError(w http.ResponseWriter, r *http.Request, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusInternalServerError)
+	fmt.Fprintf(w, "{\"error\":%q}", err.Error())
+}
+
+func TestErrorMapper(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+
+	chain := filter.NewChain()
+	chain.Add(NewFilter(WithErrorMapper(testErrorMapper{})), http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("failure")
+	}))
+	chain.ServeHTTP(w, r)
+	if w.Code != 500 {
+		t.Fatalf("unexpected code %v", w.Code)
+	}
+	if w.Body.String() != `{"error":"failure"}` {
+		t.Fatalf("unexpected body %v", w.Body.String())
+	}
+}
+
+type errorCapture struct {
+	err error
+}
+
+func (c *errorCapture) MapError(w http.ResponseWriter, r *http.Request, err error) {
+	c.err = err
+	w.WriteHeader(http.StatusNotFound)
+}
+
+func TestPanicError(t *testing.T) {
+	errNotFound := errors.New("not found")
+	mapper := &errorCapture{}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+
+	chain := filter.NewChain()
+	chain.Add(NewFilter(WithErrorMapper(mapper)), http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic(errNotFound)
+	}))
+	chain.ServeHTTP(w, r)
+	if w.Code != 404 || mapper.err != errNotFound {
+		t.Fatalf("unexpected response %v %v", w.Code, mapper.err)
+	}
+}
+
 func testFilter(t *testing.T, h http.Handler) {
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest("GET", "/", nil)
@@ -59,7 +111,8 @@ func testFilter(t *testing.T, h http.Handler) {
 	if w.Code != 500 {
 		t.Fatalf("unexpected code %v", w.Code)
 	}
-	if strings.TrimSpace(w.Body.String()) != http.StatusText(http.StatusInternalServerError) {
+	if w.Header().Get("Content-Type") != "application/problem+json" ||
+		strings.TrimSpace(w.Body.String()) != `{"type":"about:blank","title":"Internal Server Error","status":500,"instance":"/"}` {
 		t.Fatalf("unexpected body %v", w.Body.String())
 	}
 }
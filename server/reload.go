@@ -0,0 +1,127 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/goburrow/melon/core"
+	"github.com/goburrow/melon/server/filter"
+)
+
+// reloadableFilter delegates requests to a filter which can be replaced
+// while the server is running. Requests are passed through when there is
+// no filter.
+type reloadableFilter struct {
+	mu      sync.RWMutex
+	current *filterGeneration
+}
+
+// filterGeneration is a filter with its resources and requests in flight.
+type filterGeneration struct {
+	filter  filter.Filter
+	closers []io.Closer
+	active  sync.WaitGroup
+}
+
+func newReloadableFilter(h filter.Filter, closers []io.Closer) *reloadableFilter {
+	return &reloadableFilter{
+		current: &filterGeneration{filter: h, closers: closers},
+	}
+}
+
+func (f *reloadableFilter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.RLock()
+	g := f.current
+	g.active.Add(1)
+	f.mu.RUnlock()
+	defer g.active.Done()
+	if g.filter == nil {
+		filter.Continue(w, r)
+		return
+	}
+	g.filter.ServeHTTP(w, r)
+}
+
+// set replaces the filter. Resources of the previous one are closed after
+// requests being handled by it finish.
+func (f *reloadableFilter) set(h filter.Filter, closers []io.Closer) {
+	f.mu.Lock()
+	previous := f.current
+	f.current = &filterGeneration{filter: h, closers: closers}
+	f.mu.Unlock()
+	if len(previous.closers) > 0 {
+		go func() {
+			previous.active.Wait()
+			closeAll(previous.closers)
+		}()
+	}
+}
+
+func closeAll(closers []io.Closer) {
+	for _, c := range closers {
+		c.Close()
+	}
+}
+
+// reloader is implemented by server factories supporting reloading.
+type reloader interface {
+	ReloadServer(core.ServerFactory) error
+}
+
+// ReloadServer applies request log and rate limit of factory n to the server
+// built by this factory. Type of the server must not be changed.
+func (factory *Factory) ReloadServer(n core.ServerFactory) error {
+	r, ok := factory.Value().(reloader)
+	if !ok {
+		return fmt.Errorf("server: reloading is not supported by %T", factory.Value())
+	}
+	if nf, ok := n.(*Factory); ok {
+		n, _ = nf.Value().(core.ServerFactory)
+	}
+	return r.ReloadServer(n)
+}
+
+// ReloadServer applies request log and rate limit of factory n.
+func (factory *DefaultFactory) ReloadServer(n core.ServerFactory) error {
+	nf, ok := n.(*DefaultFactory)
+	if !ok {
+		return fmt.Errorf("server: could not reload %T with %T", factory, n)
+	}
+	return factory.commonFactory.reload(&nf.commonFactory)
+}
+
+// ReloadServer applies request log and rate limit of factory n.
+func (factory *SimpleFactory) ReloadServer(n core.ServerFactory) error {
+	nf, ok := n.(*SimpleFactory)
+	if !ok {
+		return fmt.Errorf("server: could not reload %T with %T", factory, n)
+	}
+	return factory.commonFactory.reload(&nf.commonFactory)
+}
+
+// reload replaces request log and rate limit filters by the ones built from
+// configuration of n.
+func (f *commonFactory) reload(n *commonFactory) error {
+	requestLogFilter, closers, err := n.RequestLog.build()
+	if err != nil {
+		return err
+	}
+	rateLimitFilter, err := n.RateLimit.Build()
+	if err != nil {
+		closeAll(closers)
+		return err
+	}
+	if f.requestLogFilter != nil {
+		f.requestLogFilter.set(requestLogFilter, closers)
+	} else {
+		closeAll(closers)
+	}
+	if f.rateLimitFilter != nil {
+		f.rateLimitFilter.set(rateLimitFilter, nil)
+	}
+	f.RequestLog = n.RequestLog
+	f.RateLimit = n.RateLimit
+	return nil
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/goburrow/melon/server/router"
+)
+
+func TestReloadServer(t *testing.T) {
+	factory := &DefaultFactory{}
+	handler := router.New()
+	handler.Handle("GET", "/", http.NotFoundHandler())
+	if err := factory.AddRateLimitFilter(handler); err != nil {
+		t.Fatal(err)
+	}
+	request := func() int {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		return w.Code
+	}
+	for i := 0; i < 3; i++ {
+		if status := request(); status != http.StatusNotFound {
+			t.Fatalf("unexpected status of request %d: %v", i, status)
+		}
+	}
+	reloaded := &DefaultFactory{}
+	reloaded.RateLimit = RateLimitConfiguration{Enabled: true, Rate: 1}
+	if err := factory.ReloadServer(reloaded); err != nil {
+		t.Fatal(err)
+	}
+	for i, status := range []int{http.StatusNotFound, http.StatusTooManyRequests} {
+		if s := request(); s != status {
+			t.Fatalf("unexpected status of request %d: %v", i, s)
+		}
+	}
+	if !factory.RateLimit.Enabled {
+		t.Fatalf("unexpected configuration: %+v", factory.RateLimit)
+	}
+
+	reloaded.RateLimit.Rate = 0
+	if err := factory.ReloadServer(reloaded); err == nil {
+		t.Fatal("error expected")
+	}
+	if err := factory.ReloadServer(&SimpleFactory{}); err == nil {
+		t.Fatal("error expected")
+	}
+	if err := (&Factory{}).ReloadServer(reloaded); err == nil {
+		t.Fatal("error expected")
+	}
+}
+
+type testCloser chan struct{}
+
+func (c testCloser) Close() error {
+	close(c)
+	return nil
+}
+
+func TestReloadableFilterClose(t *testing.T) {
+	closer := make(testCloser)
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	f := newReloadableFilter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+	}), []io.Closer{closer})
+	done := make(chan struct{})
+	go func() {
+		f.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		close(done)
+	}()
+	<-entered
+	f.set(nil, nil)
+	select {
+	case <-closer:
+		t.Fatal("closed while handling request")
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(release)
+	<-done
+	select {
+	case <-closer:
+	case <-time.After(time.Second):
+		t.Fatal("not closed after request finished")
+	}
+}
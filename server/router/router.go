@@ -4,12 +4,16 @@ Package router supports dynamic routes for http server.
 package router
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"net/http"
+	"net/url"
 	"path"
 	"strings"
 
 	"github.com/goburrow/melon/server/filter"
+	"github.com/goburrow/melon/server/problem"
 	"github.com/gorilla/mux"
 )
 
@@ -23,17 +27,22 @@ type Router struct {
 
 	pathPrefix string
 	endpoints  []string
+	// names contains route patterns by their names.
+	names map[string]string
 }
 
 // New creates a new Router.
 func New(options ...Option) *Router {
 	serveMux := mux.NewRouter()
+	serveMux.NotFoundHandler = http.HandlerFunc(notFound)
+	serveMux.MethodNotAllowedHandler = http.HandlerFunc(methodNotAllowed)
 	chain := filter.NewChain()
 	chain.Add(serveMux)
 
 	r := &Router{
 		serveMux:    serveMux,
 		filterChain: chain,
+		names:       make(map[string]string),
 	}
 	for _, opt := range options {
 		opt(r)
@@ -44,7 +53,7 @@ func New(options ...Option) *Router {
 // Handle registers the handler for the given pattern.
 func (h *Router) Handle(method, pattern string, handler http.Handler) {
 	r := h.serveMux.NewRoute()
-	r.Handler(handler)
+	r.Handler(&routeHandler{pattern: pattern, handler: handler})
 	if method != "" && method != "*" {
 		r.Methods(method)
 	}
@@ -54,10 +63,98 @@ func (h *Router) Handle(method, pattern string, handler http.Handler) {
 		r.Path(pattern)
 	}
 	// log endpoint
-	endpoint := fmt.Sprintf("%-7s %s%s (%T)", method, h.pathPrefix, pattern, handler)
+	var endpoint string
+	if s, ok := handler.(fmt.Stringer); ok {
+		endpoint = fmt.Sprintf("%-7s %s%s (%s)", method, h.pathPrefix, pattern, s)
+	} else {
+		endpoint = fmt.Sprintf("%-7s %s%s (%T)", method, h.pathPrefix, pattern, handler)
+	}
 	h.endpoints = append(h.endpoints, endpoint)
 }
 
+// HandleRedirect registers a handler which redirects requests from path from to
+// path to with the given HTTP status code. If from ends with "*", the rest of
+// the request path is appended to to. Absolute path to is relative to the path
+// prefix of the router.
+func (h *Router) HandleRedirect(from, to string, status int) {
+	handler := &redirectHandler{
+		to:     to,
+		status: status,
+	}
+	if strings.HasSuffix(from, "*") {
+		handler.prefix = from[:len(from)-1]
+	}
+	if strings.HasPrefix(to, "/") {
+		handler.to = h.pathPrefix + to
+	}
+	h.Handle("*", from, handler)
+}
+
+// Name names the route pattern so that its URL can be built with URL.
+func (h *Router) Name(name, pattern string) {
+	h.names[name] = pattern
+}
+
+// URL returns the path of the named route, including path prefix of the router.
+// Path variables in the route pattern are replaced by params, which are pairs
+// of variable name and value.
+func (h *Router) URL(name string, params ...string) (string, error) {
+	pattern, ok := h.names[name]
+	if !ok {
+		return "", fmt.Errorf("router: route not found %q", name)
+	}
+	if len(params)%2 != 0 {
+		return "", fmt.Errorf("router: params must be pairs of name and value: %v", params)
+	}
+	values := make(map[string]string, len(params)/2)
+	for i := 0; i < len(params); i += 2 {
+		values[params[i]] = params[i+1]
+	}
+	pattern = strings.TrimSuffix(pattern, "*")
+	var buf bytes.Buffer
+	buf.WriteString(h.pathPrefix)
+	for len(pattern) > 0 {
+		start := strings.IndexByte(pattern, '{')
+		if start < 0 {
+			buf.WriteString(pattern)
+			break
+		}
+		end := variableEnd(pattern, start)
+		if end < 0 {
+			return "", fmt.Errorf("router: unbalanced braces in %q", h.names[name])
+		}
+		buf.WriteString(pattern[:start])
+		variable := pattern[start+1 : end]
+		if i := strings.IndexByte(variable, ':'); i >= 0 {
+			variable = variable[:i]
+		}
+		value, ok := values[variable]
+		if !ok {
+			return "", fmt.Errorf("router: missing variable %q of route %q", variable, name)
+		}
+		buf.WriteString(url.PathEscape(value))
+		pattern = pattern[end+1:]
+	}
+	return buf.String(), nil
+}
+
+// variableEnd returns index of the brace closing the variable started at start.
+func variableEnd(pattern string, start int) int {
+	level := 0
+	for i := start; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '{':
+			level++
+		case '}':
+			level--
+			if level == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
 // PathPrefix returns server root context path.
 func (h *Router) PathPrefix() string {
 	return h.pathPrefix
@@ -68,6 +165,25 @@ func (h *Router) Endpoints() []string {
 	return h.endpoints
 }
 
+// Filters returns registered filters in execution order.
+func (h *Router) Filters() []filter.Filter {
+	// The last one is server mux.
+	n := h.filterChain.Length() - 1
+	filters := make([]filter.Filter, n)
+	for i := range filters {
+		filters[i] = h.filterChain.Get(i)
+	}
+	return filters
+}
+
+// VisitFilters calls fn with each registered filter, unwrapped by
+// filter.Unwrap, and its priority in execution order.
+func (h *Router) VisitFilters(fn func(f http.Handler, priority int)) {
+	for _, f := range h.Filters() {
+		fn(filter.Unwrap(f), filter.PriorityOf(f))
+	}
+}
+
 // ServeHTTP strips path prefix in the request and executes filter chain,
 // which should include ServeMux as the last one.
 func (h *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -81,10 +197,30 @@ func (h *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	h.filterChain.ServeHTTP(w, r)
 }
 
-// AddFilter adds a filter middleware.
+// AddFilter adds a filter middleware. The filter is executed after filters
+// having the same or lower priority and before ones having higher priority.
+// See filter.PriorityOf.
 func (h *Router) AddFilter(f filter.Filter) {
 	// Filter f is always added before the last filter, which is server mux.
-	h.filterChain.Insert(f, h.filterChain.Length()-1)
+	idx := h.filterChain.Length() - 1
+	priority := filter.PriorityOf(f)
+	for i := 0; i < idx; i++ {
+		if filter.PriorityOf(h.filterChain.Get(i)) > priority {
+			idx = i
+			break
+		}
+	}
+	h.filterChain.Insert(f, idx)
+}
+
+// notFound replies problem details with status 404.
+func notFound(w http.ResponseWriter, r *http.Request) {
+	problem.Error(w, r, http.StatusNotFound, "")
+}
+
+// methodNotAllowed replies problem details with status 405.
+func methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	problem.Error(w, r, http.StatusMethodNotAllowed, "")
 }
 
 // Option is router options.
@@ -110,3 +246,76 @@ func WithPathPrefix(prefix string) Option {
 func PathParams(r *http.Request) map[string]string {
 	return mux.Vars(r)
 }
+
+// Redirect is a resource which redirects requests from one path to another.
+// It can be registered to the server environment.
+type Redirect struct {
+	From   string
+	To     string
+	Status int
+}
+
+// NewRedirect returns a new Redirect with given status code.
+func NewRedirect(from, to string, status int) *Redirect {
+	return &Redirect{
+		From:   from,
+		To:     to,
+		Status: status,
+	}
+}
+
+// NewPermanentRedirect returns a new Redirect with status code 301.
+func NewPermanentRedirect(from, to string) *Redirect {
+	return NewRedirect(from, to, http.StatusMovedPermanently)
+}
+
+// redirectHandler redirects requests to the target url.
+type redirectHandler struct {
+	to     string
+	status int
+	// prefix is set when the remaining path is appended to the target.
+	prefix string
+}
+
+func (h *redirectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	to := h.to
+	if h.prefix != "" {
+		to += strings.TrimPrefix(r.URL.Path, h.prefix)
+	}
+	if r.URL.RawQuery != "" {
+		to += "?" + r.URL.RawQuery
+	}
+	http.Redirect(w, r, to, h.status)
+}
+
+// routeContextKey is the context key for recording matched route.
+type routeContextKey struct{}
+
+// NewRouteContext returns a copy of ctx which records the pattern of the route
+// handling requests with the context. It is used by filters, which are executed
+// before routing, to get the matched route after calling the next filter.
+func NewRouteContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, routeContextKey{}, new(string))
+}
+
+// Route returns the pattern of the route which has handled the request with
+// context created by NewRouteContext, or empty if no route is matched.
+func Route(ctx context.Context) string {
+	if p, ok := ctx.Value(routeContextKey{}).(*string); ok {
+		return *p
+	}
+	return ""
+}
+
+// routeHandler records its pattern to the request context.
+type routeHandler struct {
+	pattern string
+	handler http.Handler
+}
+
+func (h *routeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if p, ok := r.Context().Value(routeContextKey{}).(*string); ok {
+		*p = h.pattern
+	}
+	h.handler.ServeHTTP(w, r)
+}
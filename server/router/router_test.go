@@ -1,10 +1,13 @@
 package router
 
 import (
+	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/goburrow/melon/core"
+	"github.com/goburrow/melon/server/filter"
 )
 
 var _ core.Router = (*Router)(nil)
@@ -26,3 +29,143 @@ func TestPathPrefix(t *testing.T) {
 		}
 	}
 }
+
+func TestRedirect(t *testing.T) {
+	r := New(WithPathPrefix("/app"))
+	r.HandleRedirect("/old", "/new", http.StatusFound)
+	r.HandleRedirect("/docs/*", "/documents/", http.StatusMovedPermanently)
+	r.HandleRedirect("/home", "http://example.com/", http.StatusTemporaryRedirect)
+
+	tests := []struct {
+		path     string
+		status   int
+		location string
+	}{
+		{"/app/old?a=b", http.StatusFound, "/app/new?a=b"},
+		{"/app/docs/a/b", http.StatusMovedPermanently, "/app/documents/a/b"},
+		{"/app/home", http.StatusTemporaryRedirect, "http://example.com/"},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", test.path, nil))
+		if w.Code != test.status {
+			t.Errorf("unexpected status: %v, want: %v", w.Code, test.status)
+		}
+		if w.Header().Get("Location") != test.location {
+			t.Errorf("unexpected location: %v, want: %v", w.Header().Get("Location"), test.location)
+		}
+	}
+}
+
+func TestRoute(t *testing.T) {
+	var route string
+	handler := New()
+	handler.Handle("GET", "/users/{id}", http.NotFoundHandler())
+	handler.AddFilter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = r.WithContext(NewRouteContext(r.Context()))
+		filter.Continue(w, r)
+		route = Route(r.Context())
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users/1", nil))
+	if route != "/users/{id}" {
+		t.Fatalf("unexpected route: %v", route)
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/other", nil))
+	if route != "" {
+		t.Fatalf("unexpected route: %v", route)
+	}
+}
+
+type testFilter string
+
+func (s testFilter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(s))
+	filter.Continue(w, r)
+}
+
+func TestFilterPriority(t *testing.T) {
+	handler := New()
+	handler.Handle("GET", "/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("END"))
+	}))
+	handler.AddFilter(testFilter("d"))
+	handler.AddFilter(filter.WithPriority(testFilter("c"), filter.PriorityRateLimit))
+	handler.AddFilter(filter.WithPriority(testFilter("a"), filter.PriorityRequestLog))
+	handler.AddFilter(filter.WithPriority(testFilter("b"), filter.PriorityAuthentication))
+	handler.AddFilter(filter.WithPriority(testFilter("a2"), filter.PriorityRequestLog))
+	handler.AddFilter(testFilter("e"))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Body.String() != "aa2bcdeEND" {
+		t.Fatalf("unexpected body: %v", w.Body.String())
+	}
+	filters := handler.Filters()
+	if len(filters) != 6 || filter.Unwrap(filters[0]) != testFilter("a") || filters[5] != testFilter("e") {
+		t.Fatalf("unexpected filters: %v", filters)
+	}
+	var priorities []int
+	handler.VisitFilters(func(f http.Handler, priority int) {
+		if len(priorities) == 0 && f != testFilter("a") {
+			t.Fatalf("unexpected filter: %v", f)
+		}
+		priorities = append(priorities, priority)
+	})
+	if fmt.Sprint(priorities) != "[1000 1000 8000 9000 10000 10000]" {
+		t.Fatalf("unexpected priorities: %v", priorities)
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	r := New()
+	r.Handle("GET", "/items", http.NotFoundHandler())
+	tests := []struct {
+		method string
+		path   string
+		status int
+	}{
+		{"GET", "/users", http.StatusNotFound},
+		{"POST", "/items", http.StatusMethodNotAllowed},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(test.method, test.path, nil))
+		if w.Code != test.status || w.Header().Get("Content-Type") != "application/problem+json" {
+			t.Errorf("unexpected response: %v %v, want: %v", w.Code, w.Header(), test.status)
+		}
+	}
+}
+
+func TestURL(t *testing.T) {
+	r := New(WithPathPrefix("/app"))
+	r.Name("users", "/users")
+	r.Name("user", "/users/{id}")
+	r.Name("item", "/users/{id}/items/{item:[0-9]{1,3}}")
+	r.Name("files", "/files/*")
+
+	tests := []struct {
+		name   string
+		params []string
+		url    string
+	}{
+		{"users", nil, "/app/users"},
+		{"user", []string{"id", "a b"}, "/app/users/a%20b"},
+		{"item", []string{"item", "10", "id", "1"}, "/app/users/1/items/10"},
+		{"files", nil, "/app/files/"},
+	}
+	for _, test := range tests {
+		u, err := r.URL(test.name, test.params...)
+		if err != nil || u != test.url {
+			t.Errorf("unexpected url: %v %v, want: %v", u, err, test.url)
+		}
+	}
+	if _, err := r.URL("unknown"); err == nil {
+		t.Errorf("expected error for unknown route")
+	}
+	if _, err := r.URL("user"); err == nil {
+		t.Errorf("expected error for missing variable")
+	}
+	if _, err := r.URL("user", "id"); err == nil {
+		t.Errorf("expected error for odd params")
+	}
+}
@@ -7,36 +7,65 @@ import (
 	"context"
 	"crypto/tls"
 	"fmt"
+	"net"
 	"net/http"
 	"sync"
 	"time"
 
 	"github.com/goburrow/dynamic"
+	"github.com/goburrow/melon/configuration"
 	"github.com/goburrow/melon/core"
+	"github.com/goburrow/melon/server/decompress"
+	"github.com/goburrow/melon/server/filter"
 )
 
+// ServerTypes contains server factories selected by key "type" of server
+// configuration.
+var ServerTypes = configuration.NewRegistry("server", (*core.ServerFactory)(nil))
+
 func init() {
-	dynamic.Register("DefaultServer", func() interface{} {
+	ServerTypes.Register("DefaultServer", func() interface{} {
 		return newDefaultFactory()
 	})
-	dynamic.Register("SimpleServer", func() interface{} {
+	ServerTypes.Register("SimpleServer", func() interface{} {
 		return newSimpleFactory()
 	})
 }
 
 // Connector represents http server configuration.
 type Connector struct {
-	Type string `valid:"notempty"`
-	Addr string
+	Type string `valid:"notempty,oneof=http https"`
+	Addr string `valid:"hostport"`
+
+	CertFile string `file:"true"`
+	KeyFile  string `file:"true"`
+
+	// DecompressRequests enables decoding request bodies encoded in gzip or deflate.
+	DecompressRequests bool
+	// MaxDecompressedSize is the limit of decoded request bodies, e.g. 10MiB,
+	// default is 10MB.
+	MaxDecompressedSize configuration.Size `valid:"min=0"`
+
+	// Durations are given as e.g. "500ms" or "1m", or numbers of milliseconds.
 
-	CertFile string
-	KeyFile  string
+	// ReadTimeout is the maximum duration for reading requests including
+	// bodies.
+	ReadTimeout configuration.Duration `valid:"min=0"`
+	// WriteTimeout is the maximum duration before timing out writes of
+	// responses. It is also set as deadline of request contexts so handlers
+	// can stop before the connection is closed.
+	WriteTimeout configuration.Duration `valid:"min=0"`
+	// IdleTimeout is the maximum duration to wait for the next request when
+	// keep-alives are enabled.
+	IdleTimeout configuration.Duration `valid:"min=0"`
 }
 
 // server implements core.Managed interface. Each server can have multiple
 // connectors (listeners).
 type server struct {
 	connectors []*http.Server
+	// timeline is optional for recording duration of binding listeners.
+	timeline *core.Timeline
 }
 
 // newServer allocates and returns a new Server.
@@ -44,35 +73,58 @@ func newServer() *server {
 	return &server{}
 }
 
-// Start starts all connectors of the server.
+// Start binds all connectors of the server then serves them until they are
+// closed.
 func (s *server) Start() error {
+	started := time.Now()
+	listeners := make([]net.Listener, len(s.connectors))
+	for i, srv := range s.connectors {
+		addr := srv.Addr
+		if addr == "" {
+			addr = ":http"
+		}
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			for _, l := range listeners[:i] {
+				l.Close()
+			}
+			return fmt.Errorf("could not listen %s: %v", srv.Addr, err)
+		}
+		listeners[i] = ln
+		logger().Infof("listening %s", ln.Addr())
+	}
+	if s.timeline != nil {
+		s.timeline.Startup("bind listeners", started)
+		s.timeline.StartupDone()
+	}
+
 	wg := sync.WaitGroup{}
 	defer wg.Wait()
 
-	for _, conn := range s.connectors {
+	for i, conn := range s.connectors {
 		wg.Add(1)
-		go func(srv *http.Server) {
+		go func(srv *http.Server, ln net.Listener) {
 			defer wg.Done()
-			logger().Infof("listening %s", srv.Addr)
 			var err error
 			if srv.TLSConfig == nil {
-				err = srv.ListenAndServe()
+				err = srv.Serve(ln)
 			} else {
-				err = srv.ListenAndServeTLS("", "")
+				err = srv.ServeTLS(ln, "", "")
 			}
 			if err == http.ErrServerClosed {
 				logger().Infof("closed %s", srv.Addr)
 			} else if err != nil {
-				logger().Errorf("could not listen %s: %v", srv.Addr, err)
+				logger().Errorf("could not serve %s: %v", srv.Addr, err)
 			}
-		}(conn)
+		}(conn, listeners[i])
 	}
 	return nil
 }
 
 // Stop stops all running connectors of the server.
 func (s *server) Stop() error {
-	ctx, _ := context.WithTimeout(context.Background(), 60*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
 	for _, conn := range s.connectors {
 		conn.Shutdown(ctx)
 	}
@@ -92,9 +144,27 @@ func (s *server) addConnectors(handler http.Handler, connectors []Connector) err
 }
 
 func newHTTPServer(handler http.Handler, c *Connector) (*http.Server, error) {
+	if c.DecompressRequests {
+		var options []decompress.Option
+		if c.MaxDecompressedSize > 0 {
+			options = append(options, decompress.WithMaxSize(int64(c.MaxDecompressedSize)))
+		}
+		chain := filter.NewChain()
+		chain.Add(decompress.NewFilter(options...), handler)
+		handler = chain
+	}
+	if c.WriteTimeout > 0 {
+		handler = &deadlineHandler{
+			handler: handler,
+			timeout: time.Duration(c.WriteTimeout),
+		}
+	}
 	httpServer := &http.Server{
-		Addr:    c.Addr,
-		Handler: handler,
+		Addr:         c.Addr,
+		Handler:      handler,
+		ReadTimeout:  time.Duration(c.ReadTimeout),
+		WriteTimeout: time.Duration(c.WriteTimeout),
+		IdleTimeout:  time.Duration(c.IdleTimeout),
 	}
 	switch c.Type {
 	case "", "http":
@@ -115,11 +185,41 @@ func newHTTPServer(handler http.Handler, c *Connector) (*http.Server, error) {
 	return httpServer, nil
 }
 
-// Factory is an union of DefaultFactory and SimpleFactory.
+// deadlineHandler sets deadline of request contexts to when the write timeout
+// of the connection expires. As the write timeout starts after request headers
+// are read, the deadline is slightly later than the actual one.
+type deadlineHandler struct {
+	handler http.Handler
+	timeout time.Duration
+}
+
+func (h *deadlineHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+	h.handler.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// Factory is an union of server factories registered in ServerTypes, which
+// are DefaultFactory and SimpleFactory by default.
 type Factory struct {
 	dynamic.Type
 }
 
+// Types returns registry of server types.
+func (factory *Factory) Types() *configuration.Registry {
+	return ServerTypes
+}
+
+// UnmarshalJSON decodes the server factory of the given type.
+func (factory *Factory) UnmarshalJSON(data []byte) error {
+	v, err := ServerTypes.Unmarshal(data)
+	if err != nil {
+		return err
+	}
+	factory.SetValue(v)
+	return nil
+}
+
 // Build returns a server based on type which is either DefaultServer or SimpleServer.
 func (factory *Factory) BuildServer(environment *core.Environment) (core.Managed, error) {
 	if f, ok := factory.Value().(core.ServerFactory); ok {
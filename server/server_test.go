@@ -1,8 +1,15 @@
 package server
 
 import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/goburrow/melon/configuration"
 	"github.com/goburrow/melon/core"
 )
 
@@ -36,3 +43,47 @@ func TestInvalidFactory(t *testing.T) {
 		t.Fatal("error expected")
 	}
 }
+
+func TestConnectorDecompressRequests(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Write(b)
+	})
+	srv, err := newHTTPServer(handler, &Connector{DecompressRequests: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte("melon"))
+	gz.Close()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/", &buf)
+	r.Header.Set("Content-Encoding", "gzip")
+	srv.Handler.ServeHTTP(w, r)
+	if w.Body.String() != "melon" {
+		t.Fatalf("unexpected body: %v", w.Body.String())
+	}
+}
+
+func TestConnectorWriteTimeout(t *testing.T) {
+	var deadline time.Time
+	var ok bool
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadline, ok = r.Context().Deadline()
+	})
+	srv, err := newHTTPServer(handler, &Connector{Type: "http",
+		WriteTimeout: configuration.Duration(2 * time.Second), IdleTimeout: configuration.Duration(5 * time.Second)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if srv.WriteTimeout != 2*time.Second || srv.IdleTimeout != 5*time.Second || srv.ReadTimeout != 0 {
+		t.Fatalf("unexpected server: %+v", srv)
+	}
+	start := time.Now()
+	srv.Handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if !ok || deadline.Before(start.Add(2*time.Second)) || deadline.After(time.Now().Add(2*time.Second)) {
+		t.Fatalf("unexpected deadline: %v %v", deadline, ok)
+	}
+}
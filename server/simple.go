@@ -2,6 +2,7 @@ package server
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/goburrow/melon/core"
 	"github.com/goburrow/melon/server/router"
@@ -36,6 +37,42 @@ func (factory *SimpleFactory) BuildServer(env *core.Environment) (core.Managed,
 
 	adminHandler := router.New(router.WithPathPrefix(factory.AdminContextPath))
 	env.Admin.Router = adminHandler
+	env.Admin.StartupHealthCheck = factory.StartupHealthCheck
+	env.Admin.DrainPeriod = time.Duration(factory.DrainPeriod)
+
+	err := factory.commonFactory.AddMaintenanceFilter(env, appHandler)
+	if err != nil {
+		return nil, err
+	}
+	err = factory.commonFactory.AddRateLimitFilter(appHandler)
+	if err != nil {
+		return nil, err
+	}
+	err = factory.commonFactory.AddBulkheadFilter(appHandler)
+	if err != nil {
+		return nil, err
+	}
+	err = factory.commonFactory.AddCacheFilter(env, appHandler)
+	if err != nil {
+		return nil, err
+	}
+	err = factory.commonFactory.AddAdminAuthFilter(adminHandler)
+	if err != nil {
+		return nil, err
+	}
+	factory.commonFactory.AddAuditFilter(adminHandler)
+	err = factory.commonFactory.AddShutdownTask(env)
+	if err != nil {
+		return nil, err
+	}
+	err = factory.commonFactory.AddPingHandlers(env, appHandler)
+	if err != nil {
+		return nil, err
+	}
+	err = factory.commonFactory.AddCORSFilters(appHandler, adminHandler)
+	if err != nil {
+		return nil, err
+	}
 
 	return factory.buildServer(env, appHandler, adminHandler)
 }
@@ -53,6 +90,7 @@ func (factory *SimpleFactory) buildServer(env *core.Environment, handlers ...*ro
 		return nil, err
 	}
 	server := newServer()
+	server.timeline = env.Lifecycle.Timeline
 	err = server.addConnectors(handler, []Connector{factory.Connector})
 	if err != nil {
 		return nil, err
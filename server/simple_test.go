@@ -2,7 +2,9 @@ package server
 
 import (
 	"testing"
+	"time"
 
+	"github.com/goburrow/melon/configuration"
 	"github.com/goburrow/melon/core"
 )
 
@@ -11,6 +13,7 @@ var _ core.ServerFactory = (*SimpleFactory)(nil)
 func TestSimpleFactory(t *testing.T) {
 	env := core.NewEnvironment()
 	factory := &SimpleFactory{}
+	factory.DrainPeriod = configuration.Duration(5 * time.Second)
 
 	s, err := factory.BuildServer(env)
 	if err != nil {
@@ -25,4 +28,7 @@ func TestSimpleFactory(t *testing.T) {
 	if env.Admin.Router == nil {
 		t.Fatal("Admin.ServerHandler is nil")
 	}
+	if env.Admin.DrainPeriod != 5*time.Second {
+		t.Fatalf("unexpected drain period: %v", env.Admin.DrainPeriod)
+	}
 }
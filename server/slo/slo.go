@@ -0,0 +1,210 @@
+/*
+Package slo provides a filter which tracks service level objectives of routes.
+
+For each objective, the filter records counters SLO.<name>.Good and
+SLO.<name>.Bad. A request is bad when its response status is 5xx or its
+latency exceeds the threshold. Burn rates, the ratio between the observed
+error rate and the error budget (1 - target), are precomputed over 5 minutes
+and 1 hour in gauges SLO.<name>.BurnRate5m and SLO.<name>.BurnRate1h.
+As gauges are integers, burn rates are multiplied by 1000.
+*/
+package slo
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/codahale/metrics"
+	"github.com/goburrow/melon/server/filter"
+)
+
+const (
+	// windowMinutes is the longest window of burn rate.
+	windowMinutes = 60
+	// burnRateScale is multiplied with burn rate for integer gauges.
+	burnRateScale = 1000
+)
+
+// For testing
+var now = time.Now
+
+// objective is a service level objective of requests matching pattern.
+type objective struct {
+	pattern string
+	latency time.Duration
+	target  float64
+
+	good   metrics.Counter
+	bad    metrics.Counter
+	events *eventWindow
+}
+
+func (o *objective) record(t time.Time, status int, latency time.Duration) {
+	good := status < 500 && (o.latency <= 0 || latency <= o.latency)
+	if good {
+		o.good.Add()
+	} else {
+		o.bad.Add()
+	}
+	o.events.add(t, good)
+}
+
+// burnRate returns scaled burn rate of the last given minutes.
+func (o *objective) burnRate(minutes int) int64 {
+	bad, total := o.events.count(now(), minutes)
+	if total == 0 || o.target >= 1 {
+		return 0
+	}
+	errorRate := float64(bad) / float64(total)
+	return int64(errorRate / (1 - o.target) * burnRateScale)
+}
+
+// sloFilter records events of all objectives matching request path.
+type sloFilter struct {
+	objectives []*objective
+}
+
+// Option adds option for Filter.
+type Option func(f *sloFilter)
+
+// NewFilter returns a new Filter which tracks given service level objectives.
+func NewFilter(options ...Option) filter.Filter {
+	f := &sloFilter{}
+	for _, opt := range options {
+		opt(f)
+	}
+	return f
+}
+
+// WithObjective adds an objective name for requests matching pattern.
+// A request is good when its status is not 5xx and latency is not greater than
+// the given latency, unless latency is zero. target is the expected ratio of good
+// requests, e.g. 0.999.
+func WithObjective(name, pattern string, latency time.Duration, target float64) Option {
+	o := &objective{
+		pattern: pattern,
+		latency: latency,
+		target:  target,
+		good:    metrics.Counter("SLO." + name + ".Good"),
+		bad:     metrics.Counter("SLO." + name + ".Bad"),
+		events:  newEventWindow(windowMinutes),
+	}
+	metrics.Gauge("SLO." + name + ".BurnRate5m").SetFunc(func() int64 {
+		return o.burnRate(5)
+	})
+	metrics.Gauge("SLO." + name + ".BurnRate1h").SetFunc(func() int64 {
+		return o.burnRate(60)
+	})
+	return func(f *sloFilter) {
+		f.objectives = append(f.objectives, o)
+	}
+}
+
+func (f *sloFilter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var matched []*objective
+	for _, o := range f.objectives {
+		if filter.MatchPath(o.pattern, r.URL.Path) {
+			matched = append(matched, o)
+		}
+	}
+	if len(matched) == 0 {
+		filter.Continue(w, r)
+		return
+	}
+	rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+	start := now()
+	defer func() {
+		// Panic is normally recovered by an inner filter.
+		if err := recover(); err != nil {
+			record(matched, start, http.StatusInternalServerError)
+			panic(err)
+		}
+	}()
+	filter.Continue(rw, r)
+	record(matched, start, rw.status)
+}
+
+func record(objectives []*objective, start time.Time, status int) {
+	end := now()
+	for _, o := range objectives {
+		o.record(end, status, end.Sub(start))
+	}
+}
+
+// eventWindow counts good and bad events per minute.
+type eventWindow struct {
+	mu      sync.Mutex
+	buckets []bucket
+}
+
+type bucket struct {
+	minute int64
+	good   uint64
+	bad    uint64
+}
+
+func newEventWindow(minutes int) *eventWindow {
+	return &eventWindow{
+		buckets: make([]bucket, minutes),
+	}
+}
+
+func (w *eventWindow) add(t time.Time, good bool) {
+	minute := t.Unix() / 60
+	w.mu.Lock()
+	b := &w.buckets[minute%int64(len(w.buckets))]
+	if b.minute != minute {
+		*b = bucket{minute: minute}
+	}
+	if good {
+		b.good++
+	} else {
+		b.bad++
+	}
+	w.mu.Unlock()
+}
+
+// count returns number of bad and total events in the last given minutes.
+func (w *eventWindow) count(t time.Time, minutes int) (bad, total uint64) {
+	minute := t.Unix() / 60
+	w.mu.Lock()
+	for i := range w.buckets {
+		b := &w.buckets[i]
+		if b.minute > minute-int64(minutes) && b.minute <= minute {
+			bad += b.bad
+			total += b.good + b.bad
+		}
+	}
+	w.mu.Unlock()
+	return
+}
+
+// responseWriter is a wrapper for http.ResponseWriter and store response status.
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Flush implements http.Flusher.
+func (w *responseWriter) Flush() {
+	if fl, ok := w.ResponseWriter.(http.Flusher); ok {
+		fl.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hj, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return hj.Hijack()
+	}
+	return nil, nil, errors.New("not a Hijacker")
+}
@@ -0,0 +1,82 @@
+package slo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/goburrow/melon/server/filter"
+)
+
+func TestEventWindow(t *testing.T) {
+	w := newEventWindow(60)
+	start := time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 90; i++ {
+		w.add(start.Add(time.Duration(i)*time.Minute), i%3 != 0)
+	}
+	last := start.Add(89 * time.Minute)
+	bad, total := w.count(last, 5)
+	if bad != 1 || total != 5 {
+		t.Fatalf("unexpected count: %v/%v", bad, total)
+	}
+	bad, total = w.count(last, 60)
+	if bad != 20 || total != 60 {
+		t.Fatalf("unexpected count: %v/%v", bad, total)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	current := time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC)
+	now = func() time.Time {
+		return current
+	}
+	defer func() {
+		now = time.Now
+	}()
+	f := NewFilter(WithObjective("Test", "/api/*", 100*time.Millisecond, 0.9)).(*sloFilter)
+	o := f.objectives[0]
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/slow":
+			current = current.Add(time.Second)
+		case "/api/error":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}
+	chain := filter.NewChain()
+	chain.Add(f, http.HandlerFunc(handler))
+	for _, path := range []string{"/api/ok", "/api/slow", "/api/error", "/api/ok", "/other"} {
+		chain.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	bad, total := o.events.count(now(), 5)
+	if bad != 2 || total != 4 {
+		t.Fatalf("unexpected count: %v/%v", bad, total)
+	}
+	// Error rate 0.5 and budget 0.1
+	if burnRate := o.burnRate(5); burnRate != 5000 {
+		t.Fatalf("unexpected burn rate: %v", burnRate)
+	}
+}
+
+func TestFilterPanic(t *testing.T) {
+	f := NewFilter(WithObjective("TestPanic", "/*", 0, 0.99)).(*sloFilter)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		panic("panic")
+	}
+	chain := filter.NewChain()
+	chain.Add(f, http.HandlerFunc(handler))
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("panic expected")
+			}
+		}()
+		chain.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}()
+	bad, total := f.objectives[0].events.count(now(), 1)
+	if bad != 1 || total != 1 {
+		t.Fatalf("unexpected count: %v/%v", bad, total)
+	}
+}
@@ -0,0 +1,120 @@
+/*
+Package slowlog provides a filter which logs requests taking longer than a
+threshold at WARN level to logger "melon/server".
+*/
+package slowlog
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/goburrow/melon/core"
+	"github.com/goburrow/melon/server/filter"
+)
+
+// sensitiveHeaders are not logged.
+var sensitiveHeaders = filter.CredentialHeaders()
+
+// For testing
+var now = time.Now
+
+// slowFilter logs requests which latency exceeds threshold.
+type slowFilter struct {
+	threshold time.Duration
+	headers   bool
+	logger    core.Logger
+}
+
+// Option adds option for Filter.
+type Option func(f *slowFilter)
+
+// NewFilter returns a Filter which logs requests served longer than threshold.
+func NewFilter(threshold time.Duration, options ...Option) filter.Filter {
+	f := &slowFilter{
+		threshold: threshold,
+	}
+	for _, opt := range options {
+		opt(f)
+	}
+	return f
+}
+
+// WithHeaders also logs request and response headers. Values of credential
+// headers are redacted.
+func WithHeaders() Option {
+	return func(f *slowFilter) {
+		f.headers = true
+	}
+}
+
+// WithLogger sets logger of slow requests instead of "melon/server".
+func WithLogger(logger core.Logger) Option {
+	return func(f *slowFilter) {
+		f.logger = logger
+	}
+}
+
+func (f *slowFilter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sw := &statusWriter{ResponseWriter: w}
+	start := now()
+	filter.Continue(sw, r)
+	latency := now().Sub(start)
+	if latency < f.threshold {
+		return
+	}
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "slow request: %s %s %s %d took %v (threshold %v) remote=%s bytes=%d",
+		r.Method, r.RequestURI, r.Proto, sw.status, latency, f.threshold, r.RemoteAddr, sw.size)
+	if id := r.Header.Get("X-Request-Id"); id != "" {
+		fmt.Fprintf(&buf, " requestID=%s", id)
+	}
+	if ua := r.UserAgent(); ua != "" {
+		fmt.Fprintf(&buf, " userAgent=%q", ua)
+	}
+	if f.headers {
+		buf.WriteString("\nrequest headers:\n")
+		filter.WriteHeader(&buf, r.Header, sensitiveHeaders)
+		buf.WriteString("response headers:\n")
+		filter.WriteHeader(&buf, w.Header(), sensitiveHeaders)
+	}
+	logger := f.logger
+	if logger == nil {
+		logger = core.GetLogger("melon/server")
+	}
+	logger.Warnf("%s", buf.String())
+}
+
+// statusWriter records status code and size of the response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	return n, err
+}
+
+// Flush implements http.Flusher.
+func (w *statusWriter) Flush() {
+	if fl, ok := w.ResponseWriter.(http.Flusher); ok {
+		fl.Flush()
+	}
+}
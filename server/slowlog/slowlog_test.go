@@ -0,0 +1,71 @@
+package slowlog
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goburrow/melon/server/filter"
+)
+
+type testLogger struct {
+	warns []string
+}
+
+func (l *testLogger) Debugf(format string, args ...interface{}) {}
+func (l *testLogger) Infof(format string, args ...interface{})  {}
+func (l *testLogger) Errorf(format string, args ...interface{}) {}
+
+func (l *testLogger) Warnf(format string, args ...interface{}) {
+	l.warns = append(l.warns, fmt.Sprintf(format, args...))
+}
+
+func TestFilter(t *testing.T) {
+	var elapsed time.Duration
+	start := time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC)
+	now = func() time.Time {
+		return start.Add(elapsed)
+	}
+	defer func() { now = time.Now }()
+
+	logger := &testLogger{}
+	chain := filter.NewChain()
+	chain.Add(NewFilter(time.Second, WithHeaders(), WithLogger(logger)))
+	chain.Add(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			elapsed = 2 * time.Second
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("melon"))
+	}))
+
+	chain.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/fast", nil))
+	if len(logger.warns) != 0 {
+		t.Fatalf("unexpected logs: %v", logger.warns)
+	}
+	r := httptest.NewRequest("GET", "/slow?q=1", nil)
+	r.Header.Set("Authorization", "Basic bWVsb246bWVsb24=")
+	r.Header.Set("X-Request-Id", "go123")
+	chain.ServeHTTP(httptest.NewRecorder(), r)
+	if len(logger.warns) != 1 {
+		t.Fatalf("unexpected logs: %v", logger.warns)
+	}
+	for _, s := range []string{
+		"slow request: GET /slow?q=1 HTTP/1.1 202 took 2s (threshold 1s)",
+		"bytes=5 requestID=go123",
+		"Authorization: [REDACTED]",
+		"X-Request-Id: go123",
+		"Content-Type: text/plain",
+	} {
+		if !strings.Contains(logger.warns[0], s) {
+			t.Fatalf("unexpected log: %v", logger.warns[0])
+		}
+	}
+	if strings.Contains(logger.warns[0], "bWVsb24") {
+		t.Fatalf("unexpected log: %v", logger.warns[0])
+	}
+}
@@ -0,0 +1,146 @@
+/*
+Package timeout provides a filter which limits time of handling requests.
+
+The request context is cancelled when the deadline is exceeded, so downstream
+work using the context can stop early, and the client receives status 503.
+Similar to http.TimeoutHandler, responses are buffered until the handler
+returns, thus http.Flusher and http.Hijacker are not supported.
+*/
+package timeout
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/codahale/metrics"
+	"github.com/goburrow/melon/server/filter"
+	"github.com/goburrow/melon/server/problem"
+)
+
+// timeoutFilter cancels requests which take too long.
+type timeoutFilter struct {
+	timeouts []pathTimeout
+	counter  metrics.Counter
+}
+
+type pathTimeout struct {
+	pattern string
+	timeout time.Duration
+}
+
+// Option adds option for Filter.
+type Option func(f *timeoutFilter)
+
+// NewFilter returns a Filter which responds 503 when handling a request
+// exceeds the timeout configured for its path.
+func NewFilter(options ...Option) filter.Filter {
+	f := &timeoutFilter{
+		counter: metrics.Counter("HTTP.Timeouts"),
+	}
+	for _, opt := range options {
+		opt(f)
+	}
+	return f
+}
+
+// WithTimeout sets timeout for requests matching path pattern. When a path
+// matches multiple patterns, the first one is used.
+func WithTimeout(pattern string, timeout time.Duration) Option {
+	return func(f *timeoutFilter) {
+		f.timeouts = append(f.timeouts, pathTimeout{pattern, timeout})
+	}
+}
+
+func (f *timeoutFilter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var timeout time.Duration
+	for _, t := range f.timeouts {
+		if filter.MatchPath(t.pattern, r.URL.Path) {
+			timeout = t.timeout
+			break
+		}
+	}
+	if timeout <= 0 {
+		filter.Continue(w, r)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	tw := &timeoutWriter{
+		header: make(http.Header),
+	}
+	done := make(chan struct{})
+	panicCh := make(chan interface{}, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				panicCh <- p
+			}
+		}()
+		filter.Continue(tw, r)
+		close(done)
+	}()
+	select {
+	case p := <-panicCh:
+		// Panic is recovered by the outer filter.
+		panic(p)
+	case <-done:
+		tw.mu.Lock()
+		defer tw.mu.Unlock()
+		dst := w.Header()
+		for k, v := range tw.header {
+			dst[k] = v
+		}
+		if tw.status == 0 {
+			tw.status = http.StatusOK
+		}
+		w.WriteHeader(tw.status)
+		w.Write(tw.buf.Bytes())
+	case <-ctx.Done():
+		tw.mu.Lock()
+		defer tw.mu.Unlock()
+		if ctx.Err() == context.DeadlineExceeded {
+			f.counter.Add()
+			problem.Error(w, r, http.StatusServiceUnavailable, "request timed out")
+		}
+		tw.timedOut = true
+	}
+}
+
+// timeoutWriter buffers response until the handler completes.
+type timeoutWriter struct {
+	mu       sync.Mutex
+	header   http.Header
+	buf      bytes.Buffer
+	status   int
+	timedOut bool
+}
+
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *timeoutWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.buf.Write(b)
+}
+
+func (w *timeoutWriter) WriteHeader(status int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut || w.status != 0 {
+		return
+	}
+	w.status = status
+}
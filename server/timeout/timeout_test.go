@@ -0,0 +1,60 @@
+package timeout
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/goburrow/melon/server/filter"
+	"github.com/goburrow/melon/server/problem"
+)
+
+func TestFilter(t *testing.T) {
+	cancelled := make(chan struct{})
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			select {
+			case <-r.Context().Done():
+				close(cancelled)
+			case <-time.After(time.Second):
+			}
+		case "/panic":
+			panic("panic")
+		default:
+			w.Header().Set("X-Path", r.URL.Path)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("ok"))
+		}
+	}
+	chain := filter.NewChain()
+	chain.Add(NewFilter(WithTimeout("/slow", 10*time.Millisecond), WithTimeout("/*", time.Second)),
+		http.HandlerFunc(handler))
+
+	w := httptest.NewRecorder()
+	chain.ServeHTTP(w, httptest.NewRequest("GET", "/fast", nil))
+	if w.Code != http.StatusCreated || w.Header().Get("X-Path") != "/fast" || w.Body.String() != "ok" {
+		t.Fatalf("unexpected response: %v %v %v", w.Code, w.Header(), w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	chain.ServeHTTP(w, httptest.NewRequest("GET", "/slow", nil))
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Content-Type") != problem.ContentType {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Header())
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("context is not cancelled")
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("panic expected")
+			}
+		}()
+		chain.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/panic", nil))
+	}()
+}
@@ -0,0 +1,5 @@
+package swaggerui
+
+// distFiles contains scripts and styles of Swagger UI distribution. It is
+// replaced by running go generate in this package.
+var distFiles = map[string]string{}
@@ -0,0 +1,87 @@
+// +build ignore
+
+// gen downloads Swagger UI distribution from npm registry and generates
+// dist.go containing its scripts and styles.
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"go/format"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+)
+
+const (
+	version    = "5.17.14"
+	packageURL = "https://registry.npmjs.org/swagger-ui-dist/-/swagger-ui-dist-" + version + ".tgz"
+)
+
+var files = map[string]bool{
+	"swagger-ui.css":       true,
+	"swagger-ui-bundle.js": true,
+}
+
+func main() {
+	res, err := http.Get(packageURL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		log.Fatalf("could not download %s: %s", packageURL, res.Status)
+	}
+	gz, err := gzip.NewReader(res.Body)
+	if err != nil {
+		log.Fatal(err)
+	}
+	contents := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		name := path.Base(hdr.Name)
+		if files[name] {
+			if contents[name], err = ioutil.ReadAll(tr); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+	if len(contents) != len(files) {
+		log.Fatalf("missing files in %s", packageURL)
+	}
+	names := make([]string, 0, len(contents))
+	for name := range contents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by gen.go from swagger-ui-dist %s. DO NOT EDIT.\n\n", version)
+	fmt.Fprintf(&buf, "package swaggerui\n\n")
+	fmt.Fprintf(&buf, "// distFiles contains scripts and styles of Swagger UI distribution.\n")
+	fmt.Fprintf(&buf, "var distFiles = map[string]string{\n")
+	for _, name := range names {
+		fmt.Fprintf(&buf, "\t%q: %s,\n", name, strconv.Quote(string(contents[name])))
+	}
+	fmt.Fprintf(&buf, "}\n")
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err = ioutil.WriteFile("dist.go", src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,147 @@
+/*
+Package swaggerui provides a bundle serving Swagger UI on the admin page,
+which browses the OpenAPI specification generated by package views.
+
+Swagger UI scripts and styles of swagger-ui-dist are vendored in the package
+by go generate and served under the admin page, so no external requests are
+made by browsers. They are only loaded from AssetsURL if the package is built
+without generated assets.
+*/
+package swaggerui
+
+//go:generate go run gen.go
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/goburrow/melon/core"
+	"github.com/goburrow/melon/views"
+)
+
+const (
+	uiPath = "/swagger"
+
+	// AssetsURL is the base URL of Swagger UI distribution used when assets
+	// are not generated.
+	AssetsURL = "https://unpkg.com/swagger-ui-dist@5"
+)
+
+// assetsPath is relative to the page path so that admin context path is kept.
+const assetsPath = "swagger"
+
+var uiTemplate = template.Must(template.New("swaggerui").Parse(`<!DOCTYPE html>
+<html>
+<head>
+	<title>Swagger UI</title>
+	<link rel="stylesheet" href="{{.AssetsURL}}/swagger-ui.css">
+</head>
+<body>
+	<div id="swagger-ui"></div>
+	<script src="{{.AssetsURL}}/swagger-ui-bundle.js"></script>
+	<script>
+	window.onload = function() {
+		window.ui = SwaggerUIBundle({
+			url: {{.SpecURL}},
+			dom_id: "#swagger-ui"
+		});
+	};
+	</script>
+</body>
+</html>
+`))
+
+// bundle adds Swagger UI into admin environment.
+type bundle struct {
+	specURL string
+}
+
+// NewBundle returns a new Bundle which adds Swagger UI to admin page.
+// specURL is the URL of OpenAPI specification served by the application,
+// e.g. "http://localhost:8080/openapi.json" for the default server. If it is
+// empty, views.DefaultOpenAPIPath under the application context path is used,
+// e.g. "/application/openapi.json" for the simple server. See views.NewOpenAPI.
+func NewBundle(specURL string) core.Bundle {
+	return &bundle{
+		specURL: specURL,
+	}
+}
+
+// Initialize does nothing.
+func (b *bundle) Initialize(bootstrap *core.Bootstrap) {
+}
+
+// Run registers /swagger to admin page.
+func (b *bundle) Run(conf interface{}, env *core.Environment) error {
+	env.Admin.AddHandler(&uiHandler{
+		specURL: b.specURL,
+		server:  env.Server,
+	})
+	if len(distFiles) > 0 {
+		env.Admin.Handle("GET", uiPath+"/*", &assetsHandler{modTime: time.Now()})
+	}
+	return nil
+}
+
+// uiHandler renders Swagger UI page.
+type uiHandler struct {
+	specURL string
+	// server is used to resolve default specURL as its router is only
+	// available after the server is built.
+	server *core.ServerEnvironment
+}
+
+func (h *uiHandler) Name() string {
+	return "Swagger UI"
+}
+
+func (h *uiHandler) Path() string {
+	return uiPath
+}
+
+func (h *uiHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	assetsURL := AssetsURL
+	if len(distFiles) > 0 {
+		assetsURL = assetsPath
+	}
+	err := uiTemplate.Execute(w, map[string]string{
+		"AssetsURL": assetsURL,
+		"SpecURL":   h.spec(),
+	})
+	if err != nil {
+		core.GetLogger("melon/swaggerui").Errorf("%v", err)
+		fmt.Fprintf(w, "%v", err)
+	}
+}
+
+// spec returns URL of OpenAPI specification.
+func (h *uiHandler) spec() string {
+	if h.specURL != "" {
+		return h.specURL
+	}
+	prefix := ""
+	if h.server != nil && h.server.Router != nil {
+		prefix = strings.TrimSuffix(h.server.Router.PathPrefix(), "/")
+	}
+	return prefix + views.DefaultOpenAPIPath
+}
+
+// assetsHandler serves generated Swagger UI files.
+type assetsHandler struct {
+	modTime time.Time
+}
+
+func (h *assetsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := path.Base(r.URL.Path)
+	content, ok := distFiles[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeContent(w, r, name, h.modTime, strings.NewReader(content))
+}
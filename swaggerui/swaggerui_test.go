@@ -0,0 +1,68 @@
+package swaggerui
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goburrow/melon/core"
+	"github.com/goburrow/melon/server/router"
+)
+
+var _ core.AdminHandler = (*uiHandler)(nil)
+
+func TestHandler(t *testing.T) {
+	h := &uiHandler{specURL: "/application/openapi.json"}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/swagger", nil))
+	if w.Code != 200 {
+		t.Fatalf("unexpected response code: %v", w.Code)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, `url: "/application/openapi.json"`) {
+		t.Fatalf("unexpected body %s", body)
+	}
+}
+
+func TestDefaultSpecURL(t *testing.T) {
+	env := core.NewEnvironment()
+	h := &uiHandler{server: env.Server}
+	env.Server.Router = router.New(router.WithPathPrefix("/application"))
+	if h.spec() != "/application/openapi.json" {
+		t.Fatalf("unexpected spec URL: %v", h.spec())
+	}
+}
+
+func TestAssets(t *testing.T) {
+	files := distFiles
+	defer func() { distFiles = files }()
+
+	distFiles = map[string]string{}
+	h := &uiHandler{specURL: "/openapi.json"}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/swagger", nil))
+	if !strings.Contains(w.Body.String(), AssetsURL+"/swagger-ui-bundle.js") {
+		t.Fatalf("unexpected body %s", w.Body.String())
+	}
+
+	distFiles = map[string]string{"swagger-ui.css": "body{}"}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/swagger", nil))
+	if !strings.Contains(w.Body.String(), `href="swagger/swagger-ui.css"`) {
+		t.Fatalf("unexpected body %s", w.Body.String())
+	}
+
+	a := &assetsHandler{modTime: time.Now()}
+	w = httptest.NewRecorder()
+	a.ServeHTTP(w, httptest.NewRequest("GET", "/swagger/swagger-ui.css", nil))
+	if w.Code != 200 || w.Body.String() != "body{}" || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/css") {
+		t.Fatalf("unexpected response: %v %v %v", w.Code, w.Header(), w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	a.ServeHTTP(w, httptest.NewRequest("GET", "/swagger/index.html", nil))
+	if w.Code != 404 {
+		t.Fatalf("unexpected response code: %v", w.Code)
+	}
+}
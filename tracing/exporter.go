@@ -0,0 +1,313 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/goburrow/melon/core"
+)
+
+const (
+	spanBufferSize = 1000
+	batchSize      = 100
+	flushInterval  = 5 * time.Second
+	stopTimeout    = 10 * time.Second
+	defaultTimeout = 5 * time.Second
+
+	defaultOTLPURL = "http://localhost:4318/v1/traces"
+)
+
+// Exporter sends finished spans to an external service.
+type Exporter interface {
+	Export(serviceName string, spans []*Span) error
+}
+
+// ExporterFactory creates an Exporter.
+type ExporterFactory interface {
+	Build(*core.Environment) (Exporter, error)
+}
+
+// processor batches and delivers spans to exporters in background.
+// It implements Processor and core.Managed.
+type processor struct {
+	serviceName string
+	exporters   []Exporter
+
+	spans chan *Span
+	quit  chan struct{}
+	done  chan struct{}
+}
+
+func newProcessor(serviceName string) *processor {
+	return &processor{
+		serviceName: serviceName,
+
+		spans: make(chan *Span, spanBufferSize),
+	}
+}
+
+// OnEnd queues the span. Spans are dropped when the queue is full.
+func (p *processor) OnEnd(span *Span) {
+	select {
+	case p.spans <- span:
+	default:
+		logger().Warnf("dropped span %s: queue is full", span.SpanID)
+	}
+}
+
+// Start starts delivering spans.
+func (p *processor) Start() error {
+	p.quit = make(chan struct{})
+	p.done = make(chan struct{})
+	go p.run()
+	return nil
+}
+
+// Stop delivers remaining spans and stops. It does nothing if the processor
+// is not started.
+func (p *processor) Stop() error {
+	if p.quit == nil {
+		return nil
+	}
+	close(p.quit)
+	p.quit = nil
+	select {
+	case <-p.done:
+	case <-time.After(stopTimeout):
+		logger().Warnf("timed out exporting spans")
+	}
+	return nil
+}
+
+func (p *processor) run() {
+	defer close(p.done)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Span, 0, batchSize)
+	for {
+		select {
+		case span := <-p.spans:
+			batch = append(batch, span)
+			if len(batch) >= batchSize {
+				batch = p.export(batch)
+			}
+		case <-ticker.C:
+			batch = p.export(batch)
+		case <-p.quit:
+			for {
+				select {
+				case span := <-p.spans:
+					batch = append(batch, span)
+				default:
+					p.export(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+// export sends the batch to all exporters and returns an empty batch.
+func (p *processor) export(batch []*Span) []*Span {
+	if len(batch) == 0 {
+		return batch
+	}
+	for _, exporter := range p.exporters {
+		if err := exporter.Export(p.serviceName, batch); err != nil {
+			logger().Warnf("could not export %d spans: %v", len(batch), err)
+		}
+	}
+	return make([]*Span, 0, batchSize)
+}
+
+// LogExporterFactory provides an exporter that writes spans to the logger.
+type LogExporterFactory struct{}
+
+// Build returns a log exporter.
+func (factory *LogExporterFactory) Build(*core.Environment) (Exporter, error) {
+	return &logExporter{}, nil
+}
+
+// logExporter writes spans to the logger.
+type logExporter struct{}
+
+func (e *logExporter) Export(serviceName string, spans []*Span) error {
+	for _, s := range spans {
+		logger().Infof("%s span=%q trace=%s id=%s parent=%s duration=%v error=%t attributes=%v",
+			serviceName, s.Name, s.TraceID, s.SpanID, s.Parent.SpanID, s.End.Sub(s.Start), s.Error, s.Attributes)
+	}
+	return nil
+}
+
+// OTLPExporterFactory provides an exporter that posts spans to an
+// OpenTelemetry collector using OTLP/HTTP in JSON encoding.
+type OTLPExporterFactory struct {
+	// URL is http://localhost:4318/v1/traces by default.
+	URL     string            `valid:"url"`
+	Headers map[string]string `secret:"true"`
+}
+
+// Build returns an OTLP exporter.
+func (factory *OTLPExporterFactory) Build(*core.Environment) (Exporter, error) {
+	url := factory.URL
+	if url == "" {
+		url = defaultOTLPURL
+	}
+	return &otlpExporter{
+		url:     url,
+		headers: factory.Headers,
+		client:  &http.Client{Timeout: defaultTimeout},
+	}, nil
+}
+
+// otlpExporter sends spans to an OpenTelemetry collector.
+type otlpExporter struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func (e *otlpExporter) Export(serviceName string, spans []*Span) error {
+	body, err := json.Marshal(newOTLPRequest(serviceName, spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	rsp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	rsp.Body.Close()
+	if rsp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response status %s", rsp.Status)
+	}
+	return nil
+}
+
+// OTLP JSON encoding of ExportTraceServiceRequest.
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+}
+
+type otlpStatus struct {
+	Code int `json:"code"`
+}
+
+const (
+	otlpSpanKindServer = 2
+	otlpStatusOK       = 1
+	otlpStatusError    = 2
+)
+
+func newOTLPRequest(serviceName string, spans []*Span) *otlpRequest {
+	out := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		span := otlpSpan{
+			TraceID:           s.TraceID.String(),
+			SpanID:            s.SpanID.String(),
+			Name:              s.Name,
+			Kind:              otlpSpanKindServer,
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+			Attributes:        newOTLPAttributes(s.Attributes),
+			Status:            otlpStatus{Code: otlpStatusOK},
+		}
+		if s.Parent.SpanID.IsValid() {
+			span.ParentSpanID = s.Parent.SpanID.String()
+		}
+		if s.Error {
+			span.Status.Code = otlpStatusError
+		}
+		out = append(out, span)
+	}
+	return &otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{
+				Attributes: newOTLPAttributes(map[string]interface{}{"service.name": serviceName}),
+			},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: "github.com/goburrow/melon/tracing"},
+				Spans: out,
+			}},
+		}},
+	}
+}
+
+func newOTLPAttributes(attributes map[string]interface{}) []otlpAttribute {
+	keys := make([]string, 0, len(attributes))
+	for k := range attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make([]otlpAttribute, 0, len(attributes))
+	for _, k := range keys {
+		var s string
+		attr := otlpAttribute{Key: k}
+		switch v := attributes[k].(type) {
+		case int:
+			s = strconv.Itoa(v)
+			attr.Value.IntValue = &s
+		case int64:
+			s = strconv.FormatInt(v, 10)
+			attr.Value.IntValue = &s
+		default:
+			s = fmt.Sprint(v)
+			attr.Value.StringValue = &s
+		}
+		out = append(out, attr)
+	}
+	return out
+}
@@ -0,0 +1,383 @@
+/*
+Package tracing provides a filter which creates a server span for each HTTP
+request and exports sampled spans to external tracing systems.
+
+Trace context is propagated with W3C traceparent headers
+(https://www.w3.org/TR/trace-context/). Spans are named by the HTTP method and
+the pattern of the matched route, e.g. "GET /users/{id}", so that requests for
+different resources of the same route are grouped together.
+*/
+package tracing
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	mathrand "math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/goburrow/dynamic"
+	"github.com/goburrow/melon/configuration"
+	"github.com/goburrow/melon/core"
+	"github.com/goburrow/melon/server/filter"
+	"github.com/goburrow/melon/server/router"
+)
+
+const (
+	traceparentHeader = "Traceparent"
+	traceVersion      = "00"
+	flagSampled       = 0x01
+
+	defaultServiceName = "melon"
+)
+
+// ExporterTypes contains exporter factories selected by key "type" of
+// exporter configuration.
+var ExporterTypes = configuration.NewRegistry("exporter", (*ExporterFactory)(nil))
+
+func init() {
+	ExporterTypes.Register("LogExporter", func() interface{} { return &LogExporterFactory{} })
+	ExporterTypes.Register("OTLPExporter", func() interface{} { return &OTLPExporterFactory{} })
+}
+
+// TraceID is the identifier of a trace.
+type TraceID [16]byte
+
+// IsValid returns false if all bytes are zero.
+func (t TraceID) IsValid() bool {
+	return t != TraceID{}
+}
+
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+// SpanID is the identifier of a span.
+type SpanID [8]byte
+
+// IsValid returns false if all bytes are zero.
+func (s SpanID) IsValid() bool {
+	return s != SpanID{}
+}
+
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// SpanContext identifies a span in a trace.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// IsValid returns true when both trace and span ids are set.
+func (c SpanContext) IsValid() bool {
+	return c.TraceID.IsValid() && c.SpanID.IsValid()
+}
+
+// Span is an operation within a trace.
+type Span struct {
+	SpanContext
+	// Parent is invalid if the span is the root of the trace.
+	Parent     SpanContext
+	Name       string
+	Start      time.Time
+	End        time.Time
+	Attributes map[string]interface{}
+	// Error is true when the operation has failed.
+	Error bool
+}
+
+// SetAttribute sets a string or integer attribute of the span.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s.Attributes == nil {
+		s.Attributes = make(map[string]interface{})
+	}
+	s.Attributes[key] = value
+}
+
+type spanContextKey struct{}
+
+// ContextWithSpan returns a copy of ctx in which span is stored.
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, span)
+}
+
+// SpanFromContext returns the current span of the context or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanContextKey{}).(*Span)
+	return span
+}
+
+// Inject sets header traceparent of outgoing requests from the current span
+// in ctx so downstream services can continue the trace.
+func Inject(ctx context.Context, header http.Header) {
+	span := SpanFromContext(ctx)
+	if span == nil || !span.IsValid() {
+		return
+	}
+	header.Set(traceparentHeader, formatTraceparent(span.SpanContext))
+}
+
+// Extract returns the span context of header traceparent from incoming requests.
+// The returned context is invalid if the header is absent or malformed.
+func Extract(header http.Header) SpanContext {
+	c, _ := parseTraceparent(header.Get(traceparentHeader))
+	return c
+}
+
+// parseTraceparent parses traceparent in format version-traceid-spanid-flags.
+func parseTraceparent(value string) (SpanContext, error) {
+	var c SpanContext
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" ||
+		(parts[0] == traceVersion && len(parts) != 4) {
+		return c, fmt.Errorf("tracing: invalid traceparent %q", value)
+	}
+	var flags [1]byte
+	if err := decodeHex(c.TraceID[:], parts[1]); err != nil {
+		return c, err
+	}
+	if err := decodeHex(c.SpanID[:], parts[2]); err != nil {
+		return c, err
+	}
+	if err := decodeHex(flags[:], parts[3]); err != nil {
+		return c, err
+	}
+	if !c.IsValid() {
+		return SpanContext{}, fmt.Errorf("tracing: invalid traceparent %q", value)
+	}
+	c.Sampled = flags[0]&flagSampled != 0
+	return c, nil
+}
+
+func decodeHex(dst []byte, s string) error {
+	if len(s) != hex.EncodedLen(len(dst)) || strings.ToLower(s) != s {
+		return fmt.Errorf("tracing: invalid hex %q", s)
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err
+}
+
+func formatTraceparent(c SpanContext) string {
+	var flags byte
+	if c.Sampled {
+		flags |= flagSampled
+	}
+	return fmt.Sprintf("%s-%s-%s-%02x", traceVersion, c.TraceID, c.SpanID, flags)
+}
+
+// Factory implements core.TracingFactory interface.
+type Factory struct {
+	Enabled bool
+	// ServiceName is reported as resource attribute service.name, default is "melon".
+	ServiceName string
+	// SampleRate is the fraction of new traces to be sampled, from 0 to 1.
+	// All traces are sampled if it is not set. Traces continued from incoming
+	// requests follow the sampling decision of the caller.
+	SampleRate float64
+	Exporters  []ExporterConfiguration
+}
+
+// ExporterConfiguration is an union of exporter configurations registered in
+// ExporterTypes, which are log and OTLP by default.
+type ExporterConfiguration struct {
+	dynamic.Type
+}
+
+// Types returns registry of exporter types.
+func (c *ExporterConfiguration) Types() *configuration.Registry {
+	return ExporterTypes
+}
+
+// UnmarshalJSON decodes the exporter configuration of the given type.
+func (c *ExporterConfiguration) UnmarshalJSON(data []byte) error {
+	v, err := ExporterTypes.Unmarshal(data)
+	if err != nil {
+		return err
+	}
+	c.SetValue(v)
+	return nil
+}
+
+// ConfigureTracing builds exporters and registers the tracing filter to the
+// application server.
+func (factory *Factory) ConfigureTracing(env *core.Environment) error {
+	if !factory.Enabled {
+		return nil
+	}
+	if factory.SampleRate < 0 || factory.SampleRate > 1 {
+		return fmt.Errorf("tracing: sample rate must be between 0 and 1: %v", factory.SampleRate)
+	}
+	serviceName := factory.ServiceName
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
+	p := newProcessor(serviceName)
+	for _, exporterFactory := range factory.Exporters {
+		f, ok := exporterFactory.Value().(ExporterFactory)
+		if !ok {
+			return fmt.Errorf("tracing: unsupported exporter %#v", exporterFactory.Value())
+		}
+		exporter, err := f.Build(env)
+		if err != nil {
+			return err
+		}
+		p.exporters = append(p.exporters, exporter)
+	}
+	options := []Option{WithProcessor(p)}
+	if factory.SampleRate > 0 {
+		options = append(options, WithSampleRate(factory.SampleRate))
+	}
+	env.Lifecycle.Manage(p)
+	// Spans must also record responses of recovered panics.
+	env.Server.Register(filter.WithPriority(NewFilter(options...), filter.PriorityMonitoring))
+	return nil
+}
+
+// Processor receives finished spans.
+type Processor interface {
+	OnEnd(*Span)
+}
+
+// tracingFilter creates server spans for requests.
+type tracingFilter struct {
+	sampleRate float64
+	processor  Processor
+}
+
+// Option adds option for Filter.
+type Option func(f *tracingFilter)
+
+// NewFilter returns a Filter which creates a server span for each request.
+// The span is available to handlers with SpanFromContext.
+func NewFilter(options ...Option) filter.Filter {
+	f := &tracingFilter{
+		sampleRate: 1,
+	}
+	for _, opt := range options {
+		opt(f)
+	}
+	return f
+}
+
+// WithSampleRate sets the fraction of new traces to be sampled.
+func WithSampleRate(rate float64) Option {
+	return func(f *tracingFilter) {
+		f.sampleRate = rate
+	}
+}
+
+// WithProcessor sets the processor of sampled spans.
+func WithProcessor(p Processor) Option {
+	return func(f *tracingFilter) {
+		f.processor = p
+	}
+}
+
+func (f *tracingFilter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	span := f.startSpan(Extract(r.Header))
+	ctx := router.NewRouteContext(ContextWithSpan(r.Context(), span))
+	sw := &statusWriter{ResponseWriter: w}
+	filter.Continue(sw, r.WithContext(ctx))
+	span.End = time.Now()
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	route := router.Route(ctx)
+	if route == "" {
+		span.Name = r.Method + " unmatched"
+	} else {
+		span.Name = r.Method + " " + route
+		span.SetAttribute("http.route", route)
+	}
+	span.SetAttribute("http.method", r.Method)
+	span.SetAttribute("http.target", r.URL.RequestURI())
+	span.SetAttribute("http.status_code", sw.status)
+	span.Error = sw.status >= http.StatusInternalServerError
+	if span.Sampled && f.processor != nil {
+		f.processor.OnEnd(span)
+	}
+}
+
+// startSpan creates a child span of parent, or a root span if parent is invalid.
+func (f *tracingFilter) startSpan(parent SpanContext) *Span {
+	span := &Span{
+		Parent: parent,
+		Start:  time.Now(),
+	}
+	if parent.IsValid() {
+		span.TraceID = parent.TraceID
+		span.Sampled = parent.Sampled
+	} else {
+		randomBytes(span.TraceID[:])
+		span.Sampled = f.sampleRate >= 1 || mathrand.Float64() < f.sampleRate
+	}
+	randomBytes(span.SpanID[:])
+	return span
+}
+
+// randomBytes fills b with random bytes which are not all zero.
+func randomBytes(b []byte) {
+	if _, err := rand.Read(b); err != nil {
+		// Fallback to pseudo-random number.
+		for i := range b {
+			b[i] = byte(mathrand.Intn(256))
+		}
+	}
+	b[0] |= 0x01
+}
+
+// statusWriter records status code of the response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher.
+func (w *statusWriter) Flush() {
+	if fl, ok := w.ResponseWriter.(http.Flusher); ok {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		fl.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker.
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("not a Hijacker")
+	}
+	conn, rw, err := hj.Hijack()
+	if err == nil && w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+func logger() core.Logger {
+	return core.GetLogger("melon/tracing")
+}
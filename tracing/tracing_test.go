@@ -0,0 +1,192 @@
+package tracing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/goburrow/melon/core"
+	"github.com/goburrow/melon/server/router"
+)
+
+var _ core.TracingFactory = (*Factory)(nil)
+var _ core.Managed = (*processor)(nil)
+var _ Processor = (*processor)(nil)
+
+type recordProcessor struct {
+	mu    sync.Mutex
+	spans []*Span
+}
+
+func (p *recordProcessor) OnEnd(span *Span) {
+	p.mu.Lock()
+	p.spans = append(p.spans, span)
+	p.mu.Unlock()
+}
+
+func TestTraceparent(t *testing.T) {
+	tests := []struct {
+		value string
+		valid bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		c, err := parseTraceparent(test.value)
+		if (err == nil) != test.valid || c.IsValid() != test.valid {
+			t.Fatalf("unexpected result of %q: %+v %v", test.value, c, err)
+		}
+	}
+	c, _ := parseTraceparent(tests[0].value)
+	if !c.Sampled || formatTraceparent(c) != tests[0].value {
+		t.Fatalf("unexpected span context: %+v", c)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	p := &recordProcessor{}
+	var inject string
+	h := router.New()
+	h.Handle("GET", "/users/{id}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := make(http.Header)
+		Inject(r.Context(), header)
+		inject = header.Get("Traceparent")
+	}))
+	h.Handle("GET", "/error", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "error", http.StatusInternalServerError)
+	}))
+	h.AddFilter(NewFilter(WithProcessor(p)))
+
+	r := httptest.NewRequest("GET", "/users/1?q=1", nil)
+	r.Header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/error", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/unknown", nil))
+	r = httptest.NewRequest("GET", "/error", nil)
+	r.Header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if len(p.spans) != 3 {
+		t.Fatalf("unexpected spans: %+v", p.spans)
+	}
+	span := p.spans[0]
+	if span.Name != "GET /users/{id}" || span.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" ||
+		span.Parent.SpanID.String() != "00f067aa0ba902b7" || span.Error ||
+		span.Attributes["http.status_code"] != 200 || span.Attributes["http.target"] != "/users/1?q=1" {
+		t.Fatalf("unexpected span: %+v", span)
+	}
+	if inject != "00-4bf92f3577b34da6a3ce929d0e0e4736-"+span.SpanID.String()+"-01" {
+		t.Fatalf("unexpected traceparent: %v", inject)
+	}
+	span = p.spans[1]
+	if span.Name != "GET /error" || span.Parent.IsValid() || !span.TraceID.IsValid() || !span.Error ||
+		span.Attributes["http.status_code"] != 500 {
+		t.Fatalf("unexpected span: %+v", span)
+	}
+	span = p.spans[2]
+	if span.Name != "GET unmatched" || span.Attributes["http.status_code"] != 404 {
+		t.Fatalf("unexpected span: %+v", span)
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var v interface{}
+		if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+			t.Errorf("unexpected body: %v", err)
+		}
+		b, _ := json.Marshal(v)
+		body = string(b)
+	}))
+	defer server.Close()
+
+	factory := &OTLPExporterFactory{URL: server.URL}
+	e, err := factory.Build(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	span := &Span{
+		Name:  "GET /",
+		Start: time.Unix(1, 0),
+		End:   time.Unix(2, 0),
+		Error: true,
+	}
+	span.TraceID[0] = 1
+	span.SpanID[0] = 2
+	span.SetAttribute("http.status_code", 500)
+	if err = e.Export("test", []*Span{span}); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		`"key":"service.name","value":{"stringValue":"test"}`,
+		`"traceId":"01000000000000000000000000000000"`,
+		`"spanId":"0200000000000000"`,
+		`"startTimeUnixNano":"1000000000"`,
+		`"key":"http.status_code","value":{"intValue":"500"}`,
+		`"status":{"code":2}`,
+	} {
+		if !strings.Contains(body, s) {
+			t.Fatalf("unexpected body: %s", body)
+		}
+	}
+}
+
+func TestProcessor(t *testing.T) {
+	e := &recordExporter{}
+	p := newProcessor("test")
+	p.exporters = []Exporter{e}
+	p.Start()
+	p.OnEnd(&Span{Name: "1"})
+	p.OnEnd(&Span{Name: "2"})
+	p.Stop()
+	if len(e.spans) != 2 {
+		t.Fatalf("unexpected spans: %+v", e.spans)
+	}
+}
+
+func TestProcessorStopWithoutStart(t *testing.T) {
+	p := newProcessor("test")
+	start := time.Now()
+	p.Stop()
+	if d := time.Since(start); d >= stopTimeout {
+		t.Fatalf("unexpected stop duration: %v", d)
+	}
+}
+
+type recordExporter struct {
+	spans []*Span
+}
+
+func (e *recordExporter) Export(serviceName string, spans []*Span) error {
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func TestStatusWriter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	var w http.ResponseWriter = &statusWriter{ResponseWriter: rec}
+	fl, ok := w.(http.Flusher)
+	if !ok {
+		t.Fatal("writer is not a Flusher")
+	}
+	fl.Flush()
+	if !rec.Flushed || w.(*statusWriter).status != http.StatusOK {
+		t.Fatalf("unexpected flush: %v %v", rec.Flushed, w.(*statusWriter).status)
+	}
+	if _, _, err := w.(http.Hijacker).Hijack(); err == nil {
+		t.Fatal("hijack must fail with a writer not supporting it")
+	}
+}
@@ -5,18 +5,18 @@ package validation
 
 import (
 	"github.com/goburrow/melon/core"
-	"github.com/goburrow/validator"
 )
 
 // factory is a validator builder.
 type factory struct {
-	validator *validator.Validator
+	validator *Validator
 }
 
-// NewFactory creates a new ValidatorFactory.
+// NewFactory creates a new ValidatorFactory which validates struct tags.
+// See Validator for supported rules.
 func NewFactory() core.ValidatorFactory {
 	return &factory{
-		validator: validator.Default(),
+		validator: &Validator{},
 	}
 }
 
@@ -85,3 +85,99 @@ func TestValidateStruct(t *testing.T) {
 		t.Fatalf("unexpected error %v", err)
 	}
 }
+
+type testDynamic struct {
+	value interface{}
+}
+
+func (d *testDynamic) Value() interface{} {
+	return d.value
+}
+
+type testConnector struct {
+	Type string `valid:"required,oneof=http https"`
+	Addr string `valid:"hostport"`
+	URL  string `valid:"url"`
+	Port int    `valid:"min=0,max=65535"`
+}
+
+type testServer struct {
+	testDynamic
+}
+
+func TestValidateTags(t *testing.T) {
+	validator, _ := NewFactory().BuildValidator(nil)
+	type config struct {
+		Connectors []testConnector
+		Named      map[string]testConnector
+		Server     testServer
+	}
+	c := config{
+		Connectors: []testConnector{
+			{Type: "http", Addr: ":8080", URL: "http://localhost:8080/api"},
+			{Type: "ftp", Addr: "localhost", URL: "/api", Port: 70000},
+			{Addr: "localhost:99999"},
+		},
+		Named: map[string]testConnector{
+			"admin": {Type: "https", Addr: "localhost:https"},
+		},
+		Server: testServer{testDynamic{&testConnector{Type: "http", Port: -1}}},
+	}
+	err := validator.Validate(&c)
+	errs, ok := err.(Errors)
+	if !ok {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+	expected := []string{
+		"Connectors[1].Type: must be one of http, https",
+		"Connectors[1].Addr: must be in form of host:port",
+		"Connectors[1].URL: must be an absolute URL",
+		"Connectors[1].Port: must be at most 65535",
+		"Connectors[2].Type: is required",
+		"Connectors[2].Addr: port must be between 0 and 65535",
+		"Server.Port: must be at least 0",
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	for i, e := range expected {
+		if errs[i].Error() != e {
+			t.Fatalf("unexpected error %d: %v, expected %v", i, errs[i], e)
+		}
+	}
+	if errs[0].Field != "Connectors[1].Type" || errs[0].Rule != "oneof" || errs[4].Rule != "required" {
+		t.Fatalf("unexpected field errors: %+v %+v", errs[0], errs[4])
+	}
+
+	type invalid struct {
+		A string `valid:"email"`
+	}
+	err = validator.Validate(&invalid{})
+	if err == nil || err.Error() != `A: unknown validation rule "email"` {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestValidateCompatibleTags(t *testing.T) {
+	validator, _ := NewFactory().BuildValidator(nil)
+	type config struct {
+		A int               `valid:"nonzero"`
+		B *int              `valid:"nonnil"`
+		C string            `valid:"len=3"`
+		D []int             `valid:"len=2"`
+		E string            `valid:"regexp=^[a-z]+$"`
+		F string            `valid:"regexp=^a\\,b$"`
+		G map[string]string `valid:"nonnil"`
+	}
+	c := config{C: "ab", D: []int{1, 2}, E: "Ab", F: "a,b"}
+	err := validator.Validate(&c)
+	expected := `A: is required; B: must not be nil; C: length must be 3; E: must match ^[a-z]+$; G: must not be nil`
+	if err == nil || err.Error() != expected {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	n := 1
+	c = config{A: 1, B: &n, C: "abc", D: []int{1, 2}, E: "ab", F: "a,b", G: map[string]string{}}
+	if err = validator.Validate(&c); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,311 @@
+package validation
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// TagName is the struct tag of validation rules.
+const TagName = "valid"
+
+// FieldError is a validation error of a field. Field is the full path of
+// the field, e.g. Server.ApplicationConnectors[0].Addr, and Rule is the name
+// of the violated rule, e.g. notempty.
+type FieldError struct {
+	Field   string
+	Rule    string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// Errors contains all validation errors.
+type Errors []*FieldError
+
+func (e Errors) Error() string {
+	s := make([]string, len(e))
+	for i, err := range e {
+		s[i] = err.Error()
+	}
+	return strings.Join(s, "; ")
+}
+
+// Validator validates struct fields with rules given in tag "valid":
+//
+// 	Addr string `valid:"required,hostport"`
+//
+// Rules are separated by commas:
+//
+// 	required   value must not be zero.
+// 	nonzero    same as required.
+// 	nonnil     pointer, interface, slice or map must not be nil.
+// 	notempty   string, slice or map must not be empty.
+// 	min=n      number must not be less than n, or length of string, slice or
+// 	           map must not be less than n.
+// 	max=n      number must not be greater than n, or length of string, slice
+// 	           or map must not be greater than n.
+// 	len=n      number must be n, or length of string, slice or map must be n.
+// 	regexp=re  string must match regular expression re. Commas in re are
+// 	           escaped with a backslash.
+// 	oneof=a b  value must be one of the values separated by spaces.
+// 	url        string must be an absolute URL.
+// 	hostport   string must be in form of host:port, host can be empty.
+//
+// Rules oneof, url and hostport are not checked on zero values. Rules are
+// compatible with those of github.com/goburrow/validator, which was used
+// previously.
+// Values of configuration unions, such as logging.AppenderConfiguration, are
+// validated as well.
+type Validator struct{}
+
+// Validate returns Errors of all invalid fields of v.
+func (*Validator) Validate(v interface{}) error {
+	var errs Errors
+	validateValue(reflect.ValueOf(v), "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// valuer is implemented by dynamic types.
+type valuer interface {
+	Value() interface{}
+}
+
+func validateValue(v reflect.Value, path string, errs *Errors) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		if v.CanAddr() && v.Addr().CanInterface() {
+			if d, ok := v.Addr().Interface().(valuer); ok {
+				validateValue(reflect.ValueOf(d.Value()), path, errs)
+			}
+		}
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" && !f.Anonymous {
+				continue
+			}
+			fieldPath := path
+			if !f.Anonymous {
+				fieldPath = joinPath(path, f.Name)
+			}
+			fv := v.Field(i)
+			if tag := f.Tag.Get(TagName); tag != "" {
+				if rule, err := checkRules(fv, tag); err != nil {
+					*errs = append(*errs, &FieldError{Field: fieldPath, Rule: rule, Message: err.Error()})
+					continue
+				}
+			}
+			validateValue(fv, fieldPath, errs)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			validateValue(v.Index(i), path+"["+strconv.Itoa(i)+"]", errs)
+		}
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			validateValue(v.MapIndex(k), joinPath(path, fmt.Sprint(k)), errs)
+		}
+	}
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// checkRules returns name of the first violated rule and its error.
+func checkRules(v reflect.Value, tag string) (string, error) {
+	zero := isZero(v)
+	for _, rule := range splitRules(tag) {
+		name, param := rule, ""
+		if i := strings.IndexByte(rule, '='); i >= 0 {
+			name, param = rule[:i], rule[i+1:]
+		}
+		var err error
+		switch name {
+		case "required", "nonzero":
+			if zero {
+				err = fmt.Errorf("is required")
+			}
+		case "nonnil":
+			if canBeNil(v) && v.IsNil() {
+				err = fmt.Errorf("must not be nil")
+			}
+		case "notempty":
+			if hasLength(v) && v.Len() == 0 {
+				err = fmt.Errorf("must not be empty")
+			}
+		case "min", "max", "len", "regexp":
+			err = checkRule(v, name, param)
+		case "oneof", "url", "hostport":
+			if !zero {
+				err = checkRule(v, name, param)
+			}
+		default:
+			err = fmt.Errorf("unknown validation rule %q", name)
+		}
+		if err != nil {
+			return name, err
+		}
+	}
+	return "", nil
+}
+
+func checkRule(v reflect.Value, name, param string) error {
+	switch name {
+	case "min", "max", "len":
+		limit, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return fmt.Errorf("invalid validation rule %s=%s", name, param)
+		}
+		n, length, ok := number(v)
+		if !ok {
+			return nil
+		}
+		switch {
+		case name == "min" && n < limit && length:
+			return fmt.Errorf("length must be at least %s", param)
+		case name == "min" && n < limit:
+			return fmt.Errorf("must be at least %s", param)
+		case name == "max" && n > limit && length:
+			return fmt.Errorf("length must be at most %s", param)
+		case name == "max" && n > limit:
+			return fmt.Errorf("must be at most %s", param)
+		case name == "len" && n != limit && length:
+			return fmt.Errorf("length must be %s", param)
+		case name == "len" && n != limit:
+			return fmt.Errorf("must be %s", param)
+		}
+	case "regexp":
+		re, err := regexp.Compile(param)
+		if err != nil {
+			return fmt.Errorf("invalid validation rule %s=%s", name, param)
+		}
+		if v.Kind() == reflect.String && !re.MatchString(v.String()) {
+			return fmt.Errorf("must match %s", param)
+		}
+	case "oneof":
+		s := fmt.Sprint(v)
+		for _, o := range strings.Fields(param) {
+			if s == o {
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of %s", strings.Join(strings.Fields(param), ", "))
+	case "url":
+		u, err := url.Parse(v.String())
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("must be an absolute URL")
+		}
+	case "hostport":
+		_, port, err := net.SplitHostPort(v.String())
+		if err != nil || port == "" {
+			return fmt.Errorf("must be in form of host:port")
+		}
+		if n, err := strconv.Atoi(port); err == nil && (n < 0 || n > 65535) {
+			return fmt.Errorf("port must be between 0 and 65535")
+		}
+	}
+	return nil
+}
+
+// number returns value of numbers or length of strings, slices and maps.
+func number(v reflect.Value) (float64, bool, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), false, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), false, true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), false, true
+	}
+	if hasLength(v) {
+		return float64(v.Len()), true, true
+	}
+	return 0, false, false
+}
+
+// splitRules splits tag by commas which are not escaped with a backslash.
+func splitRules(tag string) []string {
+	var rules []string
+	var rule []byte
+	for i := 0; i < len(tag); i++ {
+		switch {
+		case tag[i] == '\\' && i+1 < len(tag) && tag[i+1] == ',':
+			rule = append(rule, ',')
+			i++
+		case tag[i] == ',':
+			rules = append(rules, string(rule))
+			rule = rule[:0]
+		default:
+			rule = append(rule, tag[i])
+		}
+	}
+	return append(rules, string(rule))
+}
+
+func canBeNil(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map, reflect.Chan, reflect.Func:
+		return true
+	}
+	return false
+}
+
+func hasLength(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return true
+	}
+	return false
+}
+
+func isZero(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.String, reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if !isZero(v.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !isZero(v.Field(i)) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
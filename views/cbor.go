@@ -0,0 +1,53 @@
+package views
+
+import (
+	"net/http"
+
+	"github.com/ugorji/go/codec"
+)
+
+var cborMediaTypes = []string{
+	"application/cbor",
+}
+
+// cborProvider handles CBOR (RFC 7049) requests and responses.
+type cborProvider struct {
+	handle codec.CborHandle
+}
+
+// NewCBORProvider returns a Provider which reads and writes CBOR requests and responses.
+func NewCBORProvider() Provider {
+	return &cborProvider{}
+}
+
+// Consumes returns CBOR media types.
+func (p *cborProvider) Consumes() []string {
+	return cborMediaTypes
+}
+
+// IsReadable always returns true.
+func (p *cborProvider) IsReadable(r *http.Request, v interface{}) bool {
+	return true
+}
+
+// ReadRequest decodes CBOR from request body.
+func (p *cborProvider) ReadRequest(r *http.Request, v interface{}) error {
+	decoder := codec.NewDecoder(r.Body, &p.handle)
+	return decoder.Decode(v)
+}
+
+// Produces returns CBOR media types.
+func (p *cborProvider) Produces() []string {
+	return cborMediaTypes
+}
+
+// IsWriteable always returns true.
+func (p *cborProvider) IsWriteable(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	return true
+}
+
+// WriteResponse encode v and writes to w.
+func (p *cborProvider) WriteResponse(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	encoder := codec.NewEncoder(w, &p.handle)
+	return encoder.Encode(v)
+}
@@ -0,0 +1,96 @@
+package views
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/goburrow/melon/server/filter"
+)
+
+// Envelope is the standard response body written by envelope provider.
+type Envelope struct {
+	Data  interface{}            `json:"data,omitempty" xml:"data,omitempty"`
+	Error interface{}            `json:"error,omitempty" xml:"error,omitempty"`
+	Meta  map[string]interface{} `json:"meta,omitempty" xml:"-"`
+}
+
+// envelopeProvider wraps responses of matching paths in Envelope.
+type envelopeProvider struct {
+	Provider
+	patterns []string
+}
+
+// EnvelopeOption adds option for envelope provider.
+type EnvelopeOption func(p *envelopeProvider)
+
+// NewEnvelopeProvider returns a Provider which wraps responses written by p in
+// Envelope. Error responses are set to field error and other entities to
+// field data. Field meta contains values added by SetMeta. Responses of all
+// paths are wrapped unless WithEnvelopePath is used. It should be registered
+// instead of p:
+//
+// 	views.NewBundle(views.NewEnvelopeProvider(views.NewJSONProvider(), views.WithEnvelopePath("/api/*")))
+func NewEnvelopeProvider(p Provider, options ...EnvelopeOption) Provider {
+	e := &envelopeProvider{
+		Provider: p,
+	}
+	for _, opt := range options {
+		opt(e)
+	}
+	if len(e.patterns) == 0 {
+		e.patterns = []string{"/*"}
+	}
+	return e
+}
+
+// WithEnvelopePath only wraps responses of requests matching path pattern.
+// See filter.MatchPath for pattern syntax.
+func WithEnvelopePath(pattern string) EnvelopeOption {
+	return func(p *envelopeProvider) {
+		p.patterns = append(p.patterns, pattern)
+	}
+}
+
+// WriteResponse writes v in Envelope if the request path matches.
+func (p *envelopeProvider) WriteResponse(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	if !p.matchPath(r.URL.Path) {
+		return p.Provider.WriteResponse(w, r, v)
+	}
+	envelope := &Envelope{}
+	if isErrorEntity(v) {
+		envelope.Error = v
+		// Envelope is not a problem details document.
+		h := w.Header()
+		if ct := h.Get("Content-Type"); strings.HasPrefix(ct, "application/problem+") {
+			h.Set("Content-Type", "application/"+strings.TrimPrefix(ct, "application/problem+"))
+		}
+	} else {
+		envelope.Data = v
+	}
+	if ctx := fromContext(r.Context()); ctx != nil {
+		envelope.Meta = ctx.meta
+	}
+	return p.Provider.WriteResponse(w, r, envelope)
+}
+
+func (p *envelopeProvider) matchPath(path string) bool {
+	for _, pattern := range p.patterns {
+		if filter.MatchPath(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetMeta adds value to field meta of Envelope of the response.
+func SetMeta(r *http.Request, key string, value interface{}) {
+	ctx := fromContext(r.Context())
+	if ctx == nil {
+		logger().Errorf("no handler in request context: %v", r.Context())
+		return
+	}
+	if ctx.meta == nil {
+		ctx.meta = make(map[string]interface{})
+	}
+	ctx.meta[key] = value
+}
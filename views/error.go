@@ -4,6 +4,10 @@ import (
 	"fmt"
 	"math/rand"
 	"net/http"
+	"reflect"
+
+	"github.com/goburrow/melon/server/problem"
+	"github.com/goburrow/melon/validation"
 )
 
 // ErrorMessage represents a HTTP error with status code and message.
@@ -34,45 +38,216 @@ func NewServerError(message string) *ErrorMessage {
 	}
 }
 
+// FieldError is a validation error of a field. Constraint is the violated
+// rule, e.g. notempty or max.
+type FieldError struct {
+	Field      string `json:"field,omitempty" xml:"field,attr,omitempty"`
+	Constraint string `json:"constraint,omitempty" xml:"constraint,attr,omitempty"`
+	Message    string `json:"message" xml:",chardata"`
+}
+
+// ValidationError represents an invalid request entity. It is responded with
+// status code 422 (Unprocessable Entity) and errors of each field.
+type ValidationError struct {
+	Code    int
+	Message string
+	Errors  []FieldError
+}
+
+// Error is for implementation of error interface.
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// newValidationError creates ValidationError from error returned by validator.
+// Errors of each field are given when err is validation.Errors or
+// *validation.FieldError.
+func newValidationError(err error) *ValidationError {
+	var errs validation.Errors
+	switch v := err.(type) {
+	case validation.Errors:
+		errs = v
+	case *validation.FieldError:
+		errs = validation.Errors{v}
+	}
+	e := &ValidationError{
+		Code:    statusUnprocessableEntity,
+		Message: err.Error(),
+	}
+	if errs == nil {
+		e.Errors = []FieldError{{Message: err.Error()}}
+		return e
+	}
+	e.Errors = make([]FieldError, 0, len(errs))
+	for _, fe := range errs {
+		e.Errors = append(e.Errors, FieldError{
+			Field:      fe.Field,
+			Constraint: fe.Rule,
+			Message:    fe.Message,
+		})
+	}
+	return e
+}
+
+// isErrorEntity returns true if v is an error response body written by
+// the error mapper.
+func isErrorEntity(v interface{}) bool {
+	switch v.(type) {
+	case *problem.Details, *ValidationProblem, *ErrorMessage, *ValidationError:
+		return true
+	}
+	return false
+}
+
 // ErrorMapper maps error to http error.
 type ErrorMapper interface {
 	MapError(http.ResponseWriter, *http.Request, error)
 }
 
+// ErrorMapping maps application errors to HTTP status code. Mappings registered
+// to the server environment are used by the default ErrorMapper, which is also
+// applied for panics recovered by the server:
+//
+// 	env.Server.Register(views.MapErrorValue(sql.ErrNoRows, http.StatusNotFound))
+// 	env.Server.Register(views.MapErrorType(&os.PathError{}, http.StatusNotFound))
+type ErrorMapping struct {
+	match func(error) bool
+	code  int
+}
+
+// MapErrorValue maps errors equal to target to status code.
+func MapErrorValue(target error, code int) *ErrorMapping {
+	return MapErrorFunc(func(err error) bool {
+		return err == target
+	}, code)
+}
+
+// MapErrorType maps errors which have the same type as target to status code.
+func MapErrorType(target error, code int) *ErrorMapping {
+	t := reflect.TypeOf(target)
+	return MapErrorFunc(func(err error) bool {
+		return reflect.TypeOf(err) == t
+	}, code)
+}
+
+// MapErrorFunc maps errors matched by function match to status code.
+func MapErrorFunc(match func(error) bool, code int) *ErrorMapping {
+	return &ErrorMapping{
+		match: match,
+		code:  code,
+	}
+}
+
+// Resolve returns ErrorMessage with the mapped status code and message of err
+// or nil if err is not matched.
+func (m *ErrorMapping) Resolve(err error) *ErrorMessage {
+	if !m.match(err) {
+		return nil
+	}
+	return &ErrorMessage{
+		Code:    m.code,
+		Message: err.Error(),
+	}
+}
+
 // errorMapper is a default implementation of ErrorMapper interface.
 type errorMapper struct {
+	mappings []*ErrorMapping
 }
 
 func newErrorMapper() *errorMapper {
 	return &errorMapper{}
 }
 
+// addMapping adds m to the mapping list. Mappings are matched in order.
+func (h *errorMapper) addMapping(m *ErrorMapping) {
+	h.mappings = append(h.mappings, m)
+}
+
 func (h *errorMapper) MapError(w http.ResponseWriter, r *http.Request, err error) {
-	var errMsg *ErrorMessage
+	for _, m := range h.mappings {
+		if errMsg := m.Resolve(err); errMsg != nil {
+			err = errMsg
+			break
+		}
+	}
+	ctx := fromContext(r.Context())
+	if ctx != nil && ctx.etag != "" {
+		w.Header().Set("ETag", ctx.etag)
+	}
+	if err == errNotModified {
+		// Response 304 must not contain a body.
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	var p *problem.Details
+	var body interface{}
 	switch v := err.(type) {
 	case *ErrorMessage:
-		errMsg = v
+		p = newProblem(r, v.Code, v.Message)
+		body = p
+	case *ValidationError:
+		p = newProblem(r, v.Code, v.Message)
+		body = &ValidationProblem{Details: *p, Errors: v.Errors}
 	default:
 		// Unknown error type, treat it as a server error
 		id := rand.Int63()
 		logger().Errorf("error handling request %s (ID %016x): %v", r.URL.Path, id, err)
-		errMsg = NewServerError(fmt.Sprintf(
+		p = newProblem(r, http.StatusInternalServerError, fmt.Sprintf(
 			"error processing your request (ID %016x)", id))
+		body = p
 	}
 	// Use provider to writes error when possible
-	if ctx := fromContext(r.Context()); ctx != nil {
-		writer, contentType := ctx.findWriter(w, r, errMsg)
+	if ctx != nil {
+		writer, contentType := ctx.findWriter(w, r, body)
 		if writer != nil {
 			if contentType != "" {
-				w.Header().Set("Content-Type", contentType)
+				w.Header().Set("Content-Type", problem.ContentTypeOf(contentType))
 			}
-			w.WriteHeader(errMsg.Code)
-			err = writer.WriteResponse(w, r, errMsg)
+			// Status is written with the body so that providers can still
+			// change headers, e.g. the envelope provider.
+			sw := &errorStatusWriter{ResponseWriter: w, status: p.Status}
+			err = writer.WriteResponse(sw, r, body)
 			if err != nil {
 				logger().Errorf("response writer: %v", err)
 			}
+			sw.WriteHeader(p.Status)
 			return
 		}
 	}
-	http.Error(w, errMsg.Message, errMsg.Code)
+	problem.Write(w, p.Status, body)
+}
+
+// errorStatusWriter writes status of an error response before the body or
+// when WriteHeader is first called.
+type errorStatusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *errorStatusWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (w *errorStatusWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(w.status)
+	return w.ResponseWriter.Write(b)
+}
+
+// ValidationProblem is the response body of ValidationError, which is problem
+// details with errors of each field.
+type ValidationProblem struct {
+	problem.Details
+	Errors []FieldError `json:"errors" xml:"errors>error"`
+}
+
+// newProblem returns problem details of the request.
+func newProblem(r *http.Request, code int, detail string) *problem.Details {
+	p := problem.New(code, detail)
+	p.Instance = r.URL.Path
+	return p
 }
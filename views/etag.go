@@ -0,0 +1,81 @@
+package views
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/goburrow/melon/server/etag"
+)
+
+var (
+	errNotModified        = &ErrorMessage{http.StatusNotModified, http.StatusText(http.StatusNotModified)}
+	errPreconditionFailed = &ErrorMessage{http.StatusPreconditionFailed, http.StatusText(http.StatusPreconditionFailed)}
+)
+
+// ETagOf returns strong entity tag of v computed from its JSON encoding.
+func ETagOf(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return etag.Compute(b, false), nil
+}
+
+// EvaluatePreconditions checks conditional headers of request r against the
+// current entity tag of the resource, which is also set to header ETag of
+// the response. It returns ErrorMessage with status code 304 (Not Modified)
+// when If-None-Match matches for GET and HEAD requests, or 412 (Precondition
+// Failed) when If-Match does not match or If-None-Match matches for other
+// methods, e.g. for optimistic concurrency control:
+//
+// 	current, _ := views.ETagOf(item)
+// 	if err := views.EvaluatePreconditions(r, current); err != nil {
+// 		return nil, err
+// 	}
+//
+// An empty entity tag means that the resource does not exist.
+func EvaluatePreconditions(r *http.Request, entityTag string) error {
+	if ctx := fromContext(r.Context()); ctx != nil {
+		ctx.etag = entityTag
+	}
+	if im := r.Header.Get("If-Match"); im != "" {
+		if !matchEntityTag(im, entityTag, true) {
+			return errPreconditionFailed
+		}
+	}
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if matchEntityTag(inm, entityTag, false) {
+			if r.Method == "GET" || r.Method == "HEAD" {
+				return errNotModified
+			}
+			return errPreconditionFailed
+		}
+	}
+	return nil
+}
+
+// matchEntityTag returns true if entityTag is in the list of header value.
+// Strong comparison is used for If-Match and weak comparison for If-None-Match.
+func matchEntityTag(header, entityTag string, strong bool) bool {
+	if entityTag == "" {
+		return false
+	}
+	if strong && strings.HasPrefix(entityTag, "W/") {
+		return false
+	}
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimSpace(v)
+		if v == "*" {
+			return true
+		}
+		if strong {
+			if v == entityTag {
+				return true
+			}
+		} else if strings.TrimPrefix(v, "W/") == strings.TrimPrefix(entityTag, "W/") {
+			return true
+		}
+	}
+	return false
+}
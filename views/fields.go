@@ -0,0 +1,115 @@
+package views
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// DefaultFieldsParam is the default query parameter of requested fields.
+const DefaultFieldsParam = "fields"
+
+// fieldsProvider prunes JSON responses to requested fields.
+type fieldsProvider struct {
+	Provider
+	param string
+}
+
+// NewFieldsProvider returns a Provider which removes fields of responses not
+// listed in query parameter param before writing them with JSON provider p.
+// Fields are separated by commas and nested fields by dots, e.g.
+// GET /users?fields=id,name,address.city. Fields of all elements are pruned
+// for arrays. If param is empty, DefaultFieldsParam is used. Error responses
+// are not pruned. It should be registered instead of p and wrap other
+// providers such as envelope provider so that only entities are pruned:
+//
+// 	views.NewBundle(views.NewFieldsProvider(views.NewJSONProvider(), ""))
+func NewFieldsProvider(p Provider, param string) Provider {
+	if param == "" {
+		param = DefaultFieldsParam
+	}
+	return &fieldsProvider{
+		Provider: p,
+		param:    param,
+	}
+}
+
+// WriteResponse writes only requested fields of v.
+func (p *fieldsProvider) WriteResponse(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	fields := parseFields(r.URL.Query().Get(p.param))
+	if fields == nil || isErrorEntity(v) {
+		return p.Provider.WriteResponse(w, r, v)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var data interface{}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	// Keep precision of numbers.
+	decoder.UseNumber()
+	if err = decoder.Decode(&data); err != nil {
+		return err
+	}
+	return p.Provider.WriteResponse(w, r, fields.prune(data))
+}
+
+// fieldSet is a tree of requested fields. A nil subtree selects the whole field.
+type fieldSet map[string]fieldSet
+
+// parseFields returns nil if no fields are requested.
+func parseFields(s string) fieldSet {
+	var fields fieldSet
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		if fields == nil {
+			fields = make(fieldSet)
+		}
+		node := fields
+		names := strings.Split(f, ".")
+		for i, name := range names {
+			sub, ok := node[name]
+			if ok && sub == nil {
+				// Whole field is already selected.
+				break
+			}
+			if i == len(names)-1 {
+				node[name] = nil
+				break
+			}
+			if sub == nil {
+				sub = make(fieldSet)
+				node[name] = sub
+			}
+			node = sub
+		}
+	}
+	return fields
+}
+
+func (f fieldSet) prune(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(f))
+		for name, sub := range f {
+			if value, ok := v[name]; ok {
+				if sub == nil {
+					m[name] = value
+				} else {
+					m[name] = sub.prune(value)
+				}
+			}
+		}
+		return m
+	case []interface{}:
+		for i := range v {
+			v[i] = f.prune(v[i])
+		}
+		return v
+	}
+	return v
+}
@@ -0,0 +1,72 @@
+package views
+
+import (
+	"io"
+	"net/http"
+	"regexp"
+)
+
+const (
+	// DefaultJSONPCallback is the default query parameter of JSONP callback.
+	DefaultJSONPCallback = "callback"
+
+	jsonpContentType = "application/javascript; charset=utf-8"
+	maxCallbackLen   = 128
+)
+
+// callbackPattern matches JavaScript identifiers which can be separated by dots.
+var callbackPattern = regexp.MustCompile(`^[a-zA-Z_$][0-9a-zA-Z_$]*(\.[a-zA-Z_$][0-9a-zA-Z_$]*)*$`)
+
+// jsonpProvider wraps JSON responses of GET requests in JavaScript callback.
+type jsonpProvider struct {
+	Provider
+	param string
+}
+
+// NewJSONPProvider returns a Provider which wraps responses of JSON provider p
+// in the callback function given in query parameter param for GET requests,
+// e.g. GET /users?callback=show responds show([...]);. If param is empty,
+// DefaultJSONPCallback is used. Invalid callback names are ignored.
+// It should be registered instead of p:
+//
+// 	views.NewBundle(views.NewJSONPProvider(views.NewJSONProvider(), ""))
+func NewJSONPProvider(p Provider, param string) Provider {
+	if param == "" {
+		param = DefaultJSONPCallback
+	}
+	return &jsonpProvider{
+		Provider: p,
+		param:    param,
+	}
+}
+
+// WriteResponse writes JSON response wrapped in the callback if requested.
+func (p *jsonpProvider) WriteResponse(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	callback := p.callback(r)
+	if callback == "" {
+		return p.Provider.WriteResponse(w, r, v)
+	}
+	w.Header().Set("Content-Type", jsonpContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// The comment prevents content sniffing attacks (e.g. Rosetta Flash).
+	if _, err := io.WriteString(w, "/**/"+callback+"("); err != nil {
+		return err
+	}
+	if err := p.Provider.WriteResponse(w, r, v); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, ");")
+	return err
+}
+
+// callback returns valid callback name in the request or empty string.
+func (p *jsonpProvider) callback(r *http.Request) string {
+	if r.Method != "GET" {
+		return ""
+	}
+	callback := r.URL.Query().Get(p.param)
+	if len(callback) > maxCallbackLen || !callbackPattern.MatchString(callback) {
+		return ""
+	}
+	return callback
+}
@@ -0,0 +1,85 @@
+package views
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/goburrow/melon/server/realip"
+)
+
+// Link is a hypermedia link to a resource.
+type Link struct {
+	Href string `json:"href" xml:"href,attr"`
+}
+
+// Links contains links by their relation types. It is usually embedded in
+// responses as field "_links":
+//
+// 	type User struct {
+// 		Name  string      `json:"name"`
+// 		Links views.Links `json:"_links"`
+// 	}
+type Links map[string]Link
+
+// urlBuilder builds URL of named routes. It is implemented by server router.
+type urlBuilder interface {
+	Name(name, pattern string)
+	URL(name string, params ...string) (string, error)
+}
+
+var errNoURLBuilder = errors.New("views: router does not support named routes")
+
+// WithName names the resource so that links to it can be built with URLFor.
+func WithName(name string) Option {
+	return func(h *httpHandler) {
+		h.name = name
+	}
+}
+
+// URLFor returns absolute URL of the resource named name. Path variables of the
+// resource are replaced by params, which are pairs of variable name and value.
+// Headers X-Forwarded-Proto and X-Forwarded-Host are only respected when the
+// request is from a trusted proxy, see package realip.
+func URLFor(r *http.Request, name string, params ...string) (string, error) {
+	ctx := fromContext(r.Context())
+	if ctx == nil {
+		logger().Errorf("no handler in request context: %v", r.Context())
+		return "", errInternalServerError
+	}
+	builder, ok := ctx.handler.router.(urlBuilder)
+	if !ok {
+		return "", errNoURLBuilder
+	}
+	path, err := builder.URL(name, params...)
+	if err != nil {
+		return "", err
+	}
+	scheme, host := "http", r.Host
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if realip.Resolved(r) {
+		if v := forwardedValue(r.Header.Get("X-Forwarded-Proto")); v != "" {
+			scheme = v
+		}
+		if v := forwardedValue(r.Header.Get("X-Forwarded-Host")); v != "" {
+			host = v
+		}
+	}
+	return scheme + "://" + host + path, nil
+}
+
+// LinkTo returns Link to the resource named name. See URLFor.
+func LinkTo(r *http.Request, name string, params ...string) (Link, error) {
+	href, err := URLFor(r, name, params...)
+	return Link{Href: href}, err
+}
+
+// forwardedValue returns the value set by the first proxy.
+func forwardedValue(v string) string {
+	if i := strings.IndexByte(v, ','); i >= 0 {
+		v = v[:i]
+	}
+	return strings.TrimSpace(v)
+}
@@ -0,0 +1,104 @@
+package views
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+const (
+	// DefaultMultipartMaxRequestSize is the default maximum size of multipart requests.
+	DefaultMultipartMaxRequestSize = 32 << 20
+	// DefaultMultipartMaxMemory is the default maximum bytes of files kept in memory.
+	DefaultMultipartMaxMemory = 1 << 20
+)
+
+var multipartMediaTypes = []string{
+	"multipart/form-data",
+}
+
+// MultipartConfiguration contains limits of multipart requests. It can be
+// embedded in the application configuration to create the provider:
+//
+// 	env.Server.Register(conf.Multipart.Build())
+type MultipartConfiguration struct {
+	// MaxRequestSize is the maximum number of bytes of request body.
+	MaxRequestSize int64
+	// MaxMemory is the maximum number of bytes of files stored in memory.
+	// The remaining parts are spilled into temporary files.
+	MaxMemory int64
+}
+
+// Build returns a multipart Provider with the configured limits.
+func (c *MultipartConfiguration) Build() Provider {
+	p := NewMultipartProvider()
+	if c.MaxRequestSize > 0 {
+		p.(*multipartProvider).maxRequestSize = c.MaxRequestSize
+	}
+	if c.MaxMemory > 0 {
+		p.(*multipartProvider).maxMemory = c.MaxMemory
+	}
+	return p
+}
+
+// multipartProvider reads multipart form requests.
+type multipartProvider struct {
+	maxRequestSize int64
+	maxMemory      int64
+}
+
+// NewMultipartProvider returns a Provider which reads multipart/form-data
+// requests into structs. Fields are bound with tag "param" using sources form
+// and file, see Params. Temporary files are removed after the response is written.
+func NewMultipartProvider() Provider {
+	return &multipartProvider{
+		maxRequestSize: DefaultMultipartMaxRequestSize,
+		maxMemory:      DefaultMultipartMaxMemory,
+	}
+}
+
+// Consumes returns multipart media types.
+func (p *multipartProvider) Consumes() []string {
+	return multipartMediaTypes
+}
+
+// IsReadable returns true if v is a pointer to struct.
+func (p *multipartProvider) IsReadable(r *http.Request, v interface{}) bool {
+	t := reflect.TypeOf(v)
+	return t != nil && t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct
+}
+
+// ReadRequest parses multipart form in request body and binds it to v.
+func (p *multipartProvider) ReadRequest(r *http.Request, v interface{}) error {
+	if r.MultipartForm == nil {
+		r.Body = http.MaxBytesReader(nil, r.Body, p.maxRequestSize)
+		if err := r.ParseMultipartForm(p.maxMemory); err != nil {
+			if strings.Contains(err.Error(), "request body too large") {
+				return &ErrorMessage{http.StatusRequestEntityTooLarge, err.Error()}
+			}
+			return err
+		}
+		form := r.MultipartForm
+		AfterResponse(r, func() {
+			if err := form.RemoveAll(); err != nil {
+				logger().Warnf("could not remove multipart files: %v", err)
+			}
+		})
+	}
+	return Params(r, v)
+}
+
+// Produces returns no media types as multipart responses are not supported.
+func (p *multipartProvider) Produces() []string {
+	return nil
+}
+
+// IsWriteable always returns false.
+func (p *multipartProvider) IsWriteable(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	return false
+}
+
+// WriteResponse returns errNotAcceptable.
+func (p *multipartProvider) WriteResponse(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	return errNotAcceptable
+}
@@ -0,0 +1,455 @@
+package views
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goburrow/melon/server/problem"
+)
+
+const (
+	openAPIVersion = "3.0.3"
+	// DefaultOpenAPIPath is the default path serving OpenAPI specification.
+	DefaultOpenAPIPath = "/openapi.json"
+)
+
+// Schema is an OpenAPI schema object.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Example              interface{}        `json:"example,omitempty"`
+}
+
+// Schemer can be implemented by entity types to provide their own schemas
+// instead of the ones generated from struct fields.
+type Schemer interface {
+	OpenAPISchema() *Schema
+}
+
+// OpenAPI serves OpenAPI 3 specification of registered resources.
+// Register it to the server environment:
+//
+// 	env.Server.Register(views.NewOpenAPI("Users", "1.0"))
+//
+// Entity schemas are generated from types given to resource options
+// WithRequestEntity and WithResponseEntity. Struct field tag "description"
+// adds description to the field schema.
+type OpenAPI struct {
+	Path    string
+	Title   string
+	Version string
+}
+
+// NewOpenAPI returns OpenAPI served at DefaultOpenAPIPath.
+func NewOpenAPI(title, version string) *OpenAPI {
+	return &OpenAPI{
+		Path:    DefaultOpenAPIPath,
+		Title:   title,
+		Version: version,
+	}
+}
+
+// WithSummary sets summary of the resource in OpenAPI specification.
+func WithSummary(summary string) Option {
+	return func(h *httpHandler) {
+		h.doc.summary = summary
+	}
+}
+
+// WithRequestEntity sets type of request entity for OpenAPI specification.
+func WithRequestEntity(v interface{}) Option {
+	return func(h *httpHandler) {
+		h.doc.request = reflect.TypeOf(v)
+	}
+}
+
+// WithResponseEntity sets type of response entity for OpenAPI specification.
+func WithResponseEntity(v interface{}) Option {
+	return func(h *httpHandler) {
+		h.doc.response = reflect.TypeOf(v)
+	}
+}
+
+// WithParams sets type of struct containing "param" tags for OpenAPI
+// specification. See Params.
+func WithParams(v interface{}) Option {
+	return func(h *httpHandler) {
+		h.doc.params = reflect.TypeOf(v)
+	}
+}
+
+// resourceDoc contains documentation of a resource.
+type resourceDoc struct {
+	summary  string
+	request  reflect.Type
+	response reflect.Type
+	params   reflect.Type
+}
+
+type openAPISpec struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       openAPIInfo                             `json:"info"`
+	Servers    []openAPIServer                         `json:"servers,omitempty"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components *openAPIComponents                      `json:"components,omitempty"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openAPIServer struct {
+	URL string `json:"url"`
+}
+
+type openAPIComponents struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+type openAPIOperation struct {
+	OperationID string                      `json:"operationId,omitempty"`
+	Summary     string                      `json:"summary,omitempty"`
+	Parameters  []*openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*openAPIResponse `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Content map[string]*openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                       `json:"description"`
+	Content     map[string]*openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIMediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+// openAPIHandler generates the specification on first request so that all
+// resources have been registered.
+type openAPIHandler struct {
+	conf     *OpenAPI
+	handlers map[string]*httpHandler
+	// pathPrefix is the router path prefix which is not included in paths.
+	pathPrefix string
+
+	once sync.Once
+	spec []byte
+	err  error
+}
+
+func (h *openAPIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.once.Do(func() {
+		h.spec, h.err = json.Marshal(h.build())
+	})
+	if h.err != nil {
+		logger().Errorf("openapi: %v", h.err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(h.spec)
+}
+
+func (h *openAPIHandler) build() *openAPISpec {
+	spec := &openAPISpec{
+		OpenAPI: openAPIVersion,
+		Info: openAPIInfo{
+			Title:   h.conf.Title,
+			Version: h.conf.Version,
+		},
+		Paths: make(map[string]map[string]*openAPIOperation),
+	}
+	if prefix := strings.TrimSuffix(h.pathPrefix, "/"); prefix != "" {
+		spec.Servers = []openAPIServer{{URL: prefix}}
+	}
+	schemas := &schemaGenerator{schemas: make(map[string]*Schema)}
+	for key, handler := range h.handlers {
+		i := strings.IndexByte(key, ' ')
+		method, pattern := strings.ToLower(key[:i]), key[i+1:]
+		if method == "*" || method == "" {
+			continue
+		}
+		path, pathParams := openAPIPath(pattern)
+		item := spec.Paths[path]
+		if item == nil {
+			item = make(map[string]*openAPIOperation)
+			spec.Paths[path] = item
+		}
+		item[method] = handler.operation(pathParams, schemas)
+	}
+	if len(schemas.schemas) > 0 {
+		spec.Components = &openAPIComponents{Schemas: schemas.schemas}
+	}
+	return spec
+}
+
+// operation returns OpenAPI operation object of the handler and its variants.
+func (h *httpHandler) operation(pathParams []string, schemas *schemaGenerator) *openAPIOperation {
+	op := &openAPIOperation{
+		OperationID: h.name,
+		Summary:     h.doc.summary,
+		Responses:   make(map[string]*openAPIResponse),
+	}
+	for _, name := range pathParams {
+		op.Parameters = append(op.Parameters, &openAPIParameter{
+			Name:     name,
+			In:       "path",
+			Required: true,
+			Schema:   &Schema{Type: "string"},
+		})
+	}
+	if h.doc.params != nil {
+		op.Parameters = append(op.Parameters, paramsOf(h.doc.params, schemas)...)
+	}
+	response := &openAPIResponse{Description: http.StatusText(http.StatusOK)}
+	for _, v := range append([]*httpHandler{h}, h.variants...) {
+		if v.doc.request != nil {
+			if op.RequestBody == nil {
+				op.RequestBody = &openAPIRequestBody{Content: make(map[string]*openAPIMediaType)}
+			}
+			schema := schemas.generate(v.doc.request)
+			for _, m := range v.consumedMediaTypes() {
+				op.RequestBody.Content[m] = &openAPIMediaType{Schema: schema}
+			}
+		}
+		if v.doc.response != nil {
+			if response.Content == nil {
+				response.Content = make(map[string]*openAPIMediaType)
+			}
+			schema := schemas.generate(v.doc.response)
+			for _, m := range v.producedMediaTypes() {
+				response.Content[m] = &openAPIMediaType{Schema: schema}
+			}
+		}
+	}
+	op.Responses["200"] = response
+	if op.RequestBody != nil {
+		op.Responses[strconv.Itoa(statusUnprocessableEntity)] = &openAPIResponse{
+			Description: "Validation failed",
+			Content: map[string]*openAPIMediaType{
+				problem.ContentType: {Schema: schemas.generate(validationProblemType)},
+			},
+		}
+	}
+	return op
+}
+
+// consumedMediaTypes returns media types explicitly consumed by the handler
+// or all media types of request readers.
+func (h *httpHandler) consumedMediaTypes() []string {
+	if len(h.providers.consumes) > 0 {
+		return h.providers.consumes
+	}
+	var mediaTypes []string
+	for _, r := range h.providers.parent.readers {
+		mediaTypes = append(mediaTypes, r.Consumes()...)
+	}
+	return mediaTypes
+}
+
+// openAPIPath converts router pattern to OpenAPI path and returns names of
+// path variables.
+func openAPIPath(pattern string) (string, []string) {
+	pattern = strings.TrimSuffix(pattern, "*")
+	var buf bytes.Buffer
+	var names []string
+	for len(pattern) > 0 {
+		start := strings.IndexByte(pattern, '{')
+		if start < 0 {
+			buf.WriteString(pattern)
+			break
+		}
+		end := start + 1
+		for level := 1; end < len(pattern); end++ {
+			if pattern[end] == '{' {
+				level++
+			} else if pattern[end] == '}' {
+				if level--; level == 0 {
+					break
+				}
+			}
+		}
+		name := pattern[start+1 : end]
+		if i := strings.IndexByte(name, ':'); i >= 0 {
+			name = name[:i]
+		}
+		names = append(names, name)
+		buf.WriteString(pattern[:start])
+		buf.WriteString("{" + name + "}")
+		if end >= len(pattern) {
+			break
+		}
+		pattern = pattern[end+1:]
+	}
+	return buf.String(), names
+}
+
+// paramsOf returns parameters of struct type t having "param" tags.
+// Path parameters are already included from the route pattern.
+func paramsOf(t reflect.Type, schemas *schemaGenerator) []*openAPIParameter {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	var params []*openAPIParameter
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get(paramTag)
+		if tag == "" || field.PkgPath != "" {
+			continue
+		}
+		p, err := parseParamTag(tag)
+		if err != nil || p.source == "path" || p.source == "form" || p.source == "file" {
+			continue
+		}
+		schema := schemas.generate(field.Type)
+		if p.hasDefault {
+			schema.Example = p.defaultValue
+		}
+		params = append(params, &openAPIParameter{
+			Name:     p.name,
+			In:       p.source,
+			Required: p.required,
+			Schema:   schema,
+		})
+	}
+	return params
+}
+
+var (
+	timeType              = reflect.TypeOf(time.Time{})
+	schemerType           = reflect.TypeOf((*Schemer)(nil)).Elem()
+	validationProblemType = reflect.TypeOf(ValidationProblem{})
+)
+
+// schemaGenerator generates schemas of Go types. Named struct types are added
+// to components and referenced.
+type schemaGenerator struct {
+	schemas map[string]*Schema
+}
+
+func (g *schemaGenerator) generate(t reflect.Type) *Schema {
+	if t.Kind() != reflect.Ptr && t.Kind() != reflect.Interface && t.Implements(schemerType) {
+		return reflect.New(t).Elem().Interface().(Schemer).OpenAPISchema()
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+		if reflect.PtrTo(t).Implements(schemerType) {
+			return reflect.New(t).Interface().(Schemer).OpenAPISchema()
+		}
+	}
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case durationType:
+		return &Schema{Type: "string"}
+	case fileHeaderType.Elem():
+		return &Schema{Type: "string", Format: "binary"}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.generate(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.generate(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.generateStruct(t)
+		}
+		name := t.Name()
+		if _, ok := g.schemas[name]; !ok {
+			// Placeholder for recursive types.
+			g.schemas[name] = &Schema{}
+			*g.schemas[name] = *g.generateStruct(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+	return &Schema{}
+}
+
+func (g *schemaGenerator) generateStruct(t reflect.Type) *Schema {
+	s := &Schema{
+		Type:       "object",
+		Properties: make(map[string]*Schema),
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name, omitEmpty := field.Name, false
+		if tag := field.Tag.Get("json"); tag != "" {
+			parts := strings.Split(tag, ",")
+			if parts[0] == "-" {
+				continue
+			}
+			if parts[0] != "" {
+				name = parts[0]
+			}
+			for _, p := range parts[1:] {
+				omitEmpty = omitEmpty || p == "omitempty"
+			}
+		}
+		if field.Anonymous && field.Tag.Get("json") == "" && field.Type.Kind() == reflect.Struct {
+			embedded := g.generateStruct(field.Type)
+			for k, v := range embedded.Properties {
+				s.Properties[k] = v
+			}
+			s.Required = append(s.Required, embedded.Required...)
+			continue
+		}
+		schema := g.generate(field.Type)
+		// Siblings of $ref are ignored.
+		if desc := field.Tag.Get("description"); desc != "" && schema.Ref == "" {
+			schema.Description = desc
+		}
+		s.Properties[name] = schema
+		if !omitEmpty && field.Type.Kind() != reflect.Ptr {
+			s.Required = append(s.Required, name)
+		}
+	}
+	sort.Strings(s.Required)
+	return s
+}
@@ -0,0 +1,242 @@
+package views
+
+import (
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goburrow/melon/server/router"
+)
+
+const paramTag = "param"
+
+var (
+	durationType    = reflect.TypeOf(time.Duration(0))
+	fileHeaderType  = reflect.TypeOf((*multipart.FileHeader)(nil))
+	fileHeadersType = reflect.TypeOf([]*multipart.FileHeader(nil))
+)
+
+// Params binds request parameters to fields of struct pointed by v according
+// to field tag "param". The tag contains the source and name of the parameter,
+// optionally followed by a default value and required flag:
+//
+// 	type ListParams struct {
+// 		Page    int           `param:"query=page,default=1"`
+// 		Tags    []string      `param:"query=tag"`
+// 		Name    string        `param:"form=name,required"`
+// 		Token   string        `param:"header=X-Token"`
+// 		ID      int64         `param:"path=id"`
+// 		Timeout time.Duration `param:"query=timeout,default=5s"`
+// 	}
+//
+// Supported sources are query, form, header and path. Fields can be string,
+// bool, integer, float, time.Duration or slice of them. Source file binds
+// uploaded files of multipart forms to fields of type *multipart.FileHeader
+// or []*multipart.FileHeader. It returns ErrorMessage with status code 400 if
+// a parameter is missing or invalid.
+func Params(r *http.Request, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("views: params must be a pointer to struct")
+	}
+	rv = rv.Elem()
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tag := field.Tag.Get(paramTag)
+		if tag == "" || field.PkgPath != "" {
+			continue
+		}
+		p, err := parseParamTag(tag)
+		if err != nil {
+			return fmt.Errorf("views: field %s: %v", field.Name, err)
+		}
+		if p.source == "file" {
+			if err = setFile(rv.Field(i), r, p); err != nil {
+				return err
+			}
+			continue
+		}
+		values, err := p.values(r)
+		if err != nil {
+			return err
+		}
+		if len(values) == 0 {
+			if p.required {
+				return NewBadRequest(fmt.Sprintf("missing %s parameter %s", p.source, p.name))
+			}
+			if !p.hasDefault {
+				continue
+			}
+			values = []string{p.defaultValue}
+		}
+		if err = setParam(rv.Field(i), values); err != nil {
+			if err, ok := err.(unsupportedTypeError); ok {
+				// Programming error rather than invalid request.
+				return fmt.Errorf("views: unsupported type %v of %s parameter %s", err.typ, p.source, p.name)
+			}
+			return NewBadRequest(fmt.Sprintf("invalid %s parameter %s: %v", p.source, p.name, err))
+		}
+	}
+	return nil
+}
+
+// param is a parsed tag of a field.
+type param struct {
+	source       string
+	name         string
+	defaultValue string
+	hasDefault   bool
+	required     bool
+}
+
+func parseParamTag(tag string) (*param, error) {
+	parts := strings.Split(tag, ",")
+	kv := strings.SplitN(parts[0], "=", 2)
+	if len(kv) != 2 || kv[1] == "" {
+		return nil, fmt.Errorf("invalid tag %q", tag)
+	}
+	p := &param{source: kv[0], name: kv[1]}
+	switch p.source {
+	case "query", "form", "header", "path", "file":
+	default:
+		return nil, fmt.Errorf("unsupported source %q", p.source)
+	}
+	for i := 1; i < len(parts); i++ {
+		switch {
+		case parts[i] == "required":
+			p.required = true
+		case strings.HasPrefix(parts[i], "default="):
+			p.defaultValue = strings.TrimPrefix(parts[i], "default=")
+			p.hasDefault = true
+		default:
+			// Commas are allowed in default value.
+			if !p.hasDefault {
+				return nil, fmt.Errorf("invalid tag %q", tag)
+			}
+			p.defaultValue += "," + parts[i]
+		}
+	}
+	return p, nil
+}
+
+// values returns values of the parameter in request r.
+func (p *param) values(r *http.Request) ([]string, error) {
+	switch p.source {
+	case "query":
+		return r.URL.Query()[p.name], nil
+	case "form":
+		if err := r.ParseForm(); err != nil {
+			return nil, NewBadRequest(err.Error())
+		}
+		return r.PostForm[p.name], nil
+	case "header":
+		return r.Header[http.CanonicalHeaderKey(p.name)], nil
+	case "path":
+		if v, ok := router.PathParams(r)[p.name]; ok {
+			return []string{v}, nil
+		}
+	}
+	return nil, nil
+}
+
+// setFile sets uploaded files of the multipart request to field v.
+func setFile(v reflect.Value, r *http.Request, p *param) error {
+	var files []*multipart.FileHeader
+	if r.MultipartForm != nil {
+		files = r.MultipartForm.File[p.name]
+	}
+	if len(files) == 0 {
+		if p.required {
+			return NewBadRequest(fmt.Sprintf("missing %s parameter %s", p.source, p.name))
+		}
+		return nil
+	}
+	switch v.Type() {
+	case fileHeaderType:
+		v.Set(reflect.ValueOf(files[0]))
+	case fileHeadersType:
+		v.Set(reflect.ValueOf(files))
+	default:
+		return fmt.Errorf("views: unsupported type %v of file parameter %s", v.Type(), p.name)
+	}
+	return nil
+}
+
+// setParam converts values and sets to field v.
+func setParam(v reflect.Value, values []string) error {
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8 {
+		s := reflect.MakeSlice(v.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := setValue(s.Index(i), value); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+	}
+	return setValue(v, values[0])
+}
+
+func setValue(v reflect.Value, value string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case reflect.Ptr:
+		p := reflect.New(v.Type().Elem())
+		if err := setValue(p.Elem(), value); err != nil {
+			return err
+		}
+		v.Set(p)
+	default:
+		return unsupportedTypeError{v.Type()}
+	}
+	return nil
+}
+
+// unsupportedTypeError is returned when the field type can not be converted
+// from parameter values.
+type unsupportedTypeError struct {
+	typ reflect.Type
+}
+
+func (e unsupportedTypeError) Error() string {
+	return "unsupported type " + e.typ.String()
+}
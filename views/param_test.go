@@ -0,0 +1,85 @@
+package views
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goburrow/melon/server/router"
+)
+
+type testParams struct {
+	Page    int           `param:"query=page,default=1"`
+	Tags    []string      `param:"query=tag"`
+	Name    string        `param:"form=name,required"`
+	Token   string        `param:"header=x-token"`
+	ID      int64         `param:"path=id"`
+	Timeout time.Duration `param:"query=timeout,default=5s"`
+	Limit   *uint         `param:"query=limit"`
+	Fields  string        `param:"query=fields,default=a,b"`
+	Ignored string
+}
+
+func TestParams(t *testing.T) {
+	var params testParams
+	var err error
+	rt := router.New()
+	rt.Handle("POST", "/items/{id}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params = testParams{}
+		err = Params(r, &params)
+	}))
+	serve := func(target, body string) {
+		r := httptest.NewRequest("POST", target, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.Header.Set("X-Token", "secret")
+		rt.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	serve("/items/10?tag=a&tag=b&limit=3", "name=melon")
+	limit := uint(3)
+	expected := testParams{
+		Page:    1,
+		Tags:    []string{"a", "b"},
+		Name:    "melon",
+		Token:   "secret",
+		ID:      10,
+		Timeout: 5 * time.Second,
+		Limit:   &limit,
+		Fields:  "a,b",
+	}
+	if err != nil || !reflect.DeepEqual(expected, params) {
+		t.Fatalf("unexpected params: %+v %v", params, err)
+	}
+
+	serve("/items/10?page=2&timeout=1m", "name=melon")
+	if err != nil || params.Page != 2 || params.Timeout != time.Minute || params.Limit != nil {
+		t.Fatalf("unexpected params: %+v %v", params, err)
+	}
+
+	serve("/items/10?page=x", "name=melon")
+	if e, ok := err.(*ErrorMessage); !ok || e.Code != http.StatusBadRequest ||
+		!strings.Contains(e.Message, "invalid query parameter page") {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	serve("/items/10", "")
+	if e, ok := err.(*ErrorMessage); !ok || e.Code != http.StatusBadRequest ||
+		e.Message != "missing form parameter name" {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+}
+
+func TestParamsUnsupportedType(t *testing.T) {
+	var params struct {
+		Filter map[string]string `param:"query=filter"`
+	}
+	r := httptest.NewRequest("GET", "/items?filter=x", nil)
+	err := Params(r, &params)
+	if _, ok := err.(*ErrorMessage); ok || err == nil ||
+		err.Error() != "views: unsupported type map[string]string of query parameter filter" {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+}
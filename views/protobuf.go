@@ -0,0 +1,74 @@
+package views
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/golang/protobuf/proto"
+)
+
+var protobufMediaTypes = []string{
+	"application/x-protobuf",
+	"application/protobuf",
+}
+
+var errNotProtoMessage = errors.New("views: value is not a proto.Message")
+
+// protobufProvider handles Protocol Buffers requests and responses.
+type protobufProvider struct{}
+
+// NewProtobufProvider returns a Provider which reads and writes proto.Message
+// in Protocol Buffers binary format.
+func NewProtobufProvider() Provider {
+	return &protobufProvider{}
+}
+
+// Consumes returns Protocol Buffers media types.
+func (p *protobufProvider) Consumes() []string {
+	return protobufMediaTypes
+}
+
+// IsReadable returns true if v is a proto.Message.
+func (p *protobufProvider) IsReadable(r *http.Request, v interface{}) bool {
+	_, ok := v.(proto.Message)
+	return ok
+}
+
+// ReadRequest decodes Protocol Buffers message from request body.
+func (p *protobufProvider) ReadRequest(r *http.Request, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return errNotProtoMessage
+	}
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(b, m)
+}
+
+// Produces returns Protocol Buffers media types.
+func (p *protobufProvider) Produces() []string {
+	return protobufMediaTypes
+}
+
+// IsWriteable returns true if v is a proto.Message.
+func (p *protobufProvider) IsWriteable(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	_, ok := v.(proto.Message)
+	return ok
+}
+
+// WriteResponse encodes v and writes to w.
+func (p *protobufProvider) WriteResponse(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return errNotProtoMessage
+	}
+	b, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
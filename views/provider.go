@@ -1,9 +1,14 @@
 package views
 
-import "net/http"
-
-// requestReader reads entity from message body.
-type requestReader interface {
+import (
+	"net/http"
+	"strings"
+)
+
+// MessageBodyReader reads entity from message body. Readers and writers added
+// to the environment as resources are selected by Content-Type and Accept of
+// requests like providers, e.g. for custom media types such as text/csv.
+type MessageBodyReader interface {
 	// Consumes returns list of MIME types which this reader can read.
 	Consumes() []string
 
@@ -11,8 +16,8 @@ type requestReader interface {
 	ReadRequest(*http.Request, interface{}) error
 }
 
-// responseWriter writes entity to message body.
-type responseWriter interface {
+// MessageBodyWriter writes entity to message body.
+type MessageBodyWriter interface {
 	// Procudes returns list of MIME types which this writer can write.
 	Produces() []string
 
@@ -20,32 +25,32 @@ type responseWriter interface {
 	WriteResponse(http.ResponseWriter, *http.Request, interface{}) error
 }
 
-// Provider define reader and writer for particular MIME types.
+// Provider defines reader and writer for particular MIME types.
 type Provider interface {
-	requestReader
-	responseWriter
+	MessageBodyReader
+	MessageBodyWriter
 }
 
 // providers is used to look up providers by MIME type.
 // TODO: Error mapper.
 type providers interface {
-	GetRequestReaders(string) []requestReader
-	GetResponseWriters(string) []responseWriter
+	GetRequestReaders(string) []MessageBodyReader
+	GetResponseWriters(string) []MessageBodyWriter
 }
 
 // providerMap associates media types with respective providers.
 type providerMap struct {
-	readers       []requestReader
-	readersByType map[string][]requestReader
+	readers       []MessageBodyReader
+	readersByType map[string][]MessageBodyReader
 
-	writers       []responseWriter
-	writersByType map[string][]responseWriter
+	writers       []MessageBodyWriter
+	writersByType map[string][]MessageBodyWriter
 }
 
 func newProviderMap() *providerMap {
 	return &providerMap{
-		readersByType: make(map[string][]requestReader),
-		writersByType: make(map[string][]responseWriter),
+		readersByType: make(map[string][]MessageBodyReader),
+		writersByType: make(map[string][]MessageBodyWriter),
 	}
 }
 
@@ -54,14 +59,14 @@ func (p *providerMap) AddProvider(provider Provider) {
 	p.addResponseWriter(provider)
 }
 
-func (p *providerMap) addRequestReader(reader requestReader) {
+func (p *providerMap) addRequestReader(reader MessageBodyReader) {
 	p.readers = append(p.readers, reader)
 	for _, m := range reader.Consumes() {
 		p.readersByType[m] = append(p.readersByType[m], reader)
 	}
 }
 
-func (p *providerMap) addResponseWriter(writer responseWriter) {
+func (p *providerMap) addResponseWriter(writer MessageBodyWriter) {
 	p.writers = append(p.writers, writer)
 	for _, m := range writer.Produces() {
 		p.writersByType[m] = append(p.writersByType[m], writer)
@@ -69,24 +74,33 @@ func (p *providerMap) addResponseWriter(writer responseWriter) {
 }
 
 // GetRequestReaders returns readers which can handle the given mime type.
-// All readers are returned if mime is wildcard.
-func (p *providerMap) GetRequestReaders(mime string) []requestReader {
+// All readers are returned if mime is wildcard. Vendor media types with
+// a structured syntax suffix, e.g. application/vnd.app.v2+json, are handled by
+// readers of the suffix, i.e. application/json, unless they are registered.
+func (p *providerMap) GetRequestReaders(mime string) []MessageBodyReader {
 	if isWildcard(mime) {
 		return p.readers
 	}
-	return p.readersByType[mime]
+	if readers, ok := p.readersByType[mime]; ok {
+		return readers
+	}
+	return p.readersByType[suffixMediaType(mime)]
 }
 
-// GetRequestReaders returns writers which can handle the given mime type.
-// All writers are returned if mime is wildcard.
-func (p *providerMap) GetResponseWriters(mime string) []responseWriter {
+// GetResponseWriters returns writers which can handle the given mime type.
+// All writers are returned if mime is wildcard. Like readers, vendor media
+// types fall back to writers of their structured syntax suffix.
+func (p *providerMap) GetResponseWriters(mime string) []MessageBodyWriter {
 	if isWildcard(mime) {
 		return p.writers
 	}
-	return p.writersByType[mime]
+	if writers, ok := p.writersByType[mime]; ok {
+		return writers
+	}
+	return p.writersByType[suffixMediaType(mime)]
 }
 
-// explicitProviderMap returns only supported requestReader and responseWriter
+// explicitProviderMap returns only supported MessageBodyReader and MessageBodyWriter
 // from explicited consumes and produces.
 type explicitProviderMap struct {
 	consumes []string
@@ -103,7 +117,7 @@ func newExplicitProviderMap(parent *providerMap) *explicitProviderMap {
 
 // GetRequestReaders returns only readers which support the given media type
 // and that media type must be in the consumes list if set.
-func (p *explicitProviderMap) GetRequestReaders(mime string) []requestReader {
+func (p *explicitProviderMap) GetRequestReaders(mime string) []MessageBodyReader {
 	if len(p.consumes) == 0 {
 		return p.parent.GetRequestReaders(mime)
 	}
@@ -127,7 +141,7 @@ func (p *explicitProviderMap) GetRequestReaders(mime string) []requestReader {
 
 // GetResponseWriters returns only writers which support the given media type
 // and that media type must be in the produces list if set.
-func (p *explicitProviderMap) GetResponseWriters(mime string) []responseWriter {
+func (p *explicitProviderMap) GetResponseWriters(mime string) []MessageBodyWriter {
 	if len(p.produces) == 0 {
 		return p.parent.GetResponseWriters(mime)
 	}
@@ -149,6 +163,17 @@ func (p *explicitProviderMap) GetResponseWriters(mime string) []responseWriter {
 	return nil
 }
 
+// suffixMediaType returns media type of the structured syntax suffix (RFC 6839)
+// of mediaType, e.g. application/json for application/vnd.app.v2+json,
+// or empty string if there is no suffix.
+func suffixMediaType(mediaType string) string {
+	i := strings.LastIndexByte(mediaType, '+')
+	if i < 0 || i == len(mediaType)-1 {
+		return ""
+	}
+	return "application/" + mediaType[i+1:]
+}
+
 func isWildcard(mediaType string) bool {
 	return mediaType == "" || mediaType == "*/*"
 }
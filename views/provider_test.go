@@ -1,6 +1,10 @@
 package views
 
-import "testing"
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+)
 
 func TestDefaultProviders(t *testing.T) {
 	p := newProviderMap()
@@ -72,3 +76,83 @@ func TestExplicitProviders(t *testing.T) {
 		t.Fatalf("provider does not support text/xml %#v", p)
 	}
 }
+
+func TestProtobufProvider(t *testing.T) {
+	p := newProviderMap()
+	protobufProvider := NewProtobufProvider()
+	p.AddProvider(protobufProvider)
+
+	writers := p.GetResponseWriters("application/x-protobuf")
+	if len(writers) != 1 || writers[0] != protobufProvider {
+		t.Fatalf("provider does not support application/x-protobuf %#v", p)
+	}
+	var v struct{}
+	if protobufProvider.IsReadable(nil, &v) || protobufProvider.IsWriteable(nil, nil, &v) {
+		t.Fatalf("provider should only support proto.Message")
+	}
+
+	w := httptest.NewRecorder()
+	m := &testMessage{Name: "melon", Count: 2}
+	if err := protobufProvider.WriteResponse(w, nil, m); err != nil {
+		t.Fatal(err)
+	}
+	if w.Body.Len() == 0 {
+		t.Fatalf("unexpected response: %v", w.Body.Bytes())
+	}
+	var m2 testMessage
+	r := httptest.NewRequest("POST", "/", w.Body)
+	if err := protobufProvider.ReadRequest(r, &m2); err != nil {
+		t.Fatal(err)
+	}
+	if m2 != *m {
+		t.Fatalf("unexpected message: %+v, expect: %+v", m2, *m)
+	}
+}
+
+// testMessage is a proto.Message declared with struct tags.
+type testMessage struct {
+	Name  string `protobuf:"bytes,1,opt,name=name,proto3"`
+	Count int32  `protobuf:"varint,2,opt,name=count,proto3"`
+}
+
+func (m *testMessage) Reset()         { *m = testMessage{} }
+func (m *testMessage) String() string { return fmt.Sprintf("%+v", *m) }
+func (*testMessage) ProtoMessage()    {}
+
+func TestCBORProvider(t *testing.T) {
+	p := newProviderMap()
+	cborProvider := NewCBORProvider()
+	p.AddProvider(NewJSONProvider())
+	p.AddProvider(cborProvider)
+
+	readers := p.GetRequestReaders("application/cbor")
+	if len(readers) != 1 || readers[0] != cborProvider {
+		t.Fatalf("provider does not support application/cbor %#v", p)
+	}
+	writers := p.GetResponseWriters("application/cbor")
+	if len(writers) != 1 || writers[0] != cborProvider {
+		t.Fatalf("provider does not support application/cbor %#v", p)
+	}
+
+	type item struct {
+		Name string
+		Tags []string
+	}
+	w := httptest.NewRecorder()
+	v := &item{Name: "melon", Tags: []string{"a", "b"}}
+	if err := cborProvider.WriteResponse(w, nil, v); err != nil {
+		t.Fatal(err)
+	}
+	// Map of 2 pairs.
+	if w.Body.Len() == 0 || w.Body.Bytes()[0] != 0xa2 {
+		t.Fatalf("unexpected response: %x", w.Body.Bytes())
+	}
+	var v2 item
+	r := httptest.NewRequest("POST", "/", w.Body)
+	if err := cborProvider.ReadRequest(r, &v2); err != nil {
+		t.Fatal(err)
+	}
+	if v2.Name != v.Name || len(v2.Tags) != 2 || v2.Tags[0] != "a" || v2.Tags[1] != "b" {
+		t.Fatalf("unexpected value: %+v, expect: %+v", v2, *v)
+	}
+}
@@ -3,7 +3,10 @@ package views
 
 import (
 	"context"
+	"mime"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -39,7 +42,11 @@ type bundle struct {
 }
 
 // NewBundle allocates and returns a new Bundle which will register provided providers.
+// JSON and XML providers are registered when no providers are given.
 func NewBundle(providers ...Provider) core.Bundle {
+	if len(providers) == 0 {
+		providers = []Provider{NewJSONProvider(), NewXMLProvider()}
+	}
 	return &bundle{
 		providers: providers,
 	}
@@ -55,6 +62,8 @@ func (u *bundle) Run(conf interface{}, env *core.Environment) error {
 	for _, p := range u.providers {
 		env.Server.Register(p)
 	}
+	// Also render errors of recovered panics with error mappings.
+	env.Server.Register(handler.defaultErrorMapper)
 	env.Server.AddResourceHandler(handler)
 	return nil
 }
@@ -67,30 +76,59 @@ type resourceHandler struct {
 	// providers contains all supported Provider.
 	providers   *providerMap
 	errorMapper ErrorMapper
+	// defaultErrorMapper contains registered error mappings.
+	defaultErrorMapper *errorMapper
+	// handlers contains registered handlers by method and path.
+	handlers map[string]*httpHandler
 }
 
 func newResourceHandler(env *core.Environment) *resourceHandler {
+	errorMapper := newErrorMapper()
 	return &resourceHandler{
 		router:    env.Server.Router,
 		validator: env.Validator,
 
-		providers:   newProviderMap(),
-		errorMapper: newErrorMapper(),
+		providers:          newProviderMap(),
+		errorMapper:        errorMapper,
+		defaultErrorMapper: errorMapper,
+		handlers:           make(map[string]*httpHandler),
 	}
 }
 
 // HandleResource registers providers.
-// It supports Provider, ErrorMapper and Resource.
+// It supports Provider, ErrorMapper, ErrorMapping, OpenAPI, Resource and Routes.
 func (h *resourceHandler) HandleResource(v interface{}) {
 	if r, ok := v.(Provider); ok {
 		h.providers.AddProvider(r)
+	} else if r, ok := v.(MessageBodyReader); ok {
+		h.providers.addRequestReader(r)
+	} else if r, ok := v.(MessageBodyWriter); ok {
+		h.providers.addResponseWriter(r)
 	}
 	if r, ok := v.(ErrorMapper); ok {
 		// FIMXE: support multiple error mappers.
 		h.errorMapper = r
 	}
+	if r, ok := v.(*ErrorMapping); ok {
+		h.defaultErrorMapper.addMapping(r)
+	}
+	if r, ok := v.(*OpenAPI); ok {
+		h.router.Handle("GET", r.Path, &openAPIHandler{
+			conf:       r,
+			handlers:   h.handlers,
+			pathPrefix: h.router.PathPrefix(),
+		})
+	}
+	resources, err := routesOf(v)
+	if err != nil {
+		logger().Errorf("could not register routes of %T: %v", v, err)
+	}
+	for _, r := range resources {
+		h.HandleResource(r)
+	}
 	if r, ok := v.(*Resource); ok {
 		handler := &httpHandler{
+			router:      h.router,
 			handler:     r.handler,
 			errorMapper: h.errorMapper,
 			validator:   h.validator,
@@ -99,7 +137,23 @@ func (h *resourceHandler) HandleResource(v interface{}) {
 		for _, opt := range r.options {
 			opt(handler)
 		}
+		// Resources registered for the same method and path are variants
+		// which are selected by request media types.
+		key := r.method + " " + r.path
+		if first, ok := h.handlers[key]; ok {
+			logger().Debugf("adding variant for %s", key)
+			first.variants = append(first.variants, handler)
+			return
+		}
+		h.handlers[key] = handler
 		h.router.Handle(r.method, r.path, handler)
+		if handler.name != "" {
+			if builder, ok := h.router.(urlBuilder); ok {
+				builder.Name(handler.name, r.path)
+			} else {
+				logger().Warnf("could not name resource %s: %v", key, errNoURLBuilder)
+			}
+		}
 	}
 }
 
@@ -136,6 +190,7 @@ var (
 
 // httpHandler implements melon server.webResource
 type httpHandler struct {
+	router      core.Router
 	handler     http.Handler
 	errorMapper ErrorMapper
 	validator   core.Validator
@@ -146,11 +201,52 @@ type httpHandler struct {
 	metricLatency  *metrics.Histogram
 
 	htmlTemplate string
+	// name is the route name for building links.
+	name string
+	// doc is used for generating OpenAPI specification.
+	doc resourceDoc
+
+	// variants are other handlers registered for the same method and path.
+	variants []*httpHandler
 }
 
-// ServeHTTP attaches handlerContext to request context. It also checks
-// Content-Type and Accept header to see if requested media type is supported.
+// ServeHTTP dispatches the request to the handler variant which supports
+// media types in Content-Type and Accept header of the request.
 func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if len(h.variants) > 0 {
+		// Responses of variants are selected by request headers.
+		w.Header().Add("Vary", "Accept")
+		h.selectVariant(r).serveHTTP(w, r)
+		return
+	}
+	h.serveHTTP(w, r)
+}
+
+// selectVariant returns the first handler which can read and write the request.
+// If none is found, h is returned so that respective error is produced.
+func (h *httpHandler) selectVariant(r *http.Request) *httpHandler {
+	if h.isAcceptable(r) {
+		return h
+	}
+	for _, v := range h.variants {
+		if v.isAcceptable(r) {
+			return v
+		}
+	}
+	return h
+}
+
+// isAcceptable returns true if there are readers and writers for the request.
+func (h *httpHandler) isAcceptable(r *http.Request) bool {
+	if len(h.getRequestReaders(r)) == 0 {
+		return false
+	}
+	return len(h.getResponseWriters(r)) > 0
+}
+
+// serveHTTP attaches handlerContext to request context. It also checks
+// Content-Type and Accept header to see if requested media type is supported.
+func (h *httpHandler) serveHTTP(w http.ResponseWriter, r *http.Request) {
 	if h.metricRequests != "" {
 		h.metricRequests.Add()
 	}
@@ -159,15 +255,18 @@ func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	requestReaders := h.getRequestReaders(r)
-	responseWriters, contentType := h.getResponseWriters(r)
+	responseWriters := h.getResponseWriters(r)
 	handlerCtx := &handlerContext{
-		handler:     h,
-		readers:     requestReaders,
-		writers:     responseWriters,
-		contentType: contentType,
+		handler: h,
+		readers: requestReaders,
+		writers: responseWriters,
+	}
+	if len(responseWriters) > 0 {
+		handlerCtx.contentType = responseWriters[0].contentType
 	}
 	ctx := newContext(r.Context(), handlerCtx)
 	r = r.WithContext(ctx)
+	defer handlerCtx.cleanup()
 	// Check if readable
 	if len(requestReaders) == 0 {
 		h.errorMapper.MapError(w, r, errUnsupportedMediaType)
@@ -181,32 +280,125 @@ func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	h.handler.ServeHTTP(w, r)
 }
 
-// getRequestReaders returns a list of requestReader according Content-Type in the request header.
-func (h *httpHandler) getRequestReaders(r *http.Request) []requestReader {
-	mime := r.Header.Get("Content-Type")
-	return h.providers.GetRequestReaders(mime)
+// getRequestReaders returns a list of MessageBodyReader according Content-Type in the request header.
+func (h *httpHandler) getRequestReaders(r *http.Request) []MessageBodyReader {
+	contentType := r.Header.Get("Content-Type")
+	if contentType != "" {
+		// Media type parameters such as charset are not used for matching.
+		if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+			contentType = mediaType
+		}
+	}
+	return h.providers.GetRequestReaders(contentType)
 }
 
-// getResponseWriters returns a list of responseWriter according Accept in the request header.
-func (h *httpHandler) getResponseWriters(r *http.Request) ([]responseWriter, string) {
-	mime := r.Header.Get("Accept")
-	if isWildcard(mime) {
-		return h.providers.GetResponseWriters(mime), ""
+// responseWriters are MessageBodyWriter for an acceptable media type.
+type responseWriters struct {
+	writers     []MessageBodyWriter
+	contentType string
+}
+
+// getResponseWriters returns MessageBodyWriter of all acceptable media types
+// according Accept in the request header. Media ranges are ordered by their
+// quality values so that the next one can be tried when none of the writers
+// of a media type is able to write the response.
+func (h *httpHandler) getResponseWriters(r *http.Request) []responseWriters {
+	accept := r.Header.Get("Accept")
+	if isWildcard(accept) {
+		return h.appendDefaultResponseWriters(nil)
 	}
-	mediaTypes := strings.Split(mime, ",")
-	// Return providers that support the first mime type
-	for _, mime = range mediaTypes {
-		// TODO: support priority
-		idx := strings.Index(mime, ";")
-		if idx >= 0 {
-			mime = mime[:idx]
+	var accepted []responseWriters
+	for _, mediaType := range parseAccept(accept) {
+		if isWildcard(mediaType) {
+			accepted = h.appendDefaultResponseWriters(accepted)
+			continue
+		}
+		if strings.HasSuffix(mediaType, "/*") {
+			// Produced media types of the range in order.
+			prefix := mediaType[:len(mediaType)-1]
+			for _, m := range h.producedMediaTypes() {
+				if strings.HasPrefix(m, prefix) {
+					if writers := h.providers.GetResponseWriters(m); len(writers) > 0 {
+						accepted = append(accepted, responseWriters{writers, m})
+					}
+				}
+			}
+			continue
 		}
-		writers := h.providers.GetResponseWriters(mime)
+		writers := h.providers.GetResponseWriters(mediaType)
 		if len(writers) > 0 {
-			return writers, mime
+			accepted = append(accepted, responseWriters{writers, mediaType})
 		}
 	}
-	return nil, ""
+	return accepted
+}
+
+// appendDefaultResponseWriters appends default writers to accepted.
+func (h *httpHandler) appendDefaultResponseWriters(accepted []responseWriters) []responseWriters {
+	writers, contentType := h.getDefaultResponseWriters()
+	if len(writers) > 0 {
+		accepted = append(accepted, responseWriters{writers, contentType})
+	}
+	return accepted
+}
+
+// getDefaultResponseWriters returns writers of the first explicitly produced
+// media type or all writers if the handler does not specify any, in which case
+// the media type is decided by the selected writer.
+func (h *httpHandler) getDefaultResponseWriters() ([]MessageBodyWriter, string) {
+	for _, m := range h.providers.produces {
+		if writers := h.providers.parent.GetResponseWriters(m); len(writers) > 0 {
+			return writers, m
+		}
+	}
+	return h.providers.GetResponseWriters(""), ""
+}
+
+// producedMediaTypes returns media types explicitly produced by the handler
+// or all media types of response writers.
+func (h *httpHandler) producedMediaTypes() []string {
+	if len(h.providers.produces) > 0 {
+		return h.providers.produces
+	}
+	var mediaTypes []string
+	for _, w := range h.providers.parent.writers {
+		mediaTypes = append(mediaTypes, w.Produces()...)
+	}
+	return mediaTypes
+}
+
+// mediaRange is a media range with its quality value in header Accept.
+type mediaRange struct {
+	mediaType string
+	quality   float64
+}
+
+// parseAccept returns acceptable media types sorted by quality values.
+// Media types with zero quality are excluded.
+func parseAccept(accept string) []string {
+	var ranges []mediaRange
+	for _, v := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(v)
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			quality, err = strconv.ParseFloat(q, 64)
+			if err != nil || quality <= 0 {
+				continue
+			}
+		}
+		ranges = append(ranges, mediaRange{mediaType: mediaType, quality: quality})
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].quality > ranges[j].quality
+	})
+	mediaTypes := make([]string, len(ranges))
+	for i := range ranges {
+		mediaTypes[i] = ranges[i].mediaType
+	}
+	return mediaTypes
 }
 
 func (h *httpHandler) setMetrics(name string) {
@@ -228,11 +420,36 @@ func (h *httpHandler) recordLatency(start time.Time) {
 // TODO: May be it needs an allocation pool.
 type handlerContext struct {
 	handler *httpHandler
-	readers []requestReader
-	writers []responseWriter
+	readers []MessageBodyReader
+	// writers are ordered by preference of the response media types.
+	writers []responseWriters
 
 	// contentType is expected response content type
 	contentType string
+	// cleanups are called after the response is written.
+	cleanups []func()
+	// meta contains values of field meta in response Envelope.
+	meta map[string]interface{}
+	// etag is set to the response header ETag.
+	etag string
+}
+
+// AfterResponse registers fn to be called after the handler has written
+// the response, e.g. for releasing resources of the request.
+func AfterResponse(r *http.Request, fn func()) {
+	ctx := fromContext(r.Context())
+	if ctx == nil {
+		logger().Errorf("no handler in request context: %v", r.Context())
+		return
+	}
+	ctx.cleanups = append(ctx.cleanups, fn)
+}
+
+// cleanup calls registered functions in reverse order.
+func (c *handlerContext) cleanup() {
+	for i := len(c.cleanups) - 1; i >= 0; i-- {
+		c.cleanups[i]()
+	}
 }
 
 // contextKey is a value for use with context.WithValue
@@ -258,7 +475,7 @@ func fromContext(ctx context.Context) *handlerContext {
 }
 
 // findReader finds first reader which can read request body to data.
-func (c *handlerContext) findReader(r *http.Request, v interface{}) requestReader {
+func (c *handlerContext) findReader(r *http.Request, v interface{}) MessageBodyReader {
 	for _, reader := range c.readers {
 		if reader.IsReadable(r, v) {
 			return reader
@@ -268,19 +485,23 @@ func (c *handlerContext) findReader(r *http.Request, v interface{}) requestReade
 }
 
 // findWriter finds first writer which can write data and response content type.
-func (c *handlerContext) findWriter(w http.ResponseWriter, r *http.Request, data interface{}) (responseWriter, string) {
-	for _, writer := range c.writers {
-		if writer.IsWriteable(w, r, data) {
-			contentType := c.contentType
-			if isWildcard(contentType) {
-				contentTypes := writer.Produces()
-				if len(contentTypes) > 0 {
-					contentType = contentTypes[0]
-				} else {
-					contentType = ""
+// Writers of less preferred media types are tried if none of the preferred
+// ones can write data.
+func (c *handlerContext) findWriter(w http.ResponseWriter, r *http.Request, data interface{}) (MessageBodyWriter, string) {
+	for _, accepted := range c.writers {
+		for _, writer := range accepted.writers {
+			if writer.IsWriteable(w, r, data) {
+				contentType := accepted.contentType
+				if isWildcard(contentType) {
+					contentTypes := writer.Produces()
+					if len(contentTypes) > 0 {
+						contentType = contentTypes[0]
+					} else {
+						contentType = ""
+					}
 				}
+				return writer, contentType
 			}
-			return writer, contentType
 		}
 	}
 	return nil, c.contentType
@@ -294,6 +515,16 @@ func Serve(w http.ResponseWriter, r *http.Request, data interface{}) {
 		logger().Errorf("no handler in request context: %v", r.Context())
 		return
 	}
+	if ctx.etag != "" {
+		w.Header().Set("ETag", ctx.etag)
+	}
+	if s, ok := data.(*StreamingOutput); ok {
+		if s.ContentType == "" && !isWildcard(ctx.contentType) {
+			w.Header().Set("Content-Type", ctx.contentType)
+		}
+		Stream(w, r, s)
+		return
+	}
 	writer, contentType := ctx.findWriter(w, r, data)
 	if writer == nil {
 		// FIXME: Hanlde unknown type
@@ -324,30 +555,50 @@ func Error(w http.ResponseWriter, r *http.Request, err error) {
 	ctx.handler.errorMapper.MapError(w, r, err)
 }
 
-// Entity reads and validates entity v from request r.
+// Entity reads and validates entity v from request r like Bind.
 func Entity(r *http.Request, v interface{}) error {
+	return Bind(r, v)
+}
+
+// Bind decodes request body to v using the provider negotiated by request
+// Content-Type and validates v with the application validator.
+// It returns *ValidationError with status code 422 if v is invalid, which is
+// responded as ValidationProblem.
+func Bind(r *http.Request, v interface{}) error {
+	ctx, err := readEntity(r, v)
+	if err != nil {
+		return err
+	}
+	validator := ctx.handler.validator
+	if validator != nil {
+		err = validator.Validate(v)
+		if err != nil {
+			return newValidationError(err)
+		}
+	}
+	return nil
+}
+
+// readEntity reads entity v from request r and returns the handler context.
+func readEntity(r *http.Request, v interface{}) (*handlerContext, error) {
 	ctx := fromContext(r.Context())
 	if ctx == nil {
 		// Invalid state
 		logger().Errorf("no handler in request context: %v", r.Context())
-		return errInternalServerError
+		return nil, errInternalServerError
 	}
 	reader := ctx.findReader(r, v)
 	if reader == nil {
-		return errUnsupportedMediaType
+		return nil, errUnsupportedMediaType
 	}
 	err := reader.ReadRequest(r, v)
 	if err != nil {
-		return &ErrorMessage{statusUnprocessableEntity, err.Error()}
-	}
-	validator := ctx.handler.validator
-	if validator != nil {
-		err = validator.Validate(v)
-		if err != nil {
-			return &ErrorMessage{http.StatusBadRequest, err.Error()}
+		if e, ok := err.(*ErrorMessage); ok {
+			return nil, e
 		}
+		return nil, &ErrorMessage{statusUnprocessableEntity, err.Error()}
 	}
-	return nil
+	return ctx, nil
 }
 
 // HandlerFunc is a http.Handler which allows users to write view handler like:
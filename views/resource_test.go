@@ -0,0 +1,805 @@
+package views
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goburrow/melon/core"
+	"github.com/goburrow/melon/server/realip"
+	"github.com/goburrow/melon/server/router"
+	"github.com/goburrow/melon/validation"
+)
+
+func newTestEnvironment() *core.Environment {
+	env := core.NewEnvironment()
+	env.Server.Router = router.New()
+	return env
+}
+
+func newTestHandler(env *core.Environment, components ...interface{}) *resourceHandler {
+	h := newResourceHandler(env)
+	h.HandleResource(NewJSONProvider())
+	h.HandleResource(NewXMLProvider())
+	for _, c := range components {
+		h.HandleResource(c)
+	}
+	return h
+}
+
+func serveTest(handler http.Handler, method, path string, header map[string]string, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func constHandler(v interface{}) HandlerFunc {
+	return func(*http.Request) (interface{}, error) {
+		return v, nil
+	}
+}
+
+func TestResourceVariants(t *testing.T) {
+	env := newTestEnvironment()
+	newTestHandler(env,
+		NewResource("GET", "/item", constHandler("json"), WithProduces("application/json")),
+		NewResource("GET", "/item", constHandler("xml"), WithProduces("application/xml")),
+		NewResource("POST", "/item", constHandler("posted"), WithConsumes("application/json")),
+	)
+	w := serveTest(env.Server.Router.(http.Handler), "GET", "/item", map[string]string{"Accept": "text/html, application/xml"}, "")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/xml" ||
+		!strings.Contains(w.Body.String(), "xml") {
+		t.Fatalf("unexpected response: %v %v %v", w.Code, w.Header(), w.Body.String())
+	}
+	w = serveTest(env.Server.Router.(http.Handler), "GET", "/item", map[string]string{"Accept": "application/json"}, "")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json" ||
+		strings.TrimSpace(w.Body.String()) != `"json"` {
+		t.Fatalf("unexpected response: %v %v %v", w.Code, w.Header(), w.Body.String())
+	}
+	w = serveTest(env.Server.Router.(http.Handler), "GET", "/item", map[string]string{"Accept": "text/html"}, "")
+	if w.Code != http.StatusNotAcceptable {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Body.String())
+	}
+	w = serveTest(env.Server.Router.(http.Handler), "POST", "/item", map[string]string{"Content-Type": "application/json; charset=utf-8"}, "{}")
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Body.String())
+	}
+	w = serveTest(env.Server.Router.(http.Handler), "POST", "/item", map[string]string{"Content-Type": "application/xml"}, "<a/>")
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Body.String())
+	}
+}
+
+func TestVendorMediaTypes(t *testing.T) {
+	env := newTestEnvironment()
+	newTestHandler(env,
+		NewResource("GET", "/item", constHandler("v1"), WithProduces("application/vnd.melon.v1+json")),
+		NewResource("GET", "/item", constHandler("v2"), WithProduces("application/vnd.melon.v2+json", "application/vnd.melon.v2+xml")),
+		NewResource("POST", "/item", HandlerFunc(func(r *http.Request) (interface{}, error) {
+			var item testItem
+			if err := Entity(r, &item); err != nil {
+				return nil, err
+			}
+			return item.Name, nil
+		}), WithConsumes("application/vnd.melon.v2+json")),
+	)
+	handler := env.Server.Router.(http.Handler)
+	tests := []struct {
+		accept      string
+		contentType string
+		body        string
+	}{
+		{"application/vnd.melon.v2+json", "application/vnd.melon.v2+json", `"v2"`},
+		{"application/vnd.melon.v1+json, application/vnd.melon.v2+json;q=0.5", "application/vnd.melon.v1+json", `"v1"`},
+		{"*/*", "application/vnd.melon.v1+json", `"v1"`},
+		{"application/vnd.melon.v2+xml", "application/vnd.melon.v2+xml", `<string>v2</string>`},
+	}
+	for _, test := range tests {
+		w := serveTest(handler, "GET", "/item", map[string]string{"Accept": test.accept}, "")
+		if w.Code != http.StatusOK || w.Header().Get("Content-Type") != test.contentType ||
+			w.Header().Get("Vary") != "Accept" || !strings.Contains(w.Body.String(), test.body) {
+			t.Fatalf("unexpected response of %+v: %v %v %v", test, w.Code, w.Header(), w.Body.String())
+		}
+	}
+	w := serveTest(handler, "GET", "/item", map[string]string{"Accept": "application/vnd.melon.v3+json"}, "")
+	if w.Code != http.StatusNotAcceptable {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Body.String())
+	}
+	w = serveTest(handler, "POST", "/item", map[string]string{"Content-Type": "application/vnd.melon.v2+json", "Accept": "application/json"}, `{"name":"melon"}`)
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != `"melon"` {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Body.String())
+	}
+}
+
+func TestParseAccept(t *testing.T) {
+	tests := []struct {
+		accept   string
+		expected string
+	}{
+		{"application/json", "application/json"},
+		{"text/html, application/xml;q=0.9, */*;q=0.8", "text/html,application/xml,*/*"},
+		{"application/xml;q=0.5, application/json", "application/json,application/xml"},
+		{"text/html;q=0, application/*", "application/*"},
+		{"invalid;;, text/plain", "text/plain"},
+	}
+	for _, test := range tests {
+		actual := strings.Join(parseAccept(test.accept), ",")
+		if actual != test.expected {
+			t.Fatalf("unexpected media types of %q: %v", test.accept, actual)
+		}
+	}
+}
+
+func TestContentNegotiation(t *testing.T) {
+	env := newTestEnvironment()
+	newTestHandler(env,
+		NewResource("GET", "/item", constHandler("item")),
+		NewResource("GET", "/text", constHandler("text"), WithProduces("text/xml", "text/json")),
+	)
+	handler := env.Server.Router.(http.Handler)
+	tests := []struct {
+		path        string
+		accept      string
+		status      int
+		contentType string
+	}{
+		{"/item", "application/xml;q=0.5, application/json", 200, "application/json"},
+		{"/item", "text/html, application/xml;q=0.1", 200, "application/xml"},
+		{"/item", "application/*", 200, "application/json"},
+		{"/item", "text/html, */*;q=0.1", 200, "application/json"},
+		{"/item", "application/json;q=0", 406, ""},
+		{"/text", "text/*", 200, "text/xml"},
+		{"/text", "application/*", 406, ""},
+	}
+	for _, test := range tests {
+		w := serveTest(handler, "GET", test.path, map[string]string{"Accept": test.accept}, "")
+		if w.Code != test.status || (test.contentType != "" && w.Header().Get("Content-Type") != test.contentType) {
+			t.Fatalf("unexpected response of %+v: %v %v %v", test, w.Code, w.Header(), w.Body.String())
+		}
+	}
+}
+
+type testItem struct {
+	XMLName xml.Name `json:"-" xml:"item"`
+	Name    string   `json:"name" xml:"name" valid:"notempty"`
+	Count   int      `json:"count" xml:"count,attr"`
+}
+
+func TestXMLEntity(t *testing.T) {
+	env := newTestEnvironment()
+	newTestHandler(env,
+		NewResource("POST", "/item", HandlerFunc(func(r *http.Request) (interface{}, error) {
+			var item testItem
+			if err := Entity(r, &item); err != nil {
+				return nil, err
+			}
+			item.Count++
+			return &item, nil
+		})),
+		NewResource("GET", "/map", constHandler(map[string]string{"a": "b"})),
+	)
+	handler := env.Server.Router.(http.Handler)
+	header := map[string]string{
+		"Content-Type": "application/xml; charset=utf-8",
+		"Accept":       "application/xml",
+	}
+	w := serveTest(handler, "POST", "/item", header, `<item count="1"><name>melon</name></item>`)
+	expected := xml.Header + `<item count="2"><name>melon</name></item>`
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/xml" ||
+		w.Body.String() != expected {
+		t.Fatalf("unexpected response: %v %v %v", w.Code, w.Header(), w.Body.String())
+	}
+	header["Accept"] = "application/json"
+	w = serveTest(handler, "POST", "/item", header, `<item count="1"><name>melon</name></item>`)
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != `{"name":"melon","count":2}` {
+		t.Fatalf("unexpected response: %v %v %v", w.Code, w.Header(), w.Body.String())
+	}
+	w = serveTest(handler, "GET", "/map", map[string]string{"Accept": "application/xml, application/json;q=0.5"}, "")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json" ||
+		strings.TrimSpace(w.Body.String()) != `{"a":"b"}` {
+		t.Fatalf("unexpected response: %v %v %v", w.Code, w.Header(), w.Body.String())
+	}
+}
+
+func TestBind(t *testing.T) {
+	env := newTestEnvironment()
+	env.Validator, _ = validation.NewFactory().BuildValidator(nil)
+	newTestHandler(env,
+		NewResource("POST", "/item", HandlerFunc(func(r *http.Request) (interface{}, error) {
+			var item testItem
+			if err := Bind(r, &item); err != nil {
+				return nil, err
+			}
+			return &item, nil
+		})),
+		NewResource("POST", "/entity", HandlerFunc(func(r *http.Request) (interface{}, error) {
+			var item testItem
+			if err := Entity(r, &item); err != nil {
+				return nil, err
+			}
+			return &item, nil
+		})),
+	)
+	handler := env.Server.Router.(http.Handler)
+	header := map[string]string{"Content-Type": "application/json"}
+	w := serveTest(handler, "POST", "/item", header, `{"name":"melon"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Body.String())
+	}
+	w = serveTest(handler, "POST", "/item", header, `{"count":1}`)
+	expected := `{"type":"about:blank","title":"Unprocessable Entity","status":422,"detail":"Name: must not be empty","instance":"/item",` +
+		`"errors":[{"field":"Name","constraint":"notempty","message":"must not be empty"}]}`
+	if w.Code != 422 || w.Header().Get("Content-Type") != "application/problem+json" ||
+		strings.TrimSpace(w.Body.String()) != expected {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Body.String())
+	}
+	w = serveTest(handler, "POST", "/entity", header, `{"count":1}`)
+	if w.Code != 422 || strings.TrimSpace(w.Body.String()) != strings.Replace(expected, `"/item"`, `"/entity"`, 1) {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Body.String())
+	}
+	w = serveTest(handler, "POST", "/item", header, `{`)
+	if w.Code != 422 {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Body.String())
+	}
+	w = serveTest(handler, "POST", "/item", map[string]string{"Content-Type": "text/plain"}, `name`)
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Body.String())
+	}
+}
+
+type testError struct{}
+
+func (testError) Error() string { return "test error" }
+
+func TestErrorMapping(t *testing.T) {
+	errNotFound := errors.New("item not found")
+	env := newTestEnvironment()
+	newTestHandler(env,
+		MapErrorValue(errNotFound, http.StatusNotFound),
+		MapErrorType(&testError{}, http.StatusConflict),
+		NewResource("GET", "/value", HandlerFunc(func(*http.Request) (interface{}, error) {
+			return nil, errNotFound
+		})),
+		NewResource("GET", "/type", HandlerFunc(func(*http.Request) (interface{}, error) {
+			return nil, &testError{}
+		})),
+		NewResource("GET", "/other", HandlerFunc(func(*http.Request) (interface{}, error) {
+			return nil, errors.New("other")
+		})),
+	)
+	handler := env.Server.Router.(http.Handler)
+	header := map[string]string{"Accept": "application/json"}
+	w := serveTest(handler, "GET", "/value", header, "")
+	if w.Code != http.StatusNotFound || strings.TrimSpace(w.Body.String()) != `{"type":"about:blank","title":"Not Found","status":404,"detail":"item not found","instance":"/value"}` {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Body.String())
+	}
+	w = serveTest(handler, "GET", "/type", header, "")
+	if w.Code != http.StatusConflict || strings.TrimSpace(w.Body.String()) != `{"type":"about:blank","title":"Conflict","status":409,"detail":"test error","instance":"/type"}` {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Body.String())
+	}
+	w = serveTest(handler, "GET", "/other", header, "")
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Body.String())
+	}
+}
+
+func TestStreamingOutput(t *testing.T) {
+	env := newTestEnvironment()
+	newTestHandler(env,
+		NewResource("GET", "/export", HandlerFunc(func(r *http.Request) (interface{}, error) {
+			return &StreamingOutput{
+				ContentType: "text/csv",
+				Write: func(w io.Writer) error {
+					for i := 0; i < 3; i++ {
+						if _, err := fmt.Fprintf(w, "%d\n", i); err != nil {
+							return err
+						}
+					}
+					return nil
+				},
+			}, nil
+		})),
+	)
+	w := serveTest(env.Server.Router.(http.Handler), "GET", "/export", nil, "")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/csv" ||
+		w.Body.String() != "0\n1\n2\n" || !w.Flushed {
+		t.Fatalf("unexpected response: %v %v %q", w.Code, w.Header(), w.Body.String())
+	}
+}
+
+func TestStreamDisconnected(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+	err := Stream(w, r, &StreamingOutput{
+		Write: func(w io.Writer) error {
+			if _, err := io.WriteString(w, "a"); err != nil {
+				return err
+			}
+			cancel()
+			_, err := io.WriteString(w, "b")
+			return err
+		},
+	})
+	if err != context.Canceled || w.Body.String() != "a" {
+		t.Fatalf("unexpected result: %v %q", err, w.Body.String())
+	}
+}
+
+func TestURLFor(t *testing.T) {
+	env := newTestEnvironment()
+	newTestHandler(env,
+		NewResource("GET", "/users/{id}", HandlerFunc(func(r *http.Request) (interface{}, error) {
+			self, err := LinkTo(r, "user", "id", router.PathParams(r)["id"])
+			if err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{"_links": Links{"self": self}}, nil
+		}), WithName("user")),
+	)
+	networks, _ := realip.ParseNetworks("10.0.0.0/8")
+	env.Server.Router.(*router.Router).AddFilter(realip.NewFilter(realip.WithTrusted(networks...)))
+	handler := env.Server.Router.(http.Handler)
+	header := map[string]string{
+		"X-Forwarded-For":   "1.2.3.4",
+		"X-Forwarded-Proto": "https",
+		"X-Forwarded-Host":  "api.example.com, proxy.local",
+	}
+	w := serveTest(handler, "GET", "/users/1", header, "")
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != `{"_links":{"self":{"href":"http://example.com/users/1"}}}` {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/users/2", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != `{"_links":{"self":{"href":"https://api.example.com/users/2"}}}` {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Body.String())
+	}
+}
+
+type testUpload struct {
+	Name  string                  `param:"form=name,required"`
+	File  *multipart.FileHeader   `param:"file=file,required"`
+	Files []*multipart.FileHeader `param:"file=files"`
+}
+
+func TestMultipart(t *testing.T) {
+	var cleaned bool
+	env := newTestEnvironment()
+	conf := MultipartConfiguration{MaxRequestSize: 1024, MaxMemory: 16}
+	newTestHandler(env,
+		conf.Build(),
+		NewResource("POST", "/upload", HandlerFunc(func(r *http.Request) (interface{}, error) {
+			var upload testUpload
+			if err := Entity(r, &upload); err != nil {
+				return nil, err
+			}
+			AfterResponse(r, func() { cleaned = true })
+			f, err := upload.File.Open()
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+			b, err := ioutil.ReadAll(f)
+			if err != nil {
+				return nil, err
+			}
+			return fmt.Sprintf("%s:%s:%d", upload.Name, b, len(upload.Files)), nil
+		})),
+	)
+	handler := env.Server.Router.(http.Handler)
+	newBody := func(content string) (map[string]string, string) {
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		mw.WriteField("name", "melon")
+		fw, _ := mw.CreateFormFile("file", "a.txt")
+		io.WriteString(fw, content)
+		mw.Close()
+		return map[string]string{"Content-Type": mw.FormDataContentType(), "Accept": "application/json"}, buf.String()
+	}
+	header, body := newBody("file content larger than memory")
+	w := serveTest(handler, "POST", "/upload", header, body)
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != `"melon:file content larger than memory:0"` || !cleaned {
+		t.Fatalf("unexpected response: %v %v %v", w.Code, w.Body.String(), cleaned)
+	}
+	header, body = newBody(strings.Repeat("a", 2048))
+	w = serveTest(handler, "POST", "/upload", header, body)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Body.String())
+	}
+}
+
+type testUser struct {
+	ID      int64      `json:"id" description:"User ID"`
+	Name    string     `json:"name"`
+	Email   *string    `json:"email"`
+	Tags    []string   `json:"tags,omitempty"`
+	Created time.Time  `json:"created"`
+	Friends []testUser `json:"friends,omitempty"`
+}
+
+type testListParams struct {
+	Page  int    `param:"query=page,default=1"`
+	Token string `param:"header=X-Token,required"`
+}
+
+type testMoney struct {
+	currency string
+}
+
+func (m *testMoney) OpenAPISchema() *Schema {
+	return &Schema{Type: "string", Description: "Amount in " + m.currency}
+}
+
+func TestOpenAPI(t *testing.T) {
+	env := newTestEnvironment()
+	env.Server.Router = router.New(router.WithPathPrefix("/api"))
+	newTestHandler(env,
+		NewOpenAPI("Users", "1.0"),
+		NewResource("GET", "/users", constHandler(nil), WithName("listUsers"),
+			WithParams(testListParams{}), WithResponseEntity([]testUser{})),
+		NewResource("POST", "/users", constHandler(nil), WithConsumes("application/json"),
+			WithRequestEntity(&testUser{}), WithResponseEntity(&testUser{}), WithProduces("application/json")),
+		NewResource("GET", "/users/{id:[0-9]+}", constHandler(nil), WithSummary("Get user"),
+			WithResponseEntity(&testUser{}), WithProduces("application/json")),
+		NewResource("GET", "/balance", constHandler(nil), WithResponseEntity(&testMoney{}),
+			WithProduces("application/json")),
+	)
+	w := serveTest(env.Server.Router.(http.Handler), "GET", "/api/openapi.json", nil, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected response: %v %v", w.Code, w.Body.String())
+	}
+	var spec map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &spec); err != nil {
+		t.Fatal(err)
+	}
+	get := func(v interface{}, keys ...string) interface{} {
+		for _, k := range keys {
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				return nil
+			}
+			v = m[k]
+		}
+		return v
+	}
+	tests := []struct {
+		keys     []string
+		expected interface{}
+	}{
+		{[]string{"openapi"}, "3.0.3"},
+		{[]string{"info", "title"}, "Users"},
+		{[]string{"paths", "/users", "get", "operationId"}, "listUsers"},
+		{[]string{"paths", "/users", "get", "responses", "200", "content", "application/xml", "schema", "type"}, "array"},
+		{[]string{"paths", "/users", "post", "requestBody", "content", "application/json", "schema", "$ref"}, "#/components/schemas/testUser"},
+		{[]string{"paths", "/users", "post", "responses", "422", "content", "application/problem+json", "schema", "$ref"}, "#/components/schemas/ValidationProblem"},
+		{[]string{"paths", "/users/{id}", "get", "summary"}, "Get user"},
+		{[]string{"paths", "/balance", "get", "responses", "200", "content", "application/json", "schema", "description"}, "Amount in "},
+		{[]string{"components", "schemas", "testUser", "properties", "id", "description"}, "User ID"},
+		{[]string{"components", "schemas", "testUser", "properties", "created", "format"}, "date-time"},
+		{[]string{"components", "schemas", "testUser", "properties", "friends", "items", "$ref"}, "#/components/schemas/testUser"},
+		{[]string{"components", "schemas", "ValidationProblem", "properties", "errors", "items", "$ref"}, "#/components/schemas/FieldError"},
+		{[]string{"components", "schemas", "FieldError", "properties", "constraint", "type"}, "string"},
+	}
+	for _, test := range tests {
+		if actual := get(spec, test.keys...); actual != test.expected {
+			t.Fatalf("unexpected %v: %v, want: %v", test.keys, actual, test.expected)
+		}
+	}
+	servers, _ := spec["servers"].([]interface{})
+	if len(servers) != 1 || get(servers[0], "url") != "/api" {
+		t.Fatalf("unexpected servers: %v", spec["servers"])
+	}
+	params := get(spec, "paths", "/users", "get", "parameters").([]interface{})
+	if len(params) != 2 || get(params[1], "name") != "X-Token" || get(params[1], "required") != true {
+		t.Fatalf("unexpected parameters: %v", params)
+	}
+	required := get(spec, "components", "schemas", "testUser", "required")
+	if fmt.Sprint(required) != "[created id name]" {
+		t.Fatalf("unexpected required: %v", required)
+	}
+}
+
+func TestJSONP(t *testing.T) {
+	env := newTestEnvironment()
+	h := newResourceHandler(env)
+	h.HandleResource(NewJSONPProvider(NewJSONProvider(), "cb"))
+	h.HandleResource(NewResource("GET", "/item", constHandler("item")))
+	h.HandleResource(NewResource("POST", "/item", constHandler("item")))
+	handler := env.Server.Router.(http.Handler)
+
+	tests := []struct {
+		method      string
+		path        string
+		contentType string
+		body        string
+	}{
+		{"GET", "/item?cb=app.show", "application/javascript; charset=utf-8", "/**/app.show(\"item\"\n);"},
+		{"GET", "/item", "application/json", "\"item\"\n"},
+		{"GET", "/item?cb=alert(1)", "application/json", "\"item\"\n"},
+		{"POST", "/item?cb=show", "application/json", "\"item\"\n"},
+	}
+	for _, test := range tests {
+		w := serveTest(handler, test.method, test.path, nil, "")
+		if w.Code != http.StatusOK || w.Header().Get("Content-Type") != test.contentType || w.Body.String() != test.body {
+			t.Fatalf("unexpected response of %+v: %v %v %q", test, w.Code, w.Header(), w.Body.String())
+		}
+	}
+}
+
+func TestFields(t *testing.T) {
+	type address struct {
+		City    string `json:"city"`
+		Country string `json:"country"`
+	}
+	type user struct {
+		ID      int64   `json:"id"`
+		Name    string  `json:"name"`
+		Address address `json:"address"`
+	}
+	users := []user{
+		{ID: 9007199254740993, Name: "a", Address: address{City: "x", Country: "y"}},
+		{ID: 2, Name: "b"},
+	}
+	env := newTestEnvironment()
+	h := newResourceHandler(env)
+	h.HandleResource(NewFieldsProvider(NewEnvelopeProvider(NewJSONProvider()), ""))
+	h.HandleResource(NewResource("GET", "/users", constHandler(users)))
+	h.HandleResource(NewResource("GET", "/error", HandlerFunc(func(*http.Request) (interface{}, error) {
+		return nil, NewBadRequest("bad")
+	})))
+	handler := env.Server.Router.(http.Handler)
+
+	tests := []struct {
+		path string
+		body string
+	}{
+		{"/users?fields=id,address.city", `{"data":[{"address":{"city":"x"},"id":9007199254740993},{"address":{"city":""},"id":2}]}`},
+		{"/users?fields=address.city,address,unknown", `{"data":[{"address":{"city":"x","country":"y"}},{"address":{"city":"","country":""}}]}`},
+		{"/users?fields=", `{"data":[{"id":9007199254740993,"name":"a","address":{"city":"x","country":"y"}},{"id":2,"name":"b","address":{"city":"","country":""}}]}`},
+		{"/error?fields=id", `{"error":{"type":"about:blank","title":"Bad Request","status":400,"detail":"bad","instance":"/error"}}`},
+	}
+	for _, test := range tests {
+		w := serveTest(handler, "GET", test.path, nil, "")
+		if strings.TrimSpace(w.Body.String()) != test.body {
+			t.Fatalf("unexpected response of %+v: %v %v %v", test, w.Code, w.Header(), w.Body.String())
+		}
+	}
+}
+
+func TestEnvelope(t *testing.T) {
+	env := newTestEnvironment()
+	h := newResourceHandler(env)
+	h.HandleResource(NewEnvelopeProvider(NewJSONProvider(), WithEnvelopePath("/api/*")))
+	h.HandleResource(NewResource("GET", "/api/items", HandlerFunc(func(r *http.Request) (interface{}, error) {
+		SetMeta(r, "total", 2)
+		return []string{"a", "b"}, nil
+	})))
+	h.HandleResource(NewResource("GET", "/api/error", HandlerFunc(func(r *http.Request) (interface{}, error) {
+		return nil, NewBadRequest("invalid")
+	})))
+	h.HandleResource(NewResource("GET", "/items", constHandler([]string{"a"})))
+	handler := env.Server.Router.(http.Handler)
+
+	tests := []struct {
+		path        string
+		status      int
+		contentType string
+		body        string
+	}{
+		{"/api/items", 200, "application/json", `{"data":["a","b"],"meta":{"total":2}}`},
+		{"/api/error", 400, "application/json",
+			`{"error":{"type":"about:blank","title":"Bad Request","status":400,"detail":"invalid","instance":"/api/error"}}`},
+		{"/items", 200, "application/json", `["a"]`},
+	}
+	for _, test := range tests {
+		w := serveTest(handler, "GET", test.path, nil, "")
+		// Headers changed after WriteHeader are not sent.
+		resp := w.Result()
+		if resp.StatusCode != test.status || resp.Header.Get("Content-Type") != test.contentType ||
+			strings.TrimSpace(w.Body.String()) != test.body {
+			t.Fatalf("unexpected response of %+v: %v %v %q", test, resp.StatusCode, resp.Header, w.Body.String())
+		}
+	}
+}
+
+func TestEvaluatePreconditions(t *testing.T) {
+	item := &testItem{Name: "melon"}
+	current, err := ETagOf(item)
+	if err != nil || !strings.HasPrefix(current, `"`) {
+		t.Fatalf("unexpected etag: %v %v", current, err)
+	}
+	handle := HandlerFunc(func(r *http.Request) (interface{}, error) {
+		if err := EvaluatePreconditions(r, current); err != nil {
+			return nil, err
+		}
+		return item, nil
+	})
+	env := newTestEnvironment()
+	newTestHandler(env,
+		NewResource("GET", "/item", handle),
+		NewResource("PUT", "/item", handle),
+	)
+	handler := env.Server.Router.(http.Handler)
+	tests := []struct {
+		method string
+		header map[string]string
+		status int
+	}{
+		{"GET", nil, 200},
+		{"GET", map[string]string{"If-None-Match": `"other", W/` + current}, 304},
+		{"GET", map[string]string{"If-None-Match": `"other"`}, 200},
+		{"PUT", map[string]string{"If-Match": current}, 200},
+		{"PUT", map[string]string{"If-Match": `"other"`}, 412},
+		{"PUT", map[string]string{"If-Match": "W/" + current}, 412},
+		{"PUT", map[string]string{"If-None-Match": "*"}, 412},
+	}
+	for _, test := range tests {
+		w := serveTest(handler, test.method, "/item", test.header, "")
+		if w.Code != test.status || w.Header().Get("ETag") != current {
+			t.Fatalf("unexpected response of %+v: %v %v %q", test, w.Code, w.Header(), w.Body.String())
+		}
+		if test.status == 304 && w.Body.Len() != 0 {
+			t.Fatalf("unexpected body: %q", w.Body.String())
+		}
+	}
+}
+
+type testRoutesResource struct{}
+
+func (testRoutesResource) List(*http.Request) (interface{}, error) {
+	return "list", nil
+}
+
+func (testRoutesResource) Get(r *http.Request) (interface{}, error) {
+	return router.PathParams(r)["id"], nil
+}
+
+func (u *testRoutesResource) Routes() []Route {
+	return []Route{
+		{Method: "GET", Path: "/users", Handler: HandlerFunc(u.List)},
+		{Method: "GET", Path: "/users/{id}", Handler: HandlerFunc(u.Get), Options: []Option{WithProduces("application/json")}},
+	}
+}
+
+type testStaticHandler struct {
+	body string
+}
+
+func (h testStaticHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(h.body))
+}
+
+type testTaggedResource struct {
+	List   HandlerFunc       `route:"GET /items"`
+	Post   HandlerFunc       `route:"POST /items"`
+	Static testStaticHandler `route:"GET /static"`
+}
+
+func TestRoutes(t *testing.T) {
+	env := newTestEnvironment()
+	newTestHandler(env,
+		&testRoutesResource{},
+		&testTaggedResource{
+			List: constHandler("items"),
+			Post:   constHandler("posted"),
+			Static: testStaticHandler{body: `"static"`},
+		},
+	)
+	handler := env.Server.Router.(http.Handler)
+	tests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{"GET", "/users", `"list"`},
+		{"GET", "/users/1", `"1"`},
+		{"GET", "/items", `"items"`},
+		{"POST", "/items", `"posted"`},
+		{"GET", "/static", `"static"`},
+	}
+	for _, test := range tests {
+		w := serveTest(handler, test.method, test.path, map[string]string{"Accept": "application/json"}, "")
+		if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != test.body {
+			t.Fatalf("unexpected response of %+v: %v %q", test, w.Code, w.Body.String())
+		}
+	}
+	endpoints := env.Server.Router.Endpoints()
+	if len(endpoints) != 5 || !strings.HasSuffix(endpoints[0], "views.testRoutesResource.List)") {
+		t.Fatalf("unexpected endpoints: %v", endpoints)
+	}
+	if _, err := routesOf(&testTaggedResource{}); err == nil || err.Error() != "views: route field List is not a http.Handler" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// csvWriter writes records as text/csv.
+type csvWriter struct{}
+
+func (csvWriter) Produces() []string {
+	return []string{"text/csv"}
+}
+
+func (csvWriter) IsWriteable(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	_, ok := v.([][]string)
+	return ok
+}
+
+func (csvWriter) WriteResponse(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	return csv.NewWriter(w).WriteAll(v.([][]string))
+}
+
+// ndjsonReader reads newline delimited JSON items.
+type ndjsonReader struct{}
+
+func (ndjsonReader) Consumes() []string {
+	return []string{"application/x-ndjson"}
+}
+
+func (ndjsonReader) IsReadable(r *http.Request, v interface{}) bool {
+	_, ok := v.(*[]testItem)
+	return ok
+}
+
+func (ndjsonReader) ReadRequest(r *http.Request, v interface{}) error {
+	items := v.(*[]testItem)
+	decoder := json.NewDecoder(r.Body)
+	for decoder.More() {
+		var item testItem
+		if err := decoder.Decode(&item); err != nil {
+			return err
+		}
+		*items = append(*items, item)
+	}
+	return nil
+}
+
+func TestMessageBodyReaderWriter(t *testing.T) {
+	env := newTestEnvironment()
+	newTestHandler(env,
+		csvWriter{},
+		ndjsonReader{},
+		NewResource("GET", "/records", constHandler([][]string{{"a", "1"}, {"b", "2"}})),
+		NewResource("POST", "/items", HandlerFunc(func(r *http.Request) (interface{}, error) {
+			var items []testItem
+			if err := Entity(r, &items); err != nil {
+				return nil, err
+			}
+			return len(items), nil
+		})),
+	)
+	handler := env.Server.Router.(http.Handler)
+	w := serveTest(handler, "GET", "/records", map[string]string{"Accept": "text/csv, application/json;q=0.5"}, "")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/csv" || w.Body.String() != "a,1\nb,2\n" {
+		t.Fatalf("unexpected response: %v %v %v", w.Code, w.Header(), w.Body.String())
+	}
+	w = serveTest(handler, "GET", "/records", map[string]string{"Accept": "application/json"}, "")
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != `[["a","1"],["b","2"]]` {
+		t.Fatalf("unexpected response: %v %v %v", w.Code, w.Header(), w.Body.String())
+	}
+	w = serveTest(handler, "POST", "/items", map[string]string{"Content-Type": "application/x-ndjson", "Accept": "application/json"},
+		"{\"name\":\"a\"}\n{\"name\":\"b\"}\n")
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != "2" {
+		t.Fatalf("unexpected response: %v %v %v", w.Code, w.Header(), w.Body.String())
+	}
+}
@@ -0,0 +1,107 @@
+package views
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+const routeTag = "route"
+
+// Route is an entry of the routes table of a resource group.
+type Route struct {
+	Method  string
+	Path    string
+	Handler http.Handler
+	Options []Option
+}
+
+// Routes is implemented by types which register multiple resources at once:
+//
+// 	func (u *UserResource) Routes() []views.Route {
+// 		return []views.Route{
+// 			{Method: "GET", Path: "/users", Handler: views.HandlerFunc(u.List)},
+// 			{Method: "GET", Path: "/users/{id}", Handler: views.HandlerFunc(u.Get)},
+// 		}
+// 	}
+//
+// Alternatively, resources can be fields of a struct with tag "route"
+// containing method and path:
+//
+// 	type UserResource struct {
+// 		List views.HandlerFunc `route:"GET /users"`
+// 		Get  views.HandlerFunc `route:"GET /users/{id}"`
+// 	}
+type Routes interface {
+	Routes() []Route
+}
+
+// routesOf returns resources of v, which implements Routes or is a pointer
+// to struct having fields with tag "route".
+func routesOf(v interface{}) ([]*Resource, error) {
+	if r, ok := v.(Routes); ok {
+		routes := r.Routes()
+		resources := make([]*Resource, len(routes))
+		for i, route := range routes {
+			resources[i] = NewResource(route.Method, route.Path, route.Handler, route.Options...)
+		}
+		return resources, nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return nil, nil
+	}
+	rv = rv.Elem()
+	rt := rv.Type()
+	var resources []*Resource
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tag := field.Tag.Get(routeTag)
+		if tag == "" {
+			continue
+		}
+		parts := strings.Fields(tag)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("views: invalid route %q of field %s", tag, field.Name)
+		}
+		if field.PkgPath != "" {
+			return nil, fmt.Errorf("views: route field %s must be exported", field.Name)
+		}
+		fv := rv.Field(i)
+		handler, ok := fv.Interface().(http.Handler)
+		if !ok || isNil(fv) {
+			return nil, fmt.Errorf("views: route field %s is not a http.Handler", field.Name)
+		}
+		resources = append(resources, NewResource(parts[0], parts[1], handler))
+	}
+	return resources, nil
+}
+
+// isNil returns true if v is nil. Unlike reflect.Value.IsNil, it does not
+// panic for kinds which can not be nil.
+func isNil(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Chan, reflect.Func, reflect.Interface, reflect.Map, reflect.Ptr, reflect.Slice:
+		return v.IsNil()
+	}
+	return false
+}
+
+// String returns name of the underlying handler for endpoint logs.
+func (h *httpHandler) String() string {
+	return handlerName(h.handler)
+}
+
+// handlerName returns function name of function handlers or type of others.
+func handlerName(handler http.Handler) string {
+	v := reflect.ValueOf(handler)
+	if v.Kind() == reflect.Func && !v.IsNil() {
+		if f := runtime.FuncForPC(v.Pointer()); f != nil {
+			// Method values have suffix "-fm".
+			return strings.TrimSuffix(f.Name(), "-fm")
+		}
+	}
+	return fmt.Sprintf("%T", handler)
+}
@@ -0,0 +1,67 @@
+package views
+
+import (
+	"context"
+	"io"
+	"net/http"
+)
+
+// StreamingOutput is a response entity which body is written incrementally,
+// e.g. large exports or long downloads. Returning it from HandlerFunc streams
+// the response instead of encoding it with a provider:
+//
+// 	func export(r *http.Request) (interface{}, error) {
+// 		return &views.StreamingOutput{
+// 			ContentType: "text/csv",
+// 			Write: func(w io.Writer) error {
+// 				return writeRecords(csv.NewWriter(w))
+// 			},
+// 		}, nil
+// 	}
+type StreamingOutput struct {
+	// ContentType is the media type of the response.
+	// The negotiated media type is used when it is empty.
+	ContentType string
+	// Write writes response body to w. Data written is flushed to the client
+	// immediately and the writer returns context error once the client has
+	// disconnected, so Write should stop on error.
+	Write func(w io.Writer) error
+}
+
+// Stream writes s to the response with status 200. Writing goes through
+// server filters so responses are still compressed and counted by the request
+// logging.
+func Stream(w http.ResponseWriter, r *http.Request, s *StreamingOutput) error {
+	if s.ContentType != "" {
+		w.Header().Set("Content-Type", s.ContentType)
+	}
+	w.WriteHeader(http.StatusOK)
+	sw := &streamWriter{
+		ctx: r.Context(),
+		w:   w,
+	}
+	sw.flusher, _ = w.(http.Flusher)
+	err := s.Write(sw)
+	if err != nil && err != sw.ctx.Err() {
+		logger().Errorf("streaming %s: %v", r.URL.Path, err)
+	}
+	return err
+}
+
+// streamWriter flushes data written and stops when the request is done.
+type streamWriter struct {
+	ctx     context.Context
+	w       io.Writer
+	flusher http.Flusher
+}
+
+func (w *streamWriter) Write(b []byte) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := w.w.Write(b)
+	if err == nil && w.flusher != nil {
+		w.flusher.Flush()
+	}
+	return n, err
+}
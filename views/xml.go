@@ -2,7 +2,9 @@ package views
 
 import (
 	"encoding/xml"
+	"io"
 	"net/http"
+	"reflect"
 )
 
 var xmlMediaTypes = []string{
@@ -39,13 +41,23 @@ func (p *xmlProvider) Produces() []string {
 	return xmlMediaTypes
 }
 
-// IsWriteable always returns true.
+// IsWriteable returns false if v is nil or a map, which can not be encoded to XML.
 func (p *xmlProvider) IsWriteable(w http.ResponseWriter, r *http.Request, v interface{}) bool {
-	return true
+	if v == nil {
+		return false
+	}
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() != reflect.Map
 }
 
-// WriteResponse encode v and writes to w.
+// WriteResponse encode v and writes to w with XML declaration.
 func (p *xmlProvider) WriteResponse(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
 	encoder := xml.NewEncoder(w)
 	return encoder.Encode(v)
 }
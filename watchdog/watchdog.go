@@ -0,0 +1,288 @@
+/*
+Package watchdog provides a background watchdog which samples number of
+goroutines and heap size to detect unbounded growth.
+
+The watchdog registers health check "watchdog" which becomes unhealthy when
+the lowest value in the latter half of the window is larger than the lowest
+value in the former half by the configured ratio. Using the lowest values
+ignores spikes between garbage collections. Samples are also available at admin
+endpoint /watchdog.
+*/
+package watchdog
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goburrow/melon/core"
+	"github.com/goburrow/melon/health"
+)
+
+const (
+	watchdogPath = "/watchdog"
+
+	defaultInterval    = 10 * time.Second
+	defaultWindow      = 10 * time.Minute
+	defaultGrowthRatio = 2.0
+	// minSamples is the minimum number of samples for detecting growth.
+	minSamples = 4
+)
+
+// Factory implements core.WatchdogFactory interface.
+type Factory struct {
+	Enabled bool
+	// Interval is the number of seconds between samples, default is 10.
+	Interval int `valid:"min=0"`
+	// Window is the number of seconds of samples to detect growth, default is 600.
+	Window int `valid:"min=0"`
+	// GrowthRatio is the ratio of growth considered unbounded, default is 2.
+	GrowthRatio float64
+	// ProfileDir is the directory to write goroutine and heap profiles into
+	// when a growth is detected. Profiles are not written if it is empty.
+	ProfileDir string `file:"true"`
+}
+
+// ConfigureWatchdog registers the watchdog to admin environment when enabled.
+func (factory *Factory) ConfigureWatchdog(env *core.Environment) error {
+	if !factory.Enabled {
+		return nil
+	}
+	w, err := factory.build()
+	if err != nil {
+		return err
+	}
+	env.Admin.HealthChecks.Register("watchdog", w)
+	env.Admin.AddHandler(w)
+	env.Lifecycle.Manage(w)
+	return nil
+}
+
+func (factory *Factory) build() (*watchdog, error) {
+	interval := defaultInterval
+	if factory.Interval > 0 {
+		interval = time.Duration(factory.Interval) * time.Second
+	}
+	window := defaultWindow
+	if factory.Window > 0 {
+		window = time.Duration(factory.Window) * time.Second
+	}
+	size := int(window / interval)
+	if size < minSamples {
+		return nil, fmt.Errorf("watchdog: window %v must be at least %d intervals of %v", window, minSamples, interval)
+	}
+	ratio := defaultGrowthRatio
+	if factory.GrowthRatio != 0 {
+		if factory.GrowthRatio <= 1 {
+			return nil, fmt.Errorf("watchdog: growth ratio must be greater than 1: %v", factory.GrowthRatio)
+		}
+		ratio = factory.GrowthRatio
+	}
+	return &watchdog{
+		interval:    interval,
+		size:        size,
+		growthRatio: ratio,
+		profileDir:  factory.ProfileDir,
+	}, nil
+}
+
+// Sample is a snapshot of runtime statistics.
+type Sample struct {
+	Time       time.Time
+	Goroutines int
+	HeapAlloc  uint64
+}
+
+// readSample is used for testing.
+var readSample = func() Sample {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return Sample{
+		Time:       time.Now(),
+		Goroutines: runtime.NumGoroutine(),
+		HeapAlloc:  m.HeapAlloc,
+	}
+}
+
+// watchdog implements core.Managed, core.AdminHandler and health.Checker.
+type watchdog struct {
+	interval    time.Duration
+	size        int
+	growthRatio float64
+	profileDir  string
+
+	mu       sync.Mutex
+	samples  []Sample
+	growing  []string
+	profiles []string
+
+	quit chan struct{}
+	done chan struct{}
+}
+
+// Start samples runtime statistics in background.
+func (w *watchdog) Start() error {
+	w.quit = make(chan struct{})
+	w.done = make(chan struct{})
+	go w.run()
+	return nil
+}
+
+// Stop stops sampling.
+func (w *watchdog) Stop() error {
+	if w.quit != nil {
+		close(w.quit)
+		<-w.done
+		w.quit = nil
+	}
+	return nil
+}
+
+func (w *watchdog) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.add(readSample())
+		case <-w.quit:
+			return
+		}
+	}
+}
+
+// add records the sample and checks for growth. Profiles are written when
+// the watchdog becomes unhealthy.
+func (w *watchdog) add(s Sample) {
+	w.mu.Lock()
+	w.samples = append(w.samples, s)
+	if len(w.samples) > w.size {
+		w.samples = w.samples[len(w.samples)-w.size:]
+	}
+	wasGrowing := len(w.growing) > 0
+	w.growing = w.detect()
+	startGrowing := !wasGrowing && len(w.growing) > 0
+	growing := w.growing
+	w.mu.Unlock()
+
+	if startGrowing {
+		logger().Warnf("unbounded growth detected: %s", strings.Join(growing, ", "))
+		if w.profileDir != "" {
+			w.writeProfiles(s.Time)
+		}
+	}
+}
+
+// detect returns names of statistics which are growing.
+func (w *watchdog) detect() []string {
+	if len(w.samples) < w.size {
+		return nil
+	}
+	half := len(w.samples) / 2
+	var goroutines [2]int
+	var heap [2]uint64
+	for i, s := range w.samples {
+		h := 0
+		if i >= half {
+			h = 1
+		}
+		if i == 0 || i == half || s.Goroutines < goroutines[h] {
+			goroutines[h] = s.Goroutines
+		}
+		if i == 0 || i == half || s.HeapAlloc < heap[h] {
+			heap[h] = s.HeapAlloc
+		}
+	}
+	var growing []string
+	if float64(goroutines[1]) >= float64(goroutines[0])*w.growthRatio {
+		growing = append(growing, fmt.Sprintf("goroutines %d -> %d", goroutines[0], goroutines[1]))
+	}
+	if float64(heap[1]) >= float64(heap[0])*w.growthRatio {
+		growing = append(growing, fmt.Sprintf("heap %d -> %d", heap[0], heap[1]))
+	}
+	return growing
+}
+
+func (w *watchdog) writeProfiles(t time.Time) {
+	if err := os.MkdirAll(w.profileDir, 0755); err != nil {
+		logger().Errorf("could not create profile directory: %v", err)
+		return
+	}
+	suffix := t.Format("20060102T150405") + ".pprof"
+	for _, name := range []string{"goroutine", "heap"} {
+		path := filepath.Join(w.profileDir, name+"-"+suffix)
+		if err := writeProfile(name, path); err != nil {
+			logger().Errorf("could not write %s profile: %v", name, err)
+			continue
+		}
+		logger().Infof("%s profile written to %s", name, path)
+		w.mu.Lock()
+		w.profiles = append(w.profiles, path)
+		w.mu.Unlock()
+	}
+}
+
+func writeProfile(name, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = pprof.Lookup(name).WriteTo(f, 0)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Check is unhealthy when a growth is detected.
+func (w *watchdog) Check() health.Result {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.growing) > 0 {
+		return health.ResultUnhealthy("unbounded growth: "+strings.Join(w.growing, ", "), nil)
+	}
+	return health.Healthy
+}
+
+func (w *watchdog) Name() string {
+	return "Watchdog"
+}
+
+func (w *watchdog) Path() string {
+	return watchdogPath
+}
+
+// ServeHTTP displays collected samples, detected growth and written profiles.
+func (w *watchdog) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	w.mu.Lock()
+	status := struct {
+		Growing  []string
+		Profiles []string
+		Samples  []Sample
+	}{
+		Growing:  w.growing,
+		Profiles: w.profiles,
+		Samples:  w.samples,
+	}
+	b, err := json.MarshalIndent(&status, "", "  ")
+	w.mu.Unlock()
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Cache-Control", "must-revalidate,no-cache,no-store")
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(b)
+}
+
+func logger() core.Logger {
+	return core.GetLogger("melon/watchdog")
+}
@@ -0,0 +1,125 @@
+package watchdog
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goburrow/melon/core"
+)
+
+var _ core.WatchdogFactory = (*Factory)(nil)
+var _ core.Managed = (*watchdog)(nil)
+var _ core.AdminHandler = (*watchdog)(nil)
+
+func TestDetect(t *testing.T) {
+	factory := &Factory{Interval: 1, Window: 6}
+	w, err := factory.build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	// Heap fluctuates but its baseline is stable.
+	for i := 0; i < 6; i++ {
+		w.add(Sample{Time: start, Goroutines: 10, HeapAlloc: uint64(100 + (i%2)*300)})
+	}
+	if !w.Check().Healthy() {
+		t.Fatalf("unexpected result: %v", w.Check().Message())
+	}
+	for i := 0; i < 3; i++ {
+		w.add(Sample{Time: start, Goroutines: 20 + i, HeapAlloc: 100})
+	}
+	result := w.Check()
+	if result.Healthy() || !strings.Contains(result.Message(), "goroutines 10 -> 20") {
+		t.Fatalf("unexpected result: %v", result.Message())
+	}
+	for i := 0; i < 6; i++ {
+		w.add(Sample{Time: start, Goroutines: 20, HeapAlloc: 100})
+	}
+	if !w.Check().Healthy() {
+		t.Fatalf("unexpected result: %v", w.Check().Message())
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watchdog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	factory := &Factory{Interval: 1, Window: 4, ProfileDir: dir}
+	w, err := factory.build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	for _, heap := range []uint64{100, 100, 300, 300} {
+		w.add(Sample{Time: start, Goroutines: 1, HeapAlloc: heap})
+	}
+	if len(w.profiles) != 2 {
+		t.Fatalf("unexpected profiles: %v", w.profiles)
+	}
+	for _, p := range w.profiles {
+		if _, err = os.Stat(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rw := httptest.NewRecorder()
+	w.ServeHTTP(rw, httptest.NewRequest("GET", "/watchdog", nil))
+	if rw.Code != 200 || !strings.Contains(rw.Body.String(), `"heap 100`) {
+		t.Fatalf("unexpected response: %d %s", rw.Code, rw.Body.String())
+	}
+}
+
+func TestFactory(t *testing.T) {
+	env := core.NewEnvironment()
+	factory := &Factory{}
+	if err := factory.ConfigureWatchdog(env); err != nil {
+		t.Fatal(err)
+	}
+	if len(env.Admin.HealthChecks.Names()) != 0 {
+		t.Fatalf("unexpected health checks: %v", env.Admin.HealthChecks.Names())
+	}
+	factory.Enabled = true
+	factory.Window = 10
+	if err := factory.ConfigureWatchdog(env); err == nil {
+		t.Fatal("error expected")
+	}
+	factory.Window = 0
+	factory.GrowthRatio = 0.5
+	if err := factory.ConfigureWatchdog(env); err == nil {
+		t.Fatal("error expected")
+	}
+	factory.GrowthRatio = 0
+	if err := factory.ConfigureWatchdog(env); err != nil {
+		t.Fatal(err)
+	}
+	result := env.Admin.HealthChecks.RunChecker("watchdog")
+	if !result.Healthy() {
+		t.Fatalf("unexpected result: %v", result.Message())
+	}
+}
+
+func TestStopWithoutStart(t *testing.T) {
+	w, err := (&Factory{Interval: 1, Window: 4}).build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		w.Stop()
+		w.Start()
+		w.Stop()
+		w.Stop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("watchdog is not stopped")
+	}
+}